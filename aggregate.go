@@ -0,0 +1,56 @@
+package orderbook
+
+import (
+	"github.com/shopspring/decimal"
+)
+
+// AggregatedDepth returns price levels grouped into buckets of given price step.
+// Bid prices are rounded down and ask prices are rounded up to the step, so
+// every bucket shows the worst price of its orders
+// Arguments:
+//
+//	step  - bucket size, e.g. 0.01, 0.1, 1, 10
+//	limit - maximal number of buckets of each side, all buckets if limit <= 0
+//
+// Return:
+//
+//	error - not nil if step is less or equal 0
+//	asks  - buckets from the lowest price to the highest one
+//	bids  - buckets from the highest price to the lowest one
+func (ob *OrderBook) AggregatedDepth(step decimal.Decimal, limit int) (asks, bids []*PriceLevel, err error) {
+	if step.Sign() <= 0 {
+		return nil, nil, ErrInvalidStep
+	}
+
+	asks = aggregate(ob.asks, Sell, step, limit)
+	bids = aggregate(ob.bids, Buy, step, limit)
+	return
+}
+
+// aggregate groups levels of the side into buckets from the best price
+func aggregate(os *OrderSide, side Side, step decimal.Decimal, limit int) (buckets []*PriceLevel) {
+	os.walk(side, func(level *OrderQueue) bool {
+		price := level.Price().Div(step)
+		if side == Buy {
+			price = price.Floor().Mul(step)
+		} else {
+			price = price.Ceil().Mul(step)
+		}
+
+		if n := len(buckets); n > 0 && buckets[n-1].Price.Equal(price) {
+			buckets[n-1].Quantity = buckets[n-1].Quantity.Add(level.Volume())
+			return true
+		}
+
+		if limit > 0 && len(buckets) == limit {
+			return false
+		}
+
+		buckets = append(buckets, &PriceLevel{
+			Price:    price,
+			Quantity: level.Volume(),
+		})
+		return true
+	})
+	return
+}
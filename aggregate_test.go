@@ -0,0 +1,56 @@
+package orderbook
+
+import (
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestAggregatedDepth(t *testing.T) {
+	ob := NewOrderBook()
+	for i, price := range []string{"100.01", "100.5", "101", "101.2", "103.7"} {
+		ob.ProcessLimitOrder(Sell, "sell-"+price, decimal.New(int64(i+1), 0), decimal.RequireFromString(price))
+	}
+	for i, price := range []string{"99.99", "99.5", "99", "98.2", "96.3"} {
+		ob.ProcessLimitOrder(Buy, "buy-"+price, decimal.New(int64(i+1), 0), decimal.RequireFromString(price))
+	}
+
+	asks, bids, err := ob.AggregatedDepth(decimal.New(1, 0), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// asks round up: 101 <- 100.01, 100.5, 101; 102 <- 101.2; 104 <- 103.7
+	if len(asks) != 3 || !asks[0].Price.Equal(decimal.New(101, 0)) || !asks[0].Quantity.Equal(decimal.New(6, 0)) ||
+		!asks[1].Price.Equal(decimal.New(102, 0)) || !asks[2].Price.Equal(decimal.New(104, 0)) {
+		t.Fatal("Invalid ask buckets", asks)
+	}
+
+	// bids round down: 99 <- 99.99, 99.5, 99; 98 <- 98.2; 96 <- 96.3
+	if len(bids) != 3 || !bids[0].Price.Equal(decimal.New(99, 0)) || !bids[0].Quantity.Equal(decimal.New(6, 0)) ||
+		!bids[1].Price.Equal(decimal.New(98, 0)) || !bids[2].Price.Equal(decimal.New(96, 0)) {
+		t.Fatal("Invalid bid buckets", bids)
+	}
+
+	asks, bids, _ = ob.AggregatedDepth(decimal.New(10, 0), 0)
+	if len(asks) != 1 || !asks[0].Price.Equal(decimal.New(110, 0)) || !asks[0].Quantity.Equal(decimal.New(15, 0)) {
+		t.Fatal("Invalid ask buckets", asks)
+	}
+
+	if len(bids) != 1 || !bids[0].Price.Equal(decimal.New(90, 0)) {
+		t.Fatal("Invalid bid buckets", bids)
+	}
+
+	asks, bids, _ = ob.AggregatedDepth(decimal.New(1, -1), 2)
+	if len(asks) != 2 || !asks[0].Price.Equal(decimal.RequireFromString("100.1")) || !asks[1].Price.Equal(decimal.RequireFromString("100.5")) {
+		t.Fatal("Invalid limited ask buckets", asks)
+	}
+
+	if len(bids) != 2 || !bids[0].Price.Equal(decimal.RequireFromString("99.9")) || !bids[1].Price.Equal(decimal.RequireFromString("99.5")) {
+		t.Fatal("Invalid limited bid buckets", bids)
+	}
+
+	if _, _, err := ob.AggregatedDepth(decimal.Zero, 0); err != ErrInvalidStep {
+		t.Fatal("Can aggregate with zero step")
+	}
+}
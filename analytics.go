@@ -0,0 +1,108 @@
+package orderbook
+
+import (
+	"github.com/shopspring/decimal"
+)
+
+// walk calls fn for price levels of the side from the best price: from the highest
+// price for bids (Buy) and from the lowest price for asks (Sell) until fn returns false
+func (os *OrderSide) walk(side Side, fn func(*OrderQueue) bool) {
+	if side == Buy {
+		for level := os.MaxPriceQueue(); level != nil && fn(level); level = os.LessThan(level.Price()) {
+		}
+		return
+	}
+
+	for level := os.MinPriceQueue(); level != nil && fn(level); level = os.GreaterThan(level.Price()) {
+	}
+}
+
+// topVolume returns total volume of the top levels of the side, all levels if levels <= 0
+func (os *OrderSide) topVolume(side Side, levels int) (volume decimal.Decimal) {
+	os.walk(side, func(level *OrderQueue) bool {
+		volume = volume.Add(level.Volume())
+		levels--
+		return levels != 0
+	})
+	return
+}
+
+// MidPrice returns average of the best bid and the best ask prices, zero if a side is empty
+func MidPrice(bids, asks *OrderSide) decimal.Decimal {
+	bid, ask := bids.MaxPriceQueue(), asks.MinPriceQueue()
+	if bid == nil || ask == nil {
+		return decimal.Zero
+	}
+	return bid.Price().Add(ask.Price()).Div(decimal.New(2, 0))
+}
+
+// Imbalance returns (bid volume - ask volume) / (bid volume + ask volume) of the top levels
+// of both sides (all levels if levels <= 0). The result is between -1 and 1, zero for empty book
+func Imbalance(bids, asks *OrderSide, levels int) decimal.Decimal {
+	bidVolume := bids.topVolume(Buy, levels)
+	askVolume := asks.topVolume(Sell, levels)
+
+	total := bidVolume.Add(askVolume)
+	if total.Sign() == 0 {
+		return decimal.Zero
+	}
+	return bidVolume.Sub(askVolume).Div(total)
+}
+
+// Microprice returns the best prices weighted by the opposite best volumes:
+// (bid price × ask volume + ask price × bid volume) / (bid volume + ask volume), zero if a side is empty
+func Microprice(bids, asks *OrderSide) decimal.Decimal {
+	bid, ask := bids.MaxPriceQueue(), asks.MinPriceQueue()
+	if bid == nil || ask == nil {
+		return decimal.Zero
+	}
+
+	weighted := bid.Price().Mul(ask.Volume()).Add(ask.Price().Mul(bid.Volume()))
+	return weighted.Div(bid.Volume().Add(ask.Volume()))
+}
+
+// CumulativeDepth returns depth curve of the side from the best price: every level
+// contains total quantity available at its price or better. All levels if levels <= 0
+func CumulativeDepth(os *OrderSide, side Side, levels int) (curve []*PriceLevel) {
+	total := decimal.Zero
+	os.walk(side, func(level *OrderQueue) bool {
+		total = total.Add(level.Volume())
+		curve = append(curve, &PriceLevel{
+			Price:    level.Price(),
+			Quantity: total,
+		})
+		levels--
+		return levels != 0
+	})
+	return
+}
+
+// LiquidityWithin returns quantity and notional of the side with prices not
+// further than bps basis points from the reference (usually mid) price
+func LiquidityWithin(os *OrderSide, reference, bps decimal.Decimal) (quantity, notional decimal.Decimal) {
+	distance := reference.Mul(bps).Div(basisPoints)
+	high := reference.Add(distance)
+
+	for level := os.Ceiling(reference.Sub(distance)); level != nil && level.Price().LessThanOrEqual(high); level = os.GreaterThan(level.Price()) {
+		quantity = quantity.Add(level.Volume())
+		notional = notional.Add(level.Volume().Mul(level.Price()))
+	}
+	return
+}
+
+// WeightedAveragePrice returns volume weighted average price of the top levels
+// of the side (all levels if levels <= 0), zero for empty side
+func WeightedAveragePrice(os *OrderSide, side Side, levels int) decimal.Decimal {
+	quantity, notional := decimal.Zero, decimal.Zero
+	os.walk(side, func(level *OrderQueue) bool {
+		quantity = quantity.Add(level.Volume())
+		notional = notional.Add(level.Volume().Mul(level.Price()))
+		levels--
+		return levels != 0
+	})
+
+	if quantity.Sign() == 0 {
+		return decimal.Zero
+	}
+	return notional.Div(quantity)
+}
@@ -0,0 +1,63 @@
+package orderbook
+
+import (
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestAnalytics(t *testing.T) {
+	ob := NewOrderBook()
+	bids, asks := ob.GetOrderSide(Buy), ob.GetOrderSide(Sell)
+
+	if MidPrice(bids, asks).Sign() != 0 || Microprice(bids, asks).Sign() != 0 || Imbalance(bids, asks, 0).Sign() != 0 {
+		t.Fatal("Invalid analytics of empty order book")
+	}
+
+	addDepth(ob, "", decimal.New(2, 0))
+	ob.ProcessLimitOrder(Buy, "extra-90", decimal.New(4, 0), decimal.New(90, 0))
+
+	if !MidPrice(bids, asks).Equal(decimal.New(95, 0)) {
+		t.Fatal("Invalid mid price", MidPrice(bids, asks))
+	}
+
+	// (90*2 + 100*6) / 8
+	if !Microprice(bids, asks).Equal(decimal.New(975, -1)) {
+		t.Fatal("Invalid microprice", Microprice(bids, asks))
+	}
+
+	// top 2 levels: bids 6+2, asks 2+2
+	if !Imbalance(bids, asks, 2).Equal(decimal.New(4, 0).Div(decimal.New(12, 0))) {
+		t.Fatal("Invalid imbalance", Imbalance(bids, asks, 2))
+	}
+
+	curve := CumulativeDepth(asks, Sell, 3)
+	if len(curve) != 3 || !curve[0].Price.Equal(decimal.New(100, 0)) || !curve[2].Quantity.Equal(decimal.New(6, 0)) {
+		t.Fatal("Invalid ask depth curve", curve)
+	}
+
+	curve = CumulativeDepth(bids, Buy, 0)
+	if len(curve) != 5 || !curve[0].Price.Equal(decimal.New(90, 0)) || !curve[4].Quantity.Equal(decimal.New(14, 0)) {
+		t.Fatal("Invalid bid depth curve", curve)
+	}
+
+	// 1000 bps of 100 is 90..110
+	quantity, notional := LiquidityWithin(asks, decimal.New(100, 0), decimal.New(1000, 0))
+	if !quantity.Equal(decimal.New(4, 0)) || !notional.Equal(decimal.New(420, 0)) {
+		t.Fatal("Invalid ask liquidity", quantity, notional)
+	}
+
+	quantity, _ = LiquidityWithin(bids, decimal.New(100, 0), decimal.New(1000, 0))
+	if !quantity.Equal(decimal.New(6, 0)) {
+		t.Fatal("Invalid bid liquidity", quantity)
+	}
+
+	// (90*6 + 80*2) / 8
+	if !WeightedAveragePrice(bids, Buy, 2).Equal(decimal.New(875, -1)) {
+		t.Fatal("Invalid weighted average price", WeightedAveragePrice(bids, Buy, 2))
+	}
+
+	if WeightedAveragePrice(NewOrderSide(), Sell, 0).Sign() != 0 {
+		t.Fatal("Invalid weighted average price of empty side")
+	}
+}
@@ -0,0 +1,136 @@
+package orderbook
+
+import (
+	"container/list"
+	"sort"
+
+	"github.com/shopspring/decimal"
+)
+
+// Uncross ends call auction (or pre-open): all crossing orders are executed at single price
+// which maximizes executed quantity, then the order book switches to continuous trading
+// Return:
+//
+//	error  - not nil if the order book is not in auction or pre-open
+//	trades - executions of the uncross, all at the same price
+func (ob *OrderBook) Uncross() (trades []*Trade, err error) {
+	end, err := ob.journaled(JournalEntry{Method: "Uncross"})
+	if err != nil {
+		return nil, err
+	}
+	defer end()
+
+	if ob.phase != Auction && ob.phase != PreOpen {
+		return nil, ErrInvalidPhase
+	}
+
+	return ob.transition(Continuous, "uncross")
+}
+
+// uncross executes all crossing orders at the clearing price
+func (ob *OrderBook) uncross() (trades []*Trade) {
+	price, ok := ob.clearingPrice()
+	if !ok {
+		return nil
+	}
+
+	for {
+		bid := ob.bids.MaxPriceQueue()
+		ask := ob.asks.MinPriceQueue()
+		if bid == nil || ask == nil || bid.Price().LessThan(price) || ask.Price().GreaterThan(price) {
+			break
+		}
+
+		bidEl, askEl := bid.Head(), ask.Head()
+		bidOrder, askOrder := bidEl.Value.(*Order), askEl.Value.(*Order)
+		quantity := decimal.Min(bidOrder.Quantity(), askOrder.Quantity())
+
+		taker, maker := bidOrder, askOrder
+		if taker.Time().Before(maker.Time()) {
+			taker, maker = maker, taker
+		}
+		trades = append(trades, ob.trade(taker, maker, price, quantity, true))
+
+		ob.fill(bidEl, quantity)
+		ob.fill(askEl, quantity)
+	}
+
+	return
+}
+
+// clearingPrice finds auction price with maximal executable quantity, minimal
+// imbalance and nearest to the last trade (or band reference) price
+func (ob *OrderBook) clearingPrice() (price decimal.Decimal, ok bool) {
+	bid, ask := ob.bids.MaxPriceQueue(), ob.asks.MinPriceQueue()
+	if bid == nil || ask == nil || bid.Price().LessThan(ask.Price()) {
+		return decimal.Zero, false
+	}
+
+	// only prices between the lowest ask and the highest bid may clear
+	var candidates []decimal.Decimal
+	for level := ob.asks.Ceiling(ask.Price()); level != nil && level.Price().LessThanOrEqual(bid.Price()); level = ob.asks.GreaterThan(level.Price()) {
+		candidates = append(candidates, level.Price())
+	}
+	for level := ob.bids.Ceiling(ask.Price()); level != nil && level.Price().LessThanOrEqual(bid.Price()); level = ob.bids.GreaterThan(level.Price()) {
+		candidates = append(candidates, level.Price())
+	}
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].LessThan(candidates[j]) })
+
+	reference := decimal.Zero
+	if ob.lastTrade != nil {
+		reference = ob.lastTrade.Price
+	} else if ob.band != nil {
+		reference = ob.band.Reference
+	}
+
+	var (
+		best, bestImbalance, bestDistance decimal.Decimal
+		sellVolume                        = decimal.Zero
+		buyVolume                         = ob.bids.Volume()
+		sellLevel                         = ask
+		buyLevel                          = ob.bids.MinPriceQueue()
+	)
+
+	for _, candidate := range candidates {
+		for sellLevel != nil && sellLevel.Price().LessThanOrEqual(candidate) {
+			sellVolume = sellVolume.Add(sellLevel.Volume())
+			sellLevel = ob.asks.GreaterThan(sellLevel.Price())
+		}
+		for buyLevel != nil && buyLevel.Price().LessThan(candidate) {
+			buyVolume = buyVolume.Sub(buyLevel.Volume())
+			buyLevel = ob.bids.GreaterThan(buyLevel.Price())
+		}
+
+		executable := decimal.Min(buyVolume, sellVolume)
+		imbalance := buyVolume.Sub(sellVolume).Abs()
+		distance := candidate.Sub(reference).Abs()
+
+		switch {
+		case executable.GreaterThan(best):
+		case executable.Equal(best) && best.Sign() > 0 && imbalance.LessThan(bestImbalance):
+		case executable.Equal(best) && best.Sign() > 0 && imbalance.Equal(bestImbalance) && distance.LessThan(bestDistance):
+		default:
+			continue
+		}
+
+		best, bestImbalance, bestDistance = executable, imbalance, distance
+		price, ok = candidate, true
+	}
+
+	return
+}
+
+// fill executes quantity of the resting order, the order is removed if it is done
+func (ob *OrderBook) fill(e *list.Element, quantity decimal.Decimal) {
+	o := e.Value.(*Order)
+	if quantity.LessThan(o.Quantity()) {
+		partial := NewOrder(o.ID(), o.Side(), o.Quantity().Sub(quantity), o.Price(), o.Time())
+		partial.owner = o.Owner()
+		ob.GetOrderSide(o.Side()).Update(e, partial)
+		ob.orderEvent(OrderPartiallyFilled, partial)
+		return
+	}
+
+	ob.removeOrder(o.ID())
+	ob.orderEvent(OrderFilled, o)
+}
@@ -0,0 +1,316 @@
+package orderbook
+
+import (
+	"container/list"
+	"sort"
+
+	"github.com/shopspring/decimal"
+)
+
+// CancelAll removes all orders from the order book
+// Return:
+//
+//	cancelled - removed orders, asks first, each side from the best price
+//	error     - not nil if current trading phase doesn't allow cancels
+func (ob *OrderBook) CancelAll() (cancelled []*Order, err error) {
+	end, err := ob.journaled(JournalEntry{Method: "CancelAll"})
+	if err != nil {
+		return nil, err
+	}
+	defer end()
+
+	if err := ob.checkCancel(); err != nil {
+		return nil, err
+	}
+
+	cancelled = ob.cancelSide(Sell)
+	return append(cancelled, ob.cancelSide(Buy)...), nil
+}
+
+// CancelSide removes all orders of given side from the order book
+// Return:
+//
+//	cancelled - removed orders from the best price to the worst one
+//	error     - not nil if current trading phase doesn't allow cancels
+func (ob *OrderBook) CancelSide(side Side) (cancelled []*Order, err error) {
+	end, err := ob.journaled(JournalEntry{Method: "CancelSide", Side: side})
+	if err != nil {
+		return nil, err
+	}
+	defer end()
+
+	if err := ob.checkCancel(); err != nil {
+		return nil, err
+	}
+
+	return ob.cancelSide(side), nil
+}
+
+// cancelSide removes all orders of given side from the best price
+func (ob *OrderBook) cancelSide(side Side) (cancelled []*Order) {
+	os := ob.GetOrderSide(side)
+
+	var (
+		level *OrderQueue
+		iter  func() *OrderQueue
+	)
+
+	if side == Buy {
+		iter = os.MaxPriceQueue
+	} else {
+		iter = os.MinPriceQueue
+	}
+
+	for level = iter(); level != nil; level = iter() {
+		cancelled = append(cancelled, ob.cancelPriceQueue(os, level)...)
+	}
+
+	return
+}
+
+// CancelPriceRange removes all orders of given side with price between low and high (inclusive)
+// Return:
+//
+//	cancelled - removed orders from the lowest price to the highest one
+//	error     - not nil if current trading phase doesn't allow cancels
+func (ob *OrderBook) CancelPriceRange(side Side, low, high decimal.Decimal) (cancelled []*Order, err error) {
+	end, err := ob.journaled(JournalEntry{Method: "CancelPriceRange", Side: side, Price: low, HighPrice: high})
+	if err != nil {
+		return nil, err
+	}
+	defer end()
+
+	if err := ob.checkCancel(); err != nil {
+		return nil, err
+	}
+
+	os := ob.GetOrderSide(side)
+
+	level := os.Ceiling(low)
+	for level != nil && level.Price().LessThanOrEqual(high) {
+		next := os.GreaterThan(level.Price())
+		cancelled = append(cancelled, ob.cancelPriceQueue(os, level)...)
+		level = next
+	}
+
+	return cancelled, nil
+}
+
+// CancelBeyondPrice removes all orders of given side at or beyond given price,
+// i.e. bids with price less than or equal and asks with price greater than or equal to given
+// Return:
+//
+//	cancelled - removed orders from the lowest price to the highest one
+//	error     - not nil if current trading phase doesn't allow cancels
+func (ob *OrderBook) CancelBeyondPrice(side Side, price decimal.Decimal) ([]*Order, error) {
+	end, err := ob.journaled(JournalEntry{Method: "CancelBeyondPrice", Side: side, Price: price})
+	if err != nil {
+		return nil, err
+	}
+	defer end()
+
+	if err := ob.checkCancel(); err != nil {
+		return nil, err
+	}
+
+	os := ob.GetOrderSide(side)
+
+	if side == Buy {
+		lowest := os.MinPriceQueue()
+		if lowest == nil {
+			return nil, nil
+		}
+		return ob.CancelPriceRange(side, lowest.Price(), price)
+	}
+
+	highest := os.MaxPriceQueue()
+	if highest == nil {
+		return nil, nil
+	}
+	return ob.CancelPriceRange(side, price, highest.Price())
+}
+
+// ReduceOrder decreases quantity of the resting order keeping its time priority.
+// The order is cancelled if all of its quantity is removed, funds reserved for
+// the removed quantity are released
+// Return:
+//
+//	order - the order after reduction or the cancelled order
+//	error - ErrOrderNotExists if there is no order with given ID, ErrInvalidQuantity if
+//	        quantity is not positive or exceeds quantity of the order or
+//	        not nil if current trading phase doesn't allow cancels
+func (ob *OrderBook) ReduceOrder(orderID string, quantity decimal.Decimal) (*Order, error) {
+	end, err := ob.journaled(JournalEntry{Method: "ReduceOrder", OrderID: orderID, Quantity: quantity})
+	if err != nil {
+		return nil, err
+	}
+	defer end()
+
+	if err := ob.checkCancel(); err != nil {
+		return nil, err
+	}
+
+	e, ok := ob.orders[orderID]
+	if !ok {
+		return nil, ErrOrderNotExists
+	}
+
+	o := e.Value.(*Order)
+	if quantity.Sign() <= 0 || quantity.GreaterThan(o.Quantity()) {
+		return nil, ErrInvalidQuantity
+	}
+
+	if quantity.Equal(o.Quantity()) {
+		ob.removeOrder(orderID)
+		ob.orderEvent(OrderCancelled, o)
+		return o, nil
+	}
+
+	reduced := NewOrder(o.ID(), o.Side(), o.Quantity().Sub(quantity), o.Price(), o.Time())
+	reduced.owner = o.Owner()
+	ob.GetOrderSide(o.Side()).Update(e, reduced)
+
+	// smaller reservation always fits funds released by the original one
+	ob.release(o.Owner(), orderID)
+	ob.reserveLimit(o.Side(), orderID, o.Owner(), reduced.Quantity(), o.Price())
+	ob.orderEvent(OrderReduced, reduced)
+	return reduced, nil
+}
+
+// ReplaceOrder atomically cancels the resting order and places the limit order of
+// the same side and owner with new ID, quantity and price. The new order is checked
+// as the incoming one before the original is removed, so the original stays in the
+// order book with its time priority if the replacement is rejected. The new order
+// loses time priority
+// Arguments:
+//
+//	orderID    - ID of the resting order
+//	newOrderID - ID of the new order, it can be the same as orderID
+//	quantity   - quantity of the new order
+//	price      - limit price of the new order
+//
+// Return values are the same as for ProcessLimitOrder, error is ErrOrderNotExists if
+// there is no order with given ID or the error of the rejected replacement
+func (ob *OrderBook) ReplaceOrder(orderID, newOrderID string, quantity, price decimal.Decimal) (done []*Order, partial *Order, partialQuantityProcessed decimal.Decimal, err error) {
+	end, err := ob.journaled(JournalEntry{Method: "ReplaceOrder", OrderID: orderID, NewOrderID: newOrderID, Quantity: quantity, Price: price})
+	if err != nil {
+		return nil, nil, decimal.Zero, err
+	}
+	defer end()
+
+	if err := ob.checkCancel(); err != nil {
+		return nil, nil, decimal.Zero, err
+	}
+
+	e, ok := ob.orders[orderID]
+	if !ok {
+		return nil, nil, decimal.Zero, ErrOrderNotExists
+	}
+	o := e.Value.(*Order)
+
+	if _, ok := ob.orders[newOrderID]; ok && newOrderID != orderID {
+		return nil, nil, decimal.Zero, ErrOrderExists
+	}
+
+	if quantity.Sign() <= 0 {
+		return nil, nil, decimal.Zero, ErrInvalidQuantity
+	}
+
+	if price.Sign() <= 0 {
+		return nil, nil, decimal.Zero, ErrInvalidPrice
+	}
+
+	if err := ob.checkLimitOrder(price); err != nil {
+		return nil, nil, decimal.Zero, err
+	}
+
+	// open orders of the owner include the original one
+	if err := ob.checkExposure(o.Owner(), o.Side(), quantity.Sub(o.Quantity())); err != nil {
+		return nil, nil, decimal.Zero, err
+	}
+
+	ob.release(o.Owner(), orderID)
+	if err := ob.reserveLimit(o.Side(), newOrderID, o.Owner(), quantity, price); err != nil {
+		// funds of the original order have just been released, so it is reserved again
+		ob.reserveLimit(o.Side(), orderID, o.Owner(), o.Quantity(), o.Price())
+		return nil, nil, decimal.Zero, err
+	}
+
+	ob.unindexOrder(o)
+	ob.GetOrderSide(o.Side()).Remove(e)
+	ob.orderEvent(OrderCancelled, o)
+
+	return ob.placeLimitOrder(o.Side(), newOrderID, o.Owner(), quantity, price)
+}
+
+// CancelOwnerOrders removes all orders of given owner from the order book.
+// It can be used as a kill-switch for disconnected clients
+// Return:
+//
+//	cancelled - removed orders from the oldest one, orders of the same time by ID
+//	error     - not nil if current trading phase doesn't allow cancels
+func (ob *OrderBook) CancelOwnerOrders(owner string) (cancelled []*Order, err error) {
+	end, err := ob.journaled(JournalEntry{Method: "CancelOwnerOrders", Owner: owner})
+	if err != nil {
+		return nil, err
+	}
+	defer end()
+
+	if err := ob.checkCancel(); err != nil {
+		return nil, err
+	}
+
+	owned := ob.ownerElements(owner)
+	if len(owned) == 0 {
+		return nil, nil
+	}
+
+	cancelled = make([]*Order, 0, len(owned))
+	for _, e := range owned {
+		o := e.Value.(*Order)
+		delete(ob.orders, o.ID())
+		ob.release(owner, o.ID())
+		cancelled = append(cancelled, ob.GetOrderSide(o.Side()).Remove(e))
+		ob.orderEvent(OrderCancelled, o)
+	}
+
+	delete(ob.owners, owner)
+	return cancelled, nil
+}
+
+// OwnerOrders returns all orders of given owner placed to the order book
+// from the oldest one, orders of the same time by ID
+func (ob *OrderBook) OwnerOrders(owner string) (orders []*Order) {
+	for _, e := range ob.ownerElements(owner) {
+		orders = append(orders, e.Value.(*Order))
+	}
+	return
+}
+
+// ownerElements returns orders of the owner in deterministic order, so
+// cancels and their events are the same in replay
+func (ob *OrderBook) ownerElements(owner string) []*list.Element {
+	owned := make([]*list.Element, 0, len(ob.owners[owner]))
+	for _, e := range ob.owners[owner] {
+		owned = append(owned, e)
+	}
+
+	sort.Slice(owned, func(i, j int) bool {
+		a, b := owned[i].Value.(*Order), owned[j].Value.(*Order)
+		if !a.Time().Equal(b.Time()) {
+			return a.Time().Before(b.Time())
+		}
+		return a.ID() < b.ID()
+	})
+	return owned
+}
+
+// cancelPriceQueue removes whole price level from the side and indexes
+func (ob *OrderBook) cancelPriceQueue(os *OrderSide, level *OrderQueue) []*Order {
+	orders := os.RemovePriceQueue(level)
+	for _, o := range orders {
+		ob.dropOrder(o)
+		ob.orderEvent(OrderCancelled, o)
+	}
+	return orders
+}
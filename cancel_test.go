@@ -0,0 +1,298 @@
+package orderbook
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+func addOwnedDepth(ob *OrderBook, owner string, quantity decimal.Decimal) {
+	for i := 50; i < 100; i = i + 10 {
+		ob.ProcessLimitOrderWithOwner(Buy, fmt.Sprintf("%s-buy-%d", owner, i), owner, quantity, decimal.New(int64(i), 0))
+	}
+
+	for i := 100; i < 150; i = i + 10 {
+		ob.ProcessLimitOrderWithOwner(Sell, fmt.Sprintf("%s-sell-%d", owner, i), owner, quantity, decimal.New(int64(i), 0))
+	}
+}
+
+func TestCancelAll(t *testing.T) {
+	ob := NewOrderBook()
+	addDepth(ob, "", decimal.New(2, 0))
+
+	cancelled, err := ob.CancelAll()
+	if err != nil || len(cancelled) != 10 {
+		t.Fatal("Invalid cancelled amount", len(cancelled))
+	}
+
+	if cancelled[0].ID() != "sell-100" || cancelled[9].ID() != "buy-50" {
+		t.Fatal("Invalid cancel order", cancelled)
+	}
+
+	if ob.asks.Len() != 0 || ob.bids.Len() != 0 || ob.asks.Depth() != 0 || ob.bids.Depth() != 0 {
+		t.Fatal("Order book is not empty")
+	}
+
+	if ob.Order("buy-50") != nil || len(ob.orders) != 0 {
+		t.Fatal("Order index is not empty")
+	}
+}
+
+func TestCancelSide(t *testing.T) {
+	ob := NewOrderBook()
+	addDepth(ob, "01-", decimal.New(2, 0))
+	addDepth(ob, "02-", decimal.New(2, 0))
+
+	cancelled, err := ob.CancelSide(Buy)
+	if err != nil || len(cancelled) != 10 || cancelled[0].ID() != "01-buy-90" {
+		t.Fatal("Invalid cancelled orders", cancelled)
+	}
+
+	if ob.bids.Len() != 0 || ob.bids.Volume().Sign() != 0 {
+		t.Fatal("Bids are not empty")
+	}
+
+	if ob.asks.Len() != 10 || !ob.asks.Volume().Equal(decimal.New(20, 0)) {
+		t.Fatal("Asks are changed")
+	}
+}
+
+func TestCancelPriceRange(t *testing.T) {
+	ob := NewOrderBook()
+	addDepth(ob, "", decimal.New(2, 0))
+
+	cancelled, _ := ob.CancelPriceRange(Sell, decimal.New(105, 0), decimal.New(130, 0))
+	if len(cancelled) != 3 || cancelled[0].ID() != "sell-110" || cancelled[2].ID() != "sell-130" {
+		t.Fatal("Invalid cancelled orders", cancelled)
+	}
+
+	cancelled, _ = ob.CancelBeyondPrice(Buy, decimal.New(70, 0))
+	if len(cancelled) != 3 || cancelled[0].ID() != "buy-50" || cancelled[2].ID() != "buy-70" {
+		t.Fatal("Invalid cancelled orders", cancelled)
+	}
+
+	cancelled, _ = ob.CancelBeyondPrice(Sell, decimal.New(140, 0))
+	if len(cancelled) != 1 || cancelled[0].ID() != "sell-140" {
+		t.Fatal("Invalid cancelled orders", cancelled)
+	}
+
+	if ob.asks.Depth() != 1 || ob.bids.Depth() != 2 {
+		t.Fatal("Invalid depth")
+	}
+
+	if cancelled, err := ob.CancelPriceRange(Sell, decimal.New(1, 0), decimal.New(2, 0)); cancelled != nil || err != nil {
+		t.Fatal("Can cancel empty range")
+	}
+}
+
+func TestCancelOwnerOrders(t *testing.T) {
+	ob := NewOrderBook()
+	addOwnedDepth(ob, "alice", decimal.New(2, 0))
+	addOwnedDepth(ob, "bob", decimal.New(1, 0))
+
+	// bob's order partially fills alice's one, owner must survive the update
+	ob.ProcessLimitOrderWithOwner(Buy, "bob-take", "bob", decimal.New(1, 0), decimal.New(100, 0))
+	if o := ob.Order("alice-sell-100"); o == nil || o.Owner() != "alice" {
+		t.Fatal("Owner is lost after partial fill")
+	}
+
+	if len(ob.OwnerOrders("alice")) != 10 {
+		t.Fatal("Invalid owner orders")
+	}
+
+	cancelled, err := ob.CancelOwnerOrders("alice")
+	if err != nil || len(cancelled) != 10 {
+		t.Fatal("Invalid cancelled amount", len(cancelled))
+	}
+
+	for _, o := range cancelled {
+		if o.Owner() != "alice" {
+			t.Fatal("Cancelled foreign order", o)
+		}
+	}
+
+	if ob.asks.Len() != 5 || ob.bids.Len() != 5 {
+		t.Fatal("Invalid orders count")
+	}
+
+	if !ob.asks.Volume().Equal(decimal.New(5, 0)) {
+		t.Fatal("Invalid volume", ob.asks.Volume())
+	}
+
+	if cancelled, _ := ob.CancelOwnerOrders("alice"); cancelled != nil || len(ob.OwnerOrders("alice")) != 0 {
+		t.Fatal("Can cancel orders twice")
+	}
+
+	if _, err := ob.CancelOrder("bob-buy-50"); err != nil {
+		t.Fatal("Can't cancel order")
+	}
+
+	if cancelled, _ := ob.CancelOwnerOrders("bob"); len(cancelled) != 9 {
+		t.Fatal("Invalid cancelled amount")
+	}
+}
+
+func TestReduceOrder(t *testing.T) {
+	ob := NewOrderBook()
+	ob.ProcessLimitOrderWithOwner(Sell, "first", "alice", decimal.New(3, 0), decimal.New(100, 0))
+	ob.ProcessLimitOrderWithOwner(Sell, "second", "bob", decimal.New(3, 0), decimal.New(100, 0))
+
+	o, err := ob.ReduceOrder("first", decimal.New(2, 0))
+	if err != nil || !o.Quantity().Equal(decimal.New(1, 0)) || o.Owner() != "alice" {
+		t.Fatal("Order is not reduced", o, err)
+	}
+
+	if !ob.asks.Volume().Equal(decimal.New(4, 0)) || ob.asks.Len() != 2 {
+		t.Fatal("Invalid side after reduce", ob.asks.Volume())
+	}
+
+	// reduced order keeps its priority
+	done, _, _, _, _ := ob.ProcessMarketOrder(Buy, decimal.New(1, 0))
+	if len(done) != 1 || done[0].ID() != "first" {
+		t.Fatal("Reduced order lost priority", done)
+	}
+
+	if _, err := ob.ReduceOrder("second", decimal.New(4, 0)); err != ErrInvalidQuantity {
+		t.Fatal("Order is reduced by excess quantity", err)
+	}
+
+	if _, err := ob.ReduceOrder("second", decimal.Zero); err != ErrInvalidQuantity {
+		t.Fatal("Order is reduced by zero", err)
+	}
+
+	if _, err := ob.ReduceOrder("second", decimal.New(3, 0)); err != nil || ob.Order("second") != nil || len(ob.OwnerOrders("bob")) != 0 {
+		t.Fatal("Order is not removed", err)
+	}
+
+	if _, err := ob.ReduceOrder("second", decimal.New(1, 0)); err != ErrOrderNotExists {
+		t.Fatal("Removed order is reduced", err)
+	}
+}
+
+func TestCancelOwnerOrdersOrder(t *testing.T) {
+	ob := NewOrderBook()
+	clock := newManualClock()
+	ob.SetClock(clock)
+
+	ob.ProcessLimitOrderWithOwner(Sell, "c", "alice", decimal.New(1, 0), decimal.New(110, 0))
+	clock.Add(time.Second)
+	for _, id := range []string{"e", "a", "d", "b"} {
+		ob.ProcessLimitOrderWithOwner(Buy, id, "alice", decimal.New(1, 0), decimal.New(90, 0))
+	}
+
+	var events []string
+	ob.OnOrderEvent(func(e *OrderEvent) {
+		events = append(events, e.Order.ID())
+	})
+
+	expected := []string{"c", "a", "b", "d", "e"}
+	for i, o := range ob.OwnerOrders("alice") {
+		if o.ID() != expected[i] {
+			t.Fatal("Invalid owner orders order", i, o.ID())
+		}
+	}
+
+	cancelled, _ := ob.CancelOwnerOrders("alice")
+	for i, o := range cancelled {
+		if o.ID() != expected[i] || events[i] != expected[i] {
+			t.Fatal("Invalid cancel order", i, o.ID(), events)
+		}
+	}
+}
+
+func TestCancelClosed(t *testing.T) {
+	ob := NewOrderBook()
+	addOwnedDepth(ob, "alice", decimal.New(1, 0))
+	ob.SetPhase(Closed, "")
+
+	if cancelled, err := ob.CancelAll(); err != ErrBookClosed || cancelled != nil {
+		t.Fatal("CancelAll in closed order book", err)
+	}
+	if _, err := ob.CancelSide(Buy); err != ErrBookClosed {
+		t.Fatal("CancelSide in closed order book", err)
+	}
+	if _, err := ob.CancelPriceRange(Sell, decimal.New(100, 0), decimal.New(150, 0)); err != ErrBookClosed {
+		t.Fatal("CancelPriceRange in closed order book", err)
+	}
+	if _, err := ob.CancelBeyondPrice(Buy, decimal.New(90, 0)); err != ErrBookClosed {
+		t.Fatal("CancelBeyondPrice in closed order book", err)
+	}
+	if _, err := ob.CancelOwnerOrders("alice"); err != ErrBookClosed {
+		t.Fatal("CancelOwnerOrders in closed order book", err)
+	}
+
+	if len(ob.orders) != 10 {
+		t.Fatal("Closed order book is changed", len(ob.orders))
+	}
+}
+
+func TestReplaceOrder(t *testing.T) {
+	ob := NewOrderBook()
+	addOwnedDepth(ob, "alice", decimal.New(2, 0))
+	ob.ProcessLimitOrderWithOwner(Buy, "bob-buy-90", "bob", decimal.New(1, 0), decimal.New(90, 0))
+
+	if _, _, _, err := ob.ReplaceOrder("missing", "new", decimal.New(1, 0), decimal.New(90, 0)); err != ErrOrderNotExists {
+		t.Fatal("Missing order is replaced", err)
+	}
+
+	if _, _, _, err := ob.ReplaceOrder("alice-buy-90", "bob-buy-90", decimal.New(1, 0), decimal.New(90, 0)); err != ErrOrderExists {
+		t.Fatal("Replaced with existing order ID", err)
+	}
+
+	// rejected replacement keeps the original with its time priority
+	ob.SetPriceBand(&PriceBand{Reference: decimal.New(100, 0), Percent: decimal.New(20, 0)})
+	if _, _, _, err := ob.ReplaceOrder("alice-buy-90", "alice-new", decimal.New(1, 0), decimal.New(70, 0)); err != ErrPriceOutOfBand {
+		t.Fatal("Replacement out of band is accepted", err)
+	}
+	ob.SetPriceBand(nil)
+
+	if o := ob.Order("alice-buy-90"); o == nil || ob.Order("alice-new") != nil ||
+		ob.bids.MaxPriceQueue().Head().Value.(*Order).ID() != "alice-buy-90" {
+		t.Fatal("Original order is changed by rejected replacement")
+	}
+
+	// the same ID moves the order to the end of the queue
+	if _, _, _, err := ob.ReplaceOrder("alice-buy-90", "alice-buy-90", decimal.New(3, 0), decimal.New(90, 0)); err != nil {
+		t.Fatal(err)
+	}
+	if o := ob.Order("alice-buy-90"); o == nil || !o.Quantity().Equal(decimal.New(3, 0)) ||
+		ob.bids.MaxPriceQueue().Head().Value.(*Order).ID() != "bob-buy-90" {
+		t.Fatal("Order is not replaced", o)
+	}
+
+	done, partial, _, err := ob.ReplaceOrder("alice-buy-90", "alice-buy-100", decimal.New(1, 0), decimal.New(100, 0))
+	if err != nil || len(done) != 1 || partial == nil || partial.ID() != "alice-sell-100" || ob.Order("alice-buy-90") != nil || ob.Order("alice-buy-100") != nil {
+		t.Fatal("Replacement is not matched", done, err)
+	}
+}
+
+func TestReplaceOrderLedger(t *testing.T) {
+	ob := NewOrderBook()
+	ledger := NewLedger()
+	ob.SetRiskCheck(ledger)
+	ledger.Deposit("alice", decimal.Zero, decimal.New(1000, 0))
+
+	ob.ProcessLimitOrderWithOwner(Buy, "b1", "alice", decimal.New(5, 0), decimal.New(90, 0))
+
+	if _, _, _, err := ob.ReplaceOrder("b1", "b2", decimal.New(10, 0), decimal.New(110, 0)); err != ErrInsufficientFunds {
+		t.Fatal("Replacement without funds is accepted", err)
+	}
+	if _, quote := ledger.Balances("alice"); !quote.Reserved.Equal(decimal.New(450, 0)) || ob.Order("b1") == nil {
+		t.Fatal("Original reservation is not kept", quote)
+	}
+
+	// funds of the original order are available to the replacement
+	if _, _, _, err := ob.ReplaceOrder("b1", "b2", decimal.New(10, 0), decimal.New(100, 0)); err != nil {
+		t.Fatal(err)
+	}
+	if _, quote := ledger.Balances("alice"); !quote.Reserved.Equal(decimal.New(1000, 0)) {
+		t.Fatal("Invalid replacement reservation", quote)
+	}
+
+	ob.CancelOrder("b2")
+	if _, quote := ledger.Balances("alice"); quote.Reserved.Sign() != 0 {
+		t.Fatal("Replacement reservation is not released", quote)
+	}
+}
@@ -0,0 +1,216 @@
+package orderbook
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Candle is OHLCV bar of the trades executed within the interval starting at Start
+type Candle struct {
+	Start       time.Time       `json:"start"`
+	Interval    time.Duration   `json:"interval"`
+	Open        decimal.Decimal `json:"open"`
+	High        decimal.Decimal `json:"high"`
+	Low         decimal.Decimal `json:"low"`
+	Close       decimal.Decimal `json:"close"`
+	Volume      decimal.Decimal `json:"volume"`
+	QuoteVolume decimal.Decimal `json:"quoteVolume"`
+	Trades      int             `json:"trades"`
+}
+
+// VWAP returns volume weighted average price of the candle, close price for empty candle
+func (c *Candle) VWAP() decimal.Decimal {
+	if c.Volume.Sign() == 0 {
+		return c.Close
+	}
+	return c.QuoteVolume.Div(c.Volume)
+}
+
+// MarshalJSON implements json.Marshaler interface, VWAP is added as vwap field
+func (c Candle) MarshalJSON() ([]byte, error) {
+	type candle Candle
+	return json.Marshal(struct {
+		candle
+		VWAP decimal.Decimal `json:"vwap"`
+	}{candle(c), c.VWAP()})
+}
+
+// add applies the trade to the candle
+func (c *Candle) add(t *Trade) {
+	if c.Trades == 0 {
+		c.Open, c.High, c.Low = t.Price, t.Price, t.Price
+	}
+
+	c.High = decimal.Max(c.High, t.Price)
+	c.Low = decimal.Min(c.Low, t.Price)
+	c.Close = t.Price
+	c.Volume = c.Volume.Add(t.Quantity)
+	c.QuoteVolume = c.QuoteVolume.Add(t.Notional())
+	c.Trades++
+}
+
+// candleSeries keeps completed and in-progress candles of the interval
+type candleSeries struct {
+	interval time.Duration
+	closed   []*Candle
+	current  *Candle
+}
+
+// CandleBuilder aggregates trades of the order book into candles of the configured
+// intervals using trade timestamps. Intervals without trades are filled with empty
+// candles at the previous close price, long gaps keep only the last empty candles
+// (see maxGapCandles). It is safe to read candles from another goroutine
+type CandleBuilder struct {
+	mu sync.RWMutex
+
+	series    map[time.Duration]*candleSeries
+	retention int
+	handlers  []func(*Candle)
+}
+
+// NewCandleBuilder creates candle builder attached to the order book. Only the last
+// retention completed candles of every interval are kept
+func NewCandleBuilder(ob *OrderBook, retention int, intervals ...time.Duration) *CandleBuilder {
+	cb := &CandleBuilder{
+		series:    map[time.Duration]*candleSeries{},
+		retention: retention,
+	}
+
+	for _, interval := range intervals {
+		cb.series[interval] = &candleSeries{interval: interval}
+	}
+
+	if ob != nil {
+		ob.OnTrade(cb.Add)
+	}
+
+	return cb
+}
+
+// OnCandle registers handler called when a candle is completed
+func (cb *CandleBuilder) OnCandle(handler func(*Candle)) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	cb.handlers = append(cb.handlers, handler)
+}
+
+// Add applies the trade to candles of all intervals
+func (cb *CandleBuilder) Add(t *Trade) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	for _, s := range cb.series {
+		cb.advance(s, t.Timestamp)
+		if s.current == nil {
+			s.current = &Candle{Start: t.Timestamp.Truncate(s.interval), Interval: s.interval}
+		}
+		s.current.add(t)
+	}
+}
+
+// Advance completes candles which ended before now, it should be called
+// periodically to close candles of the intervals without trades
+func (cb *CandleBuilder) Advance(now time.Time) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	for _, s := range cb.series {
+		cb.advance(s, now)
+	}
+}
+
+// Current returns copy of the in-progress candle of the interval or nil
+func (cb *CandleBuilder) Current(interval time.Duration) *Candle {
+	cb.mu.RLock()
+	defer cb.mu.RUnlock()
+
+	s, ok := cb.series[interval]
+	if !ok || s.current == nil {
+		return nil
+	}
+
+	c := *s.current
+	return &c
+}
+
+// Candles returns completed candles of the interval from the oldest to the newest
+func (cb *CandleBuilder) Candles(interval time.Duration) []*Candle {
+	cb.mu.RLock()
+	defer cb.mu.RUnlock()
+
+	s, ok := cb.series[interval]
+	if !ok {
+		return nil
+	}
+
+	candles := make([]*Candle, len(s.closed))
+	for i, c := range s.closed {
+		copied := *c
+		candles[i] = &copied
+	}
+	return candles
+}
+
+// maxGapCandles limits number of empty candles created for the gap without trades
+const maxGapCandles = 1000
+
+// advance closes the current candle if now is after its end and fills the gap with
+// empty candles. Only the last retention (at most maxGapCandles) empty candles of
+// the gap are created, older ones would be dropped by retention anyway
+func (cb *CandleBuilder) advance(s *candleSeries, now time.Time) {
+	if s.current == nil {
+		return
+	}
+
+	start := now.Truncate(s.interval)
+	if !s.current.Start.Before(start) {
+		return
+	}
+
+	prev := s.current
+	cb.close(s, prev)
+
+	limit := maxGapCandles
+	if cb.retention > 0 && cb.retention < limit {
+		limit = cb.retention
+	}
+
+	from := prev.Start.Add(s.interval)
+	if gap := start.Sub(from) / s.interval; gap > time.Duration(limit) {
+		from = start.Add(-time.Duration(limit) * s.interval)
+	}
+
+	for at := from; at.Before(start); at = at.Add(s.interval) {
+		cb.close(s, emptyCandle(at, s.interval, prev.Close))
+	}
+	s.current = emptyCandle(start, s.interval, prev.Close)
+}
+
+// emptyCandle creates candle without trades at the close price of the previous one
+func emptyCandle(start time.Time, interval time.Duration, price decimal.Decimal) *Candle {
+	return &Candle{
+		Start:    start,
+		Interval: interval,
+		Open:     price,
+		High:     price,
+		Low:      price,
+		Close:    price,
+	}
+}
+
+// close stores completed candle and notifies handlers
+func (cb *CandleBuilder) close(s *candleSeries, c *Candle) {
+	s.closed = append(s.closed, c)
+	if cb.retention > 0 && len(s.closed) > cb.retention {
+		s.closed = s.closed[len(s.closed)-cb.retention:]
+	}
+
+	for _, handler := range cb.handlers {
+		copied := *c
+		handler(&copied)
+	}
+}
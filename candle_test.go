@@ -0,0 +1,115 @@
+package orderbook
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestCandleBuilder(t *testing.T) {
+	ob := NewOrderBook()
+	clock := newManualClock() // 10:00:00
+	ob.SetClock(clock)
+
+	cb := NewCandleBuilder(ob, 2, time.Minute, time.Hour)
+
+	var completed []*Candle
+	cb.OnCandle(func(c *Candle) {
+		completed = append(completed, c)
+	})
+
+	addDepth(ob, "", decimal.New(2, 0))
+
+	clock.Add(10 * time.Second)
+	ob.ProcessMarketOrder(Buy, decimal.New(3, 0)) // 2@100, 1@110
+
+	clock.Add(20 * time.Second)
+	ob.ProcessMarketOrder(Sell, decimal.New(1, 0)) // 1@90
+
+	current := cb.Current(time.Minute)
+	if current == nil || current.Trades != 3 || !current.Open.Equal(decimal.New(100, 0)) ||
+		!current.High.Equal(decimal.New(110, 0)) || !current.Low.Equal(decimal.New(90, 0)) ||
+		!current.Close.Equal(decimal.New(90, 0)) || !current.Volume.Equal(decimal.New(4, 0)) {
+		t.Fatal("Invalid current candle", current)
+	}
+
+	if !current.VWAP().Equal(decimal.New(100, 0)) {
+		t.Fatal("Invalid VWAP", current.VWAP())
+	}
+
+	data, err := json.Marshal(current)
+	if err != nil || !strings.Contains(string(data), `"vwap":"100"`) || !strings.Contains(string(data), `"trades":3`) {
+		t.Fatal("Invalid candle JSON", string(data), err)
+	}
+
+	if !current.Start.Equal(time.Date(2019, 3, 1, 10, 0, 0, 0, time.UTC)) {
+		t.Fatal("Invalid candle start", current.Start)
+	}
+
+	// the next trade is two minutes later, one empty candle fills the gap
+	clock.Add(2 * time.Minute)
+	ob.ProcessMarketOrder(Sell, decimal.New(1, 0)) // 1@90
+
+	candles := cb.Candles(time.Minute)
+	if len(candles) != 2 || len(completed) != 2 {
+		t.Fatal("Invalid completed candles", candles)
+	}
+
+	gap := candles[1]
+	if gap.Trades != 0 || gap.Volume.Sign() != 0 || !gap.Open.Equal(decimal.New(90, 0)) || !gap.Close.Equal(decimal.New(90, 0)) {
+		t.Fatal("Invalid gap candle", gap)
+	}
+
+	if len(cb.Candles(time.Hour)) != 0 || cb.Current(time.Hour).Trades != 4 {
+		t.Fatal("Invalid hour candle")
+	}
+
+	// retention keeps only the last two candles
+	cb.Advance(clock.Now().Add(5 * time.Minute))
+	candles = cb.Candles(time.Minute)
+	if len(candles) != 2 || !candles[1].Start.Equal(time.Date(2019, 3, 1, 10, 6, 0, 0, time.UTC)) {
+		t.Fatal("Invalid candles after advance", candles)
+	}
+
+	if cb.Current(time.Second) != nil || cb.Candles(time.Second) != nil {
+		t.Fatal("Unknown interval has candles")
+	}
+}
+
+func TestCandleBuilderLongGap(t *testing.T) {
+	ob := NewOrderBook()
+	clock := newManualClock() // 10:00:00
+	ob.SetClock(clock)
+
+	cb := NewCandleBuilder(ob, 0, time.Second)
+
+	var completed int
+	cb.OnCandle(func(c *Candle) {
+		completed++
+	})
+
+	addDepth(ob, "", decimal.New(2, 0))
+	ob.ProcessMarketOrder(Buy, decimal.New(1, 0)) // 1@100
+
+	// a quiet day creates only the last empty candles of the gap
+	now := clock.Now().Add(24*time.Hour + 500*time.Millisecond)
+	cb.Advance(now)
+
+	candles := cb.Candles(time.Second)
+	if len(candles) != maxGapCandles+1 || completed != maxGapCandles+1 || candles[0].Trades != 1 {
+		t.Fatal("Invalid candles after gap", len(candles), completed)
+	}
+
+	if !candles[1].Start.Equal(now.Truncate(time.Second).Add(-maxGapCandles * time.Second)) {
+		t.Fatal("Invalid first gap candle", candles[1])
+	}
+
+	current := cb.Current(time.Second)
+	if current == nil || !current.Start.Equal(now.Truncate(time.Second)) || current.Trades != 0 ||
+		!current.Close.Equal(decimal.New(100, 0)) {
+		t.Fatal("Invalid current candle", current)
+	}
+}
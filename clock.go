@@ -0,0 +1,17 @@
+package orderbook
+
+import "time"
+
+// Clock provides current time. It allows to replace the wall clock in tests
+// and in replays of historical data
+type Clock interface {
+	Now() time.Time
+}
+
+// SystemClock implements Clock with the wall clock in UTC
+type SystemClock struct{}
+
+// Now returns current UTC time
+func (SystemClock) Now() time.Time {
+	return time.Now().UTC()
+}
@@ -0,0 +1,96 @@
+// Command lobster replays LOBSTER message file into the order book and reports
+// messages which can't be applied or leave the book different from the orderbook file
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"orderbook"
+	"orderbook/lobster"
+)
+
+// fileDate finds the trading date in the LOBSTER file name
+var fileDate = regexp.MustCompile(`\d{4}-\d{2}-\d{2}`)
+
+func main() {
+	messagesPath := flag.String("messages", "", "LOBSTER message file")
+	snapshotsPath := flag.String("orderbook", "", "LOBSTER orderbook file of the same messages")
+	maxReports := flag.Int("max", 100, "maximum number of reported failed messages, 0 reports all")
+	dateFlag := flag.String("date", "", "trading date of the files as 2006-01-02, by default it is taken from the message file name")
+	flag.Parse()
+
+	if *messagesPath == "" || *snapshotsPath == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	// LOBSTER files are named TICKER_2006-01-02_start_end_type_levels.csv
+	if *dateFlag == "" {
+		*dateFlag = fileDate.FindString(filepath.Base(*messagesPath))
+	}
+	date, err := time.Parse(time.DateOnly, *dateFlag)
+	if err != nil {
+		log.Fatal("invalid trading date: ", err)
+	}
+
+	messages, err := os.Open(*messagesPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer messages.Close()
+
+	snapshots, err := os.Open(*snapshotsPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer snapshots.Close()
+
+	var (
+		ob     = orderbook.NewOrderBook()
+		r      = lobster.NewReplayer(ob, messages, snapshots, date)
+		total  int
+		failed int
+		trades int
+	)
+
+	ob.OnTrade(func(*orderbook.Trade) { trades++ })
+
+	for {
+		step, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		total++
+		if !step.Failed() {
+			continue
+		}
+
+		failed++
+		if *maxReports > 0 && failed > *maxReports {
+			continue
+		}
+
+		if step.Err != nil {
+			fmt.Printf("line %d: %v\n", step.Line, step.Err)
+		}
+		for _, m := range step.Mismatches {
+			fmt.Printf("line %d: %s\n", step.Line, m)
+		}
+	}
+
+	fmt.Printf("%d messages, %d failed, %d trades\n", total, failed, trades)
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,208 @@
+// Command server exposes the order book with JSON REST API, WebSocket streams
+// and optional FIX 4.4 order entry, OUCH style binary order entry, gRPC service
+// and ITCH style market data file. Commands are journaled into optional storage
+// and the order book is restored from it on start
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+
+	"orderbook"
+	"orderbook/fix"
+	"orderbook/itch"
+	"orderbook/ouch"
+	"orderbook/persist"
+	"orderbook/rpc"
+	"orderbook/stream"
+)
+
+func main() {
+	addr := flag.String("addr", ":8080", "HTTP listen address")
+	buffer := flag.Int("stream-buffer", 1024, "maximum number of stream and OUCH messages queued for the client")
+	fixAddr := flag.String("fix-addr", "", "FIX acceptor listen address, empty disables FIX")
+	fixCompID := flag.String("fix-comp-id", "EXCHANGE", "SenderCompID of the FIX acceptor")
+	fixStore := flag.String("fix-store", "fix", "directory of FIX sequence numbers and messages")
+	fixSessions := flag.String("fix-sessions", "", "comma separated SenderCompIDs of FIX counterparties allowed to log on")
+	grpcAddr := flag.String("grpc-addr", "", "gRPC listen address, empty disables gRPC")
+	itchFile := flag.String("itch-file", "", "file to write ITCH style market data to, empty disables it")
+	ouchAddr := flag.String("ouch-addr", "", "OUCH style binary order entry listen address, empty disables it")
+	ouchUsers := flag.String("ouch-users", "", "file with username:password lines of OUCH users")
+	storage := flag.String("storage", "", "storage of the command journal and snapshots: memory, file:<dir>[?archive=<dir>&sync=1] or bolt:<path>, empty disables it")
+	snapshotEvery := flag.Int("snapshot-every", 10000, "number of commands between snapshots, 0 disables it")
+	snapshotInterval := flag.Duration("snapshot-interval", time.Minute, "time between snapshots, 0 disables it")
+	flag.Parse()
+
+	ob := orderbook.NewOrderBook()
+
+	// the book is restored before the engine starts so replay is not published
+	var manager *persist.Manager
+	if *storage != "" {
+		st, err := persist.OpenStorage(*storage)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		manager, err = persist.Open(st, ob, persist.Options{SnapshotEvery: *snapshotEvery, SnapshotInterval: *snapshotInterval})
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("order book is restored from %s after command %d", *storage, manager.Seq())
+	}
+
+	engine := orderbook.NewEngine(ob)
+	defer engine.Close()
+
+	if manager != nil {
+		engine.OnCommand(func(*orderbook.OrderBook) {
+			if err := manager.Tick(); err != nil {
+				log.Printf("snapshot: %v", err)
+			}
+		})
+
+		if *snapshotInterval > 0 {
+			go func() {
+				// commands of the engine run its handlers which snapshot the book when due
+				for range time.Tick(*snapshotInterval) {
+					engine.Do(func(*orderbook.OrderBook) {})
+				}
+			}()
+		}
+	}
+
+	hub, err := stream.NewHub(engine, *buffer)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *itchFile != "" {
+		f, err := os.Create(*itchFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+
+		err = engine.Do(func(ob *orderbook.OrderBook) {
+			publisher := itch.NewPublisher(ob, f)
+			engine.OnCommand(func(*orderbook.OrderBook) {
+				if err := publisher.Flush(); err != nil {
+					log.Fatal(err)
+				}
+			})
+		})
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if *fixAddr != "" {
+		store, err := fix.NewFileStore(*fixStore)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		if *fixSessions == "" {
+			log.Fatal("fix-sessions is required to accept FIX sessions")
+		}
+
+		acceptor, err := fix.NewAcceptor(engine, *fixCompID, store, strings.Split(*fixSessions, ","))
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		l, err := net.Listen("tcp", *fixAddr)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		log.Printf("FIX acceptor is listening on %s", *fixAddr)
+		go func() {
+			log.Fatal(acceptor.Serve(l))
+		}()
+	}
+
+	if *grpcAddr != "" {
+		srv, err := rpc.NewServer(engine, *buffer)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		l, err := net.Listen("tcp", *grpcAddr)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		g := grpc.NewServer()
+		rpc.RegisterOrderBookServer(g, srv)
+
+		log.Printf("gRPC server is listening on %s", *grpcAddr)
+		go func() {
+			log.Fatal(g.Serve(l))
+		}()
+	}
+
+	if *ouchAddr != "" {
+		passwords, err := readPasswords(*ouchUsers)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		srv, err := ouch.NewServer(engine, *buffer, passwords)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		l, err := net.Listen("tcp", *ouchAddr)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		log.Printf("OUCH server is listening on %s", *ouchAddr)
+		go func() {
+			log.Fatal(srv.Serve(l))
+		}()
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/", NewServer(engine))
+	mux.Handle("GET /ws", hub)
+
+	log.Printf("order book server is listening on %s", *addr)
+	log.Fatal(http.ListenAndServe(*addr, mux))
+}
+
+// readPasswords reads username:password lines of the file, empty lines are skipped
+func readPasswords(path string) (map[string]string, error) {
+	if path == "" {
+		return nil, errors.New("ouch-users is required to accept OUCH users")
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	passwords := map[string]string{}
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		user, password, ok := strings.Cut(line, ":")
+		if !ok || user == "" {
+			return nil, fmt.Errorf("%s:%d: username:password expected", path, i+1)
+		}
+		passwords[user] = password
+	}
+	return passwords, nil
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/shopspring/decimal"
+
+	"orderbook"
+)
+
+// Server handles REST API requests by commands to the matching engine
+type Server struct {
+	engine *orderbook.Engine
+	mux    *http.ServeMux
+}
+
+// LimitOrderRequest is body of the limit order request
+type LimitOrderRequest struct {
+	Side     orderbook.Side  `json:"side"`
+	ID       string          `json:"id"`
+	Owner    string          `json:"owner,omitempty"`
+	Quantity decimal.Decimal `json:"quantity"`
+	Price    decimal.Decimal `json:"price"`
+}
+
+// MarketOrderRequest is body of the market order request
+type MarketOrderRequest struct {
+	Side     orderbook.Side  `json:"side"`
+	Owner    string          `json:"owner,omitempty"`
+	Quantity decimal.Decimal `json:"quantity"`
+}
+
+// OrderResponse is result of the order processing. Error is set with the error
+// status if the order is stopped after executions (e.g. by circuit breaker halt)
+type OrderResponse struct {
+	Done                     []*orderbook.Order `json:"done"`
+	Partial                  *orderbook.Order   `json:"partial"`
+	PartialQuantityProcessed decimal.Decimal    `json:"partialQuantityProcessed"`
+	QuantityLeft             *decimal.Decimal   `json:"quantityLeft,omitempty"`
+	Error                    string             `json:"error,omitempty"`
+}
+
+// DepthResponse is price levels of the order book, both sides are sorted by
+// price descending as returned by OrderBook.Depth
+type DepthResponse struct {
+	Asks []*orderbook.PriceLevel `json:"asks"`
+	Bids []*orderbook.PriceLevel `json:"bids"`
+}
+
+// ErrorResponse is body of the failed request
+type ErrorResponse struct {
+	Error string `json:"error"`
+}
+
+// NewServer creates REST API handler of the matching engine
+func NewServer(engine *orderbook.Engine) *Server {
+	s := &Server{
+		engine: engine,
+		mux:    http.NewServeMux(),
+	}
+
+	s.mux.HandleFunc("POST /orders/limit", s.limitOrder)
+	s.mux.HandleFunc("POST /orders/market", s.marketOrder)
+	s.mux.HandleFunc("GET /orders/{id}", s.order)
+	s.mux.HandleFunc("DELETE /orders/{id}", s.cancelOrder)
+	s.mux.HandleFunc("GET /depth", s.depth)
+	s.mux.HandleFunc("GET /market", s.market)
+	s.mux.HandleFunc("GET /snapshot", s.snapshot)
+
+	return s
+}
+
+// ServeHTTP implements http.Handler interface
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+func (s *Server) limitOrder(w http.ResponseWriter, r *http.Request) {
+	var req LimitOrderRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, &ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	var (
+		resp OrderResponse
+		err  error
+	)
+	if cerr := s.engine.Do(func(ob *orderbook.OrderBook) {
+		resp.Done, resp.Partial, resp.PartialQuantityProcessed, err =
+			ob.ProcessLimitOrderWithOwner(req.Side, req.ID, req.Owner, req.Quantity, req.Price)
+	}); cerr != nil {
+		err = cerr
+	}
+
+	writeOrder(w, &resp, err)
+}
+
+func (s *Server) marketOrder(w http.ResponseWriter, r *http.Request) {
+	var req MarketOrderRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, &ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	var (
+		resp OrderResponse
+		left decimal.Decimal
+		err  error
+	)
+	if cerr := s.engine.Do(func(ob *orderbook.OrderBook) {
+		resp.Done, resp.Partial, resp.PartialQuantityProcessed, left, err =
+			ob.ProcessMarketOrderWithOwner(req.Side, req.Owner, req.Quantity)
+	}); cerr != nil {
+		err = cerr
+	}
+
+	resp.QuantityLeft = &left
+	writeOrder(w, &resp, err)
+}
+
+func (s *Server) order(w http.ResponseWriter, r *http.Request) {
+	var o *orderbook.Order
+	if err := s.engine.Do(func(ob *orderbook.OrderBook) {
+		o = ob.Order(r.PathValue("id"))
+	}); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if o == nil {
+		writeError(w, orderbook.ErrOrderNotExists)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, o)
+}
+
+func (s *Server) cancelOrder(w http.ResponseWriter, r *http.Request) {
+	var (
+		o   *orderbook.Order
+		err error
+	)
+	if cerr := s.engine.Do(func(ob *orderbook.OrderBook) {
+		o, err = ob.CancelOrder(r.PathValue("id"))
+	}); cerr != nil {
+		err = cerr
+	}
+
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, o)
+}
+
+func (s *Server) depth(w http.ResponseWriter, r *http.Request) {
+	limit := 0
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			writeJSON(w, http.StatusBadRequest, &ErrorResponse{Error: "invalid depth limit"})
+			return
+		}
+		limit = n
+	}
+
+	var resp DepthResponse
+	if err := s.engine.Do(func(ob *orderbook.OrderBook) {
+		resp.Asks, resp.Bids = ob.Depth()
+	}); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	// levels closest to the spread are the last asks and the first bids
+	if limit > 0 && len(resp.Asks) > limit {
+		resp.Asks = resp.Asks[len(resp.Asks)-limit:]
+	}
+	if limit > 0 && len(resp.Bids) > limit {
+		resp.Bids = resp.Bids[:limit]
+	}
+
+	writeJSON(w, http.StatusOK, &resp)
+}
+
+func (s *Server) market(w http.ResponseWriter, r *http.Request) {
+	var view *orderbook.MarketView
+	if err := s.engine.Do(func(ob *orderbook.OrderBook) {
+		view = ob.MarketOverview()
+	}); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, view)
+}
+
+func (s *Server) snapshot(w http.ResponseWriter, r *http.Request) {
+	var (
+		data []byte
+		err  error
+	)
+	if cerr := s.engine.Do(func(ob *orderbook.OrderBook) {
+		data, err = ob.MarshalJSON()
+	}); cerr != nil {
+		err = cerr
+	}
+
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
+
+// statusCode maps order book errors to HTTP status codes
+func statusCode(err error) int {
+	switch {
+	case errors.Is(err, orderbook.ErrOrderNotExists):
+		return http.StatusNotFound
+	case errors.Is(err, orderbook.ErrOrderExists):
+		return http.StatusConflict
+	case errors.Is(err, orderbook.ErrInvalidQuantity),
+		errors.Is(err, orderbook.ErrInvalidPrice),
+		errors.Is(err, orderbook.ErrInvalidNotional),
+		errors.Is(err, orderbook.ErrInvalidLot),
+		errors.Is(err, orderbook.ErrInvalidProtection),
+		errors.Is(err, orderbook.ErrInvalidStep),
+		errors.Is(err, orderbook.ErrPriceOutOfBand):
+		return http.StatusBadRequest
+	case errors.Is(err, orderbook.ErrInsufficientQuantity),
+		errors.Is(err, orderbook.ErrInsufficientFunds),
+		errors.Is(err, orderbook.ErrExposureLimit):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, orderbook.ErrTradingHalted),
+		errors.Is(err, orderbook.ErrAuctionMarketOrder),
+		errors.Is(err, orderbook.ErrPreOpenMarketOrder),
+		errors.Is(err, orderbook.ErrBookClosed),
+		errors.Is(err, orderbook.ErrInvalidPhase):
+		return http.StatusConflict
+	case errors.Is(err, orderbook.ErrEngineClosed):
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// writeOrder writes result of the order, executions are kept in the error response
+func writeOrder(w http.ResponseWriter, resp *OrderResponse, err error) {
+	switch {
+	case err == nil:
+		writeJSON(w, http.StatusOK, resp)
+	case len(resp.Done) == 0 && resp.Partial == nil:
+		writeError(w, err)
+	default:
+		resp.Error = err.Error()
+		writeJSON(w, statusCode(err), resp)
+	}
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	writeJSON(w, statusCode(err), &ErrorResponse{Error: err.Error()})
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
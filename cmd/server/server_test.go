@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"orderbook"
+)
+
+func request(t *testing.T, srv *httptest.Server, method, path string, body interface{}, resp interface{}) int {
+	var data []byte
+	if body != nil {
+		var err error
+		if data, err = json.Marshal(body); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	req, err := http.NewRequest(method, srv.URL+path, bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	if resp != nil {
+		if err := json.NewDecoder(res.Body).Decode(resp); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return res.StatusCode
+}
+
+func newTestServer() (*httptest.Server, *orderbook.Engine) {
+	engine := orderbook.NewEngine(orderbook.NewOrderBook())
+	return httptest.NewServer(NewServer(engine)), engine
+}
+
+func TestServerOrders(t *testing.T) {
+	srv, engine := newTestServer()
+	defer srv.Close()
+	defer engine.Close()
+
+	for i, price := range []int64{100, 110, 120} {
+		req := &LimitOrderRequest{
+			Side:     orderbook.Sell,
+			ID:       fmt.Sprintf("sell-%d", price),
+			Owner:    "maker",
+			Quantity: decimal.New(int64(i+1), 0),
+			Price:    decimal.New(price, 0),
+		}
+		if code := request(t, srv, http.MethodPost, "/orders/limit", req, nil); code != http.StatusOK {
+			t.Fatal("Invalid limit order status", code)
+		}
+	}
+
+	var errResp ErrorResponse
+	dup := &LimitOrderRequest{Side: orderbook.Sell, ID: "sell-100", Quantity: decimal.New(1, 0), Price: decimal.New(1, 0)}
+	if code := request(t, srv, http.MethodPost, "/orders/limit", dup, &errResp); code != http.StatusConflict ||
+		errResp.Error != orderbook.ErrOrderExists.Error() {
+		t.Fatal("Invalid duplicate order response", code, errResp)
+	}
+
+	invalid := &LimitOrderRequest{Side: orderbook.Buy, ID: "buy", Quantity: decimal.Zero, Price: decimal.New(1, 0)}
+	if code := request(t, srv, http.MethodPost, "/orders/limit", invalid, nil); code != http.StatusBadRequest {
+		t.Fatal("Invalid bad quantity status", code)
+	}
+
+	var resp OrderResponse
+	market := &MarketOrderRequest{Side: orderbook.Buy, Owner: "taker", Quantity: decimal.New(2, 0)}
+	if code := request(t, srv, http.MethodPost, "/orders/market", market, &resp); code != http.StatusOK {
+		t.Fatal("Invalid market order status", code)
+	}
+	if len(resp.Done) != 1 || resp.Done[0].ID() != "sell-100" || resp.Partial.ID() != "sell-110" ||
+		!resp.Partial.Quantity().Equal(decimal.New(1, 0)) || !resp.QuantityLeft.IsZero() {
+		t.Fatal("Invalid market order response", resp)
+	}
+
+	var o orderbook.Order
+	if code := request(t, srv, http.MethodGet, "/orders/sell-110", nil, &o); code != http.StatusOK ||
+		o.Owner() != "maker" || !o.Quantity().Equal(decimal.New(1, 0)) {
+		t.Fatal("Invalid order response", code, &o)
+	}
+
+	if code := request(t, srv, http.MethodDelete, "/orders/sell-110", nil, &o); code != http.StatusOK || o.ID() != "sell-110" {
+		t.Fatal("Invalid cancel response", code, &o)
+	}
+
+	if code := request(t, srv, http.MethodDelete, "/orders/sell-110", nil, nil); code != http.StatusNotFound {
+		t.Fatal("Invalid cancel of unknown order status", code)
+	}
+
+	if code := request(t, srv, http.MethodGet, "/orders/sell-110", nil, nil); code != http.StatusNotFound {
+		t.Fatal("Invalid unknown order status", code)
+	}
+}
+
+func TestServerMarketData(t *testing.T) {
+	srv, engine := newTestServer()
+	defer srv.Close()
+	defer engine.Close()
+
+	engine.Do(func(ob *orderbook.OrderBook) {
+		for i := int64(1); i <= 3; i++ {
+			ob.ProcessLimitOrder(orderbook.Sell, fmt.Sprintf("sell-%d", i), decimal.New(i, 0), decimal.New(100+i, 0))
+			ob.ProcessLimitOrder(orderbook.Buy, fmt.Sprintf("buy-%d", i), decimal.New(i, 0), decimal.New(100-i, 0))
+		}
+	})
+
+	var depth DepthResponse
+	if code := request(t, srv, http.MethodGet, "/depth?limit=2", nil, &depth); code != http.StatusOK {
+		t.Fatal("Invalid depth status", code)
+	}
+	if len(depth.Asks) != 2 || !depth.Asks[1].Price.Equal(decimal.New(101, 0)) ||
+		len(depth.Bids) != 2 || !depth.Bids[0].Price.Equal(decimal.New(99, 0)) {
+		t.Fatal("Invalid depth", depth)
+	}
+
+	if code := request(t, srv, http.MethodGet, "/depth?limit=x", nil, nil); code != http.StatusBadRequest {
+		t.Fatal("Invalid bad limit status", code)
+	}
+
+	var view orderbook.MarketView
+	if code := request(t, srv, http.MethodGet, "/market", nil, &view); code != http.StatusOK ||
+		!view.Asks["103"].Equal(decimal.New(3, 0)) || len(view.Bids) != 3 {
+		t.Fatal("Invalid market overview", code, view)
+	}
+
+	snapshot := orderbook.NewOrderBook()
+	if code := request(t, srv, http.MethodGet, "/snapshot", nil, snapshot); code != http.StatusOK {
+		t.Fatal("Invalid snapshot status", code)
+	}
+	if o := snapshot.Order("buy-2"); o == nil || !o.Price().Equal(decimal.New(98, 0)) {
+		t.Fatal("Invalid snapshot", snapshot)
+	}
+
+	engine.Close()
+	if code := request(t, srv, http.MethodGet, "/market", nil, nil); code != http.StatusServiceUnavailable {
+		t.Fatal("Invalid status of closed engine", code)
+	}
+}
+
+func TestServerHalt(t *testing.T) {
+	srv, engine := newTestServer()
+	defer srv.Close()
+	defer engine.Close()
+
+	engine.Do(func(ob *orderbook.OrderBook) {
+		ob.SetCircuitBreaker(&orderbook.CircuitBreaker{Percent: decimal.New(15, 0), Window: time.Minute})
+		ob.ProcessLimitOrderWithOwner(orderbook.Sell, "sell-100", "maker", decimal.New(1, 0), decimal.New(100, 0))
+		ob.ProcessLimitOrderWithOwner(orderbook.Sell, "sell-120", "maker", decimal.New(1, 0), decimal.New(120, 0))
+	})
+
+	// the trade at 120 is more than 15% away from 100
+	var resp OrderResponse
+	market := &MarketOrderRequest{Side: orderbook.Buy, Owner: "taker", Quantity: decimal.New(2, 0)}
+	if code := request(t, srv, http.MethodPost, "/orders/market", market, &resp); code != http.StatusConflict ||
+		resp.Error != orderbook.ErrTradingHalted.Error() {
+		t.Fatal("Invalid halted market order status", code, resp.Error)
+	}
+	if len(resp.Done) != 1 || resp.Done[0].ID() != "sell-100" || !resp.QuantityLeft.Equal(decimal.New(1, 0)) {
+		t.Fatal("Executions before the halt are lost", resp)
+	}
+}
@@ -0,0 +1,77 @@
+package orderbook
+
+import "sync"
+
+// Engine serializes all access to the order book through the single matching goroutine.
+// Trade and phase handlers of the order book are called from the matching goroutine
+type Engine struct {
+	book     *OrderBook
+	commands chan func()
+	done     chan struct{}
+	once     sync.Once
+
+	handlers []func(*OrderBook)
+}
+
+// NewEngine starts matching goroutine of the order book
+func NewEngine(ob *OrderBook) *Engine {
+	e := &Engine{
+		book:     ob,
+		commands: make(chan func()),
+		done:     make(chan struct{}),
+	}
+
+	go e.run()
+	return e
+}
+
+// OnCommand registers handler called in the matching goroutine after every command.
+// It allows to publish changes collected by the order book handlers during the command.
+// Handlers should be registered before the first command or from the matching goroutine
+func (e *Engine) OnCommand(handler func(ob *OrderBook)) {
+	e.handlers = append(e.handlers, handler)
+}
+
+// Do runs fn in the matching goroutine and waits for it to return
+// Return:
+//
+//	error - ErrEngineClosed if the engine is closed, fn is not called then
+func (e *Engine) Do(fn func(ob *OrderBook)) error {
+	finished := make(chan struct{})
+	cmd := func() {
+		defer close(finished)
+		fn(e.book)
+
+		for _, handler := range e.handlers {
+			handler(e.book)
+		}
+	}
+
+	select {
+	case e.commands <- cmd:
+	case <-e.done:
+		return ErrEngineClosed
+	}
+
+	<-finished
+	return nil
+}
+
+// Close stops the matching goroutine, commands in progress are completed
+func (e *Engine) Close() {
+	e.once.Do(func() {
+		close(e.done)
+	})
+}
+
+// run executes commands until the engine is closed
+func (e *Engine) run() {
+	for {
+		select {
+		case cmd := <-e.commands:
+			cmd()
+		case <-e.done:
+			return
+		}
+	}
+}
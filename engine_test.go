@@ -0,0 +1,35 @@
+package orderbook
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestEngine(t *testing.T) {
+	e := NewEngine(NewOrderBook())
+
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			e.Do(func(ob *OrderBook) {
+				ob.ProcessLimitOrder(Sell, "", decimal.New(1, 0), decimal.New(100, 0))
+				ob.ProcessMarketOrder(Buy, decimal.New(1, 0))
+			})
+		}()
+	}
+	wg.Wait()
+
+	var trades uint64
+	if err := e.Do(func(ob *OrderBook) { trades = ob.tradeSeq }); err != nil || trades != 100 {
+		t.Fatal("Invalid number of trades", trades, err)
+	}
+
+	e.Close()
+	if err := e.Do(func(*OrderBook) { t.Fatal("Command after close") }); err != ErrEngineClosed {
+		t.Fatal("Can run command after close", err)
+	}
+}
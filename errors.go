@@ -9,4 +9,21 @@ var (
 	ErrOrderExists          = errors.New("orderbook: order already exists")
 	ErrOrderNotExists       = errors.New("orderbook: order does not exist")
 	ErrInsufficientQuantity = errors.New("orderbook: insufficient quantity to calculate price")
+	ErrInvalidNotional      = errors.New("orderbook: invalid order notional")
+	ErrInvalidProtection    = errors.New("orderbook: invalid market protection")
+	ErrInvalidLot           = errors.New("orderbook: invalid lot size")
+	ErrSessionExists        = errors.New("orderbook: session already exists")
+	ErrSessionNotExists     = errors.New("orderbook: session does not exist")
+	ErrPriceOutOfBand       = errors.New("orderbook: order price is out of price band")
+	ErrTradingHalted        = errors.New("orderbook: trading is halted")
+	ErrAuctionMarketOrder   = errors.New("orderbook: market orders are not allowed in auction")
+	ErrPreOpenMarketOrder   = errors.New("orderbook: market orders are not allowed in pre-open")
+	ErrBookClosed           = errors.New("orderbook: order book is closed")
+	ErrInvalidPhase         = errors.New("orderbook: invalid trading phase transition")
+	ErrInsufficientFunds    = errors.New("orderbook: insufficient funds")
+	ErrExposureLimit        = errors.New("orderbook: position exposure limit exceeded")
+	ErrInvalidStep          = errors.New("orderbook: invalid price step")
+	ErrEngineClosed         = errors.New("orderbook: engine is closed")
+	ErrInvalidSnapshot      = errors.New("orderbook: invalid snapshot")
+	ErrSnapshotVersion      = errors.New("orderbook: unsupported snapshot version")
 )
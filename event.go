@@ -0,0 +1,100 @@
+package orderbook
+
+import (
+	"encoding/json"
+	"reflect"
+	"time"
+)
+
+// OrderStatus is status of the resting order reported by order events
+type OrderStatus int
+
+// Statuses of the resting order:
+//
+//	OrderNew             - order is placed to the order book
+//	OrderPartiallyFilled - part of the order is executed, the rest stays in the order book
+//	OrderFilled          - the rest of the order is executed and it is removed from the order book
+//	OrderCancelled       - order is removed from the order book without execution
+//	OrderReduced         - part of the order is cancelled, the rest keeps its priority
+const (
+	OrderNew OrderStatus = iota
+	OrderPartiallyFilled
+	OrderFilled
+	OrderCancelled
+	OrderReduced
+)
+
+// String implements fmt.Stringer interface
+func (s OrderStatus) String() string {
+	switch s {
+	case OrderPartiallyFilled:
+		return "partiallyFilled"
+	case OrderFilled:
+		return "filled"
+	case OrderCancelled:
+		return "cancelled"
+	case OrderReduced:
+		return "reduced"
+	default:
+		return "new"
+	}
+}
+
+// MarshalJSON implements json.Marshaler interface
+func (s OrderStatus) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + s.String() + `"`), nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler interface
+func (s *OrderStatus) UnmarshalJSON(data []byte) error {
+	switch string(data) {
+	case `"new"`:
+		*s = OrderNew
+	case `"partiallyFilled"`:
+		*s = OrderPartiallyFilled
+	case `"filled"`:
+		*s = OrderFilled
+	case `"cancelled"`:
+		*s = OrderCancelled
+	case `"reduced"`:
+		*s = OrderReduced
+	default:
+		return &json.UnsupportedValueError{
+			Value: reflect.New(reflect.TypeOf(data)),
+			Str:   string(data),
+		}
+	}
+
+	return nil
+}
+
+// OrderEvent is change of the resting order. Order contains quantity left in the
+// order book, for filled and cancelled orders it is quantity before removal
+type OrderEvent struct {
+	Status OrderStatus `json:"status"`
+	Order  *Order      `json:"order"`
+	Time   time.Time   `json:"time"`
+}
+
+// OnOrderEvent registers handler called for every change of the resting orders.
+// Incoming orders which are executed immediately are reported by trades only (see OnTrade)
+func (ob *OrderBook) OnOrderEvent(handler func(*OrderEvent)) {
+	ob.orderHandlers = append(ob.orderHandlers, handler)
+}
+
+// orderEvent notifies order event handlers
+func (ob *OrderBook) orderEvent(status OrderStatus, o *Order) {
+	if len(ob.orderHandlers) == 0 {
+		return
+	}
+
+	ev := &OrderEvent{
+		Status: status,
+		Order:  o,
+		Time:   ob.now(),
+	}
+
+	for _, handler := range ob.orderHandlers {
+		handler(ev)
+	}
+}
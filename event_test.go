@@ -0,0 +1,47 @@
+package orderbook
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestOrderEvents(t *testing.T) {
+	ob := NewOrderBook()
+
+	var events []*OrderEvent
+	ob.OnOrderEvent(func(ev *OrderEvent) {
+		events = append(events, ev)
+	})
+
+	ob.ProcessLimitOrder(Sell, "sell-1", decimal.New(2, 0), decimal.New(100, 0))
+	ob.ProcessLimitOrder(Sell, "sell-2", decimal.New(2, 0), decimal.New(110, 0))
+	ob.ProcessMarketOrder(Buy, decimal.New(3, 0))
+	ob.ProcessLimitOrder(Buy, "buy-1", decimal.New(1, 0), decimal.New(90, 0))
+	ob.CancelOrder("buy-1")
+	ob.ProcessLimitOrder(Buy, "buy-2", decimal.New(3, 0), decimal.New(90, 0))
+	ob.ReduceOrder("buy-2", decimal.New(1, 0))
+	ob.CancelAll()
+
+	var log []string
+	for _, ev := range events {
+		log = append(log, ev.Status.String()+" "+ev.Order.ID()+" "+ev.Order.Quantity().String())
+	}
+
+	expected := "new sell-1 2, new sell-2 2, filled sell-1 2, partiallyFilled sell-2 1, " +
+		"new buy-1 1, cancelled buy-1 1, new buy-2 3, reduced buy-2 2, cancelled sell-2 1, cancelled buy-2 2"
+	if strings.Join(log, ", ") != expected {
+		t.Fatal("Invalid order events", log)
+	}
+
+	data, err := events[3].Status.MarshalJSON()
+	if err != nil || string(data) != `"partiallyFilled"` {
+		t.Fatal("Invalid status JSON", string(data), err)
+	}
+
+	var status OrderStatus
+	if err := status.UnmarshalJSON([]byte(`"cancelled"`)); err != nil || status != OrderCancelled {
+		t.Fatal("Invalid status from JSON", status, err)
+	}
+}
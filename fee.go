@@ -0,0 +1,193 @@
+package orderbook
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// feeVolumeDays is the period of trading volume used to find fee tier
+const feeVolumeDays = 30
+
+var basisPoints = decimal.New(10000, 0)
+
+// FeeModel calculates maker and taker fees of the trade. Negative fee is a rebate.
+// Fees has no side effects, so it can quote fees of the trade that is not executed.
+// Record is called once the trade with its fees is executed
+type FeeModel interface {
+	Fees(t *Trade) (makerFee, takerFee decimal.Decimal)
+	Record(t *Trade)
+}
+
+// SetFeeModel sets fee model applied to every trade, nil disables fees
+func (ob *OrderBook) SetFeeModel(model FeeModel) {
+	ob.feeModel = model
+}
+
+// FeeTier contains fee rates in basis points for accounts with 30-day
+// volume (in the quote currency) greater than or equal to Volume
+type FeeTier struct {
+	Volume   decimal.Decimal `json:"volume"`
+	MakerBps decimal.Decimal `json:"makerBps"`
+	TakerBps decimal.Decimal `json:"takerBps"`
+}
+
+// FeeTotals contains fees paid by the account, negative maker fees are rebates
+type FeeTotals struct {
+	Maker  decimal.Decimal `json:"maker"`
+	Taker  decimal.Decimal `json:"taker"`
+	Volume decimal.Decimal `json:"volume"`
+}
+
+// FeeSchedule implements FeeModel with tiered fee rates by 30-day trading volume
+// of the account (owner of the order) and minimal fee. Flat fee is a schedule with
+// single tier. FeeSchedule keeps volumes and fee totals of every account, it is
+// safe to reload tiers from another goroutine
+type FeeSchedule struct {
+	mu sync.RWMutex
+
+	tiers   []FeeTier
+	minFee  decimal.Decimal
+	volumes map[string]map[int64]decimal.Decimal // account -> day -> volume
+	totals  map[string]*FeeTotals
+}
+
+// NewFeeSchedule creates fee schedule with given tiers and minimal positive fee
+func NewFeeSchedule(tiers []FeeTier, minFee decimal.Decimal) *FeeSchedule {
+	fs := &FeeSchedule{
+		volumes: map[string]map[int64]decimal.Decimal{},
+		totals:  map[string]*FeeTotals{},
+	}
+	fs.SetTiers(tiers, minFee)
+	return fs
+}
+
+// SetTiers replaces fee tiers and minimal fee, accumulated volumes and totals are kept
+func (fs *FeeSchedule) SetTiers(tiers []FeeTier, minFee decimal.Decimal) {
+	sorted := append([]FeeTier(nil), tiers...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Volume.LessThan(sorted[j].Volume)
+	})
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	fs.tiers = sorted
+	fs.minFee = minFee
+}
+
+// Tier returns fee tier of the account at given time
+func (fs *FeeSchedule) Tier(account string, at time.Time) FeeTier {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+
+	return fs.tier(fs.volume(account, at))
+}
+
+// Volume returns 30-day trading volume of the account at given time
+func (fs *FeeSchedule) Volume(account string, at time.Time) decimal.Decimal {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+
+	return fs.volume(account, at)
+}
+
+// Totals returns fees paid by the account
+func (fs *FeeSchedule) Totals(account string) FeeTotals {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+
+	if totals, ok := fs.totals[account]; ok {
+		return *totals
+	}
+
+	return FeeTotals{}
+}
+
+// Fees implements FeeModel interface. Fee rates are taken by the volume before the trade
+func (fs *FeeSchedule) Fees(t *Trade) (makerFee, takerFee decimal.Decimal) {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+
+	notional := t.Notional()
+	makerFee = fs.fee(notional, fs.tier(fs.volume(t.MakerOwner, t.Timestamp)).MakerBps)
+	takerFee = fs.fee(notional, fs.tier(fs.volume(t.TakerOwner, t.Timestamp)).TakerBps)
+	return
+}
+
+// Record implements FeeModel interface. The executed trade is added to volumes
+// and totals of both accounts
+func (fs *FeeSchedule) Record(t *Trade) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	notional := t.Notional()
+	fs.record(t.MakerOwner, t.Timestamp, notional, t.MakerFee, decimal.Zero)
+	fs.record(t.TakerOwner, t.Timestamp, notional, decimal.Zero, t.TakerFee)
+}
+
+// fee calculates fee of the notional, positive fee is not less than minimal fee
+func (fs *FeeSchedule) fee(notional, bps decimal.Decimal) decimal.Decimal {
+	fee := notional.Mul(bps).Div(basisPoints)
+	if fee.Sign() > 0 && fee.LessThan(fs.minFee) {
+		return fs.minFee
+	}
+	return fee
+}
+
+// tier finds the highest tier available for the volume
+func (fs *FeeSchedule) tier(volume decimal.Decimal) (tier FeeTier) {
+	for _, t := range fs.tiers {
+		if volume.LessThan(t.Volume) {
+			break
+		}
+		tier = t
+	}
+	return
+}
+
+// volume sums daily volumes of the account within 30 days before at
+func (fs *FeeSchedule) volume(account string, at time.Time) decimal.Decimal {
+	today := feeDay(at)
+	total := decimal.Zero
+	for day, volume := range fs.volumes[account] {
+		if day > today-feeVolumeDays && day <= today {
+			total = total.Add(volume)
+		}
+	}
+	return total
+}
+
+// record adds the trade to account volume and totals
+func (fs *FeeSchedule) record(account string, at time.Time, notional, makerFee, takerFee decimal.Decimal) {
+	today := feeDay(at)
+
+	days, ok := fs.volumes[account]
+	if !ok {
+		days = map[int64]decimal.Decimal{}
+		fs.volumes[account] = days
+	}
+
+	for day := range days {
+		if day <= today-feeVolumeDays {
+			delete(days, day)
+		}
+	}
+	days[today] = days[today].Add(notional)
+
+	totals, ok := fs.totals[account]
+	if !ok {
+		totals = &FeeTotals{}
+		fs.totals[account] = totals
+	}
+	totals.Maker = totals.Maker.Add(makerFee)
+	totals.Taker = totals.Taker.Add(takerFee)
+	totals.Volume = totals.Volume.Add(notional)
+}
+
+// feeDay returns number of the day since unix epoch
+func feeDay(t time.Time) int64 {
+	return t.Unix() / int64(24*time.Hour/time.Second)
+}
@@ -0,0 +1,131 @@
+package orderbook
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestFeeSchedule(t *testing.T) {
+	ob := NewOrderBook()
+	clock := newManualClock()
+	ob.SetClock(clock)
+
+	fees := NewFeeSchedule([]FeeTier{
+		{Volume: decimal.New(1000, 0), MakerBps: decimal.New(-1, 0), TakerBps: decimal.New(5, 0)},
+		{Volume: decimal.Zero, MakerBps: decimal.New(2, 0), TakerBps: decimal.New(10, 0)},
+	}, decimal.New(1, -2))
+	ob.SetFeeModel(fees)
+
+	var trades []*Trade
+	ob.OnTrade(func(trade *Trade) {
+		trades = append(trades, trade)
+	})
+
+	ob.ProcessLimitOrderWithOwner(Sell, "s1", "maker", decimal.New(10, 0), decimal.New(100, 0))
+	ob.ProcessMarketOrderWithOwner(Buy, "taker", decimal.New(1, 0))
+
+	// notional 100: maker 2 bps = 0.02, taker 10 bps = 0.1
+	if !trades[0].MakerFee.Equal(decimal.New(2, -2)) || !trades[0].TakerFee.Equal(decimal.New(1, -1)) {
+		t.Fatal("Invalid first tier fees", trades[0].MakerFee, trades[0].TakerFee)
+	}
+
+	// quoting fees doesn't change volumes and totals
+	maker, taker := fees.Fees(trades[0])
+	if !maker.Equal(trades[0].MakerFee) || !taker.Equal(trades[0].TakerFee) ||
+		!fees.Volume("maker", clock.Now()).Equal(decimal.New(100, 0)) || !fees.Totals("taker").Taker.Equal(decimal.New(1, -1)) {
+		t.Fatal("Fees changed the schedule", maker, taker, fees.Totals("taker"))
+	}
+
+	// minimal fee is applied to small taker fee
+	ob.ProcessMarketOrderWithOwner(Buy, "taker", decimal.New(5, -2))
+	if !trades[1].TakerFee.Equal(decimal.New(1, -2)) || !trades[1].MakerFee.Equal(decimal.New(1, -2)) {
+		t.Fatal("Minimal fee is not applied", trades[1].TakerFee)
+	}
+
+	ob.ProcessMarketOrderWithOwner(Buy, "taker", decimal.New(895, -2))
+	if !fees.Volume("maker", clock.Now()).Equal(decimal.New(1000, 0)) {
+		t.Fatal("Invalid volume", fees.Volume("maker", clock.Now()))
+	}
+
+	// maker reached the second tier and gets rebate 1 bps
+	ob.ProcessLimitOrderWithOwner(Sell, "s2", "maker", decimal.New(10, 0), decimal.New(100, 0))
+	ob.ProcessMarketOrderWithOwner(Buy, "taker", decimal.New(10, 0))
+	if !trades[3].MakerFee.Equal(decimal.New(-1, -1)) || !trades[3].TakerFee.Equal(decimal.New(5, -1)) {
+		t.Fatal("Invalid second tier fees", trades[3].MakerFee, trades[3].TakerFee)
+	}
+
+	totals := fees.Totals("maker")
+	if !totals.Maker.Equal(decimal.New(109, -3)) || totals.Taker.Sign() != 0 || !totals.Volume.Equal(decimal.New(2000, 0)) {
+		t.Fatal("Invalid maker totals", totals)
+	}
+
+	totals = fees.Totals("taker")
+	if !totals.Taker.Equal(decimal.New(1505, -3)) {
+		t.Fatal("Invalid taker totals", totals)
+	}
+
+	// volume leaves the 30-day window
+	clock.Add(31 * 24 * time.Hour)
+	if fees.Volume("maker", clock.Now()).Sign() != 0 || fees.Tier("maker", clock.Now()).Volume.Sign() != 0 {
+		t.Fatal("Volume is not expired")
+	}
+
+	// tiers are reloaded at runtime
+	fees.SetTiers([]FeeTier{{MakerBps: decimal.Zero, TakerBps: decimal.New(1, 0)}}, decimal.Zero)
+	ob.ProcessLimitOrderWithOwner(Sell, "s3", "maker", decimal.New(1, 0), decimal.New(100, 0))
+	ob.ProcessMarketOrderWithOwner(Buy, "taker", decimal.New(1, 0))
+	if trades[4].MakerFee.Sign() != 0 || !trades[4].TakerFee.Equal(decimal.New(1, -2)) {
+		t.Fatal("Tiers are not reloaded", trades[4].MakerFee, trades[4].TakerFee)
+	}
+}
+
+func TestFeeScheduleSnapshot(t *testing.T) {
+	ob := NewOrderBook()
+	clock := newManualClock()
+	ob.SetClock(clock)
+
+	tiers := []FeeTier{
+		{Volume: decimal.New(1000, 0), MakerBps: decimal.New(-1, 0), TakerBps: decimal.New(5, 0)},
+		{Volume: decimal.Zero, MakerBps: decimal.New(2, 0), TakerBps: decimal.New(10, 0)},
+	}
+	fees := NewFeeSchedule(tiers, decimal.Zero)
+	ob.SetFeeModel(fees)
+
+	ob.ProcessLimitOrderWithOwner(Sell, "s1", "maker", decimal.New(20, 0), decimal.New(100, 0))
+	ob.ProcessMarketOrderWithOwner(Buy, "taker", decimal.New(12, 0))
+
+	data, err := ob.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	restored := NewOrderBook()
+	restored.SetClock(clock)
+	restoredFees := NewFeeSchedule(tiers, decimal.Zero)
+	restored.SetFeeModel(restoredFees)
+	if err := restored.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+
+	if !restoredFees.Volume("maker", clock.Now()).Equal(decimal.New(1200, 0)) ||
+		!restoredFees.Totals("taker").Taker.Equal(decimal.New(12, -1)) || !restoredFees.Totals("maker").Maker.Equal(decimal.New(24, -2)) {
+		t.Fatal("Fee schedule is not restored", restoredFees.Totals("taker"))
+	}
+
+	var trades []*Trade
+	restored.OnTrade(func(trade *Trade) {
+		trades = append(trades, trade)
+	})
+	restored.ProcessMarketOrderWithOwner(Buy, "taker", decimal.New(1, 0))
+
+	// restored volume 1200 keeps the second tier: maker -1 bps, taker 5 bps
+	if !trades[0].MakerFee.Equal(decimal.New(-1, -2)) || !trades[0].TakerFee.Equal(decimal.New(5, -2)) {
+		t.Fatal("Restored volume is not used", trades[0].MakerFee, trades[0].TakerFee)
+	}
+
+	if err := NewOrderBook().UnmarshalBinary(data); err != nil {
+		t.Fatal("Fee schedule is required", err)
+	}
+}
@@ -0,0 +1,642 @@
+package fix
+
+import (
+	"bufio"
+	"log"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"orderbook"
+)
+
+// Values of the enumerated fields
+const (
+	sideBuy  = "1"
+	sideSell = "2"
+
+	ordTypeMarket = "1"
+	ordTypeLimit  = "2"
+
+	execNew      = "0"
+	execCanceled = "4"
+	execReplaced = "5"
+	execRejected = "8"
+	execTrade    = "F"
+
+	statusNew             = "0"
+	statusPartiallyFilled = "1"
+	statusFilled          = "2"
+	statusCanceled        = "4"
+	statusRejected        = "8"
+
+	massCancelSecurity = "1"
+	massCancelAll      = "7"
+	massCancelRejected = "0"
+
+	cxlRejToCancel  = "1"
+	cxlRejToReplace = "2"
+)
+
+// Acceptor accepts FIX 4.4 sessions and maps order entry messages onto the matching engine:
+//
+//	NewOrderSingle            - ProcessLimitOrderWithOwner or ProcessMarketOrderWithOwner
+//	OrderCancelRequest        - CancelOrder
+//	OrderCancelReplaceRequest - ReplaceOrder, the order loses time priority
+//	OrderMassCancelRequest    - CancelOwnerOrders
+//
+// Only configured counterparties can log on. SenderCompID of the counterparty is
+// the owner of its orders, ClOrdID is unique within the session. Market orders
+// are immediate or cancel
+type Acceptor struct {
+	// ErrorLog receives store and connection errors which disconnect the session,
+	// nil logs them by the standard logger
+	ErrorLog *log.Logger
+
+	engine  *orderbook.Engine
+	compID  string
+	store   Store
+	allowed map[string]bool
+
+	mu       sync.Mutex
+	sessions map[string]*session
+
+	// fields below are accessed from the matching goroutine only
+	orders    map[string]*orderState
+	active    *orderState
+	cancel    *Message
+	replacing *orderState
+	pending   []pending
+	execID    uint64
+}
+
+// orderState tracks execution of the order submitted through the acceptor
+type orderState struct {
+	session     string
+	bookID      string
+	clOrdID     string
+	origClOrdID string
+	symbol      string
+	side        orderbook.Side
+	ordType     string
+	quantity    decimal.Decimal
+	price       decimal.Decimal
+	cum         decimal.Decimal
+	notional    decimal.Decimal
+}
+
+// pending is message waiting for the end of the command
+type pending struct {
+	session string
+	message *Message
+}
+
+// NewAcceptor creates acceptor of the matching engine
+// Arguments:
+//
+//	engine   - matching engine of the order book
+//	compID   - SenderCompID of the acceptor
+//	store    - storage of sequence numbers and sent messages
+//	sessions - SenderCompIDs of the counterparties allowed to log on
+//
+// Return:
+//
+//	error - ErrInvalidSession if the session name contains path separators
+func NewAcceptor(engine *orderbook.Engine, compID string, store Store, sessions []string) (*Acceptor, error) {
+	a := &Acceptor{
+		engine:   engine,
+		compID:   compID,
+		store:    store,
+		allowed:  map[string]bool{},
+		sessions: map[string]*session{},
+		orders:   map[string]*orderState{},
+	}
+
+	for _, id := range sessions {
+		if !validSession(id) {
+			return nil, ErrInvalidSession
+		}
+		a.allowed[id] = true
+	}
+
+	err := engine.Do(func(ob *orderbook.OrderBook) {
+		ob.OnTrade(a.onTrade)
+		ob.OnOrderEvent(a.onOrderEvent)
+		engine.OnCommand(a.flush)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return a, nil
+}
+
+// Serve accepts connections until the listener is closed
+func (a *Acceptor) Serve(l net.Listener) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go a.serve(conn)
+	}
+}
+
+// serve handles logon and messages of the connection
+func (a *Acceptor) serve(conn net.Conn) {
+	defer conn.Close()
+
+	reader := bufio.NewReader(conn)
+	conn.SetReadDeadline(time.Now().Add(logonTimeout))
+
+	data, err := ReadMessage(reader)
+	if err != nil {
+		return
+	}
+
+	logon, err := Parse(data)
+	if err != nil || logon.Type() != MsgLogon || logon.Get(TagTargetCompID) != a.compID ||
+		!a.allowed[logon.Get(TagSenderCompID)] || logon.Int(TagHeartBtInt) <= 0 {
+		return
+	}
+
+	s, err := a.session(logon.Get(TagSenderCompID))
+	if err != nil {
+		return
+	}
+
+	if err := s.attach(conn); err != nil {
+		return
+	}
+	defer s.detach(conn)
+
+	c := &connection{
+		session:   s,
+		conn:      conn,
+		reader:    reader,
+		heartbeat: time.Duration(logon.Int(TagHeartBtInt)) * time.Second,
+		done:      make(chan struct{}),
+	}
+	c.received.Store(time.Now().UnixNano())
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		c.writeLoop()
+	}()
+	defer func() {
+		close(c.done)
+		wg.Wait()
+	}()
+
+	reply := NewMessage(MsgLogon).
+		Set(TagEncryptMethod, "0").
+		Set(TagHeartBtInt, logon.Get(TagHeartBtInt))
+
+	if logon.Get(TagResetSeqNumFlag) == "Y" {
+		if err := s.reset(); err != nil {
+			return
+		}
+		reply.Set(TagResetSeqNumFlag, "Y")
+	}
+
+	seq := uint64(logon.Int(TagMsgSeqNum))
+	target := s.expected()
+	if seq < target {
+		s.send(NewMessage(MsgLogout).Set(TagText, "MsgSeqNum too low, expecting "+strconv.FormatUint(target, 10)))
+		return
+	}
+
+	s.send(reply)
+	if seq > target {
+		c.resending = true
+		s.send(NewMessage(MsgResendRequest).
+			Set(TagBeginSeqNo, strconv.FormatUint(target, 10)).
+			Set(TagEndSeqNo, "0"))
+	} else {
+		s.setTarget(target + 1)
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		c.heartbeatLoop()
+	}()
+
+	c.readLoop()
+}
+
+// session returns session of the counterparty loading its sequence numbers if needed
+func (a *Acceptor) session(id string) (*session, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if s, ok := a.sessions[id]; ok {
+		return s, nil
+	}
+
+	sender, target, err := a.store.SeqNums(id)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &session{acceptor: a, id: id, sender: sender, target: target}
+	a.sessions[id] = s
+	return s, nil
+}
+
+// handle processes application message of the session in the matching goroutine
+func (a *Acceptor) handle(s *session, m *Message) {
+	err := a.engine.Do(func(ob *orderbook.OrderBook) {
+		switch m.Type() {
+		case MsgNewOrderSingle:
+			a.newOrder(ob, s, m)
+		case MsgOrderCancelRequest:
+			a.cancelOrder(ob, s, m)
+		case MsgOrderCancelReplaceRequest:
+			a.replaceOrder(ob, s, m)
+		case MsgOrderMassCancelRequest:
+			a.massCancel(ob, s, m)
+		}
+	})
+
+	if err != nil {
+		s.send(NewMessage(MsgReject).
+			Set(TagRefSeqNum, m.Get(TagMsgSeqNum)).
+			Set(TagText, err.Error()))
+	}
+}
+
+// bookID returns ID of the order in the order book. Session names have no
+// slashes, so the ID of different sessions and ClOrdIDs are different
+func bookID(s *session, clOrdID string) string {
+	return s.id + "/" + clOrdID
+}
+
+func (a *Acceptor) newOrder(ob *orderbook.OrderBook, s *session, m *Message) {
+	st := &orderState{
+		session: s.id,
+		bookID:  bookID(s, m.Get(TagClOrdID)),
+		clOrdID: m.Get(TagClOrdID),
+		symbol:  m.Get(TagSymbol),
+		ordType: m.Get(TagOrdType),
+	}
+
+	if text := st.parse(m); text != "" {
+		a.reject(st, text)
+		return
+	}
+
+	if _, ok := a.orders[st.bookID]; ok || ob.Order(st.bookID) != nil {
+		a.reject(st, orderbook.ErrOrderExists.Error())
+		return
+	}
+
+	ack := a.report(st, execNew, statusNew, "")
+	mark := len(a.pending)
+
+	var (
+		left decimal.Decimal
+		err  error
+	)
+
+	a.active = st
+	if st.ordType == ordTypeLimit {
+		a.orders[st.bookID] = st
+		_, _, _, err = ob.ProcessLimitOrderWithOwner(st.side, st.bookID, s.id, st.quantity, st.price)
+	} else {
+		_, _, _, left, err = ob.ProcessMarketOrderWithOwner(st.side, s.id, st.quantity)
+	}
+	a.active = nil
+
+	if err != nil && st.cum.Sign() == 0 {
+		delete(a.orders, st.bookID)
+		a.pending = a.pending[:mark]
+		a.reject(st, err.Error())
+		return
+	}
+
+	a.insert(mark, st.session, ack)
+
+	switch {
+	case err != nil:
+		delete(a.orders, st.bookID)
+		a.queue(st.session, a.report(st, execCanceled, statusCanceled, err.Error()))
+	case st.ordType == ordTypeMarket && left.Sign() > 0:
+		a.queue(st.session, a.report(st, execCanceled, statusCanceled, "no liquidity"))
+	}
+}
+
+func (a *Acceptor) cancelOrder(ob *orderbook.OrderBook, s *session, m *Message) {
+	st, ok := a.orders[bookID(s, m.Get(TagOrigClOrdID))]
+	if !ok || st.session != s.id {
+		a.cancelReject(s, m, cxlRejToCancel, "1", orderbook.ErrOrderNotExists.Error())
+		return
+	}
+
+	a.cancel = m
+	_, err := ob.CancelOrder(st.bookID)
+	a.cancel = nil
+
+	if err != nil {
+		a.cancelReject(s, m, cxlRejToCancel, "0", err.Error())
+	}
+}
+
+func (a *Acceptor) replaceOrder(ob *orderbook.OrderBook, s *session, m *Message) {
+	st, ok := a.orders[bookID(s, m.Get(TagOrigClOrdID))]
+	if !ok || st.session != s.id {
+		a.cancelReject(s, m, cxlRejToReplace, "1", orderbook.ErrOrderNotExists.Error())
+		return
+	}
+
+	replaced := &orderState{
+		session:     s.id,
+		bookID:      bookID(s, m.Get(TagClOrdID)),
+		clOrdID:     m.Get(TagClOrdID),
+		origClOrdID: st.clOrdID,
+		symbol:      st.symbol,
+		ordType:     ordTypeLimit,
+		cum:         st.cum,
+		notional:    st.notional,
+	}
+
+	if text := replaced.parse(m); text != "" {
+		a.cancelReject(s, m, cxlRejToReplace, "99", text)
+		return
+	}
+
+	if replaced.side != st.side || replaced.ordType != ordTypeLimit {
+		a.cancelReject(s, m, cxlRejToReplace, "99", "side and order type can not be changed")
+		return
+	}
+
+	if !replaced.quantity.GreaterThan(st.cum) {
+		a.cancelReject(s, m, cxlRejToReplace, "99", "order quantity is not greater than executed one")
+		return
+	}
+
+	if _, ok := a.orders[replaced.bookID]; ok || ob.Order(replaced.bookID) != nil {
+		a.cancelReject(s, m, cxlRejToReplace, "99", orderbook.ErrOrderExists.Error())
+		return
+	}
+
+	ack := a.report(replaced, execReplaced, replaced.status(), "")
+	mark := len(a.pending)
+
+	a.active, a.replacing = replaced, st
+	a.orders[replaced.bookID] = replaced
+	_, _, _, err := ob.ReplaceOrder(st.bookID, replaced.bookID, replaced.quantity.Sub(replaced.cum), replaced.price)
+	a.active, a.replacing = nil, nil
+
+	// the original order stays in the order book if the replacement is rejected
+	if _, ok := a.orders[st.bookID]; ok {
+		delete(a.orders, replaced.bookID)
+		a.pending = a.pending[:mark]
+		a.cancelReject(s, m, cxlRejToReplace, "99", err.Error())
+		return
+	}
+
+	a.insert(mark, s.id, ack)
+	if err != nil {
+		delete(a.orders, replaced.bookID)
+		a.queue(s.id, a.report(replaced, execCanceled, statusCanceled, err.Error()))
+	}
+}
+
+func (a *Acceptor) massCancel(ob *orderbook.OrderBook, s *session, m *Message) {
+	reqType := m.Get(TagMassCancelRequestType)
+
+	report := NewMessage(MsgOrderMassCancelReport).
+		Set(TagClOrdID, m.Get(TagClOrdID)).
+		Set(TagOrderID, a.nextExecID()).
+		Set(TagMassCancelRequestType, reqType)
+
+	if reqType != massCancelSecurity && reqType != massCancelAll {
+		report.Set(TagMassCancelResponse, massCancelRejected).Set(TagText, "unsupported mass cancel request type")
+		a.queue(s.id, report)
+		return
+	}
+
+	cancelled := 0
+	for _, o := range ob.OwnerOrders(s.id) {
+		st, ok := a.orders[o.ID()]
+		if reqType == massCancelSecurity && ok && st.symbol != m.Get(TagSymbol) {
+			continue
+		}
+		if _, err := ob.CancelOrder(o.ID()); err == nil {
+			cancelled++
+		}
+	}
+
+	report.Set(TagMassCancelResponse, reqType).Set(TagTotalAffected, strconv.Itoa(cancelled))
+	a.queue(s.id, report)
+}
+
+// onTrade updates executed quantity of the orders in the matching goroutine
+func (a *Acceptor) onTrade(t *orderbook.Trade) {
+	taker := a.active
+	if taker == nil || (t.TakerOrderID != "" && t.TakerOrderID != taker.bookID) {
+		taker = a.orders[t.TakerOrderID]
+	}
+
+	for _, st := range []*orderState{taker, a.orders[t.MakerOrderID]} {
+		if st == nil {
+			continue
+		}
+
+		st.cum = st.cum.Add(t.Quantity)
+		st.notional = st.notional.Add(t.Notional())
+
+		status := st.status()
+		if status == statusFilled {
+			delete(a.orders, st.bookID)
+		}
+
+		a.queue(st.session, a.report(st, execTrade, status, "").
+			Set(TagLastQty, t.Quantity.String()).
+			Set(TagLastPx, t.Price.String()))
+	}
+}
+
+// onOrderEvent reports cancelled orders in the matching goroutine
+func (a *Acceptor) onOrderEvent(ev *orderbook.OrderEvent) {
+	if ev.Status != orderbook.OrderCancelled {
+		return
+	}
+
+	st, ok := a.orders[ev.Order.ID()]
+	if !ok {
+		return
+	}
+	delete(a.orders, st.bookID)
+
+	if st == a.replacing {
+		return
+	}
+
+	report := a.report(st, execCanceled, statusCanceled, "")
+	if a.cancel != nil {
+		report.Set(TagClOrdID, a.cancel.Get(TagClOrdID)).Set(TagOrigClOrdID, st.clOrdID)
+	}
+	a.queue(st.session, report)
+}
+
+// flush sends messages collected during the command
+func (a *Acceptor) flush(*orderbook.OrderBook) {
+	for _, p := range a.pending {
+		if s, err := a.session(p.session); err == nil {
+			s.send(p.message)
+		}
+	}
+	a.pending = nil
+}
+
+func (a *Acceptor) queue(session string, m *Message) {
+	a.pending = append(a.pending, pending{session: session, message: m})
+}
+
+// insert puts the message before the messages collected after mark
+func (a *Acceptor) insert(mark int, session string, m *Message) {
+	a.pending = append(a.pending, pending{})
+	copy(a.pending[mark+1:], a.pending[mark:])
+	a.pending[mark] = pending{session: session, message: m}
+}
+
+// logf logs the error to ErrorLog
+func (a *Acceptor) logf(format string, args ...interface{}) {
+	if a.ErrorLog != nil {
+		a.ErrorLog.Printf(format, args...)
+	} else {
+		log.Printf(format, args...)
+	}
+}
+
+func (a *Acceptor) nextExecID() string {
+	a.execID++
+	return strconv.FormatUint(a.execID, 10)
+}
+
+// report returns ExecutionReport of the order
+func (a *Acceptor) report(st *orderState, execType, status, text string) *Message {
+	leaves := st.quantity.Sub(st.cum)
+	if status == statusCanceled || status == statusRejected || leaves.Sign() < 0 {
+		leaves = decimal.Zero
+	}
+
+	avg := decimal.Zero
+	if st.cum.Sign() > 0 {
+		avg = st.notional.Div(st.cum)
+	}
+
+	orderID := st.bookID
+	if status == statusRejected {
+		orderID = "NONE"
+	}
+
+	m := NewMessage(MsgExecutionReport).
+		Set(TagOrderID, orderID).
+		Set(TagClOrdID, st.clOrdID)
+
+	if st.origClOrdID != "" {
+		m.Set(TagOrigClOrdID, st.origClOrdID)
+	}
+
+	m.Set(TagExecID, a.nextExecID()).
+		Set(TagExecType, execType).
+		Set(TagOrdStatus, status).
+		Set(TagSymbol, st.symbol).
+		Set(TagSide, formatSide(st.side)).
+		Set(TagOrdType, st.ordType).
+		Set(TagOrderQty, st.quantity.String())
+
+	if st.ordType == ordTypeLimit {
+		m.Set(TagPrice, st.price.String())
+	}
+
+	m.Set(TagLeavesQty, leaves.String()).
+		Set(TagCumQty, st.cum.String()).
+		Set(TagAvgPx, avg.String()).
+		Set(TagTransactTime, time.Now().UTC().Format(timeFormat))
+
+	if text != "" {
+		m.Set(TagText, text)
+	}
+	return m
+}
+
+func (a *Acceptor) reject(st *orderState, text string) {
+	a.queue(st.session, a.report(st, execRejected, statusRejected, text))
+}
+
+// cancelReject queues OrderCancelReject of the cancel or replace request
+func (a *Acceptor) cancelReject(s *session, m *Message, responseTo, reason, text string) {
+	a.queue(s.id, NewMessage(MsgOrderCancelReject).
+		Set(TagOrderID, "NONE").
+		Set(TagClOrdID, m.Get(TagClOrdID)).
+		Set(TagOrigClOrdID, m.Get(TagOrigClOrdID)).
+		Set(TagOrdStatus, statusRejected).
+		Set(TagCxlRejRespTo, responseTo).
+		Set(TagCxlRejReason, reason).
+		Set(TagText, text))
+}
+
+// parse reads side, order type, quantity and price of the order
+// Return:
+//
+//	string - reason of the rejection or empty string
+func (st *orderState) parse(m *Message) string {
+	if st.clOrdID == "" {
+		return "ClOrdID is required"
+	}
+
+	switch m.Get(TagSide) {
+	case sideBuy:
+		st.side = orderbook.Buy
+	case sideSell:
+		st.side = orderbook.Sell
+	default:
+		return "unsupported side"
+	}
+
+	st.ordType = m.Get(TagOrdType)
+	if st.ordType != ordTypeLimit && st.ordType != ordTypeMarket {
+		return "unsupported order type"
+	}
+
+	var err error
+	if st.quantity, err = decimal.NewFromString(m.Get(TagOrderQty)); err != nil {
+		return orderbook.ErrInvalidQuantity.Error()
+	}
+
+	if st.ordType == ordTypeLimit {
+		if st.price, err = decimal.NewFromString(m.Get(TagPrice)); err != nil {
+			return orderbook.ErrInvalidPrice.Error()
+		}
+	}
+
+	return ""
+}
+
+// status returns OrdStatus of the open order by executed quantity
+func (st *orderState) status() string {
+	switch {
+	case st.cum.GreaterThanOrEqual(st.quantity):
+		return statusFilled
+	case st.cum.Sign() > 0:
+		return statusPartiallyFilled
+	default:
+		return statusNew
+	}
+}
+
+func formatSide(side orderbook.Side) string {
+	if side == orderbook.Buy {
+		return sideBuy
+	}
+	return sideSell
+}
@@ -0,0 +1,429 @@
+package fix
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"orderbook"
+)
+
+type testClient struct {
+	t      *testing.T
+	id     string
+	conn   net.Conn
+	reader *bufio.Reader
+	seq    uint64
+}
+
+func dial(t *testing.T, l net.Listener, id string, seq uint64) *testClient {
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testClient{t: t, id: id, conn: conn, reader: bufio.NewReader(conn), seq: seq}
+}
+
+func (c *testClient) send(m *Message) {
+	framed := &Message{Fields: []Field{
+		{Tag: TagMsgType, Value: m.Type()},
+		{Tag: TagSenderCompID, Value: c.id},
+		{Tag: TagTargetCompID, Value: "EXCHANGE"},
+		{Tag: TagMsgSeqNum, Value: strconv.FormatUint(c.seq, 10)},
+		{Tag: TagSendingTime, Value: time.Now().UTC().Format(timeFormat)},
+	}}
+	framed.Fields = append(framed.Fields, m.Fields[1:]...)
+	c.seq++
+
+	if _, err := c.conn.Write(framed.Bytes()); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+func (c *testClient) read() *Message {
+	c.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	data, err := ReadMessage(c.reader)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+
+	m, err := Parse(data)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	return m
+}
+
+func (c *testClient) logon() *Message {
+	c.send(NewMessage(MsgLogon).Set(TagEncryptMethod, "0").Set(TagHeartBtInt, "30"))
+	return c.read()
+}
+
+func (c *testClient) expect(msgType, execType, status string) *Message {
+	m := c.read()
+	if m.Type() != msgType || m.Get(TagExecType) != execType || m.Get(TagOrdStatus) != status {
+		c.t.Fatal("Unexpected message", m)
+	}
+	return m
+}
+
+func newOrder(clOrdID, side, ordType string, quantity, price int64) *Message {
+	m := NewMessage(MsgNewOrderSingle).
+		Set(TagClOrdID, clOrdID).
+		Set(TagSymbol, "BTCUSD").
+		Set(TagSide, side).
+		Set(TagOrdType, ordType).
+		Set(TagOrderQty, strconv.FormatInt(quantity, 10))
+
+	if ordType == ordTypeLimit {
+		m.Set(TagPrice, strconv.FormatInt(price, 10))
+	}
+	return m
+}
+
+func newTestAcceptor(t *testing.T, store Store) (*Acceptor, *orderbook.Engine, net.Listener) {
+	engine := orderbook.NewEngine(orderbook.NewOrderBook())
+	a, err := NewAcceptor(engine, "EXCHANGE", store, []string{"MAKER", "TAKER"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go a.Serve(l)
+
+	return a, engine, l
+}
+
+func TestMessage(t *testing.T) {
+	m := NewMessage(MsgHeartbeat).Set(TagSenderCompID, "A").Set(TagTestReqID, "test")
+	data := m.Bytes()
+
+	parsed, err := Parse(data)
+	if err != nil || parsed.Type() != MsgHeartbeat || parsed.Get(TagTestReqID) != "test" || len(parsed.Fields) != 3 {
+		t.Fatal("Invalid parsed message", parsed, err)
+	}
+
+	read, err := ReadMessage(bufio.NewReader(bytes.NewReader(append(data, data...))))
+	if err != nil || string(read) != string(data) {
+		t.Fatal("Invalid read message", string(read), err)
+	}
+
+	data[len(data)-2]++
+	if _, err := Parse(data); err != ErrBadChecksum {
+		t.Fatal("Invalid checksum is accepted", err)
+	}
+
+	if _, err := Parse([]byte("8=FIX.4.4\x019=6\x0135=0\x0110=000\x01")); err != ErrGarbled {
+		t.Fatal("Invalid body length is accepted", err)
+	}
+}
+
+func TestFileStore(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if sender, target, err := store.SeqNums("A"); err != nil || sender != 1 || target != 1 {
+		t.Fatal("Invalid initial sequence numbers", sender, target, err)
+	}
+
+	store.SetSeqNums("A", 5, 7)
+	store.SaveMessage("A", 3, []byte("three"))
+	store.SaveMessage("A", 4, []byte("four\nlines"))
+
+	store, _ = NewFileStore(dir)
+	if sender, target, err := store.SeqNums("A"); err != nil || sender != 5 || target != 7 {
+		t.Fatal("Invalid stored sequence numbers", sender, target, err)
+	}
+
+	messages, err := store.Messages("A", 4, 10)
+	if err != nil || len(messages) != 1 || string(messages[4]) != "four\nlines" {
+		t.Fatal("Invalid stored messages", messages, err)
+	}
+
+	store.Reset("A")
+	if sender, _, _ := store.SeqNums("A"); sender != 1 {
+		t.Fatal("Sequence numbers are not reset", sender)
+	}
+
+	for _, session := range []string{"../A", "..", "A/B", `A\B`, ""} {
+		if err := store.SetSeqNums(session, 1, 1); err != ErrInvalidSession {
+			t.Fatal("Session leaves the store directory", session, err)
+		}
+	}
+}
+
+func TestAcceptorSessions(t *testing.T) {
+	_, engine, l := newTestAcceptor(t, NewMemoryStore())
+	defer l.Close()
+	defer engine.Close()
+
+	if _, err := NewAcceptor(engine, "EXCHANGE", NewMemoryStore(), []string{"../MAKER"}); err != ErrInvalidSession {
+		t.Fatal("Invalid session is configured", err)
+	}
+
+	unknown := dial(t, l, "UNKNOWN", 1)
+	defer unknown.conn.Close()
+
+	unknown.send(NewMessage(MsgLogon).Set(TagEncryptMethod, "0").Set(TagHeartBtInt, "30"))
+	unknown.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := ReadMessage(unknown.reader); err == nil {
+		t.Fatal("Unknown session is logged on")
+	}
+}
+
+func TestAcceptorOrders(t *testing.T) {
+	_, engine, l := newTestAcceptor(t, NewMemoryStore())
+	defer l.Close()
+	defer engine.Close()
+
+	maker := dial(t, l, "MAKER", 1)
+	defer maker.conn.Close()
+	taker := dial(t, l, "TAKER", 1)
+	defer taker.conn.Close()
+
+	if m := maker.logon(); m.Type() != MsgLogon || m.Get(TagMsgSeqNum) != "1" || m.Get(TagTargetCompID) != "MAKER" {
+		t.Fatal("Invalid logon", m)
+	}
+	taker.logon()
+
+	maker.send(newOrder("m1", sideSell, ordTypeLimit, 5, 100))
+	if m := maker.expect(MsgExecutionReport, execNew, statusNew); m.Get(TagOrderID) != "MAKER/m1" || m.Get(TagLeavesQty) != "5" {
+		t.Fatal("Invalid new order report", m)
+	}
+
+	maker.send(newOrder("m2", sideSell, ordTypeLimit, 5, 110))
+	maker.expect(MsgExecutionReport, execNew, statusNew)
+
+	maker.send(newOrder("m1", sideSell, ordTypeLimit, 1, 100))
+	if m := maker.expect(MsgExecutionReport, execRejected, statusRejected); m.Get(TagText) != orderbook.ErrOrderExists.Error() {
+		t.Fatal("Invalid duplicate rejection", m)
+	}
+
+	maker.send(newOrder("m3", sideSell, ordTypeLimit, 0, 100))
+	if m := maker.expect(MsgExecutionReport, execRejected, statusRejected); m.Get(TagText) != orderbook.ErrInvalidQuantity.Error() {
+		t.Fatal("Invalid quantity rejection", m)
+	}
+
+	taker.send(newOrder("t1", sideBuy, ordTypeMarket, 7, 0))
+	taker.expect(MsgExecutionReport, execNew, statusNew)
+	if m := taker.expect(MsgExecutionReport, execTrade, statusPartiallyFilled); m.Get(TagLastQty) != "5" || m.Get(TagLastPx) != "100" {
+		t.Fatal("Invalid first taker fill", m)
+	}
+	m := taker.expect(MsgExecutionReport, execTrade, statusFilled)
+	if avg, _ := decimal.NewFromString(m.Get(TagAvgPx)); m.Get(TagCumQty) != "7" || !avg.Equal(decimal.New(720, 0).Div(decimal.New(7, 0))) {
+		t.Fatal("Invalid second taker fill", m)
+	}
+
+	maker.expect(MsgExecutionReport, execTrade, statusFilled)
+	if m := maker.expect(MsgExecutionReport, execTrade, statusPartiallyFilled); m.Get(TagClOrdID) != "m2" || m.Get(TagLeavesQty) != "3" {
+		t.Fatal("Invalid maker fill", m)
+	}
+
+	maker.send(NewMessage(MsgOrderCancelReplaceRequest).
+		Set(TagClOrdID, "m2r").
+		Set(TagOrigClOrdID, "m2").
+		Set(TagSymbol, "BTCUSD").
+		Set(TagSide, sideSell).
+		Set(TagOrdType, ordTypeLimit).
+		Set(TagOrderQty, "6").
+		Set(TagPrice, "105"))
+	if m := maker.expect(MsgExecutionReport, execReplaced, statusPartiallyFilled); m.Get(TagOrigClOrdID) != "m2" ||
+		m.Get(TagLeavesQty) != "4" || m.Get(TagCumQty) != "2" {
+		t.Fatal("Invalid replace report", m)
+	}
+
+	var resting *orderbook.Order
+	engine.Do(func(ob *orderbook.OrderBook) { resting = ob.Order("MAKER/m2r") })
+	if resting == nil || !resting.Quantity().Equal(decimal.New(4, 0)) || !resting.Price().Equal(decimal.New(105, 0)) {
+		t.Fatal("Invalid replaced order", resting)
+	}
+
+	// rejected replacement keeps the original order
+	engine.Do(func(ob *orderbook.OrderBook) {
+		ob.SetPriceBand(&orderbook.PriceBand{Reference: decimal.New(100, 0), Percent: decimal.New(10, 0)})
+	})
+	maker.send(NewMessage(MsgOrderCancelReplaceRequest).
+		Set(TagClOrdID, "m2x").
+		Set(TagOrigClOrdID, "m2r").
+		Set(TagSymbol, "BTCUSD").
+		Set(TagSide, sideSell).
+		Set(TagOrdType, ordTypeLimit).
+		Set(TagOrderQty, "6").
+		Set(TagPrice, "150"))
+	if m := maker.read(); m.Type() != MsgOrderCancelReject || m.Get(TagCxlRejRespTo) != cxlRejToReplace ||
+		m.Get(TagText) != orderbook.ErrPriceOutOfBand.Error() {
+		t.Fatal("Invalid replace reject", m)
+	}
+
+	engine.Do(func(ob *orderbook.OrderBook) {
+		ob.SetPriceBand(nil)
+		resting = ob.Order("MAKER/m2r")
+	})
+	if resting == nil || !resting.Quantity().Equal(decimal.New(4, 0)) {
+		t.Fatal("Original order is lost by rejected replacement", resting)
+	}
+
+	cancel := NewMessage(MsgOrderCancelRequest).
+		Set(TagClOrdID, "c1").
+		Set(TagOrigClOrdID, "m2r").
+		Set(TagSymbol, "BTCUSD").
+		Set(TagSide, sideSell)
+	maker.send(cancel)
+	if m := maker.expect(MsgExecutionReport, execCanceled, statusCanceled); m.Get(TagClOrdID) != "c1" || m.Get(TagOrigClOrdID) != "m2r" {
+		t.Fatal("Invalid cancel report", m)
+	}
+
+	maker.send(cancel)
+	if m := maker.read(); m.Type() != MsgOrderCancelReject || m.Get(TagCxlRejRespTo) != cxlRejToCancel || m.Get(TagCxlRejReason) != "1" {
+		t.Fatal("Invalid cancel reject", m)
+	}
+
+	maker.send(newOrder("m4", sideSell, ordTypeLimit, 1, 120))
+	maker.expect(MsgExecutionReport, execNew, statusNew)
+	maker.send(newOrder("m5", sideSell, ordTypeLimit, 1, 130))
+	maker.expect(MsgExecutionReport, execNew, statusNew)
+
+	taker.send(NewMessage(MsgOrderCancelRequest).
+		Set(TagClOrdID, "c2").
+		Set(TagOrigClOrdID, "m4").
+		Set(TagSymbol, "BTCUSD").
+		Set(TagSide, sideSell))
+	if m := taker.read(); m.Type() != MsgOrderCancelReject || m.Get(TagCxlRejReason) != "1" {
+		t.Fatal("Order of another session is cancelled", m)
+	}
+
+	maker.send(NewMessage(MsgOrderMassCancelRequest).Set(TagClOrdID, "mc").Set(TagMassCancelRequestType, massCancelAll))
+	maker.expect(MsgExecutionReport, execCanceled, statusCanceled)
+	maker.expect(MsgExecutionReport, execCanceled, statusCanceled)
+	if m := maker.read(); m.Type() != MsgOrderMassCancelReport || m.Get(TagTotalAffected) != "2" || m.Get(TagMassCancelResponse) != massCancelAll {
+		t.Fatal("Invalid mass cancel report", m)
+	}
+
+	taker.send(newOrder("t2", sideBuy, ordTypeMarket, 1, 0))
+	taker.expect(MsgExecutionReport, execNew, statusNew)
+	taker.expect(MsgExecutionReport, execCanceled, statusCanceled)
+
+	taker.send(NewMessage("AE"))
+	if m := taker.read(); m.Type() != MsgReject {
+		t.Fatal("Unsupported message is not rejected", m)
+	}
+}
+
+// failingStore fails to save application messages
+type failingStore struct {
+	*MemoryStore
+}
+
+func (fs failingStore) SaveMessage(session string, seq uint64, data []byte) error {
+	return errors.New("disk is full")
+}
+
+// logWriter passes log lines to the channel
+type logWriter chan string
+
+func (w logWriter) Write(p []byte) (int, error) {
+	w <- string(p)
+	return len(p), nil
+}
+
+func TestAcceptorStoreError(t *testing.T) {
+	a, engine, l := newTestAcceptor(t, failingStore{NewMemoryStore()})
+	defer l.Close()
+	defer engine.Close()
+
+	logs := make(logWriter, 10)
+	a.ErrorLog = log.New(logs, "", 0)
+
+	maker := dial(t, l, "MAKER", 1)
+	defer maker.conn.Close()
+	maker.logon()
+
+	maker.send(newOrder("m1", sideSell, ordTypeLimit, 1, 100))
+	maker.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := ReadMessage(maker.reader); err == nil {
+		t.Fatal("Session is not disconnected on store error")
+	}
+
+	if line := <-logs; !strings.Contains(line, "MAKER") || !strings.Contains(line, "disk is full") {
+		t.Fatal("Invalid error log", line)
+	}
+}
+
+func TestAcceptorResend(t *testing.T) {
+	dir := t.TempDir()
+	store, _ := NewFileStore(dir)
+	_, engine, l := newTestAcceptor(t, store)
+
+	maker := dial(t, l, "MAKER", 1)
+	maker.logon()
+	maker.send(newOrder("m1", sideSell, ordTypeLimit, 1, 100))
+	maker.expect(MsgExecutionReport, execNew, statusNew)
+	maker.send(NewMessage(MsgLogout))
+	if m := maker.read(); m.Type() != MsgLogout {
+		t.Fatal("Invalid logout", m)
+	}
+	maker.conn.Close()
+
+	// the fill is stored while the maker is disconnected
+	taker := dial(t, l, "TAKER", 1)
+	taker.logon()
+	taker.send(newOrder("t1", sideBuy, ordTypeMarket, 1, 0))
+	taker.expect(MsgExecutionReport, execNew, statusNew)
+	taker.expect(MsgExecutionReport, execTrade, statusFilled)
+	taker.conn.Close()
+	l.Close()
+
+	// the maker report is stored after the taker one, wait for the command to complete
+	engine.Do(func(*orderbook.OrderBook) {})
+	engine.Close()
+
+	// restart with sequence numbers from the same directory
+	store, _ = NewFileStore(dir)
+	_, engine, l = newTestAcceptor(t, store)
+	defer l.Close()
+	defer engine.Close()
+
+	maker = dial(t, l, "MAKER", maker.seq)
+	defer maker.conn.Close()
+
+	// logon, order and logout were sent before, fill is missed
+	if m := maker.logon(); m.Get(TagMsgSeqNum) != "5" {
+		t.Fatal("Invalid logon sequence number", m)
+	}
+
+	maker.send(NewMessage(MsgResendRequest).Set(TagBeginSeqNo, "4").Set(TagEndSeqNo, "0"))
+	m := maker.read()
+	if m.Get(TagMsgSeqNum) != "4" || m.Get(TagPossDupFlag) != "Y" || m.Get(TagExecType) != execTrade || m.Get(TagClOrdID) != "m1" {
+		t.Fatal("Invalid resent report", m)
+	}
+
+	m = maker.read()
+	if m.Type() != MsgSequenceReset || m.Get(TagMsgSeqNum) != "5" || m.Get(TagNewSeqNo) != "6" || m.Get(TagGapFillFlag) != "Y" {
+		t.Fatal("Invalid gap fill", m)
+	}
+
+	maker.send(NewMessage(MsgTestRequest).Set(TagTestReqID, "ping"))
+	if m := maker.read(); m.Type() != MsgHeartbeat || m.Get(TagTestReqID) != "ping" || m.Get(TagMsgSeqNum) != "6" {
+		t.Fatal("Invalid heartbeat", m)
+	}
+
+	maker.seq = 1
+	maker.send(NewMessage(MsgHeartbeat))
+	if m := maker.read(); m.Type() != MsgLogout || m.Get(TagText) == "" {
+		t.Fatal("Too low sequence number is accepted", m)
+	}
+}
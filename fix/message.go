@@ -0,0 +1,257 @@
+// Package fix implements FIX 4.4 order entry acceptor of the matching engine
+package fix
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// BeginString of the supported protocol version
+const BeginString = "FIX.4.4"
+
+// soh separates fields of the message
+const soh = '\x01'
+
+// Tags used by the acceptor
+const (
+	TagAvgPx                 = 6
+	TagBeginSeqNo            = 7
+	TagBeginString           = 8
+	TagBodyLength            = 9
+	TagCheckSum              = 10
+	TagClOrdID               = 11
+	TagCumQty                = 14
+	TagEndSeqNo              = 16
+	TagExecID                = 17
+	TagLastPx                = 31
+	TagLastQty               = 32
+	TagMsgSeqNum             = 34
+	TagMsgType               = 35
+	TagNewSeqNo              = 36
+	TagOrderID               = 37
+	TagOrderQty              = 38
+	TagOrdStatus             = 39
+	TagOrdType               = 40
+	TagOrigClOrdID           = 41
+	TagPossDupFlag           = 43
+	TagPrice                 = 44
+	TagRefSeqNum             = 45
+	TagSenderCompID          = 49
+	TagSendingTime           = 52
+	TagSide                  = 54
+	TagSymbol                = 55
+	TagTargetCompID          = 56
+	TagText                  = 58
+	TagTransactTime          = 60
+	TagEncryptMethod         = 98
+	TagCxlRejReason          = 102
+	TagHeartBtInt            = 108
+	TagTestReqID             = 112
+	TagOrigSendingTime       = 122
+	TagGapFillFlag           = 123
+	TagResetSeqNumFlag       = 141
+	TagExecType              = 150
+	TagLeavesQty             = 151
+	TagCxlRejRespTo          = 434
+	TagMassCancelRequestType = 530
+	TagMassCancelResponse    = 531
+	TagTotalAffected         = 533
+)
+
+// Message types used by the acceptor
+const (
+	MsgHeartbeat                 = "0"
+	MsgTestRequest               = "1"
+	MsgResendRequest             = "2"
+	MsgReject                    = "3"
+	MsgSequenceReset             = "4"
+	MsgLogout                    = "5"
+	MsgExecutionReport           = "8"
+	MsgOrderCancelReject         = "9"
+	MsgLogon                     = "A"
+	MsgNewOrderSingle            = "D"
+	MsgOrderCancelRequest        = "F"
+	MsgOrderCancelReplaceRequest = "G"
+	MsgOrderMassCancelRequest    = "q"
+	MsgOrderMassCancelReport     = "r"
+)
+
+// Errors of the message codec
+var (
+	ErrGarbled     = errors.New("fix: garbled message")
+	ErrBadChecksum = errors.New("fix: invalid checksum")
+)
+
+// Field is tag and value pair of the message
+type Field struct {
+	Tag   int
+	Value string
+}
+
+// Message is FIX message as ordered list of fields without BeginString,
+// BodyLength and CheckSum which are handled by the codec
+type Message struct {
+	Fields []Field
+}
+
+// NewMessage creates message of given type
+func NewMessage(msgType string) *Message {
+	return &Message{Fields: []Field{{Tag: TagMsgType, Value: msgType}}}
+}
+
+// Type returns MsgType of the message
+func (m *Message) Type() string {
+	return m.Get(TagMsgType)
+}
+
+// Get returns value of the first field with given tag or empty string
+func (m *Message) Get(tag int) string {
+	for _, f := range m.Fields {
+		if f.Tag == tag {
+			return f.Value
+		}
+	}
+	return ""
+}
+
+// Has checks that the message contains field with given tag
+func (m *Message) Has(tag int) bool {
+	for _, f := range m.Fields {
+		if f.Tag == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// Int returns integer value of the field, zero if it is absent or invalid
+func (m *Message) Int(tag int) int64 {
+	v, _ := strconv.ParseInt(m.Get(tag), 10, 64)
+	return v
+}
+
+// Set replaces value of the field with given tag or appends the field
+func (m *Message) Set(tag int, value string) *Message {
+	for i := range m.Fields {
+		if m.Fields[i].Tag == tag {
+			m.Fields[i].Value = value
+			return m
+		}
+	}
+	m.Fields = append(m.Fields, Field{Tag: tag, Value: value})
+	return m
+}
+
+// Bytes encodes the message with BeginString, BodyLength and CheckSum
+func (m *Message) Bytes() []byte {
+	var body bytes.Buffer
+	for _, f := range m.Fields {
+		body.WriteString(strconv.Itoa(f.Tag))
+		body.WriteByte('=')
+		body.WriteString(f.Value)
+		body.WriteByte(soh)
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%d=%s%c%d=%d%c", TagBeginString, BeginString, soh, TagBodyLength, body.Len(), soh)
+	buf.Write(body.Bytes())
+	fmt.Fprintf(&buf, "%d=%03d%c", TagCheckSum, checksum(buf.Bytes()), soh)
+	return buf.Bytes()
+}
+
+// String returns the message with SOH replaced by '|'
+func (m *Message) String() string {
+	return string(bytes.ReplaceAll(m.Bytes(), []byte{soh}, []byte{'|'}))
+}
+
+// Parse decodes the message and validates BeginString, BodyLength and CheckSum
+func Parse(data []byte) (*Message, error) {
+	m := &Message{}
+	rest := data
+	for len(rest) > 0 {
+		end := bytes.IndexByte(rest, soh)
+		if end < 0 {
+			return nil, ErrGarbled
+		}
+
+		eq := bytes.IndexByte(rest[:end], '=')
+		if eq <= 0 {
+			return nil, ErrGarbled
+		}
+
+		tag, err := strconv.Atoi(string(rest[:eq]))
+		if err != nil {
+			return nil, ErrGarbled
+		}
+
+		m.Fields = append(m.Fields, Field{Tag: tag, Value: string(rest[eq+1 : end])})
+		rest = rest[end+1:]
+	}
+
+	n := len(m.Fields)
+	if n < 4 || m.Fields[0].Tag != TagBeginString || m.Fields[0].Value != BeginString ||
+		m.Fields[1].Tag != TagBodyLength || m.Fields[2].Tag != TagMsgType || m.Fields[n-1].Tag != TagCheckSum {
+		return nil, ErrGarbled
+	}
+
+	trailer := bytes.LastIndex(data[:len(data)-1], []byte{soh}) + 1
+	header := bytes.Index(data, []byte{soh}) + 1
+	header += bytes.IndexByte(data[header:], soh) + 1
+	if length, err := strconv.Atoi(m.Fields[1].Value); err != nil || length != trailer-header {
+		return nil, ErrGarbled
+	}
+
+	if sum, err := strconv.Atoi(m.Fields[n-1].Value); err != nil || sum != checksum(data[:trailer]) {
+		return nil, ErrBadChecksum
+	}
+
+	m.Fields = m.Fields[2 : n-1]
+	return m, nil
+}
+
+// ReadMessage reads single raw message from the stream
+func ReadMessage(r *bufio.Reader) ([]byte, error) {
+	begin, err := r.ReadBytes(soh)
+	if err != nil {
+		return nil, err
+	}
+
+	length, err := r.ReadBytes(soh)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := strconv.Itoa(TagBodyLength) + "="
+	if !bytes.HasPrefix(length, []byte(prefix)) {
+		return nil, ErrGarbled
+	}
+
+	n, err := strconv.Atoi(string(length[len(prefix) : len(length)-1]))
+	if err != nil || n <= 0 {
+		return nil, ErrGarbled
+	}
+
+	// body and 7 bytes of the trailer "10=NNN<SOH>"
+	rest := make([]byte, n+7)
+	if _, err := io.ReadFull(r, rest); err != nil {
+		return nil, err
+	}
+
+	data := make([]byte, 0, len(begin)+len(length)+len(rest))
+	data = append(data, begin...)
+	data = append(data, length...)
+	return append(data, rest...), nil
+}
+
+// checksum returns sum of the bytes modulo 256
+func checksum(data []byte) int {
+	sum := 0
+	for _, b := range data {
+		sum += int(b)
+	}
+	return sum % 256
+}
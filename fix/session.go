@@ -0,0 +1,430 @@
+package fix
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// timeFormat is format of UTCTimestamp fields
+const timeFormat = "20060102-15:04:05.000"
+
+// logonTimeout limits time between connect and Logon message
+const logonTimeout = 10 * time.Second
+
+// errSessionActive is returned on logon of the session which is already connected
+var errSessionActive = errors.New("fix: session is already logged on")
+
+// session is FIX session with the counterparty identified by its SenderCompID.
+// Sequence numbers are kept between connections, messages sent while the
+// counterparty is disconnected are stored and resent on request
+type session struct {
+	acceptor *Acceptor
+	id       string
+
+	mu     sync.Mutex
+	sender uint64
+	target uint64
+	conn   net.Conn
+	queue  [][]byte
+	signal chan struct{}
+	sent   time.Time
+}
+
+// send assigns sequence number to the message, stores it and queues it to the connection
+func (s *session) send(m *Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now().UTC()
+	data := s.frame(m, s.sender, now).Bytes()
+
+	if !isAdmin(m.Type()) {
+		if err := s.acceptor.store.SaveMessage(s.id, s.sender, data); err != nil {
+			s.fail(err)
+			return err
+		}
+	}
+
+	s.sender++
+	if err := s.acceptor.store.SetSeqNums(s.id, s.sender, s.target); err != nil {
+		s.fail(err)
+		return err
+	}
+
+	s.write(data, now)
+	return nil
+}
+
+// fail logs the error of the session and closes its connection, messages lost by the
+// counterparty are resent after reconnect. Caller must hold the lock
+func (s *session) fail(err error) {
+	s.acceptor.logf("fix: session %s: %v", s.id, err)
+	if s.conn != nil {
+		s.conn.Close()
+	}
+}
+
+// frame returns the message with standard header
+func (s *session) frame(m *Message, seq uint64, now time.Time) *Message {
+	framed := &Message{Fields: []Field{
+		{Tag: TagMsgType, Value: m.Type()},
+		{Tag: TagSenderCompID, Value: s.acceptor.compID},
+		{Tag: TagTargetCompID, Value: s.id},
+		{Tag: TagMsgSeqNum, Value: strconv.FormatUint(seq, 10)},
+		{Tag: TagSendingTime, Value: now.Format(timeFormat)},
+	}}
+
+	for _, f := range m.Fields {
+		if f.Tag != TagMsgType {
+			framed.Fields = append(framed.Fields, f)
+		}
+	}
+	return framed
+}
+
+// write queues encoded message to the connection, it is dropped if there is no connection.
+// Caller must hold the lock
+func (s *session) write(data []byte, now time.Time) {
+	if s.conn == nil {
+		return
+	}
+
+	s.queue = append(s.queue, data)
+	s.sent = now
+
+	select {
+	case s.signal <- struct{}{}:
+	default:
+	}
+}
+
+// attach binds the connection to the session
+func (s *session) attach(conn net.Conn) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn != nil {
+		return errSessionActive
+	}
+
+	s.conn = conn
+	s.queue = nil
+	s.signal = make(chan struct{}, 1)
+	s.sent = time.Now()
+	return nil
+}
+
+// detach unbinds the connection from the session
+func (s *session) detach(conn net.Conn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn == conn {
+		s.conn = nil
+		s.queue = nil
+	}
+}
+
+// setTarget saves next expected incoming sequence number
+func (s *session) setTarget(target uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.target = target
+	if err := s.acceptor.store.SetSeqNums(s.id, s.sender, s.target); err != nil {
+		s.fail(err)
+		return err
+	}
+	return nil
+}
+
+// expected returns next expected incoming sequence number
+func (s *session) expected() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.target
+}
+
+// reset starts both sequences from one and drops stored messages
+func (s *session) reset() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sender, s.target = 1, 1
+	if err := s.acceptor.store.Reset(s.id); err != nil {
+		s.fail(err)
+		return err
+	}
+	return nil
+}
+
+// resend sends stored messages from begin to end (inclusive, zero is the last sent one)
+// with PossDupFlag, gaps of admin and missing messages are filled by SequenceReset
+func (s *session) resend(begin, end uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if end == 0 || end >= s.sender {
+		end = s.sender - 1
+	}
+
+	stored, err := s.acceptor.store.Messages(s.id, begin, end)
+	if err != nil {
+		s.fail(err)
+		return err
+	}
+
+	now := time.Now().UTC()
+	gap := uint64(0)
+	for seq := begin; seq <= end; seq++ {
+		data, ok := stored[seq]
+		if !ok {
+			if gap == 0 {
+				gap = seq
+			}
+			continue
+		}
+
+		if gap != 0 {
+			s.write(s.gapFill(gap, seq, now), now)
+			gap = 0
+		}
+
+		m, err := Parse(data)
+		if err != nil {
+			s.fail(err)
+			return err
+		}
+		s.write(possDup(m, now).Bytes(), now)
+	}
+
+	if gap != 0 {
+		s.write(s.gapFill(gap, end+1, now), now)
+	}
+	return nil
+}
+
+// gapFill returns SequenceReset-GapFill message with sequence number seq
+func (s *session) gapFill(seq, next uint64, now time.Time) []byte {
+	m := NewMessage(MsgSequenceReset).
+		Set(TagGapFillFlag, "Y").
+		Set(TagNewSeqNo, strconv.FormatUint(next, 10))
+
+	return possDup(s.frame(m, seq, now), now).Bytes()
+}
+
+// possDup marks resent message with PossDupFlag and OrigSendingTime
+func possDup(m *Message, now time.Time) *Message {
+	orig := m.Get(TagSendingTime)
+	if orig == "" {
+		orig = now.Format(timeFormat)
+	}
+
+	fields := make([]Field, 0, len(m.Fields)+2)
+	for _, f := range m.Fields {
+		switch f.Tag {
+		case TagPossDupFlag, TagOrigSendingTime:
+		case TagSendingTime:
+			fields = append(fields,
+				Field{Tag: TagSendingTime, Value: now.Format(timeFormat)},
+				Field{Tag: TagPossDupFlag, Value: "Y"},
+				Field{Tag: TagOrigSendingTime, Value: orig},
+			)
+		default:
+			fields = append(fields, f)
+		}
+	}
+	return &Message{Fields: fields}
+}
+
+// isAdmin checks that the message type belongs to the session level
+func isAdmin(msgType string) bool {
+	switch msgType {
+	case MsgHeartbeat, MsgTestRequest, MsgResendRequest, MsgReject, MsgSequenceReset, MsgLogout, MsgLogon:
+		return true
+	}
+	return false
+}
+
+// connection serves single TCP connection of the session
+type connection struct {
+	session   *session
+	conn      net.Conn
+	reader    *bufio.Reader
+	heartbeat time.Duration
+	received  atomic.Int64
+	testSent  atomic.Bool
+	resending bool
+	done      chan struct{}
+}
+
+// writeLoop writes queued messages to the connection
+func (c *connection) writeLoop() {
+	s := c.session
+	for {
+		s.mu.Lock()
+		signal := s.signal
+		s.mu.Unlock()
+
+		closing := false
+		select {
+		case <-signal:
+		case <-c.done:
+			closing = true
+		}
+
+		s.mu.Lock()
+		queue := s.queue
+		s.queue = nil
+		s.mu.Unlock()
+
+		for _, data := range queue {
+			if _, err := c.conn.Write(data); err != nil {
+				s.acceptor.logf("fix: session %s: %v", s.id, err)
+				c.conn.Close()
+				return
+			}
+		}
+
+		// messages queued before close (e.g. Logout) are written
+		if closing {
+			return
+		}
+	}
+}
+
+// heartbeatLoop sends heartbeats when nothing is sent and test requests when nothing is received
+func (c *connection) heartbeatLoop() {
+	ticker := time.NewTicker(c.heartbeat / 4)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-c.done:
+			return
+		}
+
+		now := time.Now()
+
+		c.session.mu.Lock()
+		idle := now.Sub(c.session.sent) >= c.heartbeat
+		c.session.mu.Unlock()
+
+		if idle {
+			c.session.send(NewMessage(MsgHeartbeat))
+		}
+
+		silent := now.Sub(time.Unix(0, c.received.Load()))
+		if silent >= c.heartbeat+c.heartbeat/5 && !c.testSent.Swap(true) {
+			c.session.send(NewMessage(MsgTestRequest).Set(TagTestReqID, now.UTC().Format(timeFormat)))
+		}
+	}
+}
+
+// readLoop handles incoming messages until logout or disconnect
+func (c *connection) readLoop() {
+	for {
+		// the counterparty has one more heartbeat interval to answer test request
+		c.conn.SetReadDeadline(time.Now().Add(2*c.heartbeat + c.heartbeat/5))
+
+		data, err := ReadMessage(c.reader)
+		if err != nil {
+			return
+		}
+
+		c.received.Store(time.Now().UnixNano())
+		c.testSent.Store(false)
+
+		m, err := Parse(data)
+		if err != nil {
+			// garbled messages are ignored and requested again by the sequence gap
+			continue
+		}
+
+		if !c.sequence(m) {
+			return
+		}
+	}
+}
+
+// sequence checks sequence number of the message and processes it
+// Return:
+//
+//	bool - false if the connection should be closed
+func (c *connection) sequence(m *Message) bool {
+	s := c.session
+	seq := uint64(m.Int(TagMsgSeqNum))
+	target := s.expected()
+
+	if m.Type() == MsgSequenceReset && m.Get(TagGapFillFlag) != "Y" {
+		if next := uint64(m.Int(TagNewSeqNo)); next > target {
+			s.setTarget(next)
+		}
+		return true
+	}
+
+	switch {
+	case seq > target:
+		if !c.resending {
+			c.resending = true
+			s.send(NewMessage(MsgResendRequest).
+				Set(TagBeginSeqNo, strconv.FormatUint(target, 10)).
+				Set(TagEndSeqNo, "0"))
+		}
+		if m.Type() == MsgLogout {
+			s.send(NewMessage(MsgLogout))
+			return false
+		}
+		return true
+	case seq < target:
+		if m.Get(TagPossDupFlag) == "Y" {
+			return true
+		}
+		s.send(NewMessage(MsgLogout).Set(TagText, "MsgSeqNum too low, expecting "+strconv.FormatUint(target, 10)))
+		return false
+	}
+
+	c.resending = false
+	if m.Type() == MsgSequenceReset {
+		next := uint64(m.Int(TagNewSeqNo))
+		if next <= target {
+			next = target + 1
+		}
+		s.setTarget(next)
+		return true
+	}
+
+	s.setTarget(target + 1)
+	return c.process(m)
+}
+
+// process handles the message received in sequence
+func (c *connection) process(m *Message) bool {
+	s := c.session
+
+	switch m.Type() {
+	case MsgHeartbeat, MsgReject, MsgLogon:
+	case MsgTestRequest:
+		s.send(NewMessage(MsgHeartbeat).Set(TagTestReqID, m.Get(TagTestReqID)))
+	case MsgResendRequest:
+		s.resend(uint64(m.Int(TagBeginSeqNo)), uint64(m.Int(TagEndSeqNo)))
+	case MsgLogout:
+		s.send(NewMessage(MsgLogout))
+		return false
+	case MsgNewOrderSingle, MsgOrderCancelRequest, MsgOrderCancelReplaceRequest, MsgOrderMassCancelRequest:
+		s.acceptor.handle(s, m)
+	default:
+		s.send(NewMessage(MsgReject).
+			Set(TagRefSeqNum, m.Get(TagMsgSeqNum)).
+			Set(TagText, "unsupported message type "+m.Type()))
+	}
+
+	return true
+}
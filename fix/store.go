@@ -0,0 +1,261 @@
+package fix
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// ErrInvalidSession is returned for session names which can't be used as SenderCompID
+// of the counterparty and as the file name
+var ErrInvalidSession = errors.New("fix: invalid session name")
+
+// validSession checks that the session name has no path separators, so it can
+// name the store files and be the prefix of order IDs
+func validSession(session string) bool {
+	return session != "" && session != "." && session != ".." && !strings.ContainsAny(session, "/\\\x00")
+}
+
+// Store persists sequence numbers and sent application messages of the sessions
+// so they survive reconnects and restarts and can be resent on request
+type Store interface {
+	// SeqNums returns next sequence numbers of outgoing and incoming messages
+	SeqNums(session string) (sender, target uint64, err error)
+	// SetSeqNums saves next sequence numbers of outgoing and incoming messages
+	SetSeqNums(session string, sender, target uint64) error
+	// SaveMessage keeps sent message with given sequence number for resend
+	SaveMessage(session string, seq uint64, data []byte) error
+	// Messages returns sent messages with sequence numbers from begin to end (inclusive)
+	Messages(session string, begin, end uint64) (map[uint64][]byte, error)
+	// Reset drops sequence numbers and messages of the session
+	Reset(session string) error
+}
+
+// memorySession is state of the session in MemoryStore
+type memorySession struct {
+	sender   uint64
+	target   uint64
+	messages map[uint64][]byte
+}
+
+// MemoryStore keeps sessions in memory, it survives reconnects but not restarts
+type MemoryStore struct {
+	mu       sync.Mutex
+	sessions map[string]*memorySession
+}
+
+// NewMemoryStore creates empty memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{sessions: map[string]*memorySession{}}
+}
+
+// session returns state of the session creating it if needed
+func (ms *MemoryStore) session(session string) *memorySession {
+	s, ok := ms.sessions[session]
+	if !ok {
+		s = &memorySession{sender: 1, target: 1, messages: map[uint64][]byte{}}
+		ms.sessions[session] = s
+	}
+	return s
+}
+
+// SeqNums implements Store interface
+func (ms *MemoryStore) SeqNums(session string) (sender, target uint64, err error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	s := ms.session(session)
+	return s.sender, s.target, nil
+}
+
+// SetSeqNums implements Store interface
+func (ms *MemoryStore) SetSeqNums(session string, sender, target uint64) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	s := ms.session(session)
+	s.sender, s.target = sender, target
+	return nil
+}
+
+// SaveMessage implements Store interface
+func (ms *MemoryStore) SaveMessage(session string, seq uint64, data []byte) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	ms.session(session).messages[seq] = data
+	return nil
+}
+
+// Messages implements Store interface
+func (ms *MemoryStore) Messages(session string, begin, end uint64) (map[uint64][]byte, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	messages := map[uint64][]byte{}
+	for seq, data := range ms.session(session).messages {
+		if seq >= begin && seq <= end {
+			messages[seq] = data
+		}
+	}
+	return messages, nil
+}
+
+// Reset implements Store interface
+func (ms *MemoryStore) Reset(session string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	delete(ms.sessions, session)
+	return nil
+}
+
+// FileStore keeps sessions in the directory: <session>.seqnums contains next
+// sequence numbers and <session>.body contains sent messages prefixed by
+// sequence number and length
+type FileStore struct {
+	mu  sync.Mutex
+	dir string
+}
+
+// NewFileStore creates store in the directory, the directory is created if needed
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+// path returns path of the session file, it fails if the session name leaves the directory
+func (fs *FileStore) path(session, ext string) (string, error) {
+	if !validSession(session) {
+		return "", ErrInvalidSession
+	}
+	return filepath.Join(fs.dir, session+ext), nil
+}
+
+// SeqNums implements Store interface
+func (fs *FileStore) SeqNums(session string) (sender, target uint64, err error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	path, err := fs.path(session, ".seqnums")
+	if err != nil {
+		return 0, 0, err
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return 1, 1, nil
+	} else if err != nil {
+		return 0, 0, err
+	}
+
+	if _, err := fmt.Sscanf(string(data), "%d %d", &sender, &target); err != nil {
+		return 0, 0, err
+	}
+	return sender, target, nil
+}
+
+// SetSeqNums implements Store interface
+func (fs *FileStore) SetSeqNums(session string, sender, target uint64) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	// write and rename to keep previous numbers if the process crashes while writing
+	path, err := fs.path(session, ".seqnums")
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(path+".tmp", []byte(fmt.Sprintf("%d %d\n", sender, target)), 0644); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+// SaveMessage implements Store interface
+func (fs *FileStore) SaveMessage(session string, seq uint64, data []byte) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	path, err := fs.path(session, ".body")
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	if _, err := fmt.Fprintf(f, "%d %d\n%s", seq, len(data), data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Messages implements Store interface
+func (fs *FileStore) Messages(session string, begin, end uint64) (map[uint64][]byte, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	path, err := fs.path(session, ".body")
+	if err != nil {
+		return nil, err
+	}
+
+	messages := map[uint64][]byte{}
+
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return messages, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	for {
+		var seq uint64
+		var n int
+		if _, err := fmt.Fscanf(r, "%d %d\n", &seq, &n); err == io.EOF {
+			return messages, nil
+		} else if err != nil {
+			return nil, err
+		}
+
+		data := make([]byte, n)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, err
+		}
+
+		if seq >= begin && seq <= end {
+			messages[seq] = data
+		}
+	}
+}
+
+// Reset implements Store interface
+func (fs *FileStore) Reset(session string) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	for _, ext := range []string{".seqnums", ".body"} {
+		path, err := fs.path(session, ext)
+		if err != nil {
+			return err
+		}
+
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
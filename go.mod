@@ -4,5 +4,16 @@ go 1.22.1
 
 require (
 	github.com/emirpasic/gods v1.18.1
+	github.com/gorilla/websocket v1.5.3
 	github.com/shopspring/decimal v1.4.0
+	go.etcd.io/bbolt v1.3.11
+	google.golang.org/grpc v1.67.1
+	google.golang.org/protobuf v1.35.1
+)
+
+require (
+	golang.org/x/net v0.28.0 // indirect
+	golang.org/x/sys v0.24.0 // indirect
+	golang.org/x/text v0.17.0 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20240814211410-ddb44dafa142 // indirect
 )
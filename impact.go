@@ -0,0 +1,133 @@
+package orderbook
+
+import (
+	"github.com/shopspring/decimal"
+)
+
+// ImpactEstimate describes execution of the market order against the current order book
+// Slippage is positive if the execution is worse than the reference price
+type ImpactEstimate struct {
+	Side            Side            `json:"side"`
+	Quantity        decimal.Decimal `json:"quantity"`
+	Notional        decimal.Decimal `json:"notional"`
+	VWAP            decimal.Decimal `json:"vwap"`
+	BestPrice       decimal.Decimal `json:"bestPrice"`
+	WorstPrice      decimal.Decimal `json:"worstPrice"`
+	MidPrice        decimal.Decimal `json:"midPrice"`
+	SlippageBestBps decimal.Decimal `json:"slippageBestBps"`
+	SlippageMidBps  decimal.Decimal `json:"slippageMidBps"`
+	Levels          int             `json:"levels"`
+	FillRatio       decimal.Decimal `json:"fillRatio"`
+}
+
+// EstimateImpact estimates execution of the market order of definite quantity without changing the order book
+func (ob *OrderBook) EstimateImpact(side Side, quantity decimal.Decimal) (*ImpactEstimate, error) {
+	if quantity.Sign() <= 0 {
+		return nil, ErrInvalidQuantity
+	}
+
+	estimate := ob.estimate(side, func(level *OrderQueue, filled, _ decimal.Decimal) decimal.Decimal {
+		return decimal.Min(level.Volume(), quantity.Sub(filled))
+	})
+	estimate.FillRatio = estimate.Quantity.Div(quantity)
+	return estimate, nil
+}
+
+// EstimateImpactByNotional estimates execution of the market order of definite notional without changing the order book
+func (ob *OrderBook) EstimateImpactByNotional(side Side, notional decimal.Decimal) (*ImpactEstimate, error) {
+	if notional.Sign() <= 0 {
+		return nil, ErrInvalidNotional
+	}
+
+	estimate := ob.estimate(side, func(level *OrderQueue, _, spent decimal.Decimal) decimal.Decimal {
+		return decimal.Min(level.Volume(), notional.Sub(spent).Div(level.Price()))
+	})
+	estimate.FillRatio = estimate.Notional.Div(notional)
+	return estimate, nil
+}
+
+// QuantityWithinBps returns quantity and notional which can be bought (or sold) before
+// the execution price moves more than bps basis points away from the best price
+func (ob *OrderBook) QuantityWithinBps(side Side, bps decimal.Decimal) (quantity, notional decimal.Decimal) {
+	var (
+		best  *OrderQueue
+		limit func(decimal.Decimal) bool
+	)
+
+	if side == Buy {
+		best = ob.asks.MinPriceQueue()
+		if best == nil {
+			return
+		}
+		limit = best.Price().Add(best.Price().Mul(bps).Div(basisPoints)).GreaterThanOrEqual
+	} else {
+		best = ob.bids.MaxPriceQueue()
+		if best == nil {
+			return
+		}
+		limit = best.Price().Sub(best.Price().Mul(bps).Div(basisPoints)).LessThanOrEqual
+	}
+
+	ob.GetOrderSide(oppositeSide(side)).walk(oppositeSide(side), func(level *OrderQueue) bool {
+		if !limit(level.Price()) {
+			return false
+		}
+		quantity = quantity.Add(level.Volume())
+		notional = notional.Add(level.Volume().Mul(level.Price()))
+		return true
+	})
+	return
+}
+
+// estimate walks the opposite side from the best price taking quantity returned by take for every level
+func (ob *OrderBook) estimate(side Side, take func(level *OrderQueue, filled, spent decimal.Decimal) decimal.Decimal) *ImpactEstimate {
+	e := &ImpactEstimate{
+		Side:     side,
+		MidPrice: MidPrice(ob.bids, ob.asks),
+	}
+
+	ob.GetOrderSide(oppositeSide(side)).walk(oppositeSide(side), func(level *OrderQueue) bool {
+		quantity := take(level, e.Quantity, e.Notional)
+		if quantity.Sign() <= 0 {
+			return false
+		}
+
+		if e.Levels == 0 {
+			e.BestPrice = level.Price()
+		}
+		e.WorstPrice = level.Price()
+		e.Levels++
+		e.Quantity = e.Quantity.Add(quantity)
+		e.Notional = e.Notional.Add(quantity.Mul(level.Price()))
+		return quantity.Equal(level.Volume())
+	})
+
+	if e.Quantity.Sign() == 0 {
+		return e
+	}
+
+	e.VWAP = e.Notional.Div(e.Quantity)
+	e.SlippageBestBps = slippageBps(side, e.VWAP, e.BestPrice)
+	if e.MidPrice.Sign() > 0 {
+		e.SlippageMidBps = slippageBps(side, e.VWAP, e.MidPrice)
+	}
+	return e
+}
+
+// slippageBps returns difference between execution and reference prices in basis points,
+// positive if the execution is worse than the reference
+func slippageBps(side Side, price, reference decimal.Decimal) decimal.Decimal {
+	difference := price.Sub(reference)
+	if side == Sell {
+		difference = difference.Neg()
+	}
+	return difference.Mul(basisPoints).Div(reference)
+}
+
+// oppositeSide returns side of the orders matched by the orders of given side
+func oppositeSide(side Side) Side {
+	if side == Buy {
+		return Sell
+	}
+	return Buy
+}
@@ -0,0 +1,84 @@
+package orderbook
+
+import (
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestEstimateImpact(t *testing.T) {
+	ob := NewOrderBook()
+	addDepth(ob, "", decimal.New(2, 0))
+
+	e, err := ob.EstimateImpact(Buy, decimal.New(5, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 2@100 + 2@110 + 1@120 = 540
+	if !e.VWAP.Equal(decimal.New(108, 0)) || !e.WorstPrice.Equal(decimal.New(120, 0)) || !e.BestPrice.Equal(decimal.New(100, 0)) {
+		t.Fatal("Invalid prices", e)
+	}
+
+	if e.Levels != 3 || !e.FillRatio.Equal(decimal.New(1, 0)) || !e.Notional.Equal(decimal.New(540, 0)) {
+		t.Fatal("Invalid execution", e)
+	}
+
+	// 800 bps from the best price, (108 - 95) / 95 from the mid price
+	if !e.SlippageBestBps.Equal(decimal.New(800, 0)) || !e.SlippageMidBps.Equal(decimal.New(130000, 0).Div(decimal.New(95, 0))) {
+		t.Fatal("Invalid slippage", e.SlippageBestBps, e.SlippageMidBps)
+	}
+
+	if ob.asks.Len() != 5 {
+		t.Fatal("Estimation changes the order book")
+	}
+
+	e, _ = ob.EstimateImpact(Sell, decimal.New(20, 0))
+	if !e.FillRatio.Equal(decimal.New(5, -1)) || e.Levels != 5 || !e.WorstPrice.Equal(decimal.New(50, 0)) {
+		t.Fatal("Invalid partial execution", e)
+	}
+
+	if !e.SlippageBestBps.Equal(decimal.New(200000, 0).Div(decimal.New(90, 0))) {
+		t.Fatal("Invalid sell slippage", e.SlippageBestBps)
+	}
+
+	e, err = ob.EstimateImpactByNotional(Buy, decimal.New(310, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !e.Quantity.Equal(decimal.New(3, 0)) || e.Levels != 2 || !e.FillRatio.Equal(decimal.New(1, 0)) {
+		t.Fatal("Invalid notional execution", e)
+	}
+
+	if _, err := ob.EstimateImpact(Buy, decimal.Zero); err != ErrInvalidQuantity {
+		t.Fatal("Can estimate zero quantity")
+	}
+
+	if _, err := ob.EstimateImpactByNotional(Buy, decimal.Zero); err != ErrInvalidNotional {
+		t.Fatal("Can estimate zero notional")
+	}
+
+	if e, _ := NewOrderBook().EstimateImpact(Buy, decimal.New(1, 0)); e.Levels != 0 || e.FillRatio.Sign() != 0 {
+		t.Fatal("Invalid estimation of empty order book", e)
+	}
+}
+
+func TestQuantityWithinBps(t *testing.T) {
+	ob := NewOrderBook()
+	addDepth(ob, "", decimal.New(2, 0))
+
+	quantity, notional := ob.QuantityWithinBps(Buy, decimal.New(1000, 0))
+	if !quantity.Equal(decimal.New(4, 0)) || !notional.Equal(decimal.New(420, 0)) {
+		t.Fatal("Invalid buy quantity", quantity, notional)
+	}
+
+	quantity, _ = ob.QuantityWithinBps(Sell, decimal.Zero)
+	if !quantity.Equal(decimal.New(2, 0)) {
+		t.Fatal("Invalid sell quantity", quantity)
+	}
+
+	if quantity, _ := NewOrderBook().QuantityWithinBps(Sell, decimal.New(1, 0)); quantity.Sign() != 0 {
+		t.Fatal("Invalid quantity of empty order book")
+	}
+}
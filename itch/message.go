@@ -0,0 +1,351 @@
+// Package itch implements order-by-order binary market data stream in the style of NASDAQ ITCH.
+//
+// Every message is preceded by two byte big-endian length and starts with one
+// byte of its type followed by fixed-length fields, integers are big-endian.
+// Timestamps are nanoseconds since Unix epoch, quantities and prices are signed
+// 64-bit integers with Scale implied decimal places. Resting orders are identified
+// by reference numbers assigned by the publisher in order of their placement
+package itch
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+
+	"github.com/shopspring/decimal"
+
+	"orderbook"
+)
+
+// Scale is number of implied decimal places of quantities and prices
+const Scale = 8
+
+// MaxMessageSize is size of the longest message
+const MaxMessageSize = 64
+
+// Types of the messages
+const (
+	TypeSystemEvent   = 'S'
+	TypeAddOrder      = 'A'
+	TypeOrderExecuted = 'E'
+	TypeOrderCancel   = 'X'
+	TypeOrderReplace  = 'U'
+	TypeTrade         = 'P'
+)
+
+// Sides of the orders
+const (
+	SideBuy  = 'B'
+	SideSell = 'S'
+)
+
+// Codes of the system events, trading phases of the order book are reported as
+// events too (see PhaseEvent)
+const (
+	EventStartOfMessages = 'O'
+	EventEndOfMessages   = 'C'
+	EventContinuous      = 'Q'
+	EventAuction         = 'A'
+	EventHalted          = 'H'
+	EventPreOpen         = 'P'
+	EventClosed          = 'M'
+)
+
+// Sizes of the messages including type
+const (
+	SizeSystemEvent   = 1 + 8 + 1
+	SizeAddOrder      = 1 + 8 + 8 + 1 + 8 + 8
+	SizeOrderExecuted = 1 + 8 + 8 + 8 + 8
+	SizeOrderCancel   = 1 + 8 + 8 + 8
+	SizeOrderReplace  = 1 + 8 + 8 + 8 + 8 + 8
+	SizeTrade         = 1 + 8 + 1 + 8 + 8 + 8
+)
+
+// Errors of the codec
+var (
+	ErrShortMessage  = errors.New("itch: message is too short")
+	ErrInvalidLength = errors.New("itch: invalid message length")
+	ErrOutOfRange    = errors.New("itch: decimal value does not fit fixed point")
+)
+
+// limits of the fixed point values
+var (
+	maxFixed = decimal.NewFromInt(math.MaxInt64)
+	minFixed = decimal.NewFromInt(math.MinInt64)
+)
+
+// SystemEvent reports start and end of the stream and trading phase changes
+type SystemEvent struct {
+	Timestamp int64
+	Event     byte
+}
+
+// AddOrder reports new order placed to the book
+type AddOrder struct {
+	Timestamp int64
+	Reference uint64
+	Side      byte
+	Quantity  int64
+	Price     int64
+}
+
+// OrderExecuted reports execution of the resting order at its price, the order
+// is removed from the book when all of its quantity is executed
+type OrderExecuted struct {
+	Timestamp int64
+	Reference uint64
+	Quantity  int64
+	Match     uint64
+}
+
+// OrderCancel reports cancelled quantity of the resting order, the order is
+// removed from the book when all of its quantity is cancelled
+type OrderCancel struct {
+	Timestamp int64
+	Reference uint64
+	Quantity  int64
+}
+
+// OrderReplace reports the resting order removed from the book and replaced
+// by new order of the same side, the replacement loses time priority
+type OrderReplace struct {
+	Timestamp   int64
+	Original    uint64
+	Replacement uint64
+	Quantity    int64
+	Price       int64
+}
+
+// Trade reports every execution once. Executions of the resting orders are
+// reported by OrderExecuted with the same match number too
+type Trade struct {
+	Timestamp int64
+	Side      byte // side of the taker
+	Quantity  int64
+	Price     int64
+	Match     uint64
+}
+
+// Encode writes the message to b and returns its size
+func (m *SystemEvent) Encode(b []byte) int {
+	_ = b[SizeSystemEvent-1]
+	b[0] = TypeSystemEvent
+	binary.BigEndian.PutUint64(b[1:], uint64(m.Timestamp))
+	b[9] = m.Event
+	return SizeSystemEvent
+}
+
+// Decode reads the message from b
+func (m *SystemEvent) Decode(b []byte) error {
+	if len(b) < SizeSystemEvent || b[0] != TypeSystemEvent {
+		return ErrShortMessage
+	}
+	m.Timestamp = int64(binary.BigEndian.Uint64(b[1:]))
+	m.Event = b[9]
+	return nil
+}
+
+// Encode writes the message to b and returns its size
+func (m *AddOrder) Encode(b []byte) int {
+	_ = b[SizeAddOrder-1]
+	b[0] = TypeAddOrder
+	binary.BigEndian.PutUint64(b[1:], uint64(m.Timestamp))
+	binary.BigEndian.PutUint64(b[9:], m.Reference)
+	b[17] = m.Side
+	binary.BigEndian.PutUint64(b[18:], uint64(m.Quantity))
+	binary.BigEndian.PutUint64(b[26:], uint64(m.Price))
+	return SizeAddOrder
+}
+
+// Decode reads the message from b
+func (m *AddOrder) Decode(b []byte) error {
+	if len(b) < SizeAddOrder || b[0] != TypeAddOrder {
+		return ErrShortMessage
+	}
+	m.Timestamp = int64(binary.BigEndian.Uint64(b[1:]))
+	m.Reference = binary.BigEndian.Uint64(b[9:])
+	m.Side = b[17]
+	m.Quantity = int64(binary.BigEndian.Uint64(b[18:]))
+	m.Price = int64(binary.BigEndian.Uint64(b[26:]))
+	return nil
+}
+
+// Encode writes the message to b and returns its size
+func (m *OrderExecuted) Encode(b []byte) int {
+	_ = b[SizeOrderExecuted-1]
+	b[0] = TypeOrderExecuted
+	binary.BigEndian.PutUint64(b[1:], uint64(m.Timestamp))
+	binary.BigEndian.PutUint64(b[9:], m.Reference)
+	binary.BigEndian.PutUint64(b[17:], uint64(m.Quantity))
+	binary.BigEndian.PutUint64(b[25:], m.Match)
+	return SizeOrderExecuted
+}
+
+// Decode reads the message from b
+func (m *OrderExecuted) Decode(b []byte) error {
+	if len(b) < SizeOrderExecuted || b[0] != TypeOrderExecuted {
+		return ErrShortMessage
+	}
+	m.Timestamp = int64(binary.BigEndian.Uint64(b[1:]))
+	m.Reference = binary.BigEndian.Uint64(b[9:])
+	m.Quantity = int64(binary.BigEndian.Uint64(b[17:]))
+	m.Match = binary.BigEndian.Uint64(b[25:])
+	return nil
+}
+
+// Encode writes the message to b and returns its size
+func (m *OrderCancel) Encode(b []byte) int {
+	_ = b[SizeOrderCancel-1]
+	b[0] = TypeOrderCancel
+	binary.BigEndian.PutUint64(b[1:], uint64(m.Timestamp))
+	binary.BigEndian.PutUint64(b[9:], m.Reference)
+	binary.BigEndian.PutUint64(b[17:], uint64(m.Quantity))
+	return SizeOrderCancel
+}
+
+// Decode reads the message from b
+func (m *OrderCancel) Decode(b []byte) error {
+	if len(b) < SizeOrderCancel || b[0] != TypeOrderCancel {
+		return ErrShortMessage
+	}
+	m.Timestamp = int64(binary.BigEndian.Uint64(b[1:]))
+	m.Reference = binary.BigEndian.Uint64(b[9:])
+	m.Quantity = int64(binary.BigEndian.Uint64(b[17:]))
+	return nil
+}
+
+// Encode writes the message to b and returns its size
+func (m *OrderReplace) Encode(b []byte) int {
+	_ = b[SizeOrderReplace-1]
+	b[0] = TypeOrderReplace
+	binary.BigEndian.PutUint64(b[1:], uint64(m.Timestamp))
+	binary.BigEndian.PutUint64(b[9:], m.Original)
+	binary.BigEndian.PutUint64(b[17:], m.Replacement)
+	binary.BigEndian.PutUint64(b[25:], uint64(m.Quantity))
+	binary.BigEndian.PutUint64(b[33:], uint64(m.Price))
+	return SizeOrderReplace
+}
+
+// Decode reads the message from b
+func (m *OrderReplace) Decode(b []byte) error {
+	if len(b) < SizeOrderReplace || b[0] != TypeOrderReplace {
+		return ErrShortMessage
+	}
+	m.Timestamp = int64(binary.BigEndian.Uint64(b[1:]))
+	m.Original = binary.BigEndian.Uint64(b[9:])
+	m.Replacement = binary.BigEndian.Uint64(b[17:])
+	m.Quantity = int64(binary.BigEndian.Uint64(b[25:]))
+	m.Price = int64(binary.BigEndian.Uint64(b[33:]))
+	return nil
+}
+
+// Encode writes the message to b and returns its size
+func (m *Trade) Encode(b []byte) int {
+	_ = b[SizeTrade-1]
+	b[0] = TypeTrade
+	binary.BigEndian.PutUint64(b[1:], uint64(m.Timestamp))
+	b[9] = m.Side
+	binary.BigEndian.PutUint64(b[10:], uint64(m.Quantity))
+	binary.BigEndian.PutUint64(b[18:], uint64(m.Price))
+	binary.BigEndian.PutUint64(b[26:], m.Match)
+	return SizeTrade
+}
+
+// Decode reads the message from b
+func (m *Trade) Decode(b []byte) error {
+	if len(b) < SizeTrade || b[0] != TypeTrade {
+		return ErrShortMessage
+	}
+	m.Timestamp = int64(binary.BigEndian.Uint64(b[1:]))
+	m.Side = b[9]
+	m.Quantity = int64(binary.BigEndian.Uint64(b[10:]))
+	m.Price = int64(binary.BigEndian.Uint64(b[18:]))
+	m.Match = binary.BigEndian.Uint64(b[26:])
+	return nil
+}
+
+// ReadMessage reads single length-prefixed message into buf which must be at
+// least MaxMessageSize long and returns the message slice of buf. Messages of
+// unknown types are returned as is, consumers should skip them
+func ReadMessage(r *bufio.Reader, buf []byte) ([]byte, error) {
+	var prefix [2]byte
+	if _, err := io.ReadFull(r, prefix[:]); err != nil {
+		return nil, err
+	}
+
+	n := int(binary.BigEndian.Uint16(prefix[:]))
+	if n == 0 || n > MaxMessageSize {
+		return nil, ErrInvalidLength
+	}
+
+	if _, err := io.ReadFull(r, buf[:n]); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return buf[:n], nil
+}
+
+// PhaseEvent returns system event code of the trading phase
+func PhaseEvent(phase orderbook.Phase) byte {
+	switch phase {
+	case orderbook.Auction:
+		return EventAuction
+	case orderbook.Halted:
+		return EventHalted
+	case orderbook.PreOpen:
+		return EventPreOpen
+	case orderbook.Closed:
+		return EventClosed
+	default:
+		return EventContinuous
+	}
+}
+
+// EventPhase returns trading phase of the system event code
+// Return:
+//
+//	bool - false if the event is not a trading phase change
+func EventPhase(event byte) (orderbook.Phase, bool) {
+	switch event {
+	case EventContinuous:
+		return orderbook.Continuous, true
+	case EventAuction:
+		return orderbook.Auction, true
+	case EventHalted:
+		return orderbook.Halted, true
+	case EventPreOpen:
+		return orderbook.PreOpen, true
+	case EventClosed:
+		return orderbook.Closed, true
+	}
+	return orderbook.Continuous, false
+}
+
+// ToDecimal converts fixed point value to decimal
+func ToDecimal(v int64) decimal.Decimal {
+	return decimal.New(v, -Scale)
+}
+
+// FromDecimal converts decimal to fixed point value
+// Return:
+//
+//	error - ErrOutOfRange if the value has more than Scale decimal places or does not fit int64
+func FromDecimal(d decimal.Decimal) (int64, error) {
+	shifted := d.Shift(Scale)
+	if !shifted.IsInteger() || shifted.GreaterThan(maxFixed) || shifted.LessThan(minFixed) {
+		return 0, ErrOutOfRange
+	}
+	return shifted.IntPart(), nil
+}
+
+// side converts side of the order to side of the message
+func side(s orderbook.Side) byte {
+	if s == orderbook.Buy {
+		return SideBuy
+	}
+	return SideSell
+}
@@ -0,0 +1,63 @@
+package itch
+
+import (
+	"bufio"
+	"bytes"
+	"testing"
+
+	"orderbook"
+)
+
+func TestMessages(t *testing.T) {
+	buf := make([]byte, MaxMessageSize)
+
+	add := AddOrder{Timestamp: 1, Reference: 2, Side: SideSell, Quantity: 3, Price: -4}
+	var addDecoded AddOrder
+	if n := add.Encode(buf); n != SizeAddOrder || addDecoded.Decode(buf[:n]) != nil || addDecoded != add {
+		t.Fatal("Invalid add order round trip", addDecoded)
+	}
+
+	replace := OrderReplace{Timestamp: 1, Original: 2, Replacement: 3, Quantity: 4, Price: 5}
+	var replaceDecoded OrderReplace
+	if n := replace.Encode(buf); n != SizeOrderReplace || replaceDecoded.Decode(buf[:n]) != nil || replaceDecoded != replace {
+		t.Fatal("Invalid order replace round trip", replaceDecoded)
+	}
+
+	trade := Trade{Timestamp: 1, Side: SideBuy, Quantity: 2, Price: 3, Match: 4}
+	var tradeDecoded Trade
+	if n := trade.Encode(buf); n != SizeTrade || tradeDecoded.Decode(buf[:n]) != nil || tradeDecoded != trade {
+		t.Fatal("Invalid trade round trip", tradeDecoded)
+	}
+
+	if err := addDecoded.Decode(buf[:SizeTrade]); err != ErrShortMessage {
+		t.Fatal("Message of other type is decoded", err)
+	}
+
+	for _, phase := range []orderbook.Phase{orderbook.Continuous, orderbook.Auction, orderbook.Halted, orderbook.PreOpen, orderbook.Closed} {
+		if p, ok := EventPhase(PhaseEvent(phase)); !ok || p != phase {
+			t.Fatal("Invalid phase event", phase, p)
+		}
+	}
+
+	if _, ok := EventPhase(EventStartOfMessages); ok {
+		t.Fatal("Start of messages is phase event")
+	}
+}
+
+func TestReadMessage(t *testing.T) {
+	buf := make([]byte, MaxMessageSize)
+
+	r := bufio.NewReader(bytes.NewReader([]byte{0, 2, 'Z', 1, 0, 0}))
+	if msg, err := ReadMessage(r, buf); err != nil || !bytes.Equal(msg, []byte{'Z', 1}) {
+		t.Fatal("Invalid message", msg, err)
+	}
+
+	if _, err := ReadMessage(r, buf); err != ErrInvalidLength {
+		t.Fatal("Empty message is read", err)
+	}
+
+	r = bufio.NewReader(bytes.NewReader([]byte{0, 10, 'S', 1}))
+	if _, err := ReadMessage(r, buf); err == nil {
+		t.Fatal("Truncated message is read")
+	}
+}
@@ -0,0 +1,229 @@
+package itch
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"orderbook"
+)
+
+// resting is reported order in the book
+type resting struct {
+	reference uint64
+	quantity  decimal.Decimal
+}
+
+// cancelled is the last cancelled order which is reported as OrderReplace if
+// the next change of the book is new order of the same owner and side
+type cancelled struct {
+	reference uint64
+	order     *orderbook.Order
+	timestamp int64
+}
+
+// Publisher writes market data stream of the order book. Messages are buffered
+// until Flush, the publisher must be used from the goroutine which changes the
+// book (see orderbook.Engine). Cancel followed by new order of the same owner
+// and side before any other change or Flush is reported as OrderReplace
+type Publisher struct {
+	w    *bufio.Writer
+	buf  [2 + MaxMessageSize]byte
+	refs map[string]*resting // orderID -> reported order
+	next uint64
+	last *cancelled
+	err  error
+}
+
+// NewPublisher creates publisher of the order book changes. The stream starts
+// with current trading phase and all resting orders in their priority
+// Arguments:
+//
+//	ob - order book to publish
+//	w  - destination of the stream
+func NewPublisher(ob *orderbook.OrderBook, w io.Writer) *Publisher {
+	p := &Publisher{
+		w:    bufio.NewWriter(w),
+		refs: map[string]*resting{},
+	}
+
+	now := time.Now().UnixNano()
+	p.frame((&SystemEvent{Timestamp: now, Event: EventStartOfMessages}).Encode(p.buf[2:]))
+	p.frame((&SystemEvent{Timestamp: now, Event: PhaseEvent(ob.Phase())}).Encode(p.buf[2:]))
+
+	for _, os := range [2]*orderbook.OrderSide{ob.GetOrderSide(orderbook.Sell), ob.GetOrderSide(orderbook.Buy)} {
+		for level := os.MaxPriceQueue(); level != nil; level = os.LessThan(level.Price()) {
+			for e := level.Head(); e != nil; e = e.Next() {
+				o := e.Value.(*orderbook.Order)
+				p.add(o, o.Time().UnixNano())
+			}
+		}
+	}
+
+	ob.OnOrderEvent(p.onOrderEvent)
+	ob.OnTrade(p.onTrade)
+	ob.OnPhaseChange(p.onPhaseChange)
+	return p
+}
+
+// Flush writes buffered messages to the destination
+// Return:
+//
+//	error - the first error of encoding or writing, the stream is broken after it
+func (p *Publisher) Flush() error {
+	p.flushCancel()
+	if p.err != nil {
+		return p.err
+	}
+
+	p.err = p.w.Flush()
+	return p.err
+}
+
+// Close writes end of the stream and flushes it, the publisher keeps
+// receiving book changes but writes nothing after Close
+func (p *Publisher) Close() error {
+	p.flushCancel()
+	p.frame((&SystemEvent{Timestamp: time.Now().UnixNano(), Event: EventEndOfMessages}).Encode(p.buf[2:]))
+
+	err := p.Flush()
+	if p.err == nil {
+		p.err = io.ErrClosedPipe
+	}
+	return err
+}
+
+func (p *Publisher) onOrderEvent(ev *orderbook.OrderEvent) {
+	o := ev.Order
+
+	switch ev.Status {
+	case orderbook.OrderNew:
+		if p.last != nil && p.last.order.Owner() == o.Owner() && p.last.order.Side() == o.Side() {
+			p.replace(p.last.reference, o, ev.Time.UnixNano())
+			p.last = nil
+			return
+		}
+		p.flushCancel()
+		p.add(o, ev.Time.UnixNano())
+	case orderbook.OrderFilled:
+		delete(p.refs, o.ID())
+	case orderbook.OrderReduced:
+		p.flushCancel()
+		r, ok := p.refs[o.ID()]
+		if !ok {
+			return
+		}
+		p.frame((&OrderCancel{
+			Timestamp: ev.Time.UnixNano(),
+			Reference: r.reference,
+			Quantity:  p.fixed(r.quantity.Sub(o.Quantity())),
+		}).Encode(p.buf[2:]))
+		r.quantity = o.Quantity()
+	case orderbook.OrderCancelled:
+		p.flushCancel()
+		r, ok := p.refs[o.ID()]
+		if !ok {
+			return
+		}
+		delete(p.refs, o.ID())
+		p.last = &cancelled{reference: r.reference, order: o, timestamp: ev.Time.UnixNano()}
+	}
+}
+
+func (p *Publisher) onTrade(t *orderbook.Trade) {
+	p.flushCancel()
+
+	quantity, price := p.fixed(t.Quantity), p.fixed(t.Price)
+	timestamp := t.Timestamp.UnixNano()
+
+	// the taker is resting only in the auction uncross
+	for _, id := range [2]string{t.MakerOrderID, t.TakerOrderID} {
+		if r, ok := p.refs[id]; ok {
+			r.quantity = r.quantity.Sub(t.Quantity)
+			p.frame((&OrderExecuted{
+				Timestamp: timestamp,
+				Reference: r.reference,
+				Quantity:  quantity,
+				Match:     t.ID,
+			}).Encode(p.buf[2:]))
+		}
+	}
+
+	p.frame((&Trade{
+		Timestamp: timestamp,
+		Side:      side(t.TakerSide),
+		Quantity:  quantity,
+		Price:     price,
+		Match:     t.ID,
+	}).Encode(p.buf[2:]))
+}
+
+func (p *Publisher) onPhaseChange(ev *orderbook.PhaseEvent) {
+	p.flushCancel()
+	p.frame((&SystemEvent{Timestamp: ev.Time.UnixNano(), Event: PhaseEvent(ev.To)}).Encode(p.buf[2:]))
+}
+
+// add assigns reference number to the resting order and reports it
+func (p *Publisher) add(o *orderbook.Order, timestamp int64) {
+	p.next++
+	p.refs[o.ID()] = &resting{reference: p.next, quantity: o.Quantity()}
+
+	p.frame((&AddOrder{
+		Timestamp: timestamp,
+		Reference: p.next,
+		Side:      side(o.Side()),
+		Quantity:  p.fixed(o.Quantity()),
+		Price:     p.fixed(o.Price()),
+	}).Encode(p.buf[2:]))
+}
+
+// replace assigns reference number to the order replacing cancelled one
+func (p *Publisher) replace(original uint64, o *orderbook.Order, timestamp int64) {
+	p.next++
+	p.refs[o.ID()] = &resting{reference: p.next, quantity: o.Quantity()}
+
+	p.frame((&OrderReplace{
+		Timestamp:   timestamp,
+		Original:    original,
+		Replacement: p.next,
+		Quantity:    p.fixed(o.Quantity()),
+		Price:       p.fixed(o.Price()),
+	}).Encode(p.buf[2:]))
+}
+
+// flushCancel reports the last cancelled order which is not replaced
+func (p *Publisher) flushCancel() {
+	if p.last == nil {
+		return
+	}
+
+	c := p.last
+	p.last = nil
+	p.frame((&OrderCancel{
+		Timestamp: c.timestamp,
+		Reference: c.reference,
+		Quantity:  p.fixed(c.order.Quantity()),
+	}).Encode(p.buf[2:]))
+}
+
+// fixed converts decimal to fixed point and remembers conversion error
+func (p *Publisher) fixed(d decimal.Decimal) int64 {
+	v, err := FromDecimal(d)
+	if err != nil && p.err == nil {
+		p.err = err
+	}
+	return v
+}
+
+// frame writes the message encoded to buf with its length prefix
+func (p *Publisher) frame(n int) {
+	if p.err != nil {
+		return
+	}
+
+	binary.BigEndian.PutUint16(p.buf[:2], uint16(n))
+	_, p.err = p.w.Write(p.buf[:2+n])
+}
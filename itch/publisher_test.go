@@ -0,0 +1,79 @@
+package itch
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/shopspring/decimal"
+
+	"orderbook"
+)
+
+func TestPublisher(t *testing.T) {
+	ob := orderbook.NewOrderBook()
+	ob.ProcessLimitOrderWithOwner(orderbook.Sell, "resting", "a", decimal.New(1, 0), decimal.New(100, 0))
+
+	var out bytes.Buffer
+	p := NewPublisher(ob, &out)
+
+	ob.ProcessLimitOrderWithOwner(orderbook.Buy, "bid", "b", decimal.New(2, 0), decimal.New(99, 0))
+	ob.ProcessLimitOrderWithOwner(orderbook.Buy, "taker", "c", decimal.New(3, 0), decimal.New(100, 0))
+
+	// cancel and new order of the same owner and side is replace
+	ob.CancelOrder("bid")
+	ob.ProcessLimitOrderWithOwner(orderbook.Buy, "bid2", "b", decimal.New(1, 0), decimal.New(98, 0))
+
+	ob.CancelOrder("bid2")
+	p.Flush()
+	ob.ProcessLimitOrderWithOwner(orderbook.Buy, "bid3", "b", decimal.New(1, 0), decimal.New(98, 0))
+	ob.Halt("test")
+
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		types []string
+		buf   = make([]byte, MaxMessageSize)
+		r     = bufio.NewReader(&out)
+	)
+
+	for {
+		msg, err := ReadMessage(r, buf)
+		if err != nil {
+			break
+		}
+
+		types = append(types, string(msg[0]))
+		switch msg[0] {
+		case TypeOrderExecuted:
+			var m OrderExecuted
+			m.Decode(msg)
+			if m.Reference != 1 || !ToDecimal(m.Quantity).Equal(decimal.New(1, 0)) || m.Match != 1 {
+				t.Fatal("Invalid execution", m)
+			}
+		case TypeOrderReplace:
+			var m OrderReplace
+			m.Decode(msg)
+			if m.Original != 2 || m.Replacement != 4 || !ToDecimal(m.Price).Equal(decimal.New(98, 0)) {
+				t.Fatal("Invalid replace", m)
+			}
+		case TypeOrderCancel:
+			var m OrderCancel
+			m.Decode(msg)
+			if m.Reference != 4 {
+				t.Fatal("Invalid cancel", m)
+			}
+		}
+	}
+
+	if s := strings.Join(types, " "); s != "S S A A E P A U X A S S" {
+		t.Fatal("Invalid stream", s)
+	}
+
+	if err := p.Flush(); err == nil {
+		t.Fatal("Closed publisher is flushed")
+	}
+}
@@ -0,0 +1,230 @@
+// Package rebuild maintains order book from ITCH style market data stream
+// (see package itch). The rebuilt book reports the same depth as the source one
+package rebuild
+
+import (
+	"bufio"
+	"container/list"
+	"errors"
+	"io"
+	"strconv"
+	"time"
+
+	"orderbook"
+	"orderbook/itch"
+)
+
+// Errors of the stream
+var (
+	ErrUnknownOrder   = errors.New("rebuild: unknown order reference")
+	ErrOrderExists    = errors.New("rebuild: order reference already exists")
+	ErrInvalidSide    = errors.New("rebuild: invalid order side")
+	ErrExcessQuantity = errors.New("rebuild: quantity exceeds the order")
+)
+
+// Book is order book view built from the market data messages. Orders of the
+// book have decimal reference numbers as IDs and no owners
+type Book struct {
+	orders map[uint64]*list.Element
+	asks   *orderbook.OrderSide
+	bids   *orderbook.OrderSide
+	phase  orderbook.Phase
+	trade  *itch.Trade
+	ended  bool
+}
+
+// NewBook creates empty book
+func NewBook() *Book {
+	return &Book{
+		orders: map[uint64]*list.Element{},
+		asks:   orderbook.NewOrderSide(),
+		bids:   orderbook.NewOrderSide(),
+	}
+}
+
+// Replay applies all messages of the stream until its end or EOF
+// Return:
+//
+//	error - not nil if the stream is broken or inconsistent with the book
+func (b *Book) Replay(r io.Reader) error {
+	br, ok := r.(*bufio.Reader)
+	if !ok {
+		br = bufio.NewReader(r)
+	}
+
+	buf := make([]byte, itch.MaxMessageSize)
+	for !b.ended {
+		msg, err := itch.ReadMessage(br, buf)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := b.Apply(msg); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Apply changes the book by single message, messages of unknown types are skipped
+func (b *Book) Apply(msg []byte) error {
+	if len(msg) == 0 {
+		return itch.ErrShortMessage
+	}
+
+	switch msg[0] {
+	case itch.TypeSystemEvent:
+		var m itch.SystemEvent
+		if err := m.Decode(msg); err != nil {
+			return err
+		}
+		if phase, ok := itch.EventPhase(m.Event); ok {
+			b.phase = phase
+		}
+		if m.Event == itch.EventEndOfMessages {
+			b.ended = true
+		}
+	case itch.TypeAddOrder:
+		var m itch.AddOrder
+		if err := m.Decode(msg); err != nil {
+			return err
+		}
+		var side orderbook.Side
+		switch m.Side {
+		case itch.SideBuy:
+			side = orderbook.Buy
+		case itch.SideSell:
+			side = orderbook.Sell
+		default:
+			return ErrInvalidSide
+		}
+		return b.add(m.Reference, side, m.Quantity, m.Price, m.Timestamp)
+	case itch.TypeOrderExecuted:
+		var m itch.OrderExecuted
+		if err := m.Decode(msg); err != nil {
+			return err
+		}
+		return b.reduce(m.Reference, m.Quantity)
+	case itch.TypeOrderCancel:
+		var m itch.OrderCancel
+		if err := m.Decode(msg); err != nil {
+			return err
+		}
+		return b.reduce(m.Reference, m.Quantity)
+	case itch.TypeOrderReplace:
+		var m itch.OrderReplace
+		if err := m.Decode(msg); err != nil {
+			return err
+		}
+		return b.replace(&m)
+	case itch.TypeTrade:
+		var m itch.Trade
+		if err := m.Decode(msg); err != nil {
+			return err
+		}
+		b.trade = &m
+	}
+
+	return nil
+}
+
+// Depth returns price levels and volume at price level in the same order as orderbook.OrderBook
+func (b *Book) Depth() (asks, bids []*orderbook.PriceLevel) {
+	for level := b.asks.MaxPriceQueue(); level != nil; level = b.asks.LessThan(level.Price()) {
+		asks = append(asks, &orderbook.PriceLevel{Price: level.Price(), Quantity: level.Volume()})
+	}
+
+	for level := b.bids.MaxPriceQueue(); level != nil; level = b.bids.LessThan(level.Price()) {
+		bids = append(bids, &orderbook.PriceLevel{Price: level.Price(), Quantity: level.Volume()})
+	}
+	return
+}
+
+// Order returns resting order by reference number
+func (b *Book) Order(reference uint64) *orderbook.Order {
+	e, ok := b.orders[reference]
+	if !ok {
+		return nil
+	}
+
+	return e.Value.(*orderbook.Order)
+}
+
+// Len returns number of resting orders
+func (b *Book) Len() int {
+	return len(b.orders)
+}
+
+// Phase returns the last reported trading phase
+func (b *Book) Phase() orderbook.Phase {
+	return b.phase
+}
+
+// LastTrade returns the last reported trade or nil
+func (b *Book) LastTrade() *itch.Trade {
+	return b.trade
+}
+
+// Ended reports whether end of the stream is received
+func (b *Book) Ended() bool {
+	return b.ended
+}
+
+func (b *Book) add(reference uint64, side orderbook.Side, quantity, price, timestamp int64) error {
+	if _, ok := b.orders[reference]; ok {
+		return ErrOrderExists
+	}
+
+	o := orderbook.NewOrder(strconv.FormatUint(reference, 10), side, itch.ToDecimal(quantity), itch.ToDecimal(price), time.Unix(0, timestamp))
+	b.orders[reference] = b.side(side).Append(o)
+	return nil
+}
+
+// reduce removes executed or cancelled quantity of the order
+func (b *Book) reduce(reference uint64, quantity int64) error {
+	e, ok := b.orders[reference]
+	if !ok {
+		return ErrUnknownOrder
+	}
+
+	o := e.Value.(*orderbook.Order)
+	left := o.Quantity().Sub(itch.ToDecimal(quantity))
+
+	switch left.Sign() {
+	case -1:
+		return ErrExcessQuantity
+	case 0:
+		delete(b.orders, reference)
+		b.side(o.Side()).Remove(e)
+	default:
+		b.side(o.Side()).Update(e, orderbook.NewOrder(o.ID(), o.Side(), left, o.Price(), o.Time()))
+	}
+
+	return nil
+}
+
+func (b *Book) replace(m *itch.OrderReplace) error {
+	e, ok := b.orders[m.Original]
+	if !ok {
+		return ErrUnknownOrder
+	}
+	if _, ok := b.orders[m.Replacement]; ok {
+		return ErrOrderExists
+	}
+
+	o := e.Value.(*orderbook.Order)
+	delete(b.orders, m.Original)
+	b.side(o.Side()).Remove(e)
+	return b.add(m.Replacement, o.Side(), m.Quantity, m.Price, m.Timestamp)
+}
+
+func (b *Book) side(s orderbook.Side) *orderbook.OrderSide {
+	if s == orderbook.Buy {
+		return b.bids
+	}
+	return b.asks
+}
@@ -0,0 +1,146 @@
+package rebuild
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/shopspring/decimal"
+
+	"orderbook"
+	"orderbook/itch"
+)
+
+func sameDepth(a, b []*orderbook.PriceLevel) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if !a[i].Price.Equal(b[i].Price) || !a[i].Quantity.Equal(b[i].Quantity) {
+			return false
+		}
+	}
+	return true
+}
+
+func TestRoundTrip(t *testing.T) {
+	ob := orderbook.NewOrderBook()
+	for i := 0; i < 10; i++ {
+		ob.ProcessLimitOrderWithOwner(orderbook.Sell, fmt.Sprintf("initial-%d", i), "a", decimal.New(int64(i+1), -1), decimal.New(int64(101+i), 0))
+	}
+
+	engine := orderbook.NewEngine(ob)
+	defer engine.Close()
+
+	var (
+		stream    bytes.Buffer
+		publisher *itch.Publisher
+	)
+
+	engine.Do(func(ob *orderbook.OrderBook) {
+		publisher = itch.NewPublisher(ob, &stream)
+		engine.OnCommand(func(*orderbook.OrderBook) { publisher.Flush() })
+	})
+
+	book := NewBook()
+	rnd := rand.New(rand.NewSource(1))
+	owners := []string{"a", "b", "c"}
+	var placed []string
+
+	for i := 0; i < 3000; i++ {
+		var (
+			asks, bids []*orderbook.PriceLevel
+			phase      orderbook.Phase
+		)
+
+		engine.Do(func(ob *orderbook.OrderBook) {
+			side := orderbook.Side(rnd.Intn(2))
+			owner := owners[rnd.Intn(len(owners))]
+			quantity := decimal.New(int64(rnd.Intn(50)+1), -1)
+			price := decimal.New(int64(180+rnd.Intn(40)), -1).Mul(decimal.New(5, 0))
+			id := fmt.Sprintf("order-%d", i)
+
+			switch n := rnd.Intn(100); {
+			case n < 55:
+				ob.ProcessLimitOrderWithOwner(side, id, owner, quantity, price)
+				placed = append(placed, id)
+			case n < 65:
+				ob.ProcessMarketOrderWithOwner(side, owner, quantity)
+			case n < 80 && len(placed) > 0:
+				ob.CancelOrder(placed[rnd.Intn(len(placed))])
+			case n < 85 && len(placed) > 0:
+				if o := ob.Order(placed[rnd.Intn(len(placed))]); o != nil {
+					ob.ReduceOrder(o.ID(), decimal.Min(quantity, o.Quantity()))
+				}
+			case n < 95 && len(placed) > 0:
+				// amend loses priority: cancel and place in one command
+				if o, err := ob.CancelOrder(placed[rnd.Intn(len(placed))]); err == nil {
+					ob.ProcessLimitOrderWithOwner(o.Side(), id, o.Owner(), quantity, price)
+					placed = append(placed, id)
+				}
+			case n < 97:
+				ob.SetPhase(orderbook.Auction, "auction")
+			case n < 98:
+				ob.Halt("halt")
+			default:
+				ob.SetPhase(orderbook.Continuous, "continuous")
+			}
+
+			asks, bids = ob.Depth()
+			phase = ob.Phase()
+		})
+
+		if err := book.Replay(&stream); err != nil {
+			t.Fatal("Invalid stream at step", i, err)
+		}
+
+		rebuiltAsks, rebuiltBids := book.Depth()
+		if !sameDepth(asks, rebuiltAsks) || !sameDepth(bids, rebuiltBids) {
+			t.Fatal("Invalid rebuilt depth at step", i, asks, rebuiltAsks, bids, rebuiltBids)
+		}
+
+		if book.Phase() != phase {
+			t.Fatal("Invalid rebuilt phase at step", i, phase, book.Phase())
+		}
+	}
+
+	engine.Do(func(*orderbook.OrderBook) { publisher.Close() })
+	if err := book.Replay(&stream); err != nil || !book.Ended() {
+		t.Fatal("Stream is not ended", err)
+	}
+
+	if book.LastTrade() == nil {
+		t.Fatal("No trades in the stream")
+	}
+}
+
+func TestBookErrors(t *testing.T) {
+	book := NewBook()
+	buf := make([]byte, itch.MaxMessageSize)
+
+	n := (&itch.AddOrder{Reference: 1, Side: itch.SideBuy, Quantity: 1e8, Price: 1e8}).Encode(buf)
+	if err := book.Apply(buf[:n]); err != nil || book.Len() != 1 || book.Order(1) == nil {
+		t.Fatal("Order is not added", err)
+	}
+
+	if err := book.Apply(buf[:n]); err != ErrOrderExists {
+		t.Fatal("Duplicate reference is added", err)
+	}
+
+	n = (&itch.OrderExecuted{Reference: 2, Quantity: 1}).Encode(buf)
+	if err := book.Apply(buf[:n]); err != ErrUnknownOrder {
+		t.Fatal("Unknown order is executed", err)
+	}
+
+	n = (&itch.OrderCancel{Reference: 1, Quantity: 2e8}).Encode(buf)
+	if err := book.Apply(buf[:n]); err != ErrExcessQuantity {
+		t.Fatal("Excess quantity is cancelled", err)
+	}
+
+	n = (&itch.OrderCancel{Reference: 1, Quantity: 1e8}).Encode(buf)
+	if err := book.Apply(buf[:n]); err != nil || book.Len() != 0 {
+		t.Fatal("Order is not cancelled", err)
+	}
+}
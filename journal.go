@@ -0,0 +1,69 @@
+package orderbook
+
+import (
+	"github.com/shopspring/decimal"
+)
+
+// JournalEntry is the command changing the order book. Method is the name of the
+// OrderBook method (or Ledger method for deposits and withdrawals), fields not
+// used by the method are empty. Deposits and withdrawals keep the base asset in
+// Quantity and the quote currency in Notional
+type JournalEntry struct {
+	Method     string
+	Side       Side
+	OrderID    string
+	NewOrderID string
+	Owner      string
+	Quantity   decimal.Decimal
+	Price      decimal.Decimal
+	HighPrice  decimal.Decimal
+	Notional   decimal.Decimal
+	Protection *MarketProtection
+	Phase      Phase
+	Reason     string
+	Schedule   []ScheduledPhase
+}
+
+// Journal writes commands ahead before they change the order book, so the book
+// can be restored by replaying them (see package persist). Begin is called before
+// the command is executed and the command is rejected with its error, End is called
+// after the command. Commands called by other commands are not written.
+// Configuration (SetPriceBand, SetCircuitBreaker, SetFeeModel, SetRiskCheck,
+// SetPositionKeeper, FeeSchedule.SetTiers, PositionKeeper.SetLimit) is not written,
+// the trading schedule (SetSchedule) and Ledger deposits and withdrawals are
+type Journal interface {
+	Begin(entry JournalEntry) error
+	End()
+}
+
+// SetJournal sets journal of the order book commands, nil disables it
+func (ob *OrderBook) SetJournal(journal Journal) {
+	ob.journal = journal
+}
+
+// noJournal ends the command which is not written to the journal
+func noJournal() {}
+
+// journaled begins the command in the journal unless it is called by another command
+// Return:
+//
+//	end   - function to call after the command
+//	error - error of the journal, the command should not be executed then
+func (ob *OrderBook) journaled(entry JournalEntry) (end func(), err error) {
+	if ob.journal == nil || ob.journaling {
+		return noJournal, nil
+	}
+
+	if err := ob.journal.Begin(entry); err != nil {
+		return noJournal, err
+	}
+
+	ob.journaling = true
+	return ob.endJournal, nil
+}
+
+// endJournal ends the command written to the journal
+func (ob *OrderBook) endJournal() {
+	ob.journaling = false
+	ob.journal.End()
+}
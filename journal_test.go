@@ -0,0 +1,52 @@
+package orderbook
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+// recordingJournal keeps methods of the journaled commands
+type recordingJournal struct {
+	methods []string
+	open    bool
+	err     error
+}
+
+func (j *recordingJournal) Begin(entry JournalEntry) error {
+	if j.err != nil {
+		return j.err
+	}
+	j.methods = append(j.methods, entry.Method)
+	j.open = true
+	return nil
+}
+
+func (j *recordingJournal) End() {
+	j.open = false
+}
+
+func TestJournal(t *testing.T) {
+	ob := NewOrderBook()
+	journal := &recordingJournal{}
+	ob.SetJournal(journal)
+
+	ob.ProcessLimitOrder(Sell, "s1", decimal.New(1, 0), decimal.New(100, 0))
+	ob.ProcessProtectedMarketOrder(Buy, "b1", "", decimal.New(2, 0), MarketProtection{Percent: decimal.New(1, 0), Rest: true})
+	ob.Halt("news")
+	ob.CancelAll()
+
+	if len(journal.methods) != 4 || journal.methods[0] != "ProcessLimitOrderWithOwner" ||
+		journal.methods[1] != "ProcessProtectedMarketOrder" || journal.methods[2] != "SetPhase" || journal.methods[3] != "CancelAll" {
+		t.Fatal("Invalid journaled commands", journal.methods)
+	}
+	if journal.open {
+		t.Fatal("Command is not ended")
+	}
+
+	journal.err = errors.New("journal is full")
+	if _, _, _, err := ob.ProcessLimitOrder(Sell, "s2", decimal.New(1, 0), decimal.New(100, 0)); err != journal.err || ob.Order("s2") != nil {
+		t.Fatal("Command is executed without the journal", err)
+	}
+}
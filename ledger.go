@@ -0,0 +1,235 @@
+package orderbook
+
+import (
+	"sync"
+
+	"github.com/shopspring/decimal"
+)
+
+// Balance of the asset, reserved funds belong to the orders in the order book
+type Balance struct {
+	Total    decimal.Decimal `json:"total"`
+	Reserved decimal.Decimal `json:"reserved"`
+}
+
+// Available returns funds which can be used by new orders or withdrawn
+func (b Balance) Available() decimal.Decimal {
+	return b.Total.Sub(b.Reserved)
+}
+
+// reservationKey identifies reservation of the order
+type reservationKey struct {
+	owner   string
+	orderID string
+}
+
+// reservation keeps funds reserved for the order
+type reservation struct {
+	side   Side
+	price  decimal.Decimal // limit price, zero for market orders
+	amount decimal.Decimal // quote for buy orders, base for sell orders
+}
+
+// ledgerAccount holds balances of the account
+type ledgerAccount struct {
+	base  Balance
+	quote Balance
+}
+
+// Ledger implements RiskCheck with account balances of the base asset and the quote currency.
+// Buy orders reserve price × quantity of the quote, sell orders reserve quantity of the base.
+// Trades move funds between accounts, fees are not settled by the Ledger
+type Ledger struct {
+	mu sync.Mutex
+
+	accounts     map[string]*ledgerAccount
+	reservations map[reservationKey]*reservation
+	journal      Journal
+}
+
+// NewLedger creates empty ledger
+func NewLedger() *Ledger {
+	return &Ledger{
+		accounts:     map[string]*ledgerAccount{},
+		reservations: map[reservationKey]*reservation{},
+	}
+}
+
+// SetJournal sets journal of deposits and withdrawals, nil disables it. Journaled
+// deposits and withdrawals should be called from the Engine goroutine
+func (l *Ledger) SetJournal(journal Journal) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.journal = journal
+}
+
+// journaled begins the deposit or withdrawal in the journal
+// Return:
+//
+//	end   - function to call after the command
+//	error - error of the journal, the command should not be executed then
+func (l *Ledger) journaled(entry JournalEntry) (end func(), err error) {
+	l.mu.Lock()
+	journal := l.journal
+	l.mu.Unlock()
+
+	if journal == nil {
+		return noJournal, nil
+	}
+
+	if err := journal.Begin(entry); err != nil {
+		return noJournal, err
+	}
+
+	return journal.End, nil
+}
+
+// Deposit adds funds to the account
+// Return:
+//
+//	error - error of the journal, the funds are not added then
+func (l *Ledger) Deposit(account string, base, quote decimal.Decimal) error {
+	end, err := l.journaled(JournalEntry{Method: "Deposit", Owner: account, Quantity: base, Notional: quote})
+	if err != nil {
+		return err
+	}
+	defer end()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	a := l.account(account)
+	a.base.Total = a.base.Total.Add(base)
+	a.quote.Total = a.quote.Total.Add(quote)
+	return nil
+}
+
+// Withdraw removes available funds from the account
+// Return:
+//
+//	error - ErrInsufficientFunds if the funds are not available or error of the journal
+func (l *Ledger) Withdraw(account string, base, quote decimal.Decimal) error {
+	end, err := l.journaled(JournalEntry{Method: "Withdraw", Owner: account, Quantity: base, Notional: quote})
+	if err != nil {
+		return err
+	}
+	defer end()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	a := l.account(account)
+	if a.base.Available().LessThan(base) || a.quote.Available().LessThan(quote) {
+		return ErrInsufficientFunds
+	}
+
+	a.base.Total = a.base.Total.Sub(base)
+	a.quote.Total = a.quote.Total.Sub(quote)
+	return nil
+}
+
+// Balances returns balances of the base asset and the quote currency of the account
+func (l *Ledger) Balances(account string) (base, quote Balance) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if a, ok := l.accounts[account]; ok {
+		return a.base, a.quote
+	}
+
+	return Balance{}, Balance{}
+}
+
+// Reserve implements RiskCheck interface
+func (l *Ledger) Reserve(r *Reservation) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	a := l.account(r.Owner)
+
+	res := &reservation{side: r.Side, price: r.Price}
+	if r.Side == Buy {
+		if a.quote.Available().LessThan(r.Notional) {
+			return ErrInsufficientFunds
+		}
+		res.amount = r.Notional
+		a.quote.Reserved = a.quote.Reserved.Add(res.amount)
+	} else {
+		if a.base.Available().LessThan(r.Quantity) {
+			return ErrInsufficientFunds
+		}
+		res.amount = r.Quantity
+		a.base.Reserved = a.base.Reserved.Add(res.amount)
+	}
+
+	l.reservations[reservationKey{owner: r.Owner, orderID: r.OrderID}] = res
+	return nil
+}
+
+// Settle implements RiskCheck interface
+func (l *Ledger) Settle(t *Trade) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	buyer, buyerOrder, seller, sellerOrder := t.TakerOwner, t.TakerOrderID, t.MakerOwner, t.MakerOrderID
+	if t.TakerSide == Sell {
+		buyer, buyerOrder, seller, sellerOrder = seller, sellerOrder, buyer, buyerOrder
+	}
+
+	notional := t.Notional()
+
+	b := l.account(buyer)
+	if res, ok := l.reservations[reservationKey{owner: buyer, orderID: buyerOrder}]; ok {
+		// limit orders reserved at their price, the price improvement is released
+		used := notional
+		if res.price.Sign() > 0 {
+			used = res.price.Mul(t.Quantity)
+		}
+		used = decimal.Min(used, res.amount)
+		res.amount = res.amount.Sub(used)
+		b.quote.Reserved = b.quote.Reserved.Sub(used)
+	}
+	b.quote.Total = b.quote.Total.Sub(notional)
+	b.base.Total = b.base.Total.Add(t.Quantity)
+
+	s := l.account(seller)
+	if res, ok := l.reservations[reservationKey{owner: seller, orderID: sellerOrder}]; ok {
+		used := decimal.Min(t.Quantity, res.amount)
+		res.amount = res.amount.Sub(used)
+		s.base.Reserved = s.base.Reserved.Sub(used)
+	}
+	s.base.Total = s.base.Total.Sub(t.Quantity)
+	s.quote.Total = s.quote.Total.Add(notional)
+}
+
+// Release implements RiskCheck interface
+func (l *Ledger) Release(owner, orderID string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	key := reservationKey{owner: owner, orderID: orderID}
+	res, ok := l.reservations[key]
+	if !ok {
+		return
+	}
+
+	a := l.account(owner)
+	if res.side == Buy {
+		a.quote.Reserved = a.quote.Reserved.Sub(res.amount)
+	} else {
+		a.base.Reserved = a.base.Reserved.Sub(res.amount)
+	}
+
+	delete(l.reservations, key)
+}
+
+// account returns account by name, it is created if not exists
+func (l *Ledger) account(name string) *ledgerAccount {
+	a, ok := l.accounts[name]
+	if !ok {
+		a = &ledgerAccount{}
+		l.accounts[name] = a
+	}
+	return a
+}
@@ -0,0 +1,227 @@
+package orderbook
+
+import (
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestLedgerReservations(t *testing.T) {
+	ob := NewOrderBook()
+	ledger := NewLedger()
+	ob.SetRiskCheck(ledger)
+
+	ledger.Deposit("alice", decimal.New(10, 0), decimal.Zero)
+	ledger.Deposit("bob", decimal.Zero, decimal.New(1000, 0))
+
+	if _, _, _, err := ob.ProcessLimitOrderWithOwner(Sell, "s1", "alice", decimal.New(11, 0), decimal.New(100, 0)); err != ErrInsufficientFunds {
+		t.Fatal("Can sell more than balance")
+	}
+
+	if _, _, _, err := ob.ProcessLimitOrderWithOwner(Sell, "s1", "alice", decimal.New(5, 0), decimal.New(100, 0)); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, _, err := ob.ProcessLimitOrderWithOwner(Sell, "s2", "alice", decimal.New(5, 0), decimal.New(110, 0)); err != nil {
+		t.Fatal(err)
+	}
+
+	base, _ := ledger.Balances("alice")
+	if !base.Reserved.Equal(decimal.New(10, 0)) || base.Available().Sign() != 0 {
+		t.Fatal("Invalid reserved base", base)
+	}
+
+	// buy 3 at limit 105, reserves 315 and executes at 100
+	if _, _, _, err := ob.ProcessLimitOrderWithOwner(Buy, "b1", "bob", decimal.New(3, 0), decimal.New(105, 0)); err != nil {
+		t.Fatal(err)
+	}
+
+	_, quote := ledger.Balances("bob")
+	if !quote.Total.Equal(decimal.New(700, 0)) || quote.Reserved.Sign() != 0 {
+		t.Fatal("Invalid buyer quote after fill", quote)
+	}
+
+	base, quote = ledger.Balances("alice")
+	if !base.Total.Equal(decimal.New(7, 0)) || !base.Reserved.Equal(decimal.New(7, 0)) || !quote.Total.Equal(decimal.New(300, 0)) {
+		t.Fatal("Invalid seller balances after fill", base, quote)
+	}
+
+	// buy 3 at limit 105, executes 2 at 100 and rests 1
+	if _, _, _, err := ob.ProcessLimitOrderWithOwner(Buy, "b2", "bob", decimal.New(3, 0), decimal.New(105, 0)); err != nil {
+		t.Fatal(err)
+	}
+
+	_, quote = ledger.Balances("bob")
+	if !quote.Total.Equal(decimal.New(500, 0)) || !quote.Reserved.Equal(decimal.New(105, 0)) {
+		t.Fatal("Invalid buyer quote after partial fill", quote)
+	}
+
+	if _, _, _, err := ob.ProcessLimitOrderWithOwner(Buy, "b3", "bob", decimal.New(4, 0), decimal.New(100, 0)); err != ErrInsufficientFunds {
+		t.Fatal("Can buy more than balance")
+	}
+
+	if _, err := ob.CancelOrder("b2"); err != nil {
+		t.Fatal(err)
+	}
+
+	_, quote = ledger.Balances("bob")
+	if quote.Reserved.Sign() != 0 || !quote.Available().Equal(decimal.New(500, 0)) {
+		t.Fatal("Reservation is not released on cancel", quote)
+	}
+
+	// market buy reserves the cost of the quantity
+	if _, _, _, _, err := ob.ProcessMarketOrderWithOwner(Buy, "bob", decimal.New(5, 0)); err != ErrInsufficientFunds {
+		t.Fatal("Can buy more than balance with market order")
+	}
+
+	if _, _, _, _, err := ob.ProcessMarketOrderWithOwner(Buy, "bob", decimal.New(4, 0)); err != nil {
+		t.Fatal(err)
+	}
+
+	base, quote = ledger.Balances("bob")
+	if !base.Total.Equal(decimal.New(9, 0)) || !quote.Total.Equal(decimal.New(60, 0)) || quote.Reserved.Sign() != 0 {
+		t.Fatal("Invalid buyer balances after market order", base, quote)
+	}
+
+	base, _ = ledger.Balances("alice")
+	if !base.Reserved.Equal(decimal.New(1, 0)) || !base.Total.Equal(decimal.New(1, 0)) {
+		t.Fatal("Invalid seller base", base)
+	}
+
+	// notional market sell reserves base quantity needed for the notional
+	ob.ProcessLimitOrderWithOwner(Buy, "b4", "bob", decimal.New(1, 0), decimal.New(50, 0))
+	if _, _, _, _, err := ob.ProcessMarketOrderByNotional(Sell, "alice", decimal.New(100, 0), decimal.New(1, -8)); err != ErrInsufficientFunds {
+		t.Fatal("Can sell more than balance with notional order")
+	}
+
+	if err := ledger.Withdraw("alice", decimal.New(1, 0), decimal.Zero); err != ErrInsufficientFunds {
+		t.Fatal("Can withdraw more than available")
+	}
+
+	if err := ledger.Withdraw("alice", decimal.Zero, decimal.New(700, 0)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLedgerOwnerCancel(t *testing.T) {
+	ob := NewOrderBook()
+	ledger := NewLedger()
+	ob.SetRiskCheck(ledger)
+	ledger.Deposit("alice", decimal.New(10, 0), decimal.New(1000, 0))
+
+	ob.ProcessLimitOrderWithOwner(Sell, "s1", "alice", decimal.New(5, 0), decimal.New(100, 0))
+	ob.ProcessLimitOrderWithOwner(Buy, "b1", "alice", decimal.New(5, 0), decimal.New(90, 0))
+
+	base, quote := ledger.Balances("alice")
+	if !base.Reserved.Equal(decimal.New(5, 0)) || !quote.Reserved.Equal(decimal.New(450, 0)) {
+		t.Fatal("Invalid reservations", base, quote)
+	}
+
+	ob.CancelOwnerOrders("alice")
+
+	base, quote = ledger.Balances("alice")
+	if base.Reserved.Sign() != 0 || quote.Reserved.Sign() != 0 {
+		t.Fatal("Reservations are not released", base, quote)
+	}
+}
+
+func TestLedgerReduce(t *testing.T) {
+	ob := NewOrderBook()
+	ledger := NewLedger()
+	ob.SetRiskCheck(ledger)
+	ledger.Deposit("alice", decimal.New(10, 0), decimal.New(1000, 0))
+
+	ob.ProcessLimitOrderWithOwner(Sell, "s1", "alice", decimal.New(5, 0), decimal.New(100, 0))
+	ob.ProcessLimitOrderWithOwner(Buy, "b1", "alice", decimal.New(5, 0), decimal.New(90, 0))
+
+	ob.ReduceOrder("s1", decimal.New(2, 0))
+	ob.ReduceOrder("b1", decimal.New(1, 0))
+
+	base, quote := ledger.Balances("alice")
+	if !base.Reserved.Equal(decimal.New(3, 0)) || !quote.Reserved.Equal(decimal.New(360, 0)) {
+		t.Fatal("Reservations are not reduced", base, quote)
+	}
+
+	ob.CancelOwnerOrders("alice")
+
+	base, quote = ledger.Balances("alice")
+	if base.Reserved.Sign() != 0 || quote.Reserved.Sign() != 0 {
+		t.Fatal("Reservations are not released", base, quote)
+	}
+}
+
+// externalRisk is a risk check which state is not kept in snapshots
+type externalRisk struct {
+	*Ledger
+}
+
+func TestLedgerSnapshot(t *testing.T) {
+	ob := NewOrderBook()
+	ledger := NewLedger()
+	ob.SetRiskCheck(ledger)
+	ledger.Deposit("alice", decimal.New(10, 0), decimal.New(1000, 0))
+	ledger.Deposit("bob", decimal.Zero, decimal.New(1000, 0))
+
+	ob.ProcessLimitOrderWithOwner(Sell, "s1", "alice", decimal.New(5, 0), decimal.New(100, 0))
+	ob.ProcessLimitOrderWithOwner(Buy, "b1", "alice", decimal.New(4, 0), decimal.New(90, 0))
+	ob.ProcessLimitOrderWithOwner(Buy, "b2", "bob", decimal.New(2, 0), decimal.New(100, 0))
+
+	data, err := ob.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	restored := NewOrderBook()
+	restoredLedger := NewLedger()
+	restored.SetRiskCheck(restoredLedger)
+	restoredLedger.Deposit("alice", decimal.New(1, 0), decimal.Zero)
+	if err := restored.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, account := range []string{"alice", "bob"} {
+		base, quote := ledger.Balances(account)
+		rbase, rquote := restoredLedger.Balances(account)
+		if !rbase.Total.Equal(base.Total) || !rbase.Reserved.Equal(base.Reserved) ||
+			!rquote.Total.Equal(quote.Total) || !rquote.Reserved.Equal(quote.Reserved) {
+			t.Fatal("Ledger is not restored", account, rbase, rquote)
+		}
+	}
+
+	// settlement of the restored reservation
+	restored.ProcessMarketOrderWithOwner(Buy, "bob", decimal.New(1, 0))
+	base, quote := restoredLedger.Balances("alice")
+	if !base.Total.Equal(decimal.New(7, 0)) || !base.Reserved.Equal(decimal.New(2, 0)) || !quote.Total.Equal(decimal.New(1300, 0)) {
+		t.Fatal("Restored reservation is not settled", base, quote)
+	}
+
+	// other risk checks reserve the restored orders
+	external := NewLedger()
+	other := NewOrderBook()
+	other.SetRiskCheck(externalRisk{external})
+	external.Deposit("alice", decimal.New(10, 0), decimal.New(1000, 0))
+	external.Deposit("bob", decimal.Zero, decimal.New(1000, 0))
+	if err := other.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+
+	base, quote = external.Balances("alice")
+	if !base.Reserved.Equal(decimal.New(3, 0)) || !quote.Reserved.Equal(decimal.New(360, 0)) {
+		t.Fatal("Restored orders are not reserved", base, quote)
+	}
+
+	poor := NewOrderBook()
+	external = NewLedger()
+	poor.SetRiskCheck(externalRisk{external})
+	external.Deposit("alice", decimal.New(1, 0), decimal.New(1000, 0))
+	poor.ProcessLimitOrderWithOwner(Sell, "s2", "alice", decimal.New(1, 0), decimal.New(120, 0))
+
+	if err := poor.UnmarshalBinary(data); err != ErrInsufficientFunds {
+		t.Fatal("Restored orders are reserved over the balance", err)
+	}
+
+	base, quote = external.Balances("alice")
+	if !base.Reserved.Equal(decimal.New(1, 0)) || quote.Reserved.Sign() != 0 || poor.Order("s2") == nil {
+		t.Fatal("Order book is changed by rejected snapshot", base, quote)
+	}
+}
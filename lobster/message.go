@@ -0,0 +1,158 @@
+// Package lobster loads LOBSTER message and orderbook files into the order book.
+//
+// Message file lines are "time,type,order id,size,price,direction" where time
+// is seconds after midnight, price is dollar price times 10000 and direction is
+// -1 for sell and 1 for buy limit orders, for executions it is direction of the
+// executed resting order. Orderbook file line k contains top levels after the
+// message k as "ask price,ask size,bid price,bid size" repeated for every level,
+// missing levels have price 9999999999 (asks) or -9999999999 (bids) and zero size
+package lobster
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"orderbook"
+)
+
+// PriceScale is number of implied decimal places of the prices
+const PriceScale = 4
+
+// EventType is type of the message
+type EventType int
+
+// Types of the messages:
+//
+//	Submission      - new limit order is placed to the book
+//	Cancellation    - part of the resting order is cancelled
+//	Deletion        - the rest of the resting order is cancelled
+//	Execution       - visible resting order is executed
+//	HiddenExecution - hidden order is executed, the visible book is not changed
+//	CrossTrade      - auction trade, the visible book is not changed
+//	TradingHalt     - price -1 halts trading, 0 resumes quoting and 1 resumes trading
+const (
+	Submission EventType = iota + 1
+	Cancellation
+	Deletion
+	Execution
+	HiddenExecution
+	CrossTrade
+	TradingHalt
+)
+
+// Errors of the files
+var (
+	ErrInvalidMessage  = errors.New("lobster: invalid message")
+	ErrInvalidSnapshot = errors.New("lobster: invalid orderbook line")
+	ErrUnknownOrder    = errors.New("lobster: order is not found in the book")
+)
+
+// dummy prices of missing levels
+const (
+	missingAsk = 9999999999
+	missingBid = -9999999999
+)
+
+// Message is single line of the message file
+type Message struct {
+	Time    time.Duration // time after midnight
+	Type    EventType
+	OrderID string
+	Size    decimal.Decimal
+	Price   decimal.Decimal
+	Side    orderbook.Side
+}
+
+// Snapshot is single line of the orderbook file, levels are ordered from the
+// best price and missing levels are omitted
+type Snapshot struct {
+	Levels int
+	Asks   []orderbook.PriceLevel
+	Bids   []orderbook.PriceLevel
+}
+
+// ParseMessage parses fields of the message file line
+func ParseMessage(record []string) (*Message, error) {
+	if len(record) < 6 {
+		return nil, ErrInvalidMessage
+	}
+
+	seconds, err := decimal.NewFromString(record[0])
+	if err != nil {
+		return nil, ErrInvalidMessage
+	}
+
+	eventType, err := strconv.Atoi(record[1])
+	if err != nil || eventType < int(Submission) || eventType > int(TradingHalt) {
+		return nil, ErrInvalidMessage
+	}
+
+	size, err := strconv.ParseInt(record[3], 10, 64)
+	if err != nil {
+		return nil, ErrInvalidMessage
+	}
+
+	price, err := strconv.ParseInt(record[4], 10, 64)
+	if err != nil {
+		return nil, ErrInvalidMessage
+	}
+
+	m := &Message{
+		Time:    time.Duration(seconds.Shift(9).IntPart()),
+		Type:    EventType(eventType),
+		OrderID: record[2],
+		Size:    decimal.NewFromInt(size),
+		Price:   decimal.New(price, -PriceScale),
+	}
+
+	switch record[5] {
+	case "1":
+		m.Side = orderbook.Buy
+	case "-1":
+		m.Side = orderbook.Sell
+	default:
+		if m.Type != TradingHalt {
+			return nil, ErrInvalidMessage
+		}
+	}
+
+	return m, nil
+}
+
+// ParseSnapshot parses fields of the orderbook file line
+func ParseSnapshot(record []string) (*Snapshot, error) {
+	if len(record) == 0 || len(record)%4 != 0 {
+		return nil, ErrInvalidSnapshot
+	}
+
+	s := &Snapshot{Levels: len(record) / 4}
+	for i := 0; i < len(record); i += 4 {
+		var values [4]int64
+		for j := range values {
+			v, err := strconv.ParseInt(record[i+j], 10, 64)
+			if err != nil {
+				return nil, ErrInvalidSnapshot
+			}
+			values[j] = v
+		}
+
+		if values[0] != missingAsk && values[1] != 0 {
+			s.Asks = append(s.Asks, orderbook.PriceLevel{
+				Price:    decimal.New(values[0], -PriceScale),
+				Quantity: decimal.NewFromInt(values[1]),
+			})
+		}
+
+		if values[2] != missingBid && values[3] != 0 {
+			s.Bids = append(s.Bids, orderbook.PriceLevel{
+				Price:    decimal.New(values[2], -PriceScale),
+				Quantity: decimal.NewFromInt(values[3]),
+			})
+		}
+	}
+
+	return s, nil
+}
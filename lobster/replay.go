@@ -0,0 +1,331 @@
+package lobster
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"orderbook"
+)
+
+// ParseError is error of the line of the message or orderbook file
+type ParseError struct {
+	Line int
+	Err  error
+}
+
+// Error implements error interface
+func (e *ParseError) Error() string {
+	return "lobster: line " + strconv.Itoa(e.Line) + ": " + e.Err.Error()
+}
+
+// Unwrap returns the cause of the error
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// Mismatch is difference between the level of the order book and the orderbook file,
+// missing levels are zero
+type Mismatch struct {
+	Side     orderbook.Side
+	Level    int // from 1 for the best price
+	Expected orderbook.PriceLevel
+	Actual   orderbook.PriceLevel
+}
+
+// String implements fmt.Stringer interface
+func (m Mismatch) String() string {
+	return m.Side.String() + " level " + strconv.Itoa(m.Level) +
+		": expected " + m.Expected.Quantity.String() + "@" + m.Expected.Price.String() +
+		", got " + m.Actual.Quantity.String() + "@" + m.Actual.Price.String()
+}
+
+// Step is result of the single message
+type Step struct {
+	Line       int
+	Message    *Message
+	Err        error // not nil if the message can't be applied to the order book
+	Mismatches []Mismatch
+}
+
+// Failed reports whether the message is not applied or the book differs from the orderbook file
+func (s *Step) Failed() bool {
+	return s.Err != nil || len(s.Mismatches) > 0
+}
+
+// Report summarizes the replay
+type Report struct {
+	Messages int
+	Failed   []*Step
+}
+
+// Replayer feeds messages into the order book and verifies its top levels
+// after every message. The first line of the orderbook file seeds the empty
+// book with one order per level because orders placed before the first
+// message are unknown. Later messages of unknown orders change these seed
+// orders at the same side and price. Executions of the first order in the
+// best level are matched by market orders and produce trades, other
+// executions reduce the order without trades. The order book clock is set
+// to the time of the message, so orders and trades have historical timestamps
+type Replayer struct {
+	ob        *orderbook.OrderBook
+	messages  *csv.Reader
+	snapshots *csv.Reader
+	line      int
+	date      time.Time
+	clock     replayClock
+}
+
+// replayClock returns time of the message being replayed
+type replayClock struct {
+	now time.Time
+}
+
+// Now implements orderbook.Clock interface
+func (c *replayClock) Now() time.Time {
+	return c.now
+}
+
+// NewReplayer creates replayer of the files
+// Arguments:
+//
+//	ob        - empty order book, its clock is replaced by the replay clock
+//	messages  - LOBSTER message file
+//	snapshots - LOBSTER orderbook file of the same messages
+//	date      - trading date of the files, message times are seconds after its midnight
+func NewReplayer(ob *orderbook.OrderBook, messages, snapshots io.Reader, date time.Time) *Replayer {
+	r := &Replayer{
+		ob:        ob,
+		messages:  csv.NewReader(messages),
+		snapshots: csv.NewReader(snapshots),
+		date:      time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, date.Location()),
+	}
+	r.clock.now = r.date
+	ob.SetClock(&r.clock)
+
+	r.messages.FieldsPerRecord = -1
+	r.messages.ReuseRecord = true
+	r.snapshots.ReuseRecord = true
+	return r
+}
+
+// Next applies the next message and verifies the order book
+// Return:
+//
+//	error - io.EOF at the end of the message file or not nil if the files are broken
+func (r *Replayer) Next() (*Step, error) {
+	record, err := r.messages.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	m, err := ParseMessage(record)
+	if err != nil {
+		return nil, &ParseError{Line: r.line + 1, Err: err}
+	}
+
+	record, err = r.snapshots.Read()
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	s, err := ParseSnapshot(record)
+	if err != nil {
+		return nil, &ParseError{Line: r.line + 1, Err: err}
+	}
+
+	r.line++
+	step := &Step{Line: r.line, Message: m}
+	r.clock.now = r.date.Add(m.Time)
+
+	if r.line == 1 {
+		// the first line of the orderbook file already contains the first message
+		step.Err = r.seed(s)
+	} else {
+		step.Err = r.apply(m)
+	}
+
+	step.Mismatches = r.verify(s)
+	return step, nil
+}
+
+// Run replays all messages
+// Return:
+//
+//	error - not nil if the files are broken
+func (r *Replayer) Run() (*Report, error) {
+	report := &Report{}
+	for {
+		step, err := r.Next()
+		if err == io.EOF {
+			return report, nil
+		}
+		if err != nil {
+			return report, err
+		}
+
+		report.Messages++
+		if step.Failed() {
+			report.Failed = append(report.Failed, step)
+		}
+	}
+}
+
+// seed places one order per level of the snapshot
+func (r *Replayer) seed(s *Snapshot) error {
+	for _, level := range s.Asks {
+		if _, _, _, err := r.ob.ProcessLimitOrder(orderbook.Sell, seedID(orderbook.Sell, level.Price), level.Quantity, level.Price); err != nil {
+			return err
+		}
+	}
+
+	for _, level := range s.Bids {
+		if _, _, _, err := r.ob.ProcessLimitOrder(orderbook.Buy, seedID(orderbook.Buy, level.Price), level.Quantity, level.Price); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// apply changes the order book by the message
+func (r *Replayer) apply(m *Message) error {
+	switch m.Type {
+	case Submission:
+		_, _, _, err := r.ob.ProcessLimitOrder(m.Side, m.OrderID, m.Size, m.Price)
+		return err
+	case Cancellation:
+		id, err := r.resolve(m)
+		if err != nil {
+			return err
+		}
+		_, err = r.ob.ReduceOrder(id, m.Size)
+		return err
+	case Deletion:
+		id, err := r.resolve(m)
+		if err != nil {
+			return err
+		}
+		if id == m.OrderID {
+			_, err = r.ob.CancelOrder(id)
+		} else {
+			_, err = r.ob.ReduceOrder(id, m.Size)
+		}
+		return err
+	case Execution:
+		return r.execute(m)
+	case TradingHalt:
+		return r.halt(m)
+	}
+
+	return nil
+}
+
+// execute matches the resting order by market order if it is the first one in the queue
+func (r *Replayer) execute(m *Message) error {
+	id, err := r.resolve(m)
+	if err != nil {
+		return err
+	}
+
+	o := r.ob.Order(id)
+	os := r.ob.GetOrderSide(o.Side())
+
+	best, opposite := os.MinPriceQueue(), orderbook.Buy
+	if o.Side() == orderbook.Buy {
+		best, opposite = os.MaxPriceQueue(), orderbook.Sell
+	}
+
+	if best.Head().Value.(*orderbook.Order).ID() != id || m.Size.GreaterThan(o.Quantity()) {
+		_, err = r.ob.ReduceOrder(id, m.Size)
+		return err
+	}
+
+	_, _, _, _, err = r.ob.ProcessMarketOrder(opposite, m.Size)
+	return err
+}
+
+// halt switches trading phase of the order book
+func (r *Replayer) halt(m *Message) error {
+	switch m.Price.Sign() {
+	case -1:
+		return r.ob.Halt("lobster")
+	case 0:
+		if r.ob.Phase() == orderbook.Halted {
+			return r.ob.Resume(orderbook.Auction)
+		}
+	default:
+		switch r.ob.Phase() {
+		case orderbook.Halted:
+			return r.ob.Resume(orderbook.Continuous)
+		case orderbook.Auction:
+			return r.ob.SetPhase(orderbook.Continuous, "lobster")
+		}
+	}
+
+	return nil
+}
+
+// resolve returns ID of the order in the book, unknown orders are looked up
+// among the seed orders
+func (r *Replayer) resolve(m *Message) (string, error) {
+	if r.ob.Order(m.OrderID) != nil {
+		return m.OrderID, nil
+	}
+
+	if id := seedID(m.Side, m.Price); r.ob.Order(id) != nil {
+		return id, nil
+	}
+
+	return "", ErrUnknownOrder
+}
+
+// verify compares top levels of the order book with the snapshot
+func (r *Replayer) verify(s *Snapshot) (mismatches []Mismatch) {
+	asks, bids := r.ob.GetOrderSide(orderbook.Sell), r.ob.GetOrderSide(orderbook.Buy)
+	ask, bid := asks.MinPriceQueue(), bids.MaxPriceQueue()
+
+	for i := 0; i < s.Levels; i++ {
+		var expected, actual orderbook.PriceLevel
+
+		if i < len(s.Asks) {
+			expected = s.Asks[i]
+		}
+		if ask != nil {
+			actual = orderbook.PriceLevel{Price: ask.Price(), Quantity: ask.Volume()}
+			ask = asks.GreaterThan(ask.Price())
+		}
+		if !sameLevel(expected, actual) {
+			mismatches = append(mismatches, Mismatch{Side: orderbook.Sell, Level: i + 1, Expected: expected, Actual: actual})
+		}
+
+		expected, actual = orderbook.PriceLevel{}, orderbook.PriceLevel{}
+		if i < len(s.Bids) {
+			expected = s.Bids[i]
+		}
+		if bid != nil {
+			actual = orderbook.PriceLevel{Price: bid.Price(), Quantity: bid.Volume()}
+			bid = bids.LessThan(bid.Price())
+		}
+		if !sameLevel(expected, actual) {
+			mismatches = append(mismatches, Mismatch{Side: orderbook.Buy, Level: i + 1, Expected: expected, Actual: actual})
+		}
+	}
+
+	return
+}
+
+func sameLevel(a, b orderbook.PriceLevel) bool {
+	return a.Price.Equal(b.Price) && a.Quantity.Equal(b.Quantity)
+}
+
+// seedID returns ID of the seed order of the level
+func seedID(side orderbook.Side, price decimal.Decimal) string {
+	return "lobster-" + side.String() + "-" + price.String()
+}
@@ -0,0 +1,113 @@
+package lobster
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"orderbook"
+)
+
+var sampleDate = time.Date(2012, 6, 21, 15, 30, 0, 0, time.UTC)
+
+func replay(t *testing.T, name string) (*orderbook.OrderBook, []*orderbook.Trade, *Report) {
+	messages, err := os.Open("testdata/" + name + "_message.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer messages.Close()
+
+	snapshots, err := os.Open("testdata/" + name + "_orderbook.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer snapshots.Close()
+
+	ob := orderbook.NewOrderBook()
+	var trades []*orderbook.Trade
+	ob.OnTrade(func(t *orderbook.Trade) { trades = append(trades, t) })
+
+	report, err := NewReplayer(ob, messages, snapshots, sampleDate).Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return ob, trades, report
+}
+
+func TestReplay(t *testing.T) {
+	ob, trades, report := replay(t, "sample")
+
+	if report.Messages != 11 || len(report.Failed) != 0 {
+		t.Fatal("Invalid replay", report.Messages, report.Failed)
+	}
+
+	// the first order is a part of the seed order
+	if len(trades) != 2 || trades[0].MakerOrderID != "lobster-sell-100" || !trades[0].Quantity.Equal(decimal.New(60, 0)) ||
+		trades[1].MakerOrderID != "3" || !trades[1].Price.Equal(decimal.New(100, 0)) {
+		t.Fatal("Invalid trades", trades)
+	}
+
+	if o := ob.Order("3"); o == nil || !o.Quantity().Equal(decimal.New(20, 0)) {
+		t.Fatal("Invalid order after execution", o)
+	}
+
+	// 34200.2 seconds after midnight of the trading date
+	if o := ob.Order("3"); !o.Time().Equal(time.Date(2012, 6, 21, 9, 30, 0, 2e8, time.UTC)) {
+		t.Fatal("Order time is not the message time", o.Time())
+	}
+
+	if ob.Phase() != orderbook.Continuous {
+		t.Fatal("Trading is not resumed", ob.Phase())
+	}
+}
+
+func TestReplaySeeded(t *testing.T) {
+	ob, trades, report := replay(t, "seeded")
+
+	if report.Messages != 5 || len(report.Failed) != 1 {
+		t.Fatal("Invalid replay", report.Messages, report.Failed)
+	}
+
+	// the last line of the orderbook file is wrong
+	step := report.Failed[0]
+	if step.Line != 5 || step.Err != nil || len(step.Mismatches) != 1 {
+		t.Fatal("Invalid failed step", step)
+	}
+
+	if s := step.Mismatches[0].String(); s != "sell level 2: expected 10@101, got 15@101" {
+		t.Fatal("Invalid mismatch", s)
+	}
+
+	if len(trades) != 1 || trades[0].MakerOrderID != "lobster-buy-99" {
+		t.Fatal("Seed order is not executed", trades)
+	}
+
+	if o := ob.Order("lobster-sell-100"); o == nil || !o.Quantity().Equal(decimal.New(40, 0)) {
+		t.Fatal("Seed order is not reduced", o)
+	}
+}
+
+func TestReplayErrors(t *testing.T) {
+	messages := "34200.0,1,1,10,1000000,-1\n34200.1,3,2,10,990000,1\n34200.2,9,1,10,1000000,-1\n"
+	snapshots := "1000000,10,-9999999999,0\n1000000,10,-9999999999,0\n1000000,10,-9999999999,0\n"
+
+	r := NewReplayer(orderbook.NewOrderBook(), strings.NewReader(messages), strings.NewReader(snapshots), sampleDate)
+
+	if step, err := r.Next(); err != nil || step.Failed() {
+		t.Fatal("Invalid first step", step, err)
+	}
+
+	if step, err := r.Next(); err != nil || step.Err != ErrUnknownOrder {
+		t.Fatal("Unknown order is deleted", step, err)
+	}
+
+	var parseErr *ParseError
+	if _, err := r.Next(); !errors.As(err, &parseErr) || parseErr.Line != 3 || !errors.Is(err, ErrInvalidMessage) {
+		t.Fatal("Invalid message is parsed", err)
+	}
+}
@@ -0,0 +1,258 @@
+package orderbook
+
+import (
+	"github.com/shopspring/decimal"
+)
+
+var oneHundred = decimal.New(100, 0)
+
+// MarketProtection limits how far a market order may move away from the best price
+// Ticks and TickSize give the maximal distance in ticks, Percent gives the
+// maximal distance in percent of the best price, it is less than 100 so the
+// sell limit price stays positive. If both are set the tighter one is used. If Rest is true the unfilled remainder is placed to the order
+// book as a limit order at the protection price, otherwise it is cancelled.
+type MarketProtection struct {
+	Ticks    int64
+	TickSize decimal.Decimal
+	Percent  decimal.Decimal
+	Rest     bool
+}
+
+func (mp MarketProtection) validate() error {
+	byTicks := mp.Ticks > 0
+	byPercent := mp.Percent.Sign() > 0
+
+	if !byTicks && !byPercent {
+		return ErrInvalidProtection
+	}
+
+	if byTicks && mp.TickSize.Sign() <= 0 {
+		return ErrInvalidProtection
+	}
+
+	if mp.Ticks < 0 || mp.Percent.Sign() < 0 || mp.Percent.GreaterThanOrEqual(oneHundred) {
+		return ErrInvalidProtection
+	}
+
+	return nil
+}
+
+// LimitPrice returns the worst price an order of given side may be executed at
+// if the best price of the opposite side is best
+func (mp MarketProtection) LimitPrice(side Side, best decimal.Decimal) decimal.Decimal {
+	var distance decimal.Decimal
+	hasDistance := false
+
+	if mp.Ticks > 0 {
+		distance = mp.TickSize.Mul(decimal.New(mp.Ticks, 0))
+		hasDistance = true
+	}
+
+	if mp.Percent.Sign() > 0 {
+		byPercent := best.Mul(mp.Percent).Div(oneHundred)
+		if !hasDistance || byPercent.LessThan(distance) {
+			distance = byPercent
+		}
+	}
+
+	if side == Buy {
+		return best.Add(distance)
+	}
+
+	return best.Sub(distance)
+}
+
+// ProcessProtectedMarketOrder gets definite quantity from the order book with market price
+// but never executes worse than the protection price calculated from the best opposite price
+// Arguments:
+//
+//	side       - what do you want to do (ob.Sell or ob.Buy)
+//	orderID    - unique order ID in depth, used if the remainder is rested
+//	owner      - owner of the order (see ProcessLimitOrderWithOwner)
+//	quantity   - how much quantity you want to sell or buy
+//	protection - maximal slippage from the best price and remainder handling
+//
+// Return:
+//
+//	error        - not nil if quantity is less or equal 0, protection is invalid
+//	               or if order with given ID is exists
+//	done         - not nil if your market order produces ends of anoter orders, this order will add to
+//	               the "done" slice
+//	partial      - not nil if your order has done but top order is not fully done. Or if the
+//	               remainder is rested - partial will contain your order with quantity to left
+//	partialQuantityProcessed - if partial order is not nil this result contains processed quatity from partial order
+//	quantityLeft - more than zero if the remainder was cancelled by the protection
+//	               or it is not enought orders to process all quantity
+func (ob *OrderBook) ProcessProtectedMarketOrder(side Side, orderID, owner string, quantity decimal.Decimal, protection MarketProtection) (done []*Order, partial *Order, partialQuantityProcessed, quantityLeft decimal.Decimal, err error) {
+	end, err := ob.journaled(JournalEntry{Method: "ProcessProtectedMarketOrder", Side: side, OrderID: orderID, Owner: owner, Quantity: quantity, Protection: &protection})
+	if err != nil {
+		return nil, nil, decimal.Zero, decimal.Zero, err
+	}
+	defer end()
+
+	if _, ok := ob.orders[orderID]; ok {
+		return nil, nil, decimal.Zero, decimal.Zero, ErrOrderExists
+	}
+
+	if quantity.Sign() <= 0 {
+		return nil, nil, decimal.Zero, decimal.Zero, ErrInvalidQuantity
+	}
+
+	if err := protection.validate(); err != nil {
+		return nil, nil, decimal.Zero, decimal.Zero, err
+	}
+
+	if err := ob.checkMarketOrder(); err != nil {
+		return nil, nil, decimal.Zero, decimal.Zero, err
+	}
+
+	var best *OrderQueue
+	if side == Buy {
+		best = ob.asks.MinPriceQueue()
+	} else {
+		best = ob.bids.MaxPriceQueue()
+	}
+
+	if best == nil {
+		return nil, nil, decimal.Zero, quantity, nil
+	}
+
+	limitPrice := protection.LimitPrice(side, best.Price())
+
+	if protection.Rest {
+		done, partial, partialQuantityProcessed, err = ob.ProcessLimitOrderWithOwner(side, orderID, owner, quantity, limitPrice)
+		return done, partial, partialQuantityProcessed, decimal.Zero, err
+	}
+
+	if err := ob.checkExposure(owner, side, quantity); err != nil {
+		return nil, nil, decimal.Zero, decimal.Zero, err
+	}
+
+	if err := ob.reserveLimit(side, orderID, owner, quantity, limitPrice); err != nil {
+		return nil, nil, decimal.Zero, decimal.Zero, err
+	}
+	defer ob.release(owner, orderID)
+
+	var (
+		sideToProcess *OrderSide
+		comparator    func(decimal.Decimal) bool
+		iter          func() *OrderQueue
+	)
+
+	if side == Buy {
+		sideToProcess = ob.asks
+		comparator = limitPrice.GreaterThanOrEqual
+		iter = ob.asks.MinPriceQueue
+	} else {
+		sideToProcess = ob.bids
+		comparator = limitPrice.LessThanOrEqual
+		iter = ob.bids.MaxPriceQueue
+	}
+
+	taker := NewOrder(orderID, side, quantity, limitPrice, ob.now())
+	taker.owner = owner
+	for quantity.Sign() > 0 && sideToProcess.Len() > 0 && ob.matching() && comparator(best.Price()) {
+		ordersDone, partialDone, partialQty, left := ob.processQueue(best, quantity, taker)
+		done = append(done, ordersDone...)
+		partial = partialDone
+		partialQuantityProcessed = partialQty
+		quantity = left
+		best = iter()
+	}
+
+	quantityLeft = quantity
+	if ob.phase == Halted {
+		err = ErrTradingHalted
+	}
+	return
+}
+
+// ProcessMarketOrderByNotional immediately spends (or receives) definite amount of the quote
+// currency with market price. Base quantity taken from each level is calculated from the
+// notional left and the level price and is rounded down to the multiple of the lot
+// Arguments:
+//
+//	side     - what do you want to do (ob.Sell or ob.Buy)
+//	owner    - owner of the order, reported as taker of the trades
+//	notional - how much quote currency you want to spend (buy) or to receive (sell)
+//	lot      - quantity step of the order, e.g. decimal.New(1, -8) for 8 decimal places
+//
+// Return:
+//
+//	error        - ErrInvalidNotional if notional is less or equal 0,
+//	               ErrInvalidLot if lot is less or equal 0
+//	done         - not nil if your market order produces ends of anoter orders, this order will add to
+//	               the "done" slice
+//	partial      - not nil if your order has done but top order is not fully done
+//	partialQuantityProcessed - if partial order is not nil this result contains processed quatity from partial order
+//	notionalLeft - more than zero if it is not enought orders to process all notional
+func (ob *OrderBook) ProcessMarketOrderByNotional(side Side, owner string, notional, lot decimal.Decimal) (done []*Order, partial *Order, partialQuantityProcessed, notionalLeft decimal.Decimal, err error) {
+	end, err := ob.journaled(JournalEntry{Method: "ProcessMarketOrderByNotional", Side: side, Owner: owner, Notional: notional, Quantity: lot})
+	if err != nil {
+		return nil, nil, decimal.Zero, decimal.Zero, err
+	}
+	defer end()
+
+	if notional.Sign() <= 0 {
+		return nil, nil, decimal.Zero, decimal.Zero, ErrInvalidNotional
+	}
+
+	if lot.Sign() <= 0 {
+		return nil, nil, decimal.Zero, decimal.Zero, ErrInvalidLot
+	}
+
+	if err := ob.checkMarketOrder(); err != nil {
+		return nil, nil, decimal.Zero, decimal.Zero, err
+	}
+
+	if err := ob.checkExposure(owner, side, ob.quantityForNotional(side, notional)); err != nil {
+		return nil, nil, decimal.Zero, decimal.Zero, err
+	}
+
+	if err := ob.reserveNotional(side, owner, notional); err != nil {
+		return nil, nil, decimal.Zero, decimal.Zero, err
+	}
+	defer ob.release(owner, "")
+
+	var (
+		iter          func() *OrderQueue
+		sideToProcess *OrderSide
+	)
+
+	if side == Buy {
+		iter = ob.asks.MinPriceQueue
+		sideToProcess = ob.asks
+	} else {
+		iter = ob.bids.MaxPriceQueue
+		sideToProcess = ob.bids
+	}
+
+	taker := NewOrder("", side, decimal.Zero, decimal.Zero, ob.now())
+	taker.owner = owner
+	for notional.Sign() > 0 && sideToProcess.Len() > 0 && ob.matching() {
+		bestPrice := iter()
+		levelNotional := bestPrice.Price().Mul(bestPrice.Volume())
+
+		quantity := bestPrice.Volume()
+		if notional.LessThan(levelNotional) {
+			quantity = notional.Div(bestPrice.Price()).Div(lot).Floor().Mul(lot)
+		}
+
+		if quantity.Sign() <= 0 {
+			break
+		}
+		notional = notional.Sub(quantity.Mul(bestPrice.Price()))
+
+		ordersDone, partialDone, partialProcessed, left := ob.processQueue(bestPrice, quantity, taker)
+		done = append(done, ordersDone...)
+		partial = partialDone
+		partialQuantityProcessed = partialProcessed
+		notional = notional.Add(left.Mul(bestPrice.Price()))
+	}
+
+	notionalLeft = notional
+	if ob.phase == Halted {
+		err = ErrTradingHalted
+	}
+	return
+}
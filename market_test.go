@@ -0,0 +1,119 @@
+package orderbook
+
+import (
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestProtectedMarketProcess(t *testing.T) {
+	ob := NewOrderBook()
+	addDepth(ob, "", decimal.New(2, 0))
+
+	protection := MarketProtection{Ticks: 1, TickSize: decimal.New(10, 0)}
+
+	done, partial, partialQty, left, err := ob.ProcessProtectedMarketOrder(Buy, "order-m1", "", decimal.New(5, 0), protection)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(done) != 2 {
+		t.Fatal("Invalid done amount", len(done))
+	}
+
+	if partial != nil || partialQty.Sign() != 0 {
+		t.Fatal("Wrong partial")
+	}
+
+	if !left.Equal(decimal.New(1, 0)) {
+		t.Fatal("Invalid left amount", left)
+	}
+
+	if ob.Order("order-m1") != nil {
+		t.Fatal("Remainder is rested")
+	}
+
+	protection = MarketProtection{Percent: decimal.New(5, 0), Rest: true}
+	done, partial, _, left, err = ob.ProcessProtectedMarketOrder(Buy, "order-m2", "", decimal.New(3, 0), protection)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(done) != 1 || done[0].ID() != "sell-120" {
+		t.Fatal("Invalid done orders", done)
+	}
+
+	if partial == nil || partial.ID() != "order-m2" || !partial.Price().Equal(decimal.New(126, 0)) {
+		t.Fatal("Remainder is not rested", partial)
+	}
+
+	if left.Sign() != 0 {
+		t.Fatal("Invalid left amount", left)
+	}
+
+	if _, _, _, _, err := ob.ProcessProtectedMarketOrder(Sell, "order-m3", "", decimal.New(1, 0), MarketProtection{}); err != ErrInvalidProtection {
+		t.Fatal("Can process order without protection")
+	}
+
+	if _, _, _, _, err := ob.ProcessProtectedMarketOrder(Sell, "order-m3", "", decimal.New(1, 0), MarketProtection{Percent: decimal.New(100, 0)}); err != ErrInvalidProtection {
+		t.Fatal("Can process order with protection price not above zero")
+	}
+
+	if _, _, _, _, err := ob.ProcessProtectedMarketOrder(Sell, "order-m2", "", decimal.New(1, 0), protection); err != ErrOrderExists {
+		t.Fatal("Can add existing order")
+	}
+
+	if _, _, _, _, err := ob.ProcessProtectedMarketOrder(Sell, "order-m3", "", decimal.Zero, protection); err != ErrInvalidQuantity {
+		t.Fatal("Can add zero quantity order")
+	}
+
+	t.Log(ob)
+}
+
+func TestNotionalMarketProcess(t *testing.T) {
+	ob := NewOrderBook()
+	addDepth(ob, "", decimal.New(2, 0))
+
+	done, partial, partialQty, left, err := ob.ProcessMarketOrderByNotional(Buy, "", decimal.New(305, 0), decimal.New(1, -2))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(done) != 1 || done[0].ID() != "sell-100" {
+		t.Fatal("Invalid done orders", done)
+	}
+
+	if partial == nil || partial.ID() != "sell-110" {
+		t.Fatal("Wrong partial", partial)
+	}
+
+	// 105 / 110 is rounded down to the lot
+	if !partialQty.Equal(decimal.New(95, -2)) {
+		t.Fatal("Wrong partial quantity processed", partialQty)
+	}
+
+	if !left.Equal(decimal.New(5, -1)) {
+		t.Fatal("Invalid left amount", left)
+	}
+
+	done, _, _, left, err = ob.ProcessMarketOrderByNotional(Sell, "", decimal.New(1000, 0), decimal.New(1, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(done) != 5 {
+		t.Fatal("Invalid done amount", len(done))
+	}
+
+	if !left.Equal(decimal.New(300, 0)) {
+		t.Fatal("Invalid left amount", left)
+	}
+
+	if _, _, _, _, err := ob.ProcessMarketOrderByNotional(Buy, "", decimal.Zero, decimal.New(1, 0)); err != ErrInvalidNotional {
+		t.Fatal("Can add zero notional order")
+	}
+
+	if _, _, _, _, err := ob.ProcessMarketOrderByNotional(Buy, "", decimal.New(1, 0), decimal.Zero); err != ErrInvalidLot {
+		t.Fatal("Can add order with zero lot")
+	}
+}
@@ -12,6 +12,7 @@ import (
 type Order struct {
 	side      Side
 	id        string
+	owner     string
 	timestamp time.Time
 	quantity  decimal.Decimal
 	price     decimal.Decimal
@@ -39,6 +40,11 @@ func (o *Order) ID() string {
 	return o.id
 }
 
+// Owner returns owner (account or session) the order belongs to
+func (o *Order) Owner() string {
+	return o.owner
+}
+
 // Side returns side of the order
 func (o *Order) Side() Side {
 	return o.side
@@ -70,12 +76,14 @@ func (o *Order) MarshalJSON() ([]byte, error) {
 		&struct {
 			S         Side            `json:"side"`
 			ID        string          `json:"id"`
+			Owner     string          `json:"owner,omitempty"`
 			Timestamp time.Time       `json:"timestamp"`
 			Quantity  decimal.Decimal `json:"quantity"`
 			Price     decimal.Decimal `json:"price"`
 		}{
 			S:         o.Side(),
 			ID:        o.ID(),
+			Owner:     o.Owner(),
 			Timestamp: o.Time(),
 			Quantity:  o.Quantity(),
 			Price:     o.Price(),
@@ -88,6 +96,7 @@ func (o *Order) UnmarshalJSON(data []byte) error {
 	obj := struct {
 		S         Side            `json:"side"`
 		ID        string          `json:"id"`
+		Owner     string          `json:"owner,omitempty"`
 		Timestamp time.Time       `json:"timestamp"`
 		Quantity  decimal.Decimal `json:"quantity"`
 		Price     decimal.Decimal `json:"price"`
@@ -99,6 +108,7 @@ func (o *Order) UnmarshalJSON(data []byte) error {
 
 	o.side = obj.S
 	o.id = obj.ID
+	o.owner = obj.Owner
 	o.timestamp = obj.Timestamp
 	o.quantity = obj.Quantity
 	o.price = obj.Price
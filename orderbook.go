@@ -10,18 +10,42 @@ import (
 
 // OrderBook implements standard matching algorithm
 type OrderBook struct {
-	orders map[string]*list.Element // orderID -> *Order (*list.Element.Value.(*Order))
+	orders map[string]*list.Element            // orderID -> *Order (*list.Element.Value.(*Order))
+	owners map[string]map[string]*list.Element // owner -> orderID -> *Order
 
 	asks *OrderSide
 	bids *OrderSide
+
+	clock         Clock
+	tradeSeq      uint64
+	tradeHandlers []func(*Trade)
+	orderHandlers []func(*OrderEvent)
+	lastTrade     *Trade
+	feeModel      FeeModel
+	risk          RiskCheck
+	positions     *PositionKeeper
+	rates         *tradeRates
+	journal       Journal
+	journaling    bool // the command is written to the journal
+
+	phase           Phase
+	phaseHandlers   []func(*PhaseEvent)
+	haltReason      string
+	schedule        []ScheduledPhase
+	scheduleChecked time.Time
+	band            *PriceBand
+	breaker         *CircuitBreaker
+	window          []tradePoint // trades within circuit breaker window
 }
 
 // NewOrderBook creates Orderbook object
 func NewOrderBook() *OrderBook {
 	return &OrderBook{
 		orders: map[string]*list.Element{},
+		owners: map[string]map[string]*list.Element{},
 		bids:   NewOrderSide(),
 		asks:   NewOrderSide(),
+		clock:  SystemClock{},
 	}
 }
 
@@ -81,10 +105,36 @@ func (ob *OrderBook) CalculatePriceAfterExecution(side Side,quantity decimal.Dec
 
 
 func (ob *OrderBook) ProcessMarketOrder(side Side, quantity decimal.Decimal) (done []*Order, partial *Order, partialQuantityProcessed, quantityLeft decimal.Decimal, err error) {
+	return ob.ProcessMarketOrderWithOwner(side, "", quantity)
+}
+
+// ProcessMarketOrderWithOwner immediately gets definite quantity from the order book with market price
+// for given owner. Owner is reported as taker of the trades (see OnTrade)
+// Arguments and return values are the same as for ProcessMarketOrder
+func (ob *OrderBook) ProcessMarketOrderWithOwner(side Side, owner string, quantity decimal.Decimal) (done []*Order, partial *Order, partialQuantityProcessed, quantityLeft decimal.Decimal, err error) {
+	end, err := ob.journaled(JournalEntry{Method: "ProcessMarketOrderWithOwner", Side: side, Owner: owner, Quantity: quantity})
+	if err != nil {
+		return nil, nil, decimal.Zero, decimal.Zero, err
+	}
+	defer end()
+
 	if quantity.Sign() <= 0 {
 		return nil, nil, decimal.Zero, decimal.Zero, ErrInvalidQuantity
 	}
 
+	if err := ob.checkMarketOrder(); err != nil {
+		return nil, nil, decimal.Zero, decimal.Zero, err
+	}
+
+	if err := ob.checkExposure(owner, side, quantity); err != nil {
+		return nil, nil, decimal.Zero, decimal.Zero, err
+	}
+
+	if err := ob.reserveMarket(side, "", owner, quantity); err != nil {
+		return nil, nil, decimal.Zero, decimal.Zero, err
+	}
+	defer ob.release(owner, "")
+
 	var (
 		iter          func() *OrderQueue
 		sideToProcess *OrderSide
@@ -98,9 +148,11 @@ func (ob *OrderBook) ProcessMarketOrder(side Side, quantity decimal.Decimal) (do
 		sideToProcess = ob.bids
 	}
 
-	for quantity.Sign() > 0 && sideToProcess.Len() > 0 {
+	taker := NewOrder("", side, quantity, decimal.Zero, ob.now())
+	taker.owner = owner
+	for quantity.Sign() > 0 && sideToProcess.Len() > 0 && ob.matching() {
 		bestPrice := iter()
-		ordersDone, partialDone, partialProcessed, quantityLeft := ob.processQueue(bestPrice, quantity)
+		ordersDone, partialDone, partialProcessed, quantityLeft := ob.processQueue(bestPrice, quantity, taker)
 		done = append(done, ordersDone...)
 		partial = partialDone
 		partialQuantityProcessed = partialProcessed
@@ -108,6 +160,9 @@ func (ob *OrderBook) ProcessMarketOrder(side Side, quantity decimal.Decimal) (do
 	}
 
 	quantityLeft = quantity
+	if ob.phase == Halted {
+		err = ErrTradingHalted
+	}
 	return
 }
 
@@ -128,6 +183,19 @@ func (ob *OrderBook) ProcessMarketOrder(side Side, quantity decimal.Decimal) (do
 //                your order with quantity to left
 //      partialQuantityProcessed - if partial order is not nil this result contains processed quatity from partial order
 func (ob *OrderBook) ProcessLimitOrder(side Side, orderID string, quantity, price decimal.Decimal) (done []*Order, partial *Order, partialQuantityProcessed decimal.Decimal, err error) {
+	return ob.ProcessLimitOrderWithOwner(side, orderID, "", quantity, price)
+}
+
+// ProcessLimitOrderWithOwner places new order which belongs to given owner to the OrderBook
+// Owner allows to cancel all orders of the account or session at once (see CancelOwnerOrders)
+// Arguments and return values are the same as for ProcessLimitOrder
+func (ob *OrderBook) ProcessLimitOrderWithOwner(side Side, orderID, owner string, quantity, price decimal.Decimal) (done []*Order, partial *Order, partialQuantityProcessed decimal.Decimal, err error) {
+	end, err := ob.journaled(JournalEntry{Method: "ProcessLimitOrderWithOwner", Side: side, OrderID: orderID, Owner: owner, Quantity: quantity, Price: price})
+	if err != nil {
+		return nil, nil, decimal.Zero, err
+	}
+	defer end()
+
 	if _, ok := ob.orders[orderID]; ok {
 		return nil, nil, decimal.Zero, ErrOrderExists
 	}
@@ -140,6 +208,23 @@ func (ob *OrderBook) ProcessLimitOrder(side Side, orderID string, quantity, pric
 		return nil, nil, decimal.Zero, ErrInvalidPrice
 	}
 
+	if err := ob.checkLimitOrder(price); err != nil {
+		return nil, nil, decimal.Zero, err
+	}
+
+	if err := ob.checkExposure(owner, side, quantity); err != nil {
+		return nil, nil, decimal.Zero, err
+	}
+
+	if err := ob.reserveLimit(side, orderID, owner, quantity, price); err != nil {
+		return nil, nil, decimal.Zero, err
+	}
+
+	return ob.placeLimitOrder(side, orderID, owner, quantity, price)
+}
+
+// placeLimitOrder matches the checked limit order with reserved funds and puts its rest to the order book
+func (ob *OrderBook) placeLimitOrder(side Side, orderID, owner string, quantity, price decimal.Decimal) (done []*Order, partial *Order, partialQuantityProcessed decimal.Decimal, err error) {
 	quantityToTrade := quantity
 	var (
 		sideToProcess *OrderSide
@@ -160,9 +245,12 @@ func (ob *OrderBook) ProcessLimitOrder(side Side, orderID string, quantity, pric
 		iter = ob.bids.MaxPriceQueue
 	}
 
+	taker := NewOrder(orderID, side, quantity, price, ob.now())
+	taker.owner = owner
+
 	bestPrice := iter()
-	for quantityToTrade.Sign() > 0 && sideToProcess.Len() > 0 && comparator(bestPrice.Price()) {
-		ordersDone, partialDone, partialQty, quantityLeft := ob.processQueue(bestPrice, quantityToTrade)
+	for quantityToTrade.Sign() > 0 && sideToProcess.Len() > 0 && ob.matching() && comparator(bestPrice.Price()) {
+		ordersDone, partialDone, partialQty, quantityLeft := ob.processQueue(bestPrice, quantityToTrade, taker)
 		done = append(done, ordersDone...)
 		partial = partialDone
 		partialQuantityProcessed = partialQty
@@ -170,13 +258,20 @@ func (ob *OrderBook) ProcessLimitOrder(side Side, orderID string, quantity, pric
 		bestPrice = iter()
 	}
 
+	if ob.phase == Halted {
+		ob.release(owner, orderID)
+		return done, partial, partialQuantityProcessed, ErrTradingHalted
+	}
+
 	if quantityToTrade.Sign() > 0 {
-		o := NewOrder(orderID, side, quantityToTrade, price, time.Now().UTC())
+		o := NewOrder(orderID, side, quantityToTrade, price, ob.now())
+		o.owner = owner
 		if len(done) > 0 {
 			partialQuantityProcessed = quantity.Sub(quantityToTrade)
 			partial = o
 		}
-		ob.orders[orderID] = sideToAdd.Append(o)
+		ob.addOrder(o, sideToAdd.Append(o))
+		ob.orderEvent(OrderNew, o)
 	} else {
 		totalQuantity := decimal.Zero
 		totalPrice := decimal.Zero
@@ -191,26 +286,38 @@ func (ob *OrderBook) ProcessLimitOrder(side Side, orderID string, quantity, pric
 			totalPrice = totalPrice.Add(partial.Price().Mul(partialQuantityProcessed))
 		}
 
-		done = append(done, NewOrder(orderID, side, quantity, totalPrice.Div(totalQuantity), time.Now().UTC()))
+		o := NewOrder(orderID, side, quantity, totalPrice.Div(totalQuantity), ob.now())
+		o.owner = owner
+		done = append(done, o)
+		ob.release(owner, orderID)
 	}
 	return
 }
 
-func (ob *OrderBook) processQueue(orderQueue *OrderQueue, quantityToTrade decimal.Decimal) (done []*Order, partial *Order, partialQuantityProcessed, quantityLeft decimal.Decimal) {
+func (ob *OrderBook) processQueue(orderQueue *OrderQueue, quantityToTrade decimal.Decimal, taker *Order) (done []*Order, partial *Order, partialQuantityProcessed, quantityLeft decimal.Decimal) {
 	quantityLeft = quantityToTrade
 
+	if !ob.allowTrade(orderQueue.Price()) {
+		return
+	}
+
 	for orderQueue.Len() > 0 && quantityLeft.Sign() > 0 {
 		headOrderEl := orderQueue.Head()
 		headOrder := headOrderEl.Value.(*Order)
 
 		if quantityLeft.LessThan(headOrder.Quantity()) {
 			partial = NewOrder(headOrder.ID(), headOrder.Side(), headOrder.Quantity().Sub(quantityLeft), headOrder.Price(), headOrder.Time())
+			partial.owner = headOrder.Owner()
 			partialQuantityProcessed = quantityLeft
-			orderQueue.Update(headOrderEl, partial)
+			ob.GetOrderSide(headOrder.Side()).Update(headOrderEl, partial)
+			ob.trade(taker, headOrder, headOrder.Price(), quantityLeft, false)
+			ob.orderEvent(OrderPartiallyFilled, partial)
 			quantityLeft = decimal.Zero
 		} else {
 			quantityLeft = quantityLeft.Sub(headOrder.Quantity())
-			done = append(done, ob.CancelOrder(headOrder.ID()))
+			ob.trade(taker, headOrder, headOrder.Price(), headOrder.Quantity(), false)
+			done = append(done, ob.removeOrder(headOrder.ID()))
+			ob.orderEvent(OrderFilled, headOrder)
 		}
 	}
 
@@ -250,13 +357,36 @@ func (ob *OrderBook) Depth() (asks, bids []*PriceLevel) {
 }
 
 // CancelOrder removes order with given ID from the order book
-func (ob *OrderBook) CancelOrder(orderID string) *Order {
+// Return:
+//      error - ErrOrderNotExists if there is no order with given ID or
+//              not nil if current trading phase doesn't allow cancels
+func (ob *OrderBook) CancelOrder(orderID string) (*Order, error) {
+	end, err := ob.journaled(JournalEntry{Method: "CancelOrder", OrderID: orderID})
+	if err != nil {
+		return nil, err
+	}
+	defer end()
+
+	if err := ob.checkCancel(); err != nil {
+		return nil, err
+	}
+
+	if o := ob.removeOrder(orderID); o != nil {
+		ob.orderEvent(OrderCancelled, o)
+		return o, nil
+	}
+
+	return nil, ErrOrderNotExists
+}
+
+// removeOrder removes order with given ID from the sides and indexes
+func (ob *OrderBook) removeOrder(orderID string) *Order {
 	e, ok := ob.orders[orderID]
 	if !ok {
 		return nil
 	}
 
-	delete(ob.orders, orderID)
+	ob.dropOrder(e.Value.(*Order))
 
 	if e.Value.(*Order).Side() == Buy {
 		return ob.bids.Remove(e)
@@ -265,6 +395,40 @@ func (ob *OrderBook) CancelOrder(orderID string) *Order {
 	return ob.asks.Remove(e)
 }
 
+// addOrder registers order placed to the side in the order book indexes
+func (ob *OrderBook) addOrder(o *Order, e *list.Element) {
+	ob.orders[o.ID()] = e
+
+	if o.Owner() == "" {
+		return
+	}
+
+	owned, ok := ob.owners[o.Owner()]
+	if !ok {
+		owned = map[string]*list.Element{}
+		ob.owners[o.Owner()] = owned
+	}
+	owned[o.ID()] = e
+}
+
+// dropOrder removes order from the order book indexes and releases its funds
+func (ob *OrderBook) dropOrder(o *Order) {
+	ob.unindexOrder(o)
+	ob.release(o.Owner(), o.ID())
+}
+
+// unindexOrder removes order from the order book indexes
+func (ob *OrderBook) unindexOrder(o *Order) {
+	delete(ob.orders, o.ID())
+
+	if owned, ok := ob.owners[o.Owner()]; ok {
+		delete(owned, o.ID())
+		if len(owned) == 0 {
+			delete(ob.owners, o.Owner())
+		}
+	}
+}
+
 
 // CalculateMarketPrice returns total market price for requested quantity
 // if err is not nil price returns total price of all levels in side
@@ -314,11 +478,15 @@ func (ob *OrderBook) String() string {
 func (ob *OrderBook) MarshalJSON() ([]byte, error) {
 	return json.Marshal(
 		&struct {
-			Asks *OrderSide `json:"asks"`
-			Bids *OrderSide `json:"bids"`
+			Asks      *OrderSide      `json:"asks"`
+			Bids      *OrderSide      `json:"bids"`
+			Phase     Phase           `json:"phase"`
+			Positions *PositionKeeper `json:"positions,omitempty"`
 		}{
-			Asks: ob.asks,
-			Bids: ob.bids,
+			Asks:      ob.asks,
+			Bids:      ob.bids,
+			Phase:     ob.phase,
+			Positions: ob.positions,
 		},
 	)
 }
@@ -326,9 +494,13 @@ func (ob *OrderBook) MarshalJSON() ([]byte, error) {
 // UnmarshalJSON implements json.Unmarshaler interface
 func (ob *OrderBook) UnmarshalJSON(data []byte) error {
 	obj := struct {
-		Asks *OrderSide `json:"asks"`
-		Bids *OrderSide `json:"bids"`
-	}{}
+		Asks      *OrderSide      `json:"asks"`
+		Bids      *OrderSide      `json:"bids"`
+		Phase     Phase           `json:"phase"`
+		Positions *PositionKeeper `json:"positions"`
+	}{
+		Positions: ob.positions,
+	}
 
 	if err := json.Unmarshal(data, &obj); err != nil {
 		return err
@@ -336,14 +508,17 @@ func (ob *OrderBook) UnmarshalJSON(data []byte) error {
 
 	ob.asks = obj.Asks
 	ob.bids = obj.Bids
+	ob.phase = obj.Phase
+	ob.positions = obj.Positions
 	ob.orders = map[string]*list.Element{}
+	ob.owners = map[string]map[string]*list.Element{}
 
 	for _, order := range ob.asks.Orders() {
-		ob.orders[order.Value.(*Order).ID()] = order
+		ob.addOrder(order.Value.(*Order), order)
 	}
 
 	for _, order := range ob.bids.Orders() {
-		ob.orders[order.Value.(*Order).ID()] = order
+		ob.addOrder(order.Value.(*Order), order)
 	}
 
 	return nil
@@ -128,7 +128,7 @@ func TestLimitProcess(t *testing.T) {
 		t.Fatal("Can add zero price")
 	}
 
-	if o := ob.CancelOrder("order-b100"); o != nil {
+	if _, err := ob.CancelOrder("order-b100"); err != ErrOrderNotExists {
 		t.Fatal("Can cancel done order")
 	}
 
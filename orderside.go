@@ -87,6 +87,33 @@ func (os *OrderSide) Remove(e *list.Element) *Order {
 	return o
 }
 
+// Update sets up new order to list value and keeps side volume consistent
+func (os *OrderSide) Update(e *list.Element, o *Order) *list.Element {
+	os.volume = os.volume.Sub(e.Value.(*Order).Quantity())
+	os.volume = os.volume.Add(o.Quantity())
+	return os.prices[o.Price().String()].Update(e, o)
+}
+
+// RemovePriceQueue removes whole price level with all of its orders
+func (os *OrderSide) RemovePriceQueue(oq *OrderQueue) []*Order {
+	strPrice := oq.Price().String()
+	if os.prices[strPrice] != oq {
+		return nil
+	}
+
+	orders := make([]*Order, 0, oq.Len())
+	for iter := oq.Head(); iter != nil; iter = iter.Next() {
+		orders = append(orders, iter.Value.(*Order))
+	}
+
+	delete(os.prices, strPrice)
+	os.priceTree.Remove(oq.Price())
+	os.depth--
+	os.numOrders -= len(orders)
+	os.volume = os.volume.Sub(oq.Volume())
+	return orders
+}
+
 // MaxPriceQueue returns maximal level of price
 func (os *OrderSide) MaxPriceQueue() *OrderQueue {
 	if os.depth > 0 {
@@ -129,6 +156,24 @@ func (os *OrderSide) LessThan(price decimal.Decimal) *OrderQueue {
 	return nil
 }
 
+// Floor returns nearest OrderQueue with price less than or equal to given
+func (os *OrderSide) Floor(price decimal.Decimal) *OrderQueue {
+	if node, found := os.priceTree.Floor(price); found {
+		return node.Value.(*OrderQueue)
+	}
+
+	return nil
+}
+
+// Ceiling returns nearest OrderQueue with price greater than or equal to given
+func (os *OrderSide) Ceiling(price decimal.Decimal) *OrderQueue {
+	if node, found := os.priceTree.Ceiling(price); found {
+		return node.Value.(*OrderQueue)
+	}
+
+	return nil
+}
+
 // GreaterThan returns nearest OrderQueue with price greater than given
 func (os *OrderSide) GreaterThan(price decimal.Decimal) *OrderQueue {
 	tree := os.priceTree.Tree
@@ -210,8 +255,10 @@ func (os *OrderSide) UnmarshalJSON(data []byte) error {
 		Tree: rbt.NewWith(rbtComparator),
 	}
 
+	os.volume = decimal.Zero
 	for price, queue := range os.prices {
 		os.priceTree.Put(decimal.RequireFromString(price), queue)
+		os.volume = os.volume.Add(queue.Volume())
 	}
 
 	return nil
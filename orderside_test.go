@@ -145,3 +145,34 @@ func BenchmarkOrderSide(b *testing.B) {
 	elapsed := time.Since(stopwatch)
 	fmt.Printf("\n\nElapsed: %s\nTransactions per second: %f\n", elapsed, float64(b.N)/elapsed.Seconds())
 }
+
+func TestOrderSideRemovePriceQueue(t *testing.T) {
+	ot := NewOrderSide()
+
+	for i := 1; i <= 3; i++ {
+		ot.Append(NewOrder(fmt.Sprintf("order-%d", i), Sell, decimal.New(10, 0), decimal.New(int64(i*10), 0), time.Now().UTC()))
+	}
+	ot.Append(NewOrder("order-4", Sell, decimal.New(5, 0), decimal.New(20, 0), time.Now().UTC()))
+
+	level := ot.Floor(decimal.New(25, 0))
+	if level == nil || !level.Price().Equal(decimal.New(20, 0)) {
+		t.Fatal("invalid floor")
+	}
+
+	if ot.Ceiling(decimal.New(20, 0)) != level || ot.Ceiling(decimal.New(31, 0)) != nil {
+		t.Fatal("invalid ceiling")
+	}
+
+	orders := ot.RemovePriceQueue(level)
+	if len(orders) != 2 || orders[0].ID() != "order-2" || orders[1].ID() != "order-4" {
+		t.Fatal("invalid removed orders")
+	}
+
+	if ot.Len() != 2 || ot.Depth() != 2 || !ot.Volume().Equal(decimal.New(20, 0)) {
+		t.Fatal("invalid side state")
+	}
+
+	if ot.RemovePriceQueue(level) != nil {
+		t.Fatal("can remove price level twice")
+	}
+}
@@ -0,0 +1,126 @@
+package ouch
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"sync"
+)
+
+// ErrLoginRejected is returned by Dial if the server rejects the login
+var ErrLoginRejected = errors.New("ouch: login rejected")
+
+// Response holds the last server message read by the client, only the field of Type is valid
+type Response struct {
+	Type      byte
+	Accepted  Accepted
+	Replaced  Replaced
+	Executed  Executed
+	Cancelled Cancelled
+	Rejected  Rejected
+}
+
+// Client is order entry connection to the server. Send methods are safe for
+// concurrent use, Receive should be called from single goroutine
+type Client struct {
+	conn net.Conn
+	r    *bufio.Reader
+
+	mu   sync.Mutex
+	w    *bufio.Writer
+	wbuf [MaxMessageSize]byte
+	rbuf [MaxMessageSize]byte
+}
+
+// Dial connects to the server and logs in with given username and password
+func Dial(addr, username, password string) (*Client, error) {
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Client{
+		conn: conn,
+		r:    bufio.NewReader(conn),
+		w:    bufio.NewWriter(conn),
+	}
+
+	login := Login{Username: NewUsername(username), Password: NewPassword(password)}
+	if err := c.send(login.Encode(c.wbuf[:])); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	data, err := ReadMessage(c.r, c.rbuf[:], ResponseSize)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	if data[0] != TypeLoginAccepted {
+		conn.Close()
+		return nil, ErrLoginRejected
+	}
+
+	return c, nil
+}
+
+// EnterOrder sends new order
+func (c *Client) EnterOrder(m *EnterOrder) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.send(m.Encode(c.wbuf[:]))
+}
+
+// ReplaceOrder sends replace of the resting order
+func (c *Client) ReplaceOrder(m *ReplaceOrder) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.send(m.Encode(c.wbuf[:]))
+}
+
+// CancelOrder sends cancel of the resting order
+func (c *Client) CancelOrder(m *CancelOrder) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.send(m.Encode(c.wbuf[:]))
+}
+
+// Receive reads the next server message into resp
+func (c *Client) Receive(resp *Response) error {
+	data, err := ReadMessage(c.r, c.rbuf[:], ResponseSize)
+	if err != nil {
+		return err
+	}
+
+	resp.Type = data[0]
+	switch resp.Type {
+	case TypeAccepted:
+		return resp.Accepted.Decode(data)
+	case TypeReplaced:
+		return resp.Replaced.Decode(data)
+	case TypeExecuted:
+		return resp.Executed.Decode(data)
+	case TypeCancelled:
+		return resp.Cancelled.Decode(data)
+	case TypeRejected:
+		return resp.Rejected.Decode(data)
+	}
+	return ErrUnknownMessage
+}
+
+// Close closes the connection
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// send writes first n bytes of the write buffer
+func (c *Client) send(n int) error {
+	if _, err := c.w.Write(c.wbuf[:n]); err != nil {
+		return err
+	}
+	return c.w.Flush()
+}
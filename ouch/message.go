@@ -0,0 +1,521 @@
+// Package ouch implements compact binary order entry protocol in the style of OUCH.
+//
+// Every message starts with one byte of its type followed by fixed-length fields,
+// integers are big-endian. Quantities and prices are signed 64-bit integers with
+// Scale implied decimal places, orders are identified by 14 byte client tokens
+// padded with spaces. Client sends Login with username and password as the first
+// message, server answers with LoginAccepted or LoginRejected
+package ouch
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+
+	"github.com/shopspring/decimal"
+)
+
+// Scale is number of implied decimal places of quantities and prices
+const Scale = 8
+
+// MaxMessageSize is size of the longest message
+const MaxMessageSize = 64
+
+// Types of the client messages
+const (
+	TypeLogin        = 'L'
+	TypeEnterOrder   = 'O'
+	TypeReplaceOrder = 'U'
+	TypeCancelOrder  = 'X'
+)
+
+// Types of the server messages
+const (
+	TypeLoginAccepted = 'a'
+	TypeLoginRejected = 'j'
+	TypeAccepted      = 'A'
+	TypeReplaced      = 'U'
+	TypeExecuted      = 'E'
+	TypeCancelled     = 'C'
+	TypeRejected      = 'J'
+)
+
+// Sides of the orders
+const (
+	SideBuy  = 'B'
+	SideSell = 'S'
+)
+
+// Reasons of rejects and cancels
+const (
+	ReasonInvalidQuantity = 'Q'
+	ReasonInvalidPrice    = 'X'
+	ReasonInvalidSide     = 'B'
+	ReasonDuplicateToken  = 'D'
+	ReasonUnknownToken    = 'N'
+	ReasonHalted          = 'H'
+	ReasonClosed          = 'C'
+	ReasonRisk            = 'R'
+	ReasonOther           = 'O'
+	ReasonUserRequested   = 'U'
+	ReasonImmediate       = 'I'
+	ReasonSupervisory     = 'S'
+	ReasonUserInUse       = 'L'
+	ReasonNotAuthorized   = 'A'
+)
+
+// Sizes of the messages including type
+const (
+	SizeLogin         = 1 + 16 + 16
+	SizeEnterOrder    = 1 + 14 + 1 + 8 + 8
+	SizeReplaceOrder  = 1 + 14 + 14 + 8 + 8
+	SizeCancelOrder   = 1 + 14
+	SizeLoginAccepted = 1
+	SizeLoginRejected = 1 + 1
+	SizeAccepted      = 1 + 8 + 14 + 1 + 8 + 8
+	SizeReplaced      = 1 + 8 + 14 + 14 + 8 + 8
+	SizeExecuted      = 1 + 8 + 14 + 8 + 8 + 8
+	SizeCancelled     = 1 + 8 + 14 + 8 + 1
+	SizeRejected      = 1 + 8 + 14 + 1
+)
+
+// Errors of the codec
+var (
+	ErrUnknownMessage = errors.New("ouch: unknown message type")
+	ErrShortMessage   = errors.New("ouch: message is too short")
+	ErrOutOfRange     = errors.New("ouch: decimal value does not fit fixed point")
+)
+
+// limits of the fixed point values
+var (
+	maxFixed = decimal.NewFromInt(math.MaxInt64)
+	minFixed = decimal.NewFromInt(math.MinInt64)
+)
+
+// Token identifies the order of the client
+type Token [14]byte
+
+// Username identifies the client
+type Username [16]byte
+
+// Password authenticates the client
+type Password [16]byte
+
+// NewToken returns token padded with spaces, longer values are truncated
+func NewToken(s string) (t Token) {
+	pad(t[:], s)
+	return
+}
+
+// String returns token without padding
+func (t Token) String() string {
+	return unpad(t[:])
+}
+
+// NewUsername returns username padded with spaces, longer values are truncated
+func NewUsername(s string) (u Username) {
+	pad(u[:], s)
+	return
+}
+
+// String returns username without padding
+func (u Username) String() string {
+	return unpad(u[:])
+}
+
+// NewPassword returns password padded with spaces, longer values are truncated
+func NewPassword(s string) (p Password) {
+	pad(p[:], s)
+	return
+}
+
+// String returns password without padding
+func (p Password) String() string {
+	return unpad(p[:])
+}
+
+// Login is the first message of the client
+type Login struct {
+	Username Username
+	Password Password
+}
+
+// EnterOrder places new order, zero price means market order which is never placed to the book
+type EnterOrder struct {
+	Token    Token
+	Side     byte
+	Quantity int64
+	Price    int64
+}
+
+// ReplaceOrder replaces quantity and price of the resting order with new token,
+// the order loses time priority
+type ReplaceOrder struct {
+	Existing    Token
+	Replacement Token
+	Quantity    int64
+	Price       int64
+}
+
+// CancelOrder removes the resting order
+type CancelOrder struct {
+	Token Token
+}
+
+// LoginRejected is reply to Login with unknown or already connected username or invalid password
+type LoginRejected struct {
+	Reason byte
+}
+
+// Accepted acknowledges EnterOrder
+type Accepted struct {
+	Timestamp int64
+	Token     Token
+	Side      byte
+	Quantity  int64
+	Price     int64
+}
+
+// Replaced acknowledges ReplaceOrder
+type Replaced struct {
+	Timestamp   int64
+	Replacement Token
+	Previous    Token
+	Quantity    int64
+	Price       int64
+}
+
+// Executed reports execution of the order, Match is trade ID of the order book
+type Executed struct {
+	Timestamp int64
+	Token     Token
+	Quantity  int64
+	Price     int64
+	Match     uint64
+}
+
+// Cancelled reports quantity of the order removed without execution
+type Cancelled struct {
+	Timestamp int64
+	Token     Token
+	Quantity  int64
+	Reason    byte
+}
+
+// Rejected reports the message which can not be processed
+type Rejected struct {
+	Timestamp int64
+	Token     Token
+	Reason    byte
+}
+
+// Encode writes the message to b and returns its size
+func (m *Login) Encode(b []byte) int {
+	_ = b[SizeLogin-1]
+	b[0] = TypeLogin
+	copy(b[1:17], m.Username[:])
+	copy(b[17:33], m.Password[:])
+	return SizeLogin
+}
+
+// Decode reads the message from b
+func (m *Login) Decode(b []byte) error {
+	if len(b) < SizeLogin || b[0] != TypeLogin {
+		return ErrShortMessage
+	}
+	copy(m.Username[:], b[1:17])
+	copy(m.Password[:], b[17:33])
+	return nil
+}
+
+// Encode writes the message to b and returns its size
+func (m *EnterOrder) Encode(b []byte) int {
+	_ = b[SizeEnterOrder-1]
+	b[0] = TypeEnterOrder
+	copy(b[1:15], m.Token[:])
+	b[15] = m.Side
+	binary.BigEndian.PutUint64(b[16:], uint64(m.Quantity))
+	binary.BigEndian.PutUint64(b[24:], uint64(m.Price))
+	return SizeEnterOrder
+}
+
+// Decode reads the message from b
+func (m *EnterOrder) Decode(b []byte) error {
+	if len(b) < SizeEnterOrder || b[0] != TypeEnterOrder {
+		return ErrShortMessage
+	}
+	copy(m.Token[:], b[1:15])
+	m.Side = b[15]
+	m.Quantity = int64(binary.BigEndian.Uint64(b[16:]))
+	m.Price = int64(binary.BigEndian.Uint64(b[24:]))
+	return nil
+}
+
+// Encode writes the message to b and returns its size
+func (m *ReplaceOrder) Encode(b []byte) int {
+	_ = b[SizeReplaceOrder-1]
+	b[0] = TypeReplaceOrder
+	copy(b[1:15], m.Existing[:])
+	copy(b[15:29], m.Replacement[:])
+	binary.BigEndian.PutUint64(b[29:], uint64(m.Quantity))
+	binary.BigEndian.PutUint64(b[37:], uint64(m.Price))
+	return SizeReplaceOrder
+}
+
+// Decode reads the message from b
+func (m *ReplaceOrder) Decode(b []byte) error {
+	if len(b) < SizeReplaceOrder || b[0] != TypeReplaceOrder {
+		return ErrShortMessage
+	}
+	copy(m.Existing[:], b[1:15])
+	copy(m.Replacement[:], b[15:29])
+	m.Quantity = int64(binary.BigEndian.Uint64(b[29:]))
+	m.Price = int64(binary.BigEndian.Uint64(b[37:]))
+	return nil
+}
+
+// Encode writes the message to b and returns its size
+func (m *CancelOrder) Encode(b []byte) int {
+	_ = b[SizeCancelOrder-1]
+	b[0] = TypeCancelOrder
+	copy(b[1:15], m.Token[:])
+	return SizeCancelOrder
+}
+
+// Decode reads the message from b
+func (m *CancelOrder) Decode(b []byte) error {
+	if len(b) < SizeCancelOrder || b[0] != TypeCancelOrder {
+		return ErrShortMessage
+	}
+	copy(m.Token[:], b[1:15])
+	return nil
+}
+
+// Encode writes the message to b and returns its size
+func (m *LoginRejected) Encode(b []byte) int {
+	_ = b[SizeLoginRejected-1]
+	b[0] = TypeLoginRejected
+	b[1] = m.Reason
+	return SizeLoginRejected
+}
+
+// Decode reads the message from b
+func (m *LoginRejected) Decode(b []byte) error {
+	if len(b) < SizeLoginRejected || b[0] != TypeLoginRejected {
+		return ErrShortMessage
+	}
+	m.Reason = b[1]
+	return nil
+}
+
+// Encode writes the message to b and returns its size
+func (m *Accepted) Encode(b []byte) int {
+	_ = b[SizeAccepted-1]
+	b[0] = TypeAccepted
+	binary.BigEndian.PutUint64(b[1:], uint64(m.Timestamp))
+	copy(b[9:23], m.Token[:])
+	b[23] = m.Side
+	binary.BigEndian.PutUint64(b[24:], uint64(m.Quantity))
+	binary.BigEndian.PutUint64(b[32:], uint64(m.Price))
+	return SizeAccepted
+}
+
+// Decode reads the message from b
+func (m *Accepted) Decode(b []byte) error {
+	if len(b) < SizeAccepted || b[0] != TypeAccepted {
+		return ErrShortMessage
+	}
+	m.Timestamp = int64(binary.BigEndian.Uint64(b[1:]))
+	copy(m.Token[:], b[9:23])
+	m.Side = b[23]
+	m.Quantity = int64(binary.BigEndian.Uint64(b[24:]))
+	m.Price = int64(binary.BigEndian.Uint64(b[32:]))
+	return nil
+}
+
+// Encode writes the message to b and returns its size
+func (m *Replaced) Encode(b []byte) int {
+	_ = b[SizeReplaced-1]
+	b[0] = TypeReplaced
+	binary.BigEndian.PutUint64(b[1:], uint64(m.Timestamp))
+	copy(b[9:23], m.Replacement[:])
+	copy(b[23:37], m.Previous[:])
+	binary.BigEndian.PutUint64(b[37:], uint64(m.Quantity))
+	binary.BigEndian.PutUint64(b[45:], uint64(m.Price))
+	return SizeReplaced
+}
+
+// Decode reads the message from b
+func (m *Replaced) Decode(b []byte) error {
+	if len(b) < SizeReplaced || b[0] != TypeReplaced {
+		return ErrShortMessage
+	}
+	m.Timestamp = int64(binary.BigEndian.Uint64(b[1:]))
+	copy(m.Replacement[:], b[9:23])
+	copy(m.Previous[:], b[23:37])
+	m.Quantity = int64(binary.BigEndian.Uint64(b[37:]))
+	m.Price = int64(binary.BigEndian.Uint64(b[45:]))
+	return nil
+}
+
+// Encode writes the message to b and returns its size
+func (m *Executed) Encode(b []byte) int {
+	_ = b[SizeExecuted-1]
+	b[0] = TypeExecuted
+	binary.BigEndian.PutUint64(b[1:], uint64(m.Timestamp))
+	copy(b[9:23], m.Token[:])
+	binary.BigEndian.PutUint64(b[23:], uint64(m.Quantity))
+	binary.BigEndian.PutUint64(b[31:], uint64(m.Price))
+	binary.BigEndian.PutUint64(b[39:], m.Match)
+	return SizeExecuted
+}
+
+// Decode reads the message from b
+func (m *Executed) Decode(b []byte) error {
+	if len(b) < SizeExecuted || b[0] != TypeExecuted {
+		return ErrShortMessage
+	}
+	m.Timestamp = int64(binary.BigEndian.Uint64(b[1:]))
+	copy(m.Token[:], b[9:23])
+	m.Quantity = int64(binary.BigEndian.Uint64(b[23:]))
+	m.Price = int64(binary.BigEndian.Uint64(b[31:]))
+	m.Match = binary.BigEndian.Uint64(b[39:])
+	return nil
+}
+
+// Encode writes the message to b and returns its size
+func (m *Cancelled) Encode(b []byte) int {
+	_ = b[SizeCancelled-1]
+	b[0] = TypeCancelled
+	binary.BigEndian.PutUint64(b[1:], uint64(m.Timestamp))
+	copy(b[9:23], m.Token[:])
+	binary.BigEndian.PutUint64(b[23:], uint64(m.Quantity))
+	b[31] = m.Reason
+	return SizeCancelled
+}
+
+// Decode reads the message from b
+func (m *Cancelled) Decode(b []byte) error {
+	if len(b) < SizeCancelled || b[0] != TypeCancelled {
+		return ErrShortMessage
+	}
+	m.Timestamp = int64(binary.BigEndian.Uint64(b[1:]))
+	copy(m.Token[:], b[9:23])
+	m.Quantity = int64(binary.BigEndian.Uint64(b[23:]))
+	m.Reason = b[31]
+	return nil
+}
+
+// Encode writes the message to b and returns its size
+func (m *Rejected) Encode(b []byte) int {
+	_ = b[SizeRejected-1]
+	b[0] = TypeRejected
+	binary.BigEndian.PutUint64(b[1:], uint64(m.Timestamp))
+	copy(b[9:23], m.Token[:])
+	b[23] = m.Reason
+	return SizeRejected
+}
+
+// Decode reads the message from b
+func (m *Rejected) Decode(b []byte) error {
+	if len(b) < SizeRejected || b[0] != TypeRejected {
+		return ErrShortMessage
+	}
+	m.Timestamp = int64(binary.BigEndian.Uint64(b[1:]))
+	copy(m.Token[:], b[9:23])
+	m.Reason = b[23]
+	return nil
+}
+
+// RequestSize returns size of the client message by its type or zero for unknown type
+func RequestSize(msgType byte) int {
+	switch msgType {
+	case TypeLogin:
+		return SizeLogin
+	case TypeEnterOrder:
+		return SizeEnterOrder
+	case TypeReplaceOrder:
+		return SizeReplaceOrder
+	case TypeCancelOrder:
+		return SizeCancelOrder
+	}
+	return 0
+}
+
+// ResponseSize returns size of the server message by its type or zero for unknown type
+func ResponseSize(msgType byte) int {
+	switch msgType {
+	case TypeLoginAccepted:
+		return SizeLoginAccepted
+	case TypeLoginRejected:
+		return SizeLoginRejected
+	case TypeAccepted:
+		return SizeAccepted
+	case TypeReplaced:
+		return SizeReplaced
+	case TypeExecuted:
+		return SizeExecuted
+	case TypeCancelled:
+		return SizeCancelled
+	case TypeRejected:
+		return SizeRejected
+	}
+	return 0
+}
+
+// ReadMessage reads single message sized by size func into buf which must be
+// at least MaxMessageSize long and returns the message slice of buf
+func ReadMessage(r *bufio.Reader, buf []byte, size func(byte) int) ([]byte, error) {
+	msgType, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+
+	n := size(msgType)
+	if n == 0 {
+		return nil, ErrUnknownMessage
+	}
+
+	buf[0] = msgType
+	if _, err := io.ReadFull(r, buf[1:n]); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return buf[:n], nil
+}
+
+// ToDecimal converts fixed point value to decimal
+func ToDecimal(v int64) decimal.Decimal {
+	return decimal.New(v, -Scale)
+}
+
+// FromDecimal converts decimal to fixed point value
+// Return:
+//
+//	error - ErrOutOfRange if the value has more than Scale decimal places or does not fit int64
+func FromDecimal(d decimal.Decimal) (int64, error) {
+	shifted := d.Shift(Scale)
+	if !shifted.IsInteger() || shifted.GreaterThan(maxFixed) || shifted.LessThan(minFixed) {
+		return 0, ErrOutOfRange
+	}
+	return shifted.IntPart(), nil
+}
+
+func pad(dst []byte, s string) {
+	n := copy(dst, s)
+	for i := n; i < len(dst); i++ {
+		dst[i] = ' '
+	}
+}
+
+func unpad(b []byte) string {
+	n := len(b)
+	for n > 0 && b[n-1] == ' ' {
+		n--
+	}
+	return string(b[:n])
+}
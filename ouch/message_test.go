@@ -0,0 +1,147 @@
+package ouch
+
+import (
+	"bufio"
+	"bytes"
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestMessages(t *testing.T) {
+	buf := make([]byte, MaxMessageSize)
+
+	enter := EnterOrder{Token: NewToken("order-1"), Side: SideBuy, Quantity: 150000000, Price: -1}
+	var enterDecoded EnterOrder
+	if n := enter.Encode(buf); n != SizeEnterOrder || enterDecoded.Decode(buf[:n]) != nil || enterDecoded != enter {
+		t.Fatal("Invalid enter order round trip", enterDecoded)
+	}
+
+	replace := ReplaceOrder{Existing: NewToken("a"), Replacement: NewToken("b"), Quantity: 1, Price: 2}
+	var replaceDecoded ReplaceOrder
+	if n := replace.Encode(buf); n != SizeReplaceOrder || replaceDecoded.Decode(buf[:n]) != nil || replaceDecoded != replace {
+		t.Fatal("Invalid replace order round trip", replaceDecoded)
+	}
+
+	executed := Executed{Timestamp: 1, Token: NewToken("x"), Quantity: 2, Price: 3, Match: 4}
+	var executedDecoded Executed
+	if n := executed.Encode(buf); n != SizeExecuted || executedDecoded.Decode(buf[:n]) != nil || executedDecoded != executed {
+		t.Fatal("Invalid executed round trip", executedDecoded)
+	}
+
+	replaced := Replaced{Timestamp: 1, Replacement: NewToken("b"), Previous: NewToken("a"), Quantity: 5, Price: 6}
+	var replacedDecoded Replaced
+	if n := replaced.Encode(buf); n != SizeReplaced || replacedDecoded.Decode(buf[:n]) != nil || replacedDecoded != replaced {
+		t.Fatal("Invalid replaced round trip", replacedDecoded)
+	}
+
+	login := Login{Username: NewUsername("user"), Password: NewPassword("secret")}
+	var loginDecoded Login
+	if n := login.Encode(buf); n != SizeLogin || loginDecoded.Decode(buf[:n]) != nil || loginDecoded.Password.String() != "secret" {
+		t.Fatal("Invalid login round trip", loginDecoded)
+	}
+
+	if err := enterDecoded.Decode(buf[:SizeEnterOrder-1]); err != ErrShortMessage {
+		t.Fatal("Short message is decoded", err)
+	}
+
+	if NewToken("order-1").String() != "order-1" || NewToken("order-with-too-long-token").String() != "order-with-too" {
+		t.Fatal("Invalid token padding")
+	}
+}
+
+func TestDecimal(t *testing.T) {
+	if v, err := FromDecimal(decimal.RequireFromString("1.23456789")); err != nil || v != 123456789 {
+		t.Fatal("Invalid fixed point value", v, err)
+	}
+
+	if !ToDecimal(123456789).Equal(decimal.RequireFromString("1.23456789")) {
+		t.Fatal("Invalid decimal value", ToDecimal(123456789))
+	}
+
+	if _, err := FromDecimal(decimal.RequireFromString("0.000000001")); err != ErrOutOfRange {
+		t.Fatal("Too precise value is converted", err)
+	}
+
+	if _, err := FromDecimal(decimal.RequireFromString("100000000000000")); err != ErrOutOfRange {
+		t.Fatal("Too big value is converted", err)
+	}
+}
+
+func TestCodecAllocations(t *testing.T) {
+	var stream bytes.Buffer
+	buf := make([]byte, MaxMessageSize)
+	enter := EnterOrder{Token: NewToken("order-1"), Side: SideSell, Quantity: 1, Price: 2}
+	for i := 0; i < 1000; i++ {
+		n := enter.Encode(buf)
+		stream.Write(buf[:n])
+	}
+
+	r := bufio.NewReader(bytes.NewReader(stream.Bytes()))
+	var decoded EnterOrder
+
+	allocs := testing.AllocsPerRun(500, func() {
+		enter.Encode(buf)
+		data, err := ReadMessage(r, buf, RequestSize)
+		if err != nil || decoded.Decode(data) != nil {
+			t.Fatal("Invalid message", err)
+		}
+	})
+
+	if allocs != 0 {
+		t.Fatal("Codec allocates", allocs)
+	}
+}
+
+func FuzzReadMessage(f *testing.F) {
+	buf := make([]byte, MaxMessageSize)
+	f.Add(buf[:(&EnterOrder{Token: NewToken("a"), Side: SideBuy, Quantity: 1, Price: 1}).Encode(buf)])
+	f.Add(buf[:(&ReplaceOrder{Existing: NewToken("a"), Replacement: NewToken("b")}).Encode(buf)])
+	f.Add(buf[:(&CancelOrder{Token: NewToken("a")}).Encode(buf)])
+	f.Add(buf[:(&Login{Username: NewUsername("user"), Password: NewPassword("secret")}).Encode(buf)])
+	f.Add([]byte{TypeEnterOrder, 1, 2})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		r := bufio.NewReader(bytes.NewReader(data))
+		out := make([]byte, MaxMessageSize)
+
+		for {
+			msg, err := ReadMessage(r, buf, RequestSize)
+			if err != nil {
+				return
+			}
+
+			var n int
+			switch msg[0] {
+			case TypeLogin:
+				var m Login
+				if err := m.Decode(msg); err != nil {
+					t.Fatal(err)
+				}
+				n = m.Encode(out)
+			case TypeEnterOrder:
+				var m EnterOrder
+				if err := m.Decode(msg); err != nil {
+					t.Fatal(err)
+				}
+				n = m.Encode(out)
+			case TypeReplaceOrder:
+				var m ReplaceOrder
+				if err := m.Decode(msg); err != nil {
+					t.Fatal(err)
+				}
+				n = m.Encode(out)
+			case TypeCancelOrder:
+				var m CancelOrder
+				if err := m.Decode(msg); err != nil {
+					t.Fatal(err)
+				}
+				n = m.Encode(out)
+			}
+
+			if !bytes.Equal(msg, out[:n]) {
+				t.Fatal("Invalid round trip", msg, out[:n])
+			}
+		}
+	})
+}
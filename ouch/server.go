@@ -0,0 +1,526 @@
+package ouch
+
+import (
+	"bufio"
+	"crypto/subtle"
+	"errors"
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"orderbook"
+)
+
+// loginTimeout limits time between connect and Login message
+const loginTimeout = 10 * time.Second
+
+// frame is encoded server message
+type frame struct {
+	n int
+	b [MaxMessageSize]byte
+}
+
+// session is logged on connection of the user
+type session struct {
+	user string
+	conn net.Conn
+	out  chan frame
+	once sync.Once
+}
+
+// orderState tracks the order entered by the session
+type orderState struct {
+	session *session
+	token   Token
+	bookID  string
+	side    orderbook.Side
+}
+
+// outbound is message waiting for the end of the command
+type outbound struct {
+	session *session
+	frame   frame
+}
+
+// Server binds the binary protocol to the matching engine. Only configured users
+// log in, username of the session is the owner of its orders, tokens are unique
+// within the user. Usernames have no slashes, so order IDs of different users
+// are different.
+// Messages to the session which does not read them fast enough disconnect it
+type Server struct {
+	// ErrorLog receives errors which disconnect the session, nil logs them by the
+	// standard logger
+	ErrorLog *log.Logger
+
+	engine    *orderbook.Engine
+	buffer    int
+	passwords map[string]string
+
+	mu    sync.Mutex
+	users map[string]*session
+
+	// fields below are accessed from the matching goroutine only
+	orders    map[string]*orderState
+	active    *orderState
+	replacing *orderState
+	reason    byte
+	pending   []outbound
+}
+
+// NewServer creates server of the matching engine
+// Arguments:
+//
+//	engine    - matching engine of the order book
+//	buffer    - maximum number of messages queued for the session
+//	passwords - passwords of the users allowed to log in by username
+func NewServer(engine *orderbook.Engine, buffer int, passwords map[string]string) (*Server, error) {
+	s := &Server{
+		engine:    engine,
+		buffer:    buffer,
+		passwords: passwords,
+		users:     map[string]*session{},
+		orders:    map[string]*orderState{},
+		reason:    ReasonSupervisory,
+	}
+
+	err := engine.Do(func(ob *orderbook.OrderBook) {
+		ob.OnTrade(s.onTrade)
+		ob.OnOrderEvent(s.onOrderEvent)
+		engine.OnCommand(s.flush)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Serve accepts connections until the listener is closed
+func (s *Server) Serve(l net.Listener) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go s.serve(conn)
+	}
+}
+
+// serve handles login and messages of the connection
+func (s *Server) serve(conn net.Conn) {
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	buf := make([]byte, MaxMessageSize)
+
+	conn.SetReadDeadline(time.Now().Add(loginTimeout))
+	data, err := ReadMessage(r, buf, RequestSize)
+	if err != nil {
+		return
+	}
+
+	var login Login
+	if err := login.Decode(data); err != nil {
+		return
+	}
+
+	sess := &session{user: login.Username.String(), conn: conn, out: make(chan frame, s.buffer)}
+	if reason := s.login(sess, login.Password.String()); reason != 0 {
+		reject := LoginRejected{Reason: reason}
+		n := reject.Encode(buf)
+		conn.Write(buf[:n])
+		return
+	}
+	defer s.logout(sess)
+
+	if _, err := conn.Write([]byte{TypeLoginAccepted}); err != nil {
+		return
+	}
+	conn.SetReadDeadline(time.Time{})
+
+	done := make(chan struct{})
+	defer close(done)
+	go sess.writeLoop(done)
+
+	for {
+		data, err := ReadMessage(r, buf, RequestSize)
+		if err != nil {
+			return
+		}
+		if err := s.handle(sess, data); err != nil {
+			return
+		}
+	}
+}
+
+// login checks the password and registers the session
+// Return:
+//
+//	byte - reason of the rejection or zero
+func (s *Server) login(sess *session, password string) byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if sess.user == "" || strings.Contains(sess.user, "/") {
+		return ReasonOther
+	}
+
+	expected, ok := s.passwords[sess.user]
+	if subtle.ConstantTimeCompare([]byte(password), []byte(expected)) != 1 || !ok {
+		return ReasonNotAuthorized
+	}
+	if _, ok := s.users[sess.user]; ok {
+		return ReasonUserInUse
+	}
+
+	s.users[sess.user] = sess
+	return 0
+}
+
+func (s *Server) logout(sess *session) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.users, sess.user)
+}
+
+// handle decodes the message of the session and processes it in the matching goroutine
+func (s *Server) handle(sess *session, data []byte) error {
+	var (
+		enter   EnterOrder
+		replace ReplaceOrder
+		cancel  CancelOrder
+		err     error
+	)
+
+	switch data[0] {
+	case TypeEnterOrder:
+		enter.Decode(data)
+		err = s.engine.Do(func(ob *orderbook.OrderBook) { s.enterOrder(ob, sess, &enter) })
+	case TypeReplaceOrder:
+		replace.Decode(data)
+		err = s.engine.Do(func(ob *orderbook.OrderBook) { s.replaceOrder(ob, sess, &replace) })
+	case TypeCancelOrder:
+		cancel.Decode(data)
+		err = s.engine.Do(func(ob *orderbook.OrderBook) { s.cancelOrder(ob, sess, &cancel) })
+	default:
+		return ErrUnknownMessage
+	}
+
+	return err
+}
+
+// bookID returns ID of the order in the order book
+func bookID(sess *session, token Token) string {
+	return sess.user + "/" + token.String()
+}
+
+// order returns the order of the user entered by the token
+func (s *Server) order(sess *session, token Token) (*orderState, bool) {
+	st, ok := s.orders[bookID(sess, token)]
+	if !ok || st.session.user != sess.user {
+		return nil, false
+	}
+	return st, true
+}
+
+func (s *Server) enterOrder(ob *orderbook.OrderBook, sess *session, m *EnterOrder) {
+	st := &orderState{session: sess, token: m.Token, bookID: bookID(sess, m.Token)}
+
+	switch m.Side {
+	case SideBuy:
+		st.side = orderbook.Buy
+	case SideSell:
+		st.side = orderbook.Sell
+	default:
+		s.reject(sess, m.Token, ReasonInvalidSide)
+		return
+	}
+
+	if _, ok := s.orders[st.bookID]; ok || ob.Order(st.bookID) != nil {
+		s.reject(sess, m.Token, ReasonDuplicateToken)
+		return
+	}
+
+	var ack frame
+	ack.n = (&Accepted{
+		Timestamp: time.Now().UnixNano(),
+		Token:     m.Token,
+		Side:      m.Side,
+		Quantity:  m.Quantity,
+		Price:     m.Price,
+	}).Encode(ack.b[:])
+	mark := len(s.pending)
+
+	var (
+		executed bool
+		left     decimal.Decimal
+		err      error
+	)
+
+	s.active = st
+	if m.Price != 0 {
+		s.orders[st.bookID] = st
+		_, _, _, err = ob.ProcessLimitOrderWithOwner(st.side, st.bookID, sess.user, ToDecimal(m.Quantity), ToDecimal(m.Price))
+	} else {
+		_, _, _, left, err = ob.ProcessMarketOrderWithOwner(st.side, sess.user, ToDecimal(m.Quantity))
+	}
+	s.active = nil
+	executed = len(s.pending) > mark
+
+	if err != nil && !executed {
+		delete(s.orders, st.bookID)
+		s.reject(sess, m.Token, reason(err))
+		return
+	}
+
+	s.insert(mark, sess, ack)
+
+	switch {
+	case err != nil:
+		// the rest of the order is not placed because trading is halted
+		delete(s.orders, st.bookID)
+		s.cancelled(sess, m.Token, m.Quantity-s.executed(mark, st), reason(err))
+	case m.Price == 0 && left.Sign() > 0:
+		q, err := FromDecimal(left)
+		if err != nil {
+			s.drop(sess, err)
+			return
+		}
+		s.cancelled(sess, m.Token, q, ReasonImmediate)
+	}
+}
+
+func (s *Server) replaceOrder(ob *orderbook.OrderBook, sess *session, m *ReplaceOrder) {
+	st, ok := s.order(sess, m.Existing)
+	if !ok {
+		s.reject(sess, m.Replacement, ReasonUnknownToken)
+		return
+	}
+
+	newID := bookID(sess, m.Replacement)
+	if _, ok := s.orders[newID]; ok || ob.Order(newID) != nil {
+		s.reject(sess, m.Replacement, ReasonDuplicateToken)
+		return
+	}
+
+	if m.Quantity <= 0 {
+		s.reject(sess, m.Replacement, ReasonInvalidQuantity)
+		return
+	}
+
+	if m.Price <= 0 {
+		s.reject(sess, m.Replacement, ReasonInvalidPrice)
+		return
+	}
+
+	var ack frame
+	ack.n = (&Replaced{
+		Timestamp:   time.Now().UnixNano(),
+		Replacement: m.Replacement,
+		Previous:    m.Existing,
+		Quantity:    m.Quantity,
+		Price:       m.Price,
+	}).Encode(ack.b[:])
+	mark := len(s.pending)
+
+	replaced := &orderState{session: sess, token: m.Replacement, bookID: newID, side: st.side}
+	s.orders[newID] = replaced
+	s.active, s.replacing = replaced, st
+	_, _, _, err := ob.ReplaceOrder(st.bookID, newID, ToDecimal(m.Quantity), ToDecimal(m.Price))
+	s.active, s.replacing = nil, nil
+
+	// the original order stays in the order book if the replacement is rejected
+	if _, ok := s.orders[st.bookID]; ok {
+		delete(s.orders, newID)
+		s.pending = s.pending[:mark]
+		s.reject(sess, m.Replacement, reason(err))
+		return
+	}
+
+	s.insert(mark, sess, ack)
+	if err != nil {
+		delete(s.orders, newID)
+		s.cancelled(sess, m.Replacement, m.Quantity-s.executed(mark, replaced), reason(err))
+	}
+}
+
+func (s *Server) cancelOrder(ob *orderbook.OrderBook, sess *session, m *CancelOrder) {
+	st, ok := s.order(sess, m.Token)
+	if !ok {
+		s.reject(sess, m.Token, ReasonUnknownToken)
+		return
+	}
+
+	s.reason = ReasonUserRequested
+	_, err := ob.CancelOrder(st.bookID)
+	s.reason = ReasonSupervisory
+
+	if err != nil {
+		s.reject(sess, m.Token, reason(err))
+	}
+}
+
+// onTrade reports executions of the orders in the matching goroutine
+func (s *Server) onTrade(t *orderbook.Trade) {
+	taker := s.active
+	if taker == nil || (t.TakerOrderID != "" && t.TakerOrderID != taker.bookID) {
+		taker = s.orders[t.TakerOrderID]
+	}
+
+	var price int64
+	quantity, err := FromDecimal(t.Quantity)
+	if err == nil {
+		price, err = FromDecimal(t.Price)
+	}
+
+	for _, st := range [2]*orderState{taker, s.orders[t.MakerOrderID]} {
+		if st == nil {
+			continue
+		}
+
+		// the execution can't be reported, so the client has to recover its orders
+		if err != nil {
+			s.drop(st.session, err)
+			continue
+		}
+
+		var f frame
+		f.n = (&Executed{
+			Timestamp: t.Timestamp.UnixNano(),
+			Token:     st.token,
+			Quantity:  quantity,
+			Price:     price,
+			Match:     t.ID,
+		}).Encode(f.b[:])
+		s.pending = append(s.pending, outbound{session: st.session, frame: f})
+	}
+}
+
+// onOrderEvent reports cancelled and forgets filled orders in the matching goroutine
+func (s *Server) onOrderEvent(ev *orderbook.OrderEvent) {
+	if ev.Status != orderbook.OrderCancelled && ev.Status != orderbook.OrderFilled {
+		return
+	}
+
+	st, ok := s.orders[ev.Order.ID()]
+	if !ok {
+		return
+	}
+	delete(s.orders, st.bookID)
+
+	if ev.Status == orderbook.OrderCancelled && st != s.replacing {
+		q, err := FromDecimal(ev.Order.Quantity())
+		if err != nil {
+			s.drop(st.session, err)
+			return
+		}
+		s.cancelled(st.session, st.token, q, s.reason)
+	}
+}
+
+// flush sends messages collected during the command
+func (s *Server) flush(*orderbook.OrderBook) {
+	for i := range s.pending {
+		s.pending[i].session.send(&s.pending[i].frame)
+	}
+	s.pending = s.pending[:0]
+}
+
+// insert puts the message before the messages collected after mark
+func (s *Server) insert(mark int, sess *session, f frame) {
+	s.pending = append(s.pending, outbound{})
+	copy(s.pending[mark+1:], s.pending[mark:])
+	s.pending[mark] = outbound{session: sess, frame: f}
+}
+
+// executed returns quantity of the order executed after mark
+func (s *Server) executed(mark int, st *orderState) int64 {
+	var sum int64
+	var m Executed
+	for _, p := range s.pending[mark:] {
+		if p.session == st.session && p.frame.b[0] == TypeExecuted && m.Decode(p.frame.b[:p.frame.n]) == nil && m.Token == st.token {
+			sum += m.Quantity
+		}
+	}
+	return sum
+}
+
+func (s *Server) reject(sess *session, token Token, reason byte) {
+	var f frame
+	f.n = (&Rejected{Timestamp: time.Now().UnixNano(), Token: token, Reason: reason}).Encode(f.b[:])
+	s.pending = append(s.pending, outbound{session: sess, frame: f})
+}
+
+func (s *Server) cancelled(sess *session, token Token, quantity int64, reason byte) {
+	var f frame
+	f.n = (&Cancelled{Timestamp: time.Now().UnixNano(), Token: token, Quantity: quantity, Reason: reason}).Encode(f.b[:])
+	s.pending = append(s.pending, outbound{session: sess, frame: f})
+}
+
+// drop logs the error and disconnects the session
+func (s *Server) drop(sess *session, err error) {
+	if s.ErrorLog != nil {
+		s.ErrorLog.Printf("ouch: user %s: %v", sess.user, err)
+	} else {
+		log.Printf("ouch: user %s: %v", sess.user, err)
+	}
+	sess.once.Do(func() { sess.conn.Close() })
+}
+
+// send queues the message, the session is disconnected if its queue is full
+func (sess *session) send(f *frame) {
+	select {
+	case sess.out <- *f:
+	default:
+		sess.once.Do(func() { sess.conn.Close() })
+	}
+}
+
+// writeLoop writes queued messages and flushes them when the queue is empty
+func (sess *session) writeLoop(done chan struct{}) {
+	w := bufio.NewWriter(sess.conn)
+	for {
+		select {
+		case f := <-sess.out:
+			if _, err := w.Write(f.b[:f.n]); err != nil {
+				return
+			}
+			if len(sess.out) == 0 {
+				if err := w.Flush(); err != nil {
+					return
+				}
+			}
+		case <-done:
+			return
+		}
+	}
+}
+
+// reason maps order book errors to reject reasons
+func reason(err error) byte {
+	switch {
+	case errors.Is(err, orderbook.ErrInvalidQuantity):
+		return ReasonInvalidQuantity
+	case errors.Is(err, orderbook.ErrInvalidPrice), errors.Is(err, orderbook.ErrPriceOutOfBand):
+		return ReasonInvalidPrice
+	case errors.Is(err, orderbook.ErrOrderExists):
+		return ReasonDuplicateToken
+	case errors.Is(err, orderbook.ErrOrderNotExists):
+		return ReasonUnknownToken
+	case errors.Is(err, orderbook.ErrTradingHalted):
+		return ReasonHalted
+	case errors.Is(err, orderbook.ErrBookClosed),
+		errors.Is(err, orderbook.ErrAuctionMarketOrder),
+		errors.Is(err, orderbook.ErrPreOpenMarketOrder):
+		return ReasonClosed
+	case errors.Is(err, orderbook.ErrInsufficientFunds), errors.Is(err, orderbook.ErrExposureLimit):
+		return ReasonRisk
+	default:
+		return ReasonOther
+	}
+}
@@ -0,0 +1,198 @@
+package ouch
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"orderbook"
+)
+
+func newTestServer(t *testing.T) (string, *orderbook.Engine) {
+	engine := orderbook.NewEngine(orderbook.NewOrderBook())
+	t.Cleanup(engine.Close)
+
+	srv, err := NewServer(engine, 64, map[string]string{
+		"alice": "alice-secret",
+		"bob":   "bob-secret",
+		"maker": "maker-secret",
+		"taker": "taker-secret",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	go srv.Serve(l)
+	return l.Addr().String(), engine
+}
+
+func dial(t *testing.T, addr, username string) *Client {
+	c, err := Dial(addr, username, username+"-secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func receive(t *testing.T, c *Client, msgType byte) *Response {
+	c.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	var resp Response
+	if err := c.Receive(&resp); err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.Type != msgType {
+		t.Fatal("Invalid message type", string(resp.Type), "expected", string(msgType))
+	}
+
+	return &resp
+}
+
+func TestServerLogin(t *testing.T) {
+	addr, _ := newTestServer(t)
+	dial(t, addr, "alice")
+
+	if _, err := Dial(addr, "alice", "alice-secret"); err != ErrLoginRejected {
+		t.Fatal("Duplicate user is logged in", err)
+	}
+
+	if _, err := Dial(addr, "alice/a", "alice-secret"); err != ErrLoginRejected {
+		t.Fatal("User with slash is logged in", err)
+	}
+
+	if _, err := Dial(addr, "bob", "alice-secret"); err != ErrLoginRejected {
+		t.Fatal("User with invalid password is logged in", err)
+	}
+
+	if _, err := Dial(addr, "mallory", ""); err != ErrLoginRejected {
+		t.Fatal("Unknown user is logged in", err)
+	}
+}
+
+func TestServerExecution(t *testing.T) {
+	addr, _ := newTestServer(t)
+	maker := dial(t, addr, "maker")
+	taker := dial(t, addr, "taker")
+
+	maker.EnterOrder(&EnterOrder{Token: NewToken("sell"), Side: SideSell, Quantity: 2e8, Price: 100e8})
+	if resp := receive(t, maker, TypeAccepted); resp.Accepted.Token.String() != "sell" || resp.Accepted.Quantity != 2e8 {
+		t.Fatal("Invalid accepted message", resp.Accepted)
+	}
+
+	taker.EnterOrder(&EnterOrder{Token: NewToken("buy"), Side: SideBuy, Quantity: 3e8, Price: 0})
+	receive(t, taker, TypeAccepted)
+
+	resp := receive(t, taker, TypeExecuted)
+	if resp.Executed.Token.String() != "buy" || resp.Executed.Quantity != 2e8 || resp.Executed.Price != 100e8 {
+		t.Fatal("Invalid taker execution", resp.Executed)
+	}
+	match := resp.Executed.Match
+
+	resp = receive(t, taker, TypeCancelled)
+	if resp.Cancelled.Quantity != 1e8 || resp.Cancelled.Reason != ReasonImmediate {
+		t.Fatal("Market order rest is not cancelled", resp.Cancelled)
+	}
+
+	resp = receive(t, maker, TypeExecuted)
+	if resp.Executed.Token.String() != "sell" || resp.Executed.Quantity != 2e8 || resp.Executed.Match != match {
+		t.Fatal("Invalid maker execution", resp.Executed)
+	}
+}
+
+func TestServerReplaceCancel(t *testing.T) {
+	addr, engine := newTestServer(t)
+	c := dial(t, addr, "alice")
+
+	c.EnterOrder(&EnterOrder{Token: NewToken("a"), Side: SideBuy, Quantity: 1e8, Price: 10e8})
+	receive(t, c, TypeAccepted)
+
+	c.ReplaceOrder(&ReplaceOrder{Existing: NewToken("a"), Replacement: NewToken("b"), Quantity: 2e8, Price: 11e8})
+	resp := receive(t, c, TypeReplaced)
+	if resp.Replaced.Previous.String() != "a" || resp.Replaced.Replacement.String() != "b" || resp.Replaced.Price != 11e8 {
+		t.Fatal("Invalid replaced message", resp.Replaced)
+	}
+
+	c.CancelOrder(&CancelOrder{Token: NewToken("a")})
+	if resp := receive(t, c, TypeRejected); resp.Rejected.Reason != ReasonUnknownToken {
+		t.Fatal("Replaced order is cancelled", resp.Rejected)
+	}
+
+	// rejected replacement keeps the original order
+	engine.Do(func(ob *orderbook.OrderBook) {
+		ob.SetPriceBand(&orderbook.PriceBand{Reference: decimal.New(10, 0), Percent: decimal.New(20, 0)})
+	})
+	c.ReplaceOrder(&ReplaceOrder{Existing: NewToken("b"), Replacement: NewToken("c"), Quantity: 2e8, Price: 20e8})
+	if resp := receive(t, c, TypeRejected); resp.Rejected.Token.String() != "c" || resp.Rejected.Reason != ReasonInvalidPrice {
+		t.Fatal("Replacement out of band is accepted", resp.Rejected)
+	}
+
+	// orders of another user can't be cancelled
+	other := dial(t, addr, "bob")
+	other.CancelOrder(&CancelOrder{Token: NewToken("b")})
+	if resp := receive(t, other, TypeRejected); resp.Rejected.Reason != ReasonUnknownToken {
+		t.Fatal("Order of another user is cancelled", resp.Rejected)
+	}
+
+	c.CancelOrder(&CancelOrder{Token: NewToken("b")})
+	resp = receive(t, c, TypeCancelled)
+	if resp.Cancelled.Token.String() != "b" || resp.Cancelled.Quantity != 2e8 || resp.Cancelled.Reason != ReasonUserRequested {
+		t.Fatal("Invalid cancelled message", resp.Cancelled)
+	}
+}
+
+func TestServerReject(t *testing.T) {
+	addr, _ := newTestServer(t)
+	c := dial(t, addr, "alice")
+
+	c.EnterOrder(&EnterOrder{Token: NewToken("a"), Side: SideBuy, Quantity: 1e8, Price: 10e8})
+	receive(t, c, TypeAccepted)
+
+	c.EnterOrder(&EnterOrder{Token: NewToken("a"), Side: SideBuy, Quantity: 1e8, Price: 10e8})
+	if resp := receive(t, c, TypeRejected); resp.Rejected.Reason != ReasonDuplicateToken {
+		t.Fatal("Duplicate token is accepted", resp.Rejected)
+	}
+
+	c.EnterOrder(&EnterOrder{Token: NewToken("b"), Side: SideBuy, Quantity: 0, Price: 10e8})
+	if resp := receive(t, c, TypeRejected); resp.Rejected.Reason != ReasonInvalidQuantity {
+		t.Fatal("Zero quantity is accepted", resp.Rejected)
+	}
+
+	c.EnterOrder(&EnterOrder{Token: NewToken("c"), Side: 'Z', Quantity: 1e8, Price: 10e8})
+	if resp := receive(t, c, TypeRejected); resp.Rejected.Reason != ReasonInvalidSide {
+		t.Fatal("Invalid side is accepted", resp.Rejected)
+	}
+
+	c.CancelOrder(&CancelOrder{Token: NewToken("d")})
+	if resp := receive(t, c, TypeRejected); resp.Rejected.Reason != ReasonUnknownToken {
+		t.Fatal("Unknown token is cancelled", resp.Rejected)
+	}
+}
+
+func TestServerOutOfRange(t *testing.T) {
+	addr, engine := newTestServer(t)
+	maker := dial(t, addr, "maker")
+
+	maker.EnterOrder(&EnterOrder{Token: NewToken("sell"), Side: SideSell, Quantity: 1e8, Price: 100e8})
+	receive(t, maker, TypeAccepted)
+
+	// the execution has more decimal places than the protocol
+	engine.Do(func(ob *orderbook.OrderBook) {
+		ob.ProcessMarketOrder(orderbook.Buy, decimal.New(1, -9))
+	})
+
+	maker.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var resp Response
+	if err := maker.Receive(&resp); err == nil {
+		t.Fatal("Execution out of range is sent", resp)
+	}
+}
@@ -0,0 +1,134 @@
+package persist
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"orderbook"
+)
+
+// Buckets of BoltStorage keyed by big-endian sequence numbers
+var (
+	journalBucket  = []byte("journal")
+	snapshotBucket = []byte("snapshots")
+)
+
+// BoltStorage keeps the journal and snapshots in embedded bbolt key-value
+// database. Every record is committed in its own transaction synced to the
+// disk, so there is no torn record after the crash
+type BoltStorage struct {
+	db *bolt.DB
+}
+
+// NewBoltStorage opens database file, the file is created if needed. It
+// fails if another process holds the database for a second
+func NewBoltStorage(path string) (*BoltStorage, error) {
+	db, err := bolt.Open(path, 0644, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		if _, err := tx.CreateBucketIfNotExists(journalBucket); err != nil {
+			return err
+		}
+		_, err := tx.CreateBucketIfNotExists(snapshotBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &BoltStorage{db: db}, nil
+}
+
+// seqKey encodes sequence number as the key, keys are ordered as the numbers
+func seqKey(seq uint64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, seq)
+	return key
+}
+
+// Append implements Storage interface
+func (bs *BoltStorage) Append(seq uint64, record []byte) error {
+	return bs.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(journalBucket).Put(seqKey(seq), record)
+	})
+}
+
+// Records implements Storage interface
+func (bs *BoltStorage) Records(after uint64, fn func(seq uint64, record []byte) error) error {
+	return bs.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(journalBucket).Cursor()
+		for k, v := c.Seek(seqKey(after + 1)); k != nil; k, v = c.Next() {
+			if err := fn(binary.BigEndian.Uint64(k), v); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// SaveSnapshot implements Storage interface
+func (bs *BoltStorage) SaveSnapshot(seq uint64, write func(w io.Writer) error) error {
+	var buf bytes.Buffer
+	if err := write(&buf); err != nil {
+		return err
+	}
+
+	return bs.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(snapshotBucket).Put(seqKey(seq), buf.Bytes())
+	})
+}
+
+// Snapshots implements Storage interface
+func (bs *BoltStorage) Snapshots() (seqs []uint64, err error) {
+	err = bs.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(snapshotBucket).ForEach(func(k, _ []byte) error {
+			seqs = append(seqs, binary.BigEndian.Uint64(k))
+			return nil
+		})
+	})
+	return
+}
+
+// LoadSnapshot implements Storage interface
+func (bs *BoltStorage) LoadSnapshot(seq uint64, read func(r io.Reader) error) error {
+	return bs.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(snapshotBucket).Get(seqKey(seq))
+		if data == nil {
+			return orderbook.ErrInvalidSnapshot
+		}
+		return read(bytes.NewReader(data))
+	})
+}
+
+// Compact implements Storage interface
+func (bs *BoltStorage) Compact(seq uint64) error {
+	return bs.db.Update(func(tx *bolt.Tx) error {
+		c := tx.Bucket(journalBucket).Cursor()
+		for k, _ := c.First(); k != nil && binary.BigEndian.Uint64(k) <= seq; k, _ = c.First() {
+			if err := c.Delete(); err != nil {
+				return err
+			}
+		}
+
+		c = tx.Bucket(snapshotBucket).Cursor()
+		for k, _ := c.First(); k != nil && binary.BigEndian.Uint64(k) < seq; k, _ = c.First() {
+			if err := c.Delete(); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Close implements Storage interface
+func (bs *BoltStorage) Close() error {
+	return bs.db.Close()
+}
@@ -0,0 +1,29 @@
+package persist
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestBoltStorage(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "book.db")
+	bs, err := NewBoltStorage(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testStorage(t, bs)
+
+	// records and snapshots survive reopening
+	bs, err = NewBoltStorage(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bs.Close()
+
+	if seqs, err := readSeqs(bs, 0); err != nil || len(seqs) != 3 || seqs[0] != 8 {
+		t.Fatal("Invalid records after reopening", seqs, err)
+	}
+	if snapshots, err := bs.Snapshots(); err != nil || len(snapshots) != 1 || snapshots[0] != 7 {
+		t.Fatal("Invalid snapshots after reopening", snapshots, err)
+	}
+}
@@ -0,0 +1,251 @@
+package persist
+
+import (
+	"encoding/json"
+	"reflect"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"orderbook"
+)
+
+// CommandType is kind of the order book command
+type CommandType int
+
+// Commands changing the order book:
+//
+//	CommandLimit           - ProcessLimitOrderWithOwner
+//	CommandMarket          - ProcessMarketOrderWithOwner
+//	CommandCancel          - CancelOrder
+//	CommandReduce          - ReduceOrder
+//	CommandCancelOwner     - CancelOwnerOrders
+//	CommandPhase           - SetPhase
+//	CommandReplace         - ReplaceOrder
+//	CommandCancelAll       - CancelAll
+//	CommandCancelSide      - CancelSide
+//	CommandCancelRange     - CancelPriceRange
+//	CommandCancelBeyond    - CancelBeyondPrice
+//	CommandProtectedMarket - ProcessProtectedMarketOrder
+//	CommandNotionalMarket  - ProcessMarketOrderByNotional, Quantity is the lot
+//	CommandUncross         - Uncross
+//	CommandResume          - Resume
+//	CommandTick            - Tick
+//	CommandSchedule        - SetSchedule
+//	CommandDeposit         - Ledger.Deposit, Quantity of the base and Notional of the quote
+//	CommandWithdraw        - Ledger.Withdraw, Quantity of the base and Notional of the quote
+const (
+	CommandLimit CommandType = iota
+	CommandMarket
+	CommandCancel
+	CommandReduce
+	CommandCancelOwner
+	CommandPhase
+	CommandReplace
+	CommandCancelAll
+	CommandCancelSide
+	CommandCancelRange
+	CommandCancelBeyond
+	CommandProtectedMarket
+	CommandNotionalMarket
+	CommandUncross
+	CommandResume
+	CommandTick
+	CommandSchedule
+	CommandDeposit
+	CommandWithdraw
+)
+
+// commandNames are JSON names of the command types
+var commandNames = [...]string{
+	CommandLimit:           "limit",
+	CommandMarket:          "market",
+	CommandCancel:          "cancel",
+	CommandReduce:          "reduce",
+	CommandCancelOwner:     "cancel-owner",
+	CommandPhase:           "phase",
+	CommandReplace:         "replace",
+	CommandCancelAll:       "cancel-all",
+	CommandCancelSide:      "cancel-side",
+	CommandCancelRange:     "cancel-range",
+	CommandCancelBeyond:    "cancel-beyond",
+	CommandProtectedMarket: "protected-market",
+	CommandNotionalMarket:  "notional-market",
+	CommandUncross:         "uncross",
+	CommandResume:          "resume",
+	CommandTick:            "tick",
+	CommandSchedule:        "schedule",
+	CommandDeposit:         "deposit",
+	CommandWithdraw:        "withdraw",
+}
+
+// commandMethods maps journaled order book methods to command types
+var commandMethods = map[string]CommandType{
+	"ProcessLimitOrderWithOwner":   CommandLimit,
+	"ProcessMarketOrderWithOwner":  CommandMarket,
+	"CancelOrder":                  CommandCancel,
+	"ReduceOrder":                  CommandReduce,
+	"CancelOwnerOrders":            CommandCancelOwner,
+	"SetPhase":                     CommandPhase,
+	"ReplaceOrder":                 CommandReplace,
+	"CancelAll":                    CommandCancelAll,
+	"CancelSide":                   CommandCancelSide,
+	"CancelPriceRange":             CommandCancelRange,
+	"CancelBeyondPrice":            CommandCancelBeyond,
+	"ProcessProtectedMarketOrder":  CommandProtectedMarket,
+	"ProcessMarketOrderByNotional": CommandNotionalMarket,
+	"Uncross":                      CommandUncross,
+	"Resume":                       CommandResume,
+	"Tick":                         CommandTick,
+	"SetSchedule":                  CommandSchedule,
+	"Deposit":                      CommandDeposit,
+	"Withdraw":                     CommandWithdraw,
+}
+
+// String implements fmt.Stringer interface
+func (t CommandType) String() string {
+	if t < 0 || int(t) >= len(commandNames) {
+		return commandNames[CommandLimit]
+	}
+	return commandNames[t]
+}
+
+// MarshalJSON implements json.Marshaler interface
+func (t CommandType) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + t.String() + `"`), nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler interface
+func (t *CommandType) UnmarshalJSON(data []byte) error {
+	for i, name := range commandNames {
+		if string(data) == `"`+name+`"` {
+			*t = CommandType(i)
+			return nil
+		}
+	}
+
+	return &json.UnsupportedValueError{
+		Value: reflect.New(reflect.TypeOf(data)),
+		Str:   string(data),
+	}
+}
+
+// Command is journaled request to the order book. Seq and Time are assigned
+// by the Manager, Time is the order book clock while the command is applied
+// so replay gives the same timestamps
+type Command struct {
+	Seq        uint64                      `json:"seq"`
+	Time       time.Time                   `json:"time"`
+	Type       CommandType                 `json:"type"`
+	Side       orderbook.Side              `json:"side,omitempty"`
+	OrderID    string                      `json:"orderID,omitempty"`
+	NewOrderID string                      `json:"newOrderID,omitempty"`
+	Owner      string                      `json:"owner,omitempty"`
+	Quantity   decimal.Decimal             `json:"quantity"`
+	Price      decimal.Decimal             `json:"price"`
+	HighPrice  decimal.Decimal             `json:"highPrice"`
+	Notional   decimal.Decimal             `json:"notional"`
+	Protection *orderbook.MarketProtection `json:"protection,omitempty"`
+	Phase      orderbook.Phase             `json:"phase,omitempty"`
+	Reason     string                      `json:"reason,omitempty"`
+	Schedule   []orderbook.ScheduledPhase  `json:"schedule,omitempty"`
+}
+
+// newCommand creates the command from the journal entry of the order book
+// Return:
+//
+//	error - ErrInvalidCommand if the method is not supported
+func newCommand(entry orderbook.JournalEntry) (*Command, error) {
+	t, ok := commandMethods[entry.Method]
+	if !ok {
+		return nil, ErrInvalidCommand
+	}
+
+	return &Command{
+		Type:       t,
+		Side:       entry.Side,
+		OrderID:    entry.OrderID,
+		NewOrderID: entry.NewOrderID,
+		Owner:      entry.Owner,
+		Quantity:   entry.Quantity,
+		Price:      entry.Price,
+		HighPrice:  entry.HighPrice,
+		Notional:   entry.Notional,
+		Protection: entry.Protection,
+		Phase:      entry.Phase,
+		Reason:     entry.Reason,
+		Schedule:   entry.Schedule,
+	}, nil
+}
+
+// Result is outcome of the command, fields not returned by the order book
+// method are empty
+type Result struct {
+	Done                     []*orderbook.Order
+	Partial                  *orderbook.Order
+	PartialQuantityProcessed decimal.Decimal
+	QuantityLeft             decimal.Decimal    // notional left of CommandNotionalMarket
+	Order                    *orderbook.Order   // cancelled or reduced order
+	Orders                   []*orderbook.Order // orders cancelled by mass cancel
+	Trades                   []*orderbook.Trade // trades of uncross and tick
+}
+
+// apply executes the command on the order book
+func apply(ob *orderbook.OrderBook, cmd *Command) (*Result, error) {
+	res := &Result{}
+	var err error
+
+	switch cmd.Type {
+	case CommandLimit:
+		res.Done, res.Partial, res.PartialQuantityProcessed, err = ob.ProcessLimitOrderWithOwner(cmd.Side, cmd.OrderID, cmd.Owner, cmd.Quantity, cmd.Price)
+	case CommandMarket:
+		res.Done, res.Partial, res.PartialQuantityProcessed, res.QuantityLeft, err = ob.ProcessMarketOrderWithOwner(cmd.Side, cmd.Owner, cmd.Quantity)
+	case CommandCancel:
+		res.Order, err = ob.CancelOrder(cmd.OrderID)
+	case CommandReduce:
+		res.Order, err = ob.ReduceOrder(cmd.OrderID, cmd.Quantity)
+	case CommandCancelOwner:
+		res.Orders, err = ob.CancelOwnerOrders(cmd.Owner)
+	case CommandPhase:
+		err = ob.SetPhase(cmd.Phase, cmd.Reason)
+	case CommandReplace:
+		res.Done, res.Partial, res.PartialQuantityProcessed, err = ob.ReplaceOrder(cmd.OrderID, cmd.NewOrderID, cmd.Quantity, cmd.Price)
+	case CommandCancelAll:
+		res.Orders, err = ob.CancelAll()
+	case CommandCancelSide:
+		res.Orders, err = ob.CancelSide(cmd.Side)
+	case CommandCancelRange:
+		res.Orders, err = ob.CancelPriceRange(cmd.Side, cmd.Price, cmd.HighPrice)
+	case CommandCancelBeyond:
+		res.Orders, err = ob.CancelBeyondPrice(cmd.Side, cmd.Price)
+	case CommandProtectedMarket:
+		if cmd.Protection == nil {
+			return res, ErrInvalidCommand
+		}
+		res.Done, res.Partial, res.PartialQuantityProcessed, res.QuantityLeft, err = ob.ProcessProtectedMarketOrder(cmd.Side, cmd.OrderID, cmd.Owner, cmd.Quantity, *cmd.Protection)
+	case CommandNotionalMarket:
+		res.Done, res.Partial, res.PartialQuantityProcessed, res.QuantityLeft, err = ob.ProcessMarketOrderByNotional(cmd.Side, cmd.Owner, cmd.Notional, cmd.Quantity)
+	case CommandUncross:
+		res.Trades, err = ob.Uncross()
+	case CommandResume:
+		err = ob.Resume(cmd.Phase)
+	case CommandTick:
+		res.Trades = ob.Tick()
+	case CommandSchedule:
+		err = ob.SetSchedule(cmd.Schedule)
+	case CommandDeposit, CommandWithdraw:
+		ledger, ok := ob.RiskCheck().(*orderbook.Ledger)
+		if !ok {
+			return res, ErrInvalidCommand
+		}
+		if cmd.Type == CommandDeposit {
+			err = ledger.Deposit(cmd.Owner, cmd.Quantity, cmd.Notional)
+		} else {
+			err = ledger.Withdraw(cmd.Owner, cmd.Quantity, cmd.Notional)
+		}
+	default:
+		err = ErrInvalidCommand
+	}
+
+	return res, err
+}
@@ -0,0 +1,421 @@
+package persist
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"orderbook"
+)
+
+// maxRecordSize limits length of the journal record, longer length means broken record
+const maxRecordSize = 1 << 20
+
+// recordHeaderSize is length of the record header: payload length, CRC32 and sequence number
+const recordHeaderSize = 16
+
+// snapshotHeaderSize is length of the sequence number written before the book snapshot
+const snapshotHeaderSize = 8
+
+// FileOptions of the FileStorage
+type FileOptions struct {
+	// ArchiveDir receives journal segments and snapshots replaced by the newer
+	// snapshot, they are deleted if it is empty
+	ArchiveDir string
+	// Sync flushes every journal record to the disk before the command is applied
+	Sync bool
+}
+
+// FileStorage keeps the journal and snapshots in the directory:
+// journal-<first seq>.log segments and snapshot-<seq>.bin files. Every journal
+// record is payload length and CRC32 (IEEE) of the sequence number and the
+// payload as uint32 big-endian, sequence number as uint64 big-endian and the
+// payload. Every start and snapshot begins a new segment
+type FileStorage struct {
+	dir   string
+	opts  FileOptions
+	f     *os.File // current segment, nil until the next append
+	first uint64   // first sequence number of the current segment
+}
+
+// NewFileStorage opens storage in the directory, the directory is created if
+// needed. Snapshots interrupted by the crash are removed and the torn last
+// record of the journal is dropped
+func NewFileStorage(dir string, opts FileOptions) (*FileStorage, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	if opts.ArchiveDir != "" {
+		if err := os.MkdirAll(opts.ArchiveDir, 0755); err != nil {
+			return nil, err
+		}
+	}
+
+	fs := &FileStorage{dir: dir, opts: opts}
+	if err := fs.removeTemporary(); err != nil {
+		return nil, err
+	}
+	if err := fs.repair(); err != nil {
+		return nil, err
+	}
+	return fs, nil
+}
+
+// removeTemporary deletes snapshots interrupted by the crash
+func (fs *FileStorage) removeTemporary() error {
+	entries, err := os.ReadDir(fs.dir)
+	if err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		if strings.HasSuffix(e.Name(), ".tmp") {
+			if err := os.Remove(filepath.Join(fs.dir, e.Name())); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// repair truncates the last segment to the last complete record. The broken
+// record there is the torn write of the crash, a broken record of any other
+// segment is ErrCorruptJournal when it is read
+func (fs *FileStorage) repair() error {
+	segments, err := fs.segments()
+	if err != nil || len(segments) == 0 {
+		return err
+	}
+
+	last := segments[len(segments)-1]
+	good, err := fs.readSegment(last, func(uint64, []byte) error { return nil })
+	if !errors.Is(err, ErrCorruptJournal) {
+		return err
+	}
+
+	f, err := os.OpenFile(filepath.Join(fs.dir, segmentName(last)), os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := f.Truncate(good); err != nil {
+		return err
+	}
+	return f.Sync()
+}
+
+// segmentName returns file name of the segment starting from the sequence number
+func segmentName(first uint64) string {
+	return fmt.Sprintf("journal-%020d.log", first)
+}
+
+// snapshotName returns file name of the snapshot after the command
+func snapshotName(seq uint64) string {
+	return fmt.Sprintf("snapshot-%020d.bin", seq)
+}
+
+// listFiles returns sequence numbers of the files named prefix, 20 digits
+// sequence number and suffix in the directory in ascending order
+func listFiles(dir, prefix, suffix string) ([]uint64, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var seqs []uint64
+	for _, e := range entries {
+		digits, ok := strings.CutPrefix(e.Name(), prefix)
+		if !ok {
+			continue
+		}
+		if digits, ok = strings.CutSuffix(digits, suffix); !ok || len(digits) != 20 {
+			continue
+		}
+		seq, err := strconv.ParseUint(digits, 10, 64)
+		if err != nil {
+			continue
+		}
+		seqs = append(seqs, seq)
+	}
+
+	sort.Slice(seqs, func(i, j int) bool { return seqs[i] < seqs[j] })
+	return seqs, nil
+}
+
+// segments returns first sequence numbers of the segments in ascending order
+func (fs *FileStorage) segments() ([]uint64, error) {
+	return listFiles(fs.dir, "journal-", ".log")
+}
+
+// readSegment calls fn for every record of the segment
+// Return:
+//
+//	good  - length of the complete records
+//	error - ErrCorruptJournal if the segment has a broken record
+func (fs *FileStorage) readSegment(first uint64, fn func(seq uint64, record []byte) error) (good int64, err error) {
+	f, err := os.Open(filepath.Join(fs.dir, segmentName(first)))
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	header := make([]byte, recordHeaderSize)
+	for {
+		seq, record, err := readRecord(r, header)
+		if err == io.EOF {
+			return good, nil
+		}
+		if err == io.ErrUnexpectedEOF || err == ErrCorruptJournal {
+			return good, fmt.Errorf("%w: %s at offset %d", ErrCorruptJournal, segmentName(first), good)
+		}
+		if err != nil {
+			return good, err
+		}
+
+		if err := fn(seq, record); err != nil {
+			return good, err
+		}
+		good += int64(recordHeaderSize + len(record))
+	}
+}
+
+// readRecord reads single record, it returns io.EOF only at the record boundary
+func readRecord(r *bufio.Reader, header []byte) (uint64, []byte, error) {
+	if _, err := io.ReadFull(r, header); err != nil {
+		return 0, nil, err
+	}
+
+	size := binary.BigEndian.Uint32(header)
+	if size == 0 || size > maxRecordSize {
+		return 0, nil, ErrCorruptJournal
+	}
+
+	record := make([]byte, size)
+	if _, err := io.ReadFull(r, record); err == io.EOF {
+		return 0, nil, io.ErrUnexpectedEOF
+	} else if err != nil {
+		return 0, nil, err
+	}
+
+	crc := crc32.Update(crc32.ChecksumIEEE(header[8:]), crc32.IEEETable, record)
+	if crc != binary.BigEndian.Uint32(header[4:]) {
+		return 0, nil, ErrCorruptJournal
+	}
+
+	return binary.BigEndian.Uint64(header[8:]), record, nil
+}
+
+// Append implements Storage interface, the segment is created if needed and
+// named after the record
+func (fs *FileStorage) Append(seq uint64, record []byte) error {
+	if fs.f == nil {
+		f, err := os.OpenFile(filepath.Join(fs.dir, segmentName(seq)), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return err
+		}
+		if err := syncDir(fs.dir); err != nil {
+			f.Close()
+			return err
+		}
+		fs.f, fs.first = f, seq
+	}
+
+	buf := make([]byte, recordHeaderSize+len(record))
+	binary.BigEndian.PutUint32(buf, uint32(len(record)))
+	binary.BigEndian.PutUint64(buf[8:], seq)
+	copy(buf[recordHeaderSize:], record)
+	binary.BigEndian.PutUint32(buf[4:], crc32.ChecksumIEEE(buf[8:]))
+
+	if _, err := fs.f.Write(buf); err != nil {
+		return err
+	}
+	if fs.opts.Sync {
+		return fs.f.Sync()
+	}
+	return nil
+}
+
+// Records implements Storage interface
+// Return:
+//
+//	error - ErrCorruptJournal if the record is broken
+func (fs *FileStorage) Records(after uint64, fn func(seq uint64, record []byte) error) error {
+	segments, err := fs.segments()
+	if err != nil {
+		return err
+	}
+
+	for i, first := range segments {
+		// the segment is entirely before the sequence number if the next one starts before it
+		if i < len(segments)-1 && segments[i+1] <= after+1 {
+			continue
+		}
+
+		_, err := fs.readSegment(first, func(seq uint64, record []byte) error {
+			if seq <= after {
+				return nil
+			}
+			return fn(seq, record)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rotate closes the current segment, the next append starts a new one
+func (fs *FileStorage) rotate() error {
+	if fs.f == nil {
+		return nil
+	}
+
+	err := fs.f.Sync()
+	if cerr := fs.f.Close(); err == nil {
+		err = cerr
+	}
+	fs.f = nil
+	return err
+}
+
+// SaveSnapshot implements Storage interface. The snapshot is written into
+// temporary file and renamed when it is on the disk, so the crash leaves
+// either complete snapshot or none. Records after the snapshot go to the new segment
+func (fs *FileStorage) SaveSnapshot(seq uint64, write func(w io.Writer) error) error {
+	path := filepath.Join(fs.dir, snapshotName(seq))
+	tmp := path + ".tmp"
+
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(f)
+	header := make([]byte, snapshotHeaderSize)
+	binary.BigEndian.PutUint64(header, seq)
+	w.Write(header)
+
+	err = write(w)
+	if err == nil {
+		err = w.Flush()
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	// rotate even if the directory is not synced, the segment must not outlive the snapshot
+	err = syncDir(fs.dir)
+	if rerr := fs.rotate(); err == nil {
+		err = rerr
+	}
+	return err
+}
+
+// Snapshots implements Storage interface
+func (fs *FileStorage) Snapshots() ([]uint64, error) {
+	return listFiles(fs.dir, "snapshot-", ".bin")
+}
+
+// LoadSnapshot implements Storage interface
+// Return:
+//
+//	error - orderbook.ErrInvalidSnapshot if the file does not belong to the sequence number
+func (fs *FileStorage) LoadSnapshot(seq uint64, read func(r io.Reader) error) error {
+	f, err := os.Open(filepath.Join(fs.dir, snapshotName(seq)))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	header := make([]byte, snapshotHeaderSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return orderbook.ErrInvalidSnapshot
+	}
+	if binary.BigEndian.Uint64(header) != seq {
+		return orderbook.ErrInvalidSnapshot
+	}
+
+	return read(r)
+}
+
+// Compact implements Storage interface. Segments starting before the
+// snapshot are removed or archived, they end before it because the snapshot
+// begins a new segment
+func (fs *FileStorage) Compact(seq uint64) error {
+	if fs.f != nil && fs.first <= seq {
+		if err := fs.rotate(); err != nil {
+			return err
+		}
+	}
+
+	segments, err := fs.segments()
+	if err != nil {
+		return err
+	}
+	for _, first := range segments {
+		if first <= seq {
+			if err := fs.retire(segmentName(first)); err != nil {
+				return err
+			}
+		}
+	}
+
+	snapshots, err := fs.Snapshots()
+	if err != nil {
+		return err
+	}
+	for _, s := range snapshots {
+		if s < seq {
+			if err := fs.retire(snapshotName(s)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return syncDir(fs.dir)
+}
+
+// retire moves the file into the archive directory or deletes it
+func (fs *FileStorage) retire(name string) error {
+	path := filepath.Join(fs.dir, name)
+	if fs.opts.ArchiveDir == "" {
+		return os.Remove(path)
+	}
+	return os.Rename(path, filepath.Join(fs.opts.ArchiveDir, name))
+}
+
+// Close implements Storage interface, it flushes the journal to the disk
+func (fs *FileStorage) Close() error {
+	return fs.rotate()
+}
+
+// syncDir flushes directory entries so created and renamed files survive a crash
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
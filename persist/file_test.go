@@ -0,0 +1,171 @@
+package persist
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"orderbook"
+)
+
+func writeSegments(t *testing.T, dir string) {
+	fs, err := NewFileStorage(dir, FileOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for seq := uint64(1); seq <= 5; seq++ {
+		if seq == 4 {
+			if err := fs.rotate(); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := fs.Append(seq, []byte(fmt.Sprintf(`{"seq":%d}`, seq))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := fs.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readSeqs(s Storage, after uint64) (seqs []uint64, err error) {
+	err = s.Records(after, func(seq uint64, record []byte) error {
+		seqs = append(seqs, seq)
+		return nil
+	})
+	return
+}
+
+func TestFileStorage(t *testing.T) {
+	fs, err := NewFileStorage(t.TempDir(), FileOptions{Sync: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	testStorage(t, fs)
+}
+
+func TestFileStorageSegments(t *testing.T) {
+	dir := t.TempDir()
+	writeSegments(t, dir)
+
+	fs, err := NewFileStorage(dir, FileOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Close()
+
+	segments, err := fs.segments()
+	if err != nil || len(segments) != 2 || segments[0] != 1 || segments[1] != 4 {
+		t.Fatal("Invalid segments", segments, err)
+	}
+
+	if seqs, err := readSeqs(fs, 3); err != nil || len(seqs) != 2 || seqs[0] != 4 {
+		t.Fatal("Invalid records after the segment", seqs, err)
+	}
+}
+
+func TestFileStorageTornTail(t *testing.T) {
+	dir := t.TempDir()
+	writeSegments(t, dir)
+
+	path := filepath.Join(dir, segmentName(4))
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write([]byte{0, 0, 0, 100, 1, 2, 3, 4, 0, 0, 0, 0, 0, 0, 0, 6, '{', '"'})
+	f.Close()
+	os.WriteFile(filepath.Join(dir, snapshotName(5)+".tmp"), []byte("partial"), 0644)
+
+	fs, err := NewFileStorage(dir, FileOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Close()
+
+	if truncated, err := os.Stat(path); err != nil || truncated.Size() != info.Size() {
+		t.Fatal("Torn tail is not truncated", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, snapshotName(5)+".tmp")); !os.IsNotExist(err) {
+		t.Fatal("Temporary snapshot is left", err)
+	}
+
+	if seqs, err := readSeqs(fs, 0); err != nil || len(seqs) != 5 {
+		t.Fatal("Invalid records before the torn tail", seqs, err)
+	}
+}
+
+func TestFileStorageCorrupt(t *testing.T) {
+	dir := t.TempDir()
+	writeSegments(t, dir)
+
+	path := filepath.Join(dir, segmentName(1))
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// broken payload of the last record in the first segment
+	data[len(data)-2] ^= 0xff
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	fs, err := NewFileStorage(dir, FileOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Close()
+
+	if _, err := readSeqs(fs, 0); !errors.Is(err, ErrCorruptJournal) {
+		t.Fatal("Broken record is not detected", err)
+	}
+	if _, err := Open(fs, orderbook.NewOrderBook(), Options{}); !errors.Is(err, ErrCorruptJournal) {
+		t.Fatal("Open must fail on broken record", err)
+	}
+}
+
+func TestFileStorageArchive(t *testing.T) {
+	dir := t.TempDir()
+	archive := t.TempDir()
+	writeSegments(t, dir)
+
+	fs, err := NewFileStorage(dir, FileOptions{ArchiveDir: archive})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Close()
+
+	for _, seq := range []uint64{3, 5} {
+		err := fs.SaveSnapshot(seq, func(w io.Writer) error {
+			_, err := w.Write([]byte("snapshot"))
+			return err
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := fs.Compact(5); err != nil {
+		t.Fatal(err)
+	}
+
+	if seqs, err := listFiles(archive, "journal-", ".log"); err != nil || len(seqs) != 2 {
+		t.Fatal("Segments are not archived", seqs, err)
+	}
+	if seqs, err := listFiles(archive, "snapshot-", ".bin"); err != nil || len(seqs) != 1 || seqs[0] != 3 {
+		t.Fatal("Snapshots are not archived", seqs, err)
+	}
+	if seqs, err := fs.Snapshots(); err != nil || len(seqs) != 1 || seqs[0] != 5 {
+		t.Fatal("Last snapshot is not kept", seqs, err)
+	}
+}
@@ -0,0 +1,282 @@
+// Package persist makes the order book durable. Commands are written ahead to
+// the journal before they change the book, the book is snapshotted every N
+// commands or T seconds and journal records covered by the snapshot are
+// removed. On start the newest valid snapshot is loaded and only the tail of
+// the journal after it is replayed. The journal and snapshots are kept by the
+// Storage backend: in memory, in files or in bbolt database
+package persist
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"orderbook"
+)
+
+// Errors of the persistence
+var (
+	ErrInvalidCommand = errors.New("persist: invalid command type")
+	ErrCorruptJournal = errors.New("persist: corrupt journal record")
+	ErrJournalGap     = errors.New("persist: journal does not continue the snapshot")
+	ErrClosed         = errors.New("persist: manager is closed")
+)
+
+// Options of the Manager
+type Options struct {
+	// SnapshotEvery is number of commands between snapshots, 0 disables it
+	SnapshotEvery int
+	// SnapshotInterval is time between snapshots if there were commands, 0 disables it
+	SnapshotInterval time.Duration
+	// Clock provides command times, SystemClock if nil
+	Clock orderbook.Clock
+}
+
+// commandClock returns time of the command while it is applied so the order
+// book gets the same timestamps in replay
+type commandClock struct {
+	base    orderbook.Clock
+	now     time.Time
+	applied bool
+}
+
+// Now implements orderbook.Clock interface
+func (c *commandClock) Now() time.Time {
+	if c.applied {
+		return c.now
+	}
+	return c.base.Now()
+}
+
+// Manager journals commands of the order book and snapshots it into the
+// storage, snapshot seq contains the book after command seq. Manager is the
+// Journal of the order book, so commands called on the book directly (e.g. by
+// front ends through the Engine) are journaled the same way as by Apply.
+// Manager is not safe for concurrent use, like the order book itself call it
+// from the Engine goroutine
+type Manager struct {
+	storage  Storage
+	ob       *orderbook.OrderBook
+	opts     Options
+	clock    *commandClock
+	seq      uint64    // last journaled command
+	snapshot uint64    // command covered by the last snapshot
+	count    int       // commands since the last snapshot
+	last     time.Time // time of the last snapshot
+	closed   bool
+}
+
+// Open restores the order book from the storage and starts journaling, it sets
+// the clock and the journal of the order book and of its Ledger if the Ledger is
+// the risk check. Snapshots keep the Ledger, fee schedule volumes and totals,
+// positions and the trading schedule. Other configuration (fee model and tiers,
+// risk check, position keeper and limits, price band, circuit breaker) is not
+// journaled: the book should be empty and configured the same way as when the
+// commands were journaled, change the configuration only together with Snapshot
+// so the journal after the snapshot is replayed with the new configuration
+// Return:
+//
+//	error - ErrCorruptJournal if a journal record is broken,
+//	        ErrJournalGap if the journal misses commands after the snapshot
+func Open(storage Storage, ob *orderbook.OrderBook, opts Options) (*Manager, error) {
+	if opts.Clock == nil {
+		opts.Clock = orderbook.SystemClock{}
+	}
+
+	m := &Manager{
+		storage: storage,
+		ob:      ob,
+		opts:    opts,
+		clock:   &commandClock{base: opts.Clock},
+	}
+	ob.SetClock(m.clock)
+
+	if err := m.loadSnapshot(); err != nil {
+		return nil, err
+	}
+	if err := m.replay(); err != nil {
+		return nil, err
+	}
+	// the crash could happen between the snapshot and compaction
+	if m.snapshot > 0 {
+		if err := storage.Compact(m.snapshot); err != nil {
+			return nil, err
+		}
+	}
+
+	m.last = opts.Clock.Now()
+	ob.SetJournal(m)
+	if ledger, ok := ob.RiskCheck().(*orderbook.Ledger); ok {
+		ledger.SetJournal(m)
+	}
+	return m, nil
+}
+
+// loadSnapshot restores the book from the newest readable snapshot
+func (m *Manager) loadSnapshot() error {
+	seqs, err := m.storage.Snapshots()
+	if err != nil {
+		return err
+	}
+
+	for i := len(seqs) - 1; i >= 0; i-- {
+		if err := m.storage.LoadSnapshot(seqs[i], m.ob.ReadSnapshot); err == nil {
+			m.seq, m.snapshot = seqs[i], seqs[i]
+			return nil
+		} else if !errors.Is(err, orderbook.ErrInvalidSnapshot) && !errors.Is(err, orderbook.ErrSnapshotVersion) {
+			return err
+		}
+	}
+	return nil
+}
+
+// replay applies journaled commands after the snapshot
+func (m *Manager) replay() error {
+	return m.storage.Records(m.seq, func(seq uint64, record []byte) error {
+		if seq != m.seq+1 {
+			return fmt.Errorf("%w: command %d after %d", ErrJournalGap, seq, m.seq)
+		}
+
+		cmd := &Command{}
+		if err := json.Unmarshal(record, cmd); err != nil || cmd.Seq != seq {
+			return fmt.Errorf("%w: command %d", ErrCorruptJournal, seq)
+		}
+
+		m.apply(cmd)
+		m.seq = seq
+		m.count++
+		return nil
+	})
+}
+
+// apply executes the command with the clock fixed to the command time
+func (m *Manager) apply(cmd *Command) (*Result, error) {
+	m.clock.now, m.clock.applied = cmd.Time, true
+	defer func() { m.clock.applied = false }()
+
+	return apply(m.ob, cmd)
+}
+
+// Begin implements orderbook.Journal interface. The command is written to
+// the journal and the clock is fixed to the command time until End
+func (m *Manager) Begin(entry orderbook.JournalEntry) error {
+	if m.closed {
+		return ErrClosed
+	}
+
+	cmd, err := newCommand(entry)
+	if err != nil {
+		return err
+	}
+
+	cmd.Seq = m.seq + 1
+	cmd.Time = m.opts.Clock.Now()
+	record, err := json.Marshal(cmd)
+	if err != nil {
+		return err
+	}
+	if err := m.storage.Append(cmd.Seq, record); err != nil {
+		return err
+	}
+	m.seq = cmd.Seq
+	m.count++
+
+	m.clock.now, m.clock.applied = cmd.Time, true
+	return nil
+}
+
+// End implements orderbook.Journal interface
+func (m *Manager) End() {
+	m.clock.applied = false
+}
+
+// Apply executes the command on the order book which journals it. Seq and
+// Time of the command are assigned by the Manager. The book is snapshotted
+// after the command if it is due
+// Return:
+//
+//	result - outcome of the command, nil if the command is not journaled
+//	error  - error of the order book method, journal or snapshot
+func (m *Manager) Apply(cmd *Command) (*Result, error) {
+	if m.closed {
+		return nil, ErrClosed
+	}
+
+	seq := m.seq
+	res, err := apply(m.ob, cmd)
+	if m.seq == seq {
+		return nil, err
+	}
+	cmd.Seq, cmd.Time = m.seq, m.clock.now
+
+	if m.due() {
+		if serr := m.Snapshot(); serr != nil && err == nil {
+			err = serr
+		}
+	}
+	return res, err
+}
+
+// Tick snapshots the book if it is due. Call it after commands called on the
+// book directly (e.g. by Engine.OnCommand) and periodically when commands are rare
+func (m *Manager) Tick() error {
+	if m.closed {
+		return ErrClosed
+	}
+	if !m.due() {
+		return nil
+	}
+	return m.Snapshot()
+}
+
+// due reports whether the snapshot should be written
+func (m *Manager) due() bool {
+	if m.count == 0 {
+		return false
+	}
+	if m.opts.SnapshotEvery > 0 && m.count >= m.opts.SnapshotEvery {
+		return true
+	}
+	return m.opts.SnapshotInterval > 0 && m.opts.Clock.Now().Sub(m.last) >= m.opts.SnapshotInterval
+}
+
+// Seq returns sequence number of the last journaled command
+func (m *Manager) Seq() uint64 {
+	return m.seq
+}
+
+// SnapshotSeq returns sequence number of the last command covered by the snapshot
+func (m *Manager) SnapshotSeq() uint64 {
+	return m.snapshot
+}
+
+// Snapshot saves the order book snapshot and compacts the journal covered by it
+func (m *Manager) Snapshot() error {
+	if m.closed {
+		return ErrClosed
+	}
+
+	m.last = m.opts.Clock.Now()
+	m.count = 0
+	if m.seq == m.snapshot {
+		return nil
+	}
+
+	if err := m.storage.SaveSnapshot(m.seq, m.ob.WriteSnapshot); err != nil {
+		return err
+	}
+	m.snapshot = m.seq
+	return m.storage.Compact(m.seq)
+}
+
+// Close closes the storage. The order book keeps the clock of the Manager
+// which falls back to Options.Clock, commands of the order book are rejected
+// with ErrClosed until the journal is removed by SetJournal(nil)
+func (m *Manager) Close() error {
+	if m.closed {
+		return nil
+	}
+	m.closed = true
+	return m.storage.Close()
+}
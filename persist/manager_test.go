@@ -0,0 +1,366 @@
+package persist
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"orderbook"
+)
+
+// stepClock moves one second forward on every call
+type stepClock struct {
+	now time.Time
+}
+
+func (c *stepClock) Now() time.Time {
+	c.now = c.now.Add(time.Second)
+	return c.now
+}
+
+func newStepClock() *stepClock {
+	return &stepClock{now: time.Date(2024, 1, 2, 9, 0, 0, 0, time.UTC)}
+}
+
+func randomCommand(rnd *rand.Rand, i int) *Command {
+	owners := []string{"a", "b", "c"}
+	cmd := &Command{
+		Side:     orderbook.Side(rnd.Intn(2)),
+		Owner:    owners[rnd.Intn(len(owners))],
+		OrderID:  fmt.Sprintf("order-%d", rnd.Intn(i+1)),
+		Quantity: decimal.New(int64(rnd.Intn(50)+1), -1),
+		Price:    decimal.New(int64(95+rnd.Intn(11)), 0),
+	}
+
+	switch n := rnd.Intn(100); {
+	case n < 60:
+		cmd.Type = CommandLimit
+		cmd.OrderID = fmt.Sprintf("order-%d", i)
+	case n < 70:
+		cmd.Type = CommandMarket
+	case n < 85:
+		cmd.Type = CommandCancel
+	case n < 95:
+		cmd.Type = CommandReduce
+	case n < 97:
+		cmd.Type = CommandCancelOwner
+	default:
+		cmd.Type = CommandPhase
+		cmd.Phase = orderbook.Phase(rnd.Intn(2))
+	}
+	return cmd
+}
+
+func bookJSON(t *testing.T, ob *orderbook.OrderBook) []byte {
+	data, err := ob.MarshalJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func countFiles(t *testing.T, dir, prefix, suffix string) int {
+	seqs, err := listFiles(dir, prefix, suffix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return len(seqs)
+}
+
+func newFileStorage(t *testing.T, dir string, opts FileOptions) *FileStorage {
+	fs, err := NewFileStorage(dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fs
+}
+
+// testManagerRestore applies random commands and restarts the Manager on the
+// storage returned by open
+func testManagerRestore(t *testing.T, open func() Storage) {
+	ob := orderbook.NewOrderBook()
+	m, err := Open(open(), ob, Options{SnapshotEvery: 100, Clock: newStepClock()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < 1050; i++ {
+		cmd := randomCommand(rnd, i)
+		m.Apply(cmd)
+		if cmd.Seq != uint64(i+1) {
+			t.Fatal("Command is not journaled", cmd.Seq)
+		}
+	}
+
+	if m.Seq() != 1050 || m.SnapshotSeq() != 1000 {
+		t.Fatal("Invalid sequence numbers", m.Seq(), m.SnapshotSeq())
+	}
+	if err := m.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Apply(randomCommand(rnd, 0)); err != ErrClosed {
+		t.Fatal("Command is applied after Close", err)
+	}
+
+	storage := open()
+	if seqs, err := storage.Snapshots(); err != nil || len(seqs) != 1 || seqs[0] != 1000 {
+		t.Fatal("Snapshots are not compacted", seqs, err)
+	}
+	if seqs, err := readSeqs(storage, 0); err != nil || len(seqs) != 50 || seqs[0] != 1001 {
+		t.Fatal("Journal is not compacted", len(seqs), err)
+	}
+
+	restored := orderbook.NewOrderBook()
+	m, err = Open(storage, restored, Options{SnapshotEvery: 100, Clock: newStepClock()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	if m.Seq() != 1050 || m.SnapshotSeq() != 1000 {
+		t.Fatal("Invalid restored sequence numbers", m.Seq(), m.SnapshotSeq())
+	}
+	if !bytes.Equal(bookJSON(t, restored), bookJSON(t, ob)) || restored.Phase() != ob.Phase() {
+		t.Fatal("Restored book differs", string(bookJSON(t, restored)), string(bookJSON(t, ob)))
+	}
+	if restored.LastTrade() == nil || restored.LastTrade().ID != ob.LastTrade().ID {
+		t.Fatal("Trade sequence is not restored", restored.LastTrade())
+	}
+}
+
+func TestManagerRestoreMemory(t *testing.T) {
+	storage := NewMemoryStorage()
+	testManagerRestore(t, func() Storage { return storage })
+}
+
+func TestManagerRestoreFile(t *testing.T) {
+	dir := t.TempDir()
+	testManagerRestore(t, func() Storage { return newFileStorage(t, dir, FileOptions{}) })
+}
+
+func TestManagerRestoreBolt(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "book.db")
+	testManagerRestore(t, func() Storage {
+		bs, err := NewBoltStorage(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return bs
+	})
+}
+
+func TestManagerJournal(t *testing.T) {
+	storage := NewMemoryStorage()
+	ob := orderbook.NewOrderBook()
+	m, err := Open(storage, ob, Options{Clock: newStepClock()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 5; i++ {
+		ob.ProcessLimitOrderWithOwner(orderbook.Sell, fmt.Sprintf("sell-%d", i), "a", decimal.New(2, 0), decimal.New(int64(100+i), 0))
+		ob.ProcessLimitOrderWithOwner(orderbook.Buy, fmt.Sprintf("buy-%d", i), "b", decimal.New(2, 0), decimal.New(int64(90+i), 0))
+	}
+	ob.ReplaceOrder("buy-0", "buy-new", decimal.New(3, 0), decimal.New(95, 0))
+	ob.ProcessMarketOrderByNotional(orderbook.Buy, "c", decimal.New(150, 0), decimal.New(1, -2))
+	ob.ProcessProtectedMarketOrder(orderbook.Buy, "protected", "c", decimal.New(5, 0), orderbook.MarketProtection{Ticks: 2, TickSize: decimal.New(1, 0), Rest: true})
+	ob.CancelPriceRange(orderbook.Buy, decimal.New(90, 0), decimal.New(91, 0))
+	ob.Halt("news")
+	ob.Resume(orderbook.Continuous)
+	ob.ProcessMarketOrder(orderbook.Sell, decimal.New(1, 0))
+
+	if m.Seq() != 17 {
+		t.Fatal("Commands of the book are not journaled", m.Seq())
+	}
+	if err := m.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, _, err := ob.ProcessLimitOrder(orderbook.Buy, "closed", decimal.New(1, 0), decimal.New(90, 0)); err != ErrClosed {
+		t.Fatal("Command is executed after Close", err)
+	}
+
+	restored := orderbook.NewOrderBook()
+	m, err = Open(storage, restored, Options{Clock: newStepClock()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	if m.Seq() != 17 || !bytes.Equal(bookJSON(t, restored), bookJSON(t, ob)) {
+		t.Fatal("Restored book differs", string(bookJSON(t, restored)), string(bookJSON(t, ob)))
+	}
+	if restored.Order("buy-new") == nil || !restored.Order("buy-new").Time().Equal(ob.Order("buy-new").Time()) {
+		t.Fatal("Order time is not restored", restored.Order("buy-new"))
+	}
+	if restored.LastTrade() == nil || restored.LastTrade().ID != ob.LastTrade().ID {
+		t.Fatal("Trade sequence is not restored", restored.LastTrade())
+	}
+}
+
+func TestManagerLedgerSchedule(t *testing.T) {
+	storage := NewMemoryStorage()
+	ob := orderbook.NewOrderBook()
+	ledger := orderbook.NewLedger()
+	ob.SetRiskCheck(ledger)
+	m, err := Open(storage, ob, Options{Clock: newStepClock()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ledger.Deposit("a", decimal.New(10, 0), decimal.Zero)
+	ledger.Deposit("b", decimal.Zero, decimal.New(1000, 0))
+	ob.ProcessLimitOrderWithOwner(orderbook.Sell, "sell", "a", decimal.New(5, 0), decimal.New(100, 0))
+	ob.ProcessLimitOrderWithOwner(orderbook.Buy, "buy", "b", decimal.New(2, 0), decimal.New(100, 0))
+	if err := m.Snapshot(); err != nil {
+		t.Fatal(err)
+	}
+
+	ledger.Deposit("c", decimal.Zero, decimal.New(100, 0))
+	ledger.Withdraw("b", decimal.Zero, decimal.New(50, 0))
+	ob.SetSchedule([]orderbook.ScheduledPhase{{Offset: 9*time.Hour + 30*time.Second, Phase: orderbook.Auction}})
+	for i := 0; i < 30; i++ {
+		ob.ProcessLimitOrderWithOwner(orderbook.Buy, fmt.Sprintf("c-%d", i), "c", decimal.New(1, -1), decimal.New(int64(60+i), 0))
+	}
+
+	if ob.Phase() != orderbook.Auction {
+		t.Fatal("Schedule is not applied", ob.Phase())
+	}
+	if err := m.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	restored := orderbook.NewOrderBook()
+	restoredLedger := orderbook.NewLedger()
+	restored.SetRiskCheck(restoredLedger)
+	m, err = Open(storage, restored, Options{Clock: newStepClock()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	if restored.Phase() != ob.Phase() || !bytes.Equal(bookJSON(t, restored), bookJSON(t, ob)) {
+		t.Fatal("Restored book differs", restored.Phase(), string(bookJSON(t, restored)))
+	}
+	for _, account := range []string{"a", "b", "c"} {
+		base, quote := ledger.Balances(account)
+		rbase, rquote := restoredLedger.Balances(account)
+		if !rbase.Total.Equal(base.Total) || !rbase.Reserved.Equal(base.Reserved) ||
+			!rquote.Total.Equal(quote.Total) || !rquote.Reserved.Equal(quote.Reserved) {
+			t.Fatal("Ledger is not restored", account, rbase, rquote, base, quote)
+		}
+	}
+
+	if err := restoredLedger.Deposit("d", decimal.New(1, 0), decimal.Zero); err != nil || m.Seq() != 38 {
+		t.Fatal("Deposit is not journaled", m.Seq(), err)
+	}
+}
+
+func TestManagerInterval(t *testing.T) {
+	dir := t.TempDir()
+	archive := t.TempDir()
+	clock := newStepClock()
+	m, err := Open(newFileStorage(t, dir, FileOptions{ArchiveDir: archive}), orderbook.NewOrderBook(), Options{SnapshotInterval: time.Minute, Clock: clock})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	if err := m.Tick(); err != nil || m.SnapshotSeq() != 0 {
+		t.Fatal("Snapshot without commands", m.SnapshotSeq(), err)
+	}
+
+	cmd := &Command{Type: CommandLimit, Side: orderbook.Sell, OrderID: "sell", Quantity: decimal.New(1, 0), Price: decimal.New(100, 0)}
+	if _, err := m.Apply(cmd); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Tick(); err != nil || m.SnapshotSeq() != 0 {
+		t.Fatal("Snapshot before the interval", m.SnapshotSeq(), err)
+	}
+
+	clock.now = clock.now.Add(time.Minute)
+	if err := m.Tick(); err != nil || m.SnapshotSeq() != 1 {
+		t.Fatal("Snapshot is not written after the interval", m.SnapshotSeq(), err)
+	}
+
+	cmd = &Command{Type: CommandCancel, OrderID: "sell"}
+	if _, err := m.Apply(cmd); err != nil {
+		t.Fatal(err)
+	}
+	clock.now = clock.now.Add(time.Minute)
+	if err := m.Tick(); err != nil || m.SnapshotSeq() != 2 {
+		t.Fatal("Snapshot is not written after the interval", m.SnapshotSeq(), err)
+	}
+
+	if countFiles(t, archive, "snapshot-", ".bin") != 1 || countFiles(t, archive, "journal-", ".log") != 2 {
+		t.Fatal("Files are not archived")
+	}
+	if countFiles(t, dir, "snapshot-", ".bin") != 1 || countFiles(t, dir, "journal-", ".log") != 0 {
+		t.Fatal("Archived files are left")
+	}
+}
+
+func TestManagerTornTail(t *testing.T) {
+	dir := t.TempDir()
+	ob := orderbook.NewOrderBook()
+	m, err := Open(newFileStorage(t, dir, FileOptions{}), ob, Options{SnapshotEvery: 7, Clock: newStepClock()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rnd := rand.New(rand.NewSource(2))
+	for i := 0; i < 10; i++ {
+		m.Apply(randomCommand(rnd, i))
+	}
+	m.Close()
+
+	// crash in the middle of the record
+	f, err := os.OpenFile(filepath.Join(dir, segmentName(8)), os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write([]byte{0, 0, 0, 200, 9, 9, 9, 9, 0, 0, 0, 0, 0, 0, 0, 11, '{'})
+	f.Close()
+
+	restored := orderbook.NewOrderBook()
+	m, err = Open(newFileStorage(t, dir, FileOptions{}), restored, Options{SnapshotEvery: 7, Clock: newStepClock()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Seq() != 10 || !bytes.Equal(bookJSON(t, restored), bookJSON(t, ob)) {
+		t.Fatal("Book is not restored before the torn record", m.Seq())
+	}
+
+	cmd := &Command{Type: CommandLimit, Side: orderbook.Buy, OrderID: "after", Quantity: decimal.New(1, 0), Price: decimal.New(90, 0)}
+	if _, err := m.Apply(cmd); err != nil || cmd.Seq != 11 {
+		t.Fatal("Command after the torn record", cmd.Seq, err)
+	}
+	m.Close()
+
+	restored = orderbook.NewOrderBook()
+	m, err = Open(newFileStorage(t, dir, FileOptions{}), restored, Options{Clock: newStepClock()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	if m.Seq() != 11 || restored.Order("after") == nil {
+		t.Fatal("Command after the torn record is lost", m.Seq())
+	}
+}
+
+func TestManagerJournalGap(t *testing.T) {
+	storage := NewMemoryStorage()
+	storage.Append(2, []byte(`{"seq":2}`))
+
+	if _, err := Open(storage, orderbook.NewOrderBook(), Options{}); !errors.Is(err, ErrJournalGap) {
+		t.Fatal("Missing commands are not detected", err)
+	}
+}
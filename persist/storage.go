@@ -0,0 +1,181 @@
+package persist
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"orderbook"
+)
+
+// ErrInvalidStorage is returned by OpenStorage for unknown backend or malformed configuration
+var ErrInvalidStorage = errors.New("persist: invalid storage configuration")
+
+// Storage keeps journal records and snapshots of the Manager. Records are
+// JSON encoded commands, snapshots are written by the order book
+type Storage interface {
+	// Append adds the record of the command with given sequence number to the journal
+	Append(seq uint64, record []byte) error
+	// Records calls fn for journal records after given sequence number in ascending order
+	Records(after uint64, fn func(seq uint64, record []byte) error) error
+	// SaveSnapshot atomically stores the snapshot of the book written by fn after
+	// command seq, seq is the last appended record
+	SaveSnapshot(seq uint64, write func(w io.Writer) error) error
+	// Snapshots returns sequence numbers of stored snapshots in ascending order
+	Snapshots() ([]uint64, error)
+	// LoadSnapshot calls fn with the snapshot after command seq
+	LoadSnapshot(seq uint64, read func(r io.Reader) error) error
+	// Compact removes journal records covered by the snapshot after command seq and older snapshots
+	Compact(seq uint64) error
+	// Close releases the storage
+	Close() error
+}
+
+// OpenStorage creates storage from configuration string, so the backend is
+// chosen without code changes:
+//
+//	memory                              - MemoryStorage
+//	file:<dir>[?archive=<dir>&sync=1]   - FileStorage
+//	bolt:<path>                         - BoltStorage
+func OpenStorage(config string) (Storage, error) {
+	backend, location, _ := strings.Cut(config, ":")
+	location, rawQuery, _ := strings.Cut(location, "?")
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return nil, ErrInvalidStorage
+	}
+
+	switch backend {
+	case "memory":
+		return NewMemoryStorage(), nil
+	case "file":
+		if location == "" {
+			return nil, ErrInvalidStorage
+		}
+
+		opts := FileOptions{ArchiveDir: query.Get("archive")}
+		if s := query.Get("sync"); s != "" {
+			if opts.Sync, err = strconv.ParseBool(s); err != nil {
+				return nil, ErrInvalidStorage
+			}
+		}
+		return NewFileStorage(location, opts)
+	case "bolt":
+		if location == "" {
+			return nil, ErrInvalidStorage
+		}
+		return NewBoltStorage(location)
+	default:
+		return nil, ErrInvalidStorage
+	}
+}
+
+// memoryRecord is journal record of MemoryStorage
+type memoryRecord struct {
+	seq  uint64
+	data []byte
+}
+
+// MemoryStorage keeps the journal and snapshots in memory. It survives Close,
+// so tests can restart the Manager on the same storage
+type MemoryStorage struct {
+	mu        sync.Mutex
+	records   []memoryRecord
+	snapshots map[uint64][]byte
+}
+
+// NewMemoryStorage creates empty memory storage
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{snapshots: map[uint64][]byte{}}
+}
+
+// Append implements Storage interface
+func (ms *MemoryStorage) Append(seq uint64, record []byte) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	ms.records = append(ms.records, memoryRecord{seq: seq, data: bytes.Clone(record)})
+	return nil
+}
+
+// Records implements Storage interface
+func (ms *MemoryStorage) Records(after uint64, fn func(seq uint64, record []byte) error) error {
+	ms.mu.Lock()
+	records := ms.records
+	ms.mu.Unlock()
+
+	for _, r := range records {
+		if r.seq <= after {
+			continue
+		}
+		if err := fn(r.seq, r.data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SaveSnapshot implements Storage interface
+func (ms *MemoryStorage) SaveSnapshot(seq uint64, write func(w io.Writer) error) error {
+	var buf bytes.Buffer
+	if err := write(&buf); err != nil {
+		return err
+	}
+
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	ms.snapshots[seq] = buf.Bytes()
+	return nil
+}
+
+// Snapshots implements Storage interface
+func (ms *MemoryStorage) Snapshots() ([]uint64, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	seqs := make([]uint64, 0, len(ms.snapshots))
+	for seq := range ms.snapshots {
+		seqs = append(seqs, seq)
+	}
+	sort.Slice(seqs, func(i, j int) bool { return seqs[i] < seqs[j] })
+	return seqs, nil
+}
+
+// LoadSnapshot implements Storage interface
+func (ms *MemoryStorage) LoadSnapshot(seq uint64, read func(r io.Reader) error) error {
+	ms.mu.Lock()
+	data, ok := ms.snapshots[seq]
+	ms.mu.Unlock()
+
+	if !ok {
+		return orderbook.ErrInvalidSnapshot
+	}
+	return read(bytes.NewReader(data))
+}
+
+// Compact implements Storage interface
+func (ms *MemoryStorage) Compact(seq uint64) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	i := sort.Search(len(ms.records), func(i int) bool { return ms.records[i].seq > seq })
+	ms.records = append([]memoryRecord(nil), ms.records[i:]...)
+
+	for s := range ms.snapshots {
+		if s < seq {
+			delete(ms.snapshots, s)
+		}
+	}
+	return nil
+}
+
+// Close implements Storage interface, the data is kept
+func (ms *MemoryStorage) Close() error {
+	return nil
+}
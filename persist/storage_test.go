@@ -0,0 +1,106 @@
+package persist
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"path/filepath"
+	"testing"
+)
+
+// testStorage checks journal and snapshot operations of the empty storage and closes it
+func testStorage(t *testing.T, s Storage) {
+	defer s.Close()
+
+	for seq := uint64(1); seq <= 10; seq++ {
+		if err := s.Append(seq, []byte{byte(seq), 'r'}); err != nil {
+			t.Fatal(err)
+		}
+
+		if seq == 4 || seq == 7 {
+			err := s.SaveSnapshot(seq, func(w io.Writer) error {
+				_, err := w.Write([]byte{'s', byte(seq)})
+				return err
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	var seqs []uint64
+	err := s.Records(4, func(seq uint64, record []byte) error {
+		if !bytes.Equal(record, []byte{byte(seq), 'r'}) {
+			t.Fatal("Invalid record", seq, record)
+		}
+		seqs = append(seqs, seq)
+		return nil
+	})
+	if err != nil || len(seqs) != 6 || seqs[0] != 5 || seqs[5] != 10 {
+		t.Fatal("Invalid records", seqs, err)
+	}
+
+	stop := errors.New("stop")
+	if err := s.Records(0, func(uint64, []byte) error { return stop }); err != stop {
+		t.Fatal("Error of the callback is not returned", err)
+	}
+
+	if err := s.SaveSnapshot(9, func(io.Writer) error { return stop }); err != stop {
+		t.Fatal("Error of the snapshot writer is not returned", err)
+	}
+
+	if snapshots, err := s.Snapshots(); err != nil || len(snapshots) != 2 || snapshots[0] != 4 || snapshots[1] != 7 {
+		t.Fatal("Invalid snapshots", snapshots, err)
+	}
+
+	var data []byte
+	err = s.LoadSnapshot(7, func(r io.Reader) (err error) {
+		data, err = io.ReadAll(r)
+		return
+	})
+	if err != nil || !bytes.Equal(data, []byte{'s', 7}) {
+		t.Fatal("Invalid snapshot", data, err)
+	}
+
+	if err := s.Compact(7); err != nil {
+		t.Fatal(err)
+	}
+
+	if seqs, err = readSeqs(s, 0); err != nil || len(seqs) != 3 || seqs[0] != 8 || seqs[2] != 10 {
+		t.Fatal("Journal is not compacted", seqs, err)
+	}
+	if snapshots, err := s.Snapshots(); err != nil || len(snapshots) != 1 || snapshots[0] != 7 {
+		t.Fatal("Snapshots are not compacted", snapshots, err)
+	}
+}
+
+func TestMemoryStorage(t *testing.T) {
+	testStorage(t, NewMemoryStorage())
+}
+
+func TestOpenStorage(t *testing.T) {
+	dir := t.TempDir()
+
+	s, err := OpenStorage("memory")
+	if _, ok := s.(*MemoryStorage); !ok || err != nil {
+		t.Fatal("Invalid memory storage", s, err)
+	}
+
+	s, err = OpenStorage("file:" + filepath.Join(dir, "journal") + "?sync=true&archive=" + filepath.Join(dir, "archive"))
+	if fs, ok := s.(*FileStorage); !ok || err != nil || !fs.opts.Sync || fs.opts.ArchiveDir != filepath.Join(dir, "archive") {
+		t.Fatal("Invalid file storage", s, err)
+	}
+	s.Close()
+
+	s, err = OpenStorage("bolt:" + filepath.Join(dir, "book.db"))
+	if _, ok := s.(*BoltStorage); !ok || err != nil {
+		t.Fatal("Invalid bolt storage", s, err)
+	}
+	s.Close()
+
+	for _, config := range []string{"", "redis:localhost", "file:", "bolt:", "file:dir?sync=maybe"} {
+		if _, err := OpenStorage(config); err != ErrInvalidStorage {
+			t.Fatal("Invalid configuration is accepted", config, err)
+		}
+	}
+}
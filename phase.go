@@ -0,0 +1,307 @@
+package orderbook
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Phase is trading phase of the order book
+type Phase int
+
+// Trading phases of the order book:
+//
+//	Continuous - incoming orders are matched immediately
+//	Auction    - limit orders are collected without matching until uncross, no market orders
+//	Halted     - cancels only
+//	PreOpen    - the same as Auction before the trading day, uncross opens continuous trading
+//	Closed     - nothing is allowed
+const (
+	Continuous Phase = iota
+	Auction
+	Halted
+	PreOpen
+	Closed
+)
+
+// phaseTransitions contains allowed transitions between trading phases
+var phaseTransitions = map[Phase][]Phase{
+	Closed:     {PreOpen, Auction, Continuous},
+	PreOpen:    {Auction, Continuous, Halted, Closed},
+	Auction:    {Continuous, Halted, Closed},
+	Continuous: {Auction, Halted, Closed},
+	Halted:     {Auction, Continuous, Closed},
+}
+
+// String implements fmt.Stringer interface
+func (p Phase) String() string {
+	switch p {
+	case Auction:
+		return "auction"
+	case Halted:
+		return "halted"
+	case PreOpen:
+		return "preopen"
+	case Closed:
+		return "closed"
+	default:
+		return "continuous"
+	}
+}
+
+// MarshalJSON implements json.Marshaler interface
+func (p Phase) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + p.String() + `"`), nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler interface
+func (p *Phase) UnmarshalJSON(data []byte) error {
+	switch string(data) {
+	case `"continuous"`:
+		*p = Continuous
+	case `"auction"`:
+		*p = Auction
+	case `"halted"`:
+		*p = Halted
+	case `"preopen"`:
+		*p = PreOpen
+	case `"closed"`:
+		*p = Closed
+	default:
+		return &json.UnsupportedValueError{
+			Value: reflect.New(reflect.TypeOf(data)),
+			Str:   string(data),
+		}
+	}
+
+	return nil
+}
+
+// PhaseEvent is emitted on every trading phase transition
+type PhaseEvent struct {
+	From   Phase     `json:"from"`
+	To     Phase     `json:"to"`
+	Time   time.Time `json:"time"`
+	Reason string    `json:"reason,omitempty"`
+}
+
+// ScheduledPhase switches the order book to Phase every day at Offset from midnight UTC
+type ScheduledPhase struct {
+	Offset time.Duration
+	Phase  Phase
+}
+
+// Phase returns current trading phase of the order book. Scheduled transitions
+// are not applied by it, see Tick
+func (ob *OrderBook) Phase() Phase {
+	return ob.phase
+}
+
+// Tick applies scheduled trading phase transitions which are due by the order
+// book clock. Order entry and cancels apply them too, call Tick periodically
+// so the phase follows the schedule without orders. Nothing is applied if the
+// journal rejects the tick
+// Return:
+//
+//	trades - executions of the uncross if the schedule opens continuous trading
+func (ob *OrderBook) Tick() []*Trade {
+	end, err := ob.journaled(JournalEntry{Method: "Tick"})
+	if err != nil {
+		return nil
+	}
+	defer end()
+
+	return ob.applySchedule()
+}
+
+// HaltReason returns description of the last halt of the order book
+func (ob *OrderBook) HaltReason() string {
+	return ob.haltReason
+}
+
+// OnPhaseChange registers handler called on every trading phase transition
+func (ob *OrderBook) OnPhaseChange(handler func(*PhaseEvent)) {
+	ob.phaseHandlers = append(ob.phaseHandlers, handler)
+}
+
+// SetPhase switches the order book to given trading phase. Switching from
+// auction or pre-open to continuous trading uncrosses the order book
+// Return:
+//
+//	error - ErrInvalidPhase if the transition is not allowed
+func (ob *OrderBook) SetPhase(phase Phase, reason string) error {
+	end, err := ob.journaled(JournalEntry{Method: "SetPhase", Phase: phase, Reason: reason})
+	if err != nil {
+		return err
+	}
+	defer end()
+
+	ob.applySchedule()
+	_, err = ob.transition(phase, reason)
+	return err
+}
+
+// Halt stops matching, only order cancelling is allowed until Resume
+func (ob *OrderBook) Halt(reason string) error {
+	return ob.SetPhase(Halted, reason)
+}
+
+// Resume restarts halted order book into continuous trading or call auction
+func (ob *OrderBook) Resume(phase Phase) error {
+	end, err := ob.journaled(JournalEntry{Method: "Resume", Phase: phase})
+	if err != nil {
+		return err
+	}
+	defer end()
+
+	ob.applySchedule()
+	if ob.phase != Halted || (phase != Continuous && phase != Auction) {
+		return ErrInvalidPhase
+	}
+
+	_, err = ob.transition(phase, "resume")
+	return err
+}
+
+// SetSchedule sets daily trading phase schedule driven by the order book clock.
+// Scheduled transitions are applied by the next order entry, cancel or Tick after
+// their time, transitions which are not allowed from the current phase are skipped.
+// Transitions are checked from the time of the call by the order book clock
+// Return:
+//
+//	error - error of the journal, the schedule is not changed then
+func (ob *OrderBook) SetSchedule(schedule []ScheduledPhase) error {
+	end, err := ob.journaled(JournalEntry{Method: "SetSchedule", Schedule: append([]ScheduledPhase(nil), schedule...)})
+	if err != nil {
+		return err
+	}
+	defer end()
+
+	ob.schedule = append([]ScheduledPhase(nil), schedule...)
+	sort.SliceStable(ob.schedule, func(i, j int) bool {
+		return ob.schedule[i].Offset < ob.schedule[j].Offset
+	})
+	ob.scheduleChecked = ob.now()
+	return nil
+}
+
+// applySchedule performs all scheduled transitions since the previous check
+func (ob *OrderBook) applySchedule() (trades []*Trade) {
+	if len(ob.schedule) == 0 {
+		return nil
+	}
+
+	now := ob.now()
+	from := ob.scheduleChecked
+	ob.scheduleChecked = now
+
+	for day := from.Truncate(24 * time.Hour); !day.After(now); day = day.Add(24 * time.Hour) {
+		for _, scheduled := range ob.schedule {
+			at := day.Add(scheduled.Offset)
+			if !at.After(from) || at.After(now) || scheduled.Phase == ob.phase {
+				continue
+			}
+
+			uncrossed, _ := ob.transition(scheduled.Phase, "schedule")
+			trades = append(trades, uncrossed...)
+		}
+	}
+
+	return
+}
+
+// transition validates and performs trading phase transition
+func (ob *OrderBook) transition(phase Phase, reason string) (trades []*Trade, err error) {
+	from := ob.phase
+	if !canTransit(from, phase) {
+		return nil, ErrInvalidPhase
+	}
+
+	if phase == Continuous && (from == Auction || from == PreOpen) {
+		trades = ob.uncross()
+	}
+
+	ob.phase = phase
+	ob.window = nil
+	if phase == Halted {
+		ob.haltReason = reason
+	}
+
+	event := &PhaseEvent{
+		From:   from,
+		To:     phase,
+		Time:   ob.now(),
+		Reason: reason,
+	}
+
+	for _, handler := range ob.phaseHandlers {
+		handler(event)
+	}
+
+	return
+}
+
+// canTransit checks that transition between phases is allowed
+func canTransit(from, to Phase) bool {
+	for _, phase := range phaseTransitions[from] {
+		if phase == to {
+			return true
+		}
+	}
+	return false
+}
+
+// checkLimitOrder validates limit order against trading phase and price band
+func (ob *OrderBook) checkLimitOrder(price decimal.Decimal) error {
+	ob.applySchedule()
+
+	switch ob.phase {
+	case Halted:
+		return ErrTradingHalted
+	case Closed:
+		return ErrBookClosed
+	}
+
+	if ob.band != nil && !ob.band.Contains(price) {
+		return ErrPriceOutOfBand
+	}
+
+	return nil
+}
+
+// checkMarketOrder validates market order against trading phase
+func (ob *OrderBook) checkMarketOrder() error {
+	ob.applySchedule()
+
+	switch ob.phase {
+	case Halted:
+		return ErrTradingHalted
+	case Closed:
+		return ErrBookClosed
+	case Auction:
+		return ErrAuctionMarketOrder
+	case PreOpen:
+		return ErrPreOpenMarketOrder
+	}
+
+	return nil
+}
+
+// checkCancel validates order cancelling against trading phase
+func (ob *OrderBook) checkCancel() error {
+	ob.applySchedule()
+
+	if ob.phase == Closed {
+		return ErrBookClosed
+	}
+
+	return nil
+}
+
+// matching reports whether incoming orders are matched right now
+func (ob *OrderBook) matching() bool {
+	return ob.phase == Continuous
+}
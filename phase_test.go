@@ -0,0 +1,140 @@
+package orderbook
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestPhaseTransitions(t *testing.T) {
+	ob := NewOrderBook()
+
+	var events []*PhaseEvent
+	ob.OnPhaseChange(func(e *PhaseEvent) {
+		events = append(events, e)
+	})
+
+	if err := ob.SetPhase(PreOpen, ""); err != ErrInvalidPhase {
+		t.Fatal("Can switch from continuous to pre-open")
+	}
+
+	if err := ob.SetPhase(Closed, "end of day"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, _, err := ob.ProcessLimitOrder(Buy, "b1", decimal.New(1, 0), decimal.New(100, 0)); err != ErrBookClosed {
+		t.Fatal("Closed order book accepts limit orders")
+	}
+
+	if _, _, _, _, err := ob.ProcessMarketOrder(Buy, decimal.New(1, 0)); err != ErrBookClosed {
+		t.Fatal("Closed order book accepts market orders")
+	}
+
+	if _, err := ob.CancelOrder("b1"); err != ErrBookClosed {
+		t.Fatal("Closed order book accepts cancels")
+	}
+
+	if err := ob.SetPhase(PreOpen, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	ob.ProcessLimitOrder(Buy, "b1", decimal.New(2, 0), decimal.New(101, 0))
+	ob.ProcessLimitOrder(Sell, "s1", decimal.New(1, 0), decimal.New(100, 0))
+
+	if ob.bids.Len() != 1 || ob.asks.Len() != 1 {
+		t.Fatal("Pre-open orders are matched")
+	}
+
+	if _, _, _, _, err := ob.ProcessMarketOrder(Buy, decimal.New(1, 0)); err != ErrPreOpenMarketOrder {
+		t.Fatal("Pre-open accepts market orders")
+	}
+
+	if _, _, _, _, err := ob.ProcessMarketOrderByNotional(Buy, "", decimal.New(1, 0), decimal.New(1, -8)); err != ErrPreOpenMarketOrder {
+		t.Fatal("Pre-open accepts notional market orders")
+	}
+
+	if err := ob.SetPhase(Continuous, "open"); err != nil {
+		t.Fatal(err)
+	}
+
+	if ob.asks.Len() != 0 || ob.Order("b1") == nil || !ob.Order("b1").Quantity().Equal(decimal.New(1, 0)) {
+		t.Fatal("Order book is not uncrossed on open")
+	}
+
+	if len(events) != 3 || events[0].To != Closed || events[1].From != Closed || events[2].To != Continuous || events[2].Reason != "open" {
+		t.Fatal("Invalid phase events", events)
+	}
+
+	result, _ := json.Marshal(ob)
+	restored := NewOrderBook()
+	if err := json.Unmarshal(result, restored); err != nil || restored.Phase() != Continuous {
+		t.Fatal("Phase is not restored", err)
+	}
+}
+
+func TestPhaseSchedule(t *testing.T) {
+	ob := NewOrderBook()
+	clock := newManualClock() // 10:00
+	ob.SetClock(clock)
+	ob.SetPhase(Closed, "")
+
+	ob.SetSchedule([]ScheduledPhase{
+		{Offset: 16 * time.Hour, Phase: Closed},
+		{Offset: 9 * time.Hour, Phase: Continuous},
+		{Offset: 8 * time.Hour, Phase: PreOpen},
+	})
+
+	if ob.Phase() != Closed {
+		t.Fatal("Schedule is applied retroactively")
+	}
+
+	clock.Add(22*time.Hour + 30*time.Minute) // 08:30 next day
+	if ob.Phase() != Closed {
+		t.Fatal("Schedule is applied by reading the phase")
+	}
+	if ob.Tick(); ob.Phase() != PreOpen {
+		t.Fatal("Pre-open is not scheduled", ob.Phase())
+	}
+
+	if _, _, _, err := ob.ProcessLimitOrder(Sell, "s1", decimal.New(1, 0), decimal.New(100, 0)); err != nil {
+		t.Fatal(err)
+	}
+
+	clock.Add(time.Hour) // 09:30
+	if _, _, _, _, err := ob.ProcessMarketOrder(Buy, decimal.New(1, 0)); err != nil {
+		t.Fatal("Continuous trading is not scheduled", err)
+	}
+
+	clock.Add(7 * time.Hour) // 16:30
+	if _, err := ob.CancelOrder("s1"); err != ErrBookClosed {
+		t.Fatal("Close is not scheduled", err)
+	}
+
+	// the whole trading day is skipped, the last transition wins
+	clock.Add(48 * time.Hour)
+	if ob.Tick(); ob.Phase() != Closed {
+		t.Fatal("Invalid phase after skipped days", ob.Phase())
+	}
+}
+
+func TestPhaseTickUncross(t *testing.T) {
+	ob := NewOrderBook()
+	clock := newManualClock() // 10:00
+	ob.SetClock(clock)
+	ob.SetPhase(Auction, "")
+	ob.ProcessLimitOrder(Sell, "s1", decimal.New(1, 0), decimal.New(100, 0))
+	ob.ProcessLimitOrder(Buy, "b1", decimal.New(1, 0), decimal.New(101, 0))
+
+	ob.SetSchedule([]ScheduledPhase{{Offset: 11 * time.Hour, Phase: Continuous}})
+	if trades := ob.Tick(); len(trades) != 0 || ob.Phase() != Auction {
+		t.Fatal("Transition before its time", trades)
+	}
+
+	clock.Add(time.Hour)
+	trades := ob.Tick()
+	if len(trades) != 1 || ob.Phase() != Continuous || ob.Order("s1") != nil {
+		t.Fatal("Scheduled uncross is not executed", trades, ob.Phase())
+	}
+}
@@ -0,0 +1,249 @@
+package orderbook
+
+import (
+	"encoding/json"
+
+	"github.com/shopspring/decimal"
+)
+
+// MarkMode selects price used to calculate unrealized PnL
+type MarkMode int
+
+// Mid price of the order book (the last trade price if a side is empty) or the last trade price
+const (
+	MarkMid MarkMode = iota
+	MarkLastTrade
+)
+
+// Position of the account built from its trades
+type Position struct {
+	Account    string          `json:"account"`
+	Quantity   decimal.Decimal `json:"quantity"` // net position, negative for short
+	EntryPrice decimal.Decimal `json:"entryPrice"`
+	Realized   decimal.Decimal `json:"realized"`
+	Unrealized decimal.Decimal `json:"unrealized"`
+	Fees       decimal.Decimal `json:"fees"`
+	Volume     decimal.Decimal `json:"volume"`
+}
+
+// PositionKeeper maintains positions and PnL of the accounts (order owners) from the
+// order book trades and limits their exposure. Attach it with OrderBook.SetPositionKeeper
+type PositionKeeper struct {
+	positions map[string]*Position
+	limits    map[string]decimal.Decimal // account -> maximal absolute position
+	mode      MarkMode
+}
+
+// NewPositionKeeper creates position keeper with given mark mode
+func NewPositionKeeper(mode MarkMode) *PositionKeeper {
+	return &PositionKeeper{
+		positions: map[string]*Position{},
+		limits:    map[string]decimal.Decimal{},
+		mode:      mode,
+	}
+}
+
+// SetPositionKeeper attaches position keeper to the order book, nil detaches it.
+// The keeper is serialized together with the order book
+func (ob *OrderBook) SetPositionKeeper(pk *PositionKeeper) {
+	ob.positions = pk
+}
+
+// SetLimit sets maximal absolute position of the account including its resting orders,
+// zero removes the limit
+func (pk *PositionKeeper) SetLimit(account string, limit decimal.Decimal) {
+	if limit.Sign() <= 0 {
+		delete(pk.limits, account)
+		return
+	}
+	pk.limits[account] = limit
+}
+
+// Position returns position of the account with unrealized PnL marked to given price
+func (pk *PositionKeeper) Position(account string, mark decimal.Decimal) Position {
+	p, ok := pk.positions[account]
+	if !ok {
+		return Position{Account: account}
+	}
+
+	result := *p
+	if mark.Sign() > 0 {
+		result.Unrealized = mark.Sub(p.EntryPrice).Mul(p.Quantity)
+	}
+	return result
+}
+
+// Accounts returns accounts which have positions
+func (pk *PositionKeeper) Accounts() (accounts []string) {
+	for account := range pk.positions {
+		accounts = append(accounts, account)
+	}
+	return
+}
+
+// MarkPrice returns price used to calculate unrealized PnL: mid price or the last trade price
+func (ob *OrderBook) MarkPrice() decimal.Decimal {
+	if ob.positions == nil || ob.positions.mode == MarkMid {
+		if mid := MidPrice(ob.bids, ob.asks); mid.Sign() > 0 {
+			return mid
+		}
+	}
+
+	if ob.lastTrade != nil {
+		return ob.lastTrade.Price
+	}
+
+	return decimal.Zero
+}
+
+// Position returns position of the account marked to the order book price
+func (ob *OrderBook) Position(account string) Position {
+	if ob.positions == nil {
+		return Position{Account: account}
+	}
+	return ob.positions.Position(account, ob.MarkPrice())
+}
+
+// apply updates positions of both accounts of the trade
+func (pk *PositionKeeper) apply(t *Trade) {
+	buyer, seller := t.TakerOwner, t.MakerOwner
+	buyerFee, sellerFee := t.TakerFee, t.MakerFee
+	if t.TakerSide == Sell {
+		buyer, seller = seller, buyer
+		buyerFee, sellerFee = sellerFee, buyerFee
+	}
+
+	pk.update(buyer, t.Price, t.Quantity, buyerFee)
+	pk.update(seller, t.Price, t.Quantity.Neg(), sellerFee)
+}
+
+// update adds signed quantity executed at price to the account position
+func (pk *PositionKeeper) update(account string, price, quantity, fee decimal.Decimal) {
+	if account == "" {
+		return
+	}
+
+	p, ok := pk.positions[account]
+	if !ok {
+		p = &Position{Account: account}
+		pk.positions[account] = p
+	}
+
+	p.Fees = p.Fees.Add(fee)
+	p.Volume = p.Volume.Add(quantity.Abs().Mul(price))
+
+	// opening or increasing the position moves the entry price
+	if p.Quantity.Sign() == 0 || p.Quantity.Sign() == quantity.Sign() {
+		total := p.Quantity.Add(quantity)
+		p.EntryPrice = p.EntryPrice.Mul(p.Quantity).Add(price.Mul(quantity)).Div(total)
+		p.Quantity = total
+		return
+	}
+
+	// closing realizes PnL, the rest opens the opposite position
+	closed := decimal.Min(quantity.Abs(), p.Quantity.Abs())
+	if p.Quantity.Sign() > 0 {
+		p.Realized = p.Realized.Add(price.Sub(p.EntryPrice).Mul(closed))
+		p.Quantity = p.Quantity.Sub(closed)
+		quantity = quantity.Add(closed)
+	} else {
+		p.Realized = p.Realized.Add(p.EntryPrice.Sub(price).Mul(closed))
+		p.Quantity = p.Quantity.Add(closed)
+		quantity = quantity.Sub(closed)
+	}
+
+	if p.Quantity.Sign() == 0 {
+		p.EntryPrice = decimal.Zero
+	}
+
+	if quantity.Sign() != 0 {
+		p.Quantity = quantity
+		p.EntryPrice = price
+	}
+}
+
+// check validates that the order keeps the account exposure within its limit
+func (pk *PositionKeeper) check(account string, side Side, quantity, openBuy, openSell decimal.Decimal) error {
+	limit, ok := pk.limits[account]
+	if !ok {
+		return nil
+	}
+
+	position := decimal.Zero
+	if p, ok := pk.positions[account]; ok {
+		position = p.Quantity
+	}
+
+	if side == Buy {
+		openBuy = openBuy.Add(quantity)
+	} else {
+		openSell = openSell.Add(quantity)
+	}
+
+	if position.Add(openBuy).Abs().GreaterThan(limit) || position.Sub(openSell).Abs().GreaterThan(limit) {
+		return ErrExposureLimit
+	}
+
+	return nil
+}
+
+// checkExposure validates the new order of the owner against position limits
+func (ob *OrderBook) checkExposure(owner string, side Side, quantity decimal.Decimal) error {
+	if ob.positions == nil {
+		return nil
+	}
+
+	openBuy, openSell := decimal.Zero, decimal.Zero
+	for _, e := range ob.owners[owner] {
+		o := e.Value.(*Order)
+		if o.Side() == Buy {
+			openBuy = openBuy.Add(o.Quantity())
+		} else {
+			openSell = openSell.Add(o.Quantity())
+		}
+	}
+
+	return ob.positions.check(owner, side, quantity, openBuy, openSell)
+}
+
+// MarshalJSON implements json.Marshaler interface
+func (pk *PositionKeeper) MarshalJSON() ([]byte, error) {
+	return json.Marshal(
+		&struct {
+			Mode      MarkMode                   `json:"mode"`
+			Positions map[string]*Position       `json:"positions"`
+			Limits    map[string]decimal.Decimal `json:"limits"`
+		}{
+			Mode:      pk.mode,
+			Positions: pk.positions,
+			Limits:    pk.limits,
+		},
+	)
+}
+
+// UnmarshalJSON implements json.Unmarshaler interface
+func (pk *PositionKeeper) UnmarshalJSON(data []byte) error {
+	obj := struct {
+		Mode      MarkMode                   `json:"mode"`
+		Positions map[string]*Position       `json:"positions"`
+		Limits    map[string]decimal.Decimal `json:"limits"`
+	}{}
+
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return err
+	}
+
+	pk.mode = obj.Mode
+	pk.positions = obj.Positions
+	pk.limits = obj.Limits
+
+	if pk.positions == nil {
+		pk.positions = map[string]*Position{}
+	}
+
+	if pk.limits == nil {
+		pk.limits = map[string]decimal.Decimal{}
+	}
+
+	return nil
+}
@@ -0,0 +1,94 @@
+package orderbook
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestPositionKeeper(t *testing.T) {
+	ob := NewOrderBook()
+	pk := NewPositionKeeper(MarkMid)
+	ob.SetPositionKeeper(pk)
+
+	ob.ProcessLimitOrderWithOwner(Sell, "s1", "maker", decimal.New(4, 0), decimal.New(100, 0))
+	ob.ProcessLimitOrderWithOwner(Sell, "s2", "maker", decimal.New(4, 0), decimal.New(110, 0))
+	ob.ProcessMarketOrderWithOwner(Buy, "taker", decimal.New(8, 0))
+
+	p := ob.Position("taker")
+	if !p.Quantity.Equal(decimal.New(8, 0)) || !p.EntryPrice.Equal(decimal.New(105, 0)) {
+		t.Fatal("Invalid position", p)
+	}
+
+	if m := ob.Position("maker"); !m.Quantity.Equal(decimal.New(-8, 0)) {
+		t.Fatal("Invalid maker position", m.Quantity)
+	}
+
+	// sell 10 at 120: closes 8 and opens short 2
+	ob.ProcessLimitOrderWithOwner(Buy, "b1", "maker", decimal.New(10, 0), decimal.New(120, 0))
+	ob.ProcessMarketOrderWithOwner(Sell, "taker", decimal.New(10, 0))
+
+	p = ob.Position("taker")
+	if !p.Quantity.Equal(decimal.New(-2, 0)) || !p.EntryPrice.Equal(decimal.New(120, 0)) {
+		t.Fatal("Invalid reversed position", p)
+	}
+
+	if !p.Realized.Equal(decimal.New(120, 0)) {
+		t.Fatal("Invalid realized PnL", p.Realized)
+	}
+
+	// mid price is (100 + 110) / 2 = 105
+	ob.ProcessLimitOrderWithOwner(Buy, "b2", "other", decimal.New(1, 0), decimal.New(100, 0))
+	ob.ProcessLimitOrderWithOwner(Sell, "s3", "other", decimal.New(1, 0), decimal.New(110, 0))
+	if !ob.MarkPrice().Equal(decimal.New(105, 0)) {
+		t.Fatal("Invalid mark price", ob.MarkPrice())
+	}
+
+	if u := ob.Position("taker").Unrealized; !u.Equal(decimal.New(30, 0)) {
+		t.Fatal("Invalid unrealized PnL", u)
+	}
+
+	result, _ := json.Marshal(ob)
+	restored := NewOrderBook()
+	if err := json.Unmarshal(result, restored); err != nil {
+		t.Fatal(err)
+	}
+
+	if r := restored.Position("taker"); !r.Quantity.Equal(decimal.New(-2, 0)) || !r.Realized.Equal(decimal.New(120, 0)) {
+		t.Fatal("Positions are not restored", r)
+	}
+}
+
+func TestPositionExposureLimit(t *testing.T) {
+	ob := NewOrderBook()
+	pk := NewPositionKeeper(MarkLastTrade)
+	ob.SetPositionKeeper(pk)
+	pk.SetLimit("alice", decimal.New(5, 0))
+
+	if _, _, _, err := ob.ProcessLimitOrderWithOwner(Buy, "b1", "alice", decimal.New(3, 0), decimal.New(100, 0)); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, _, err := ob.ProcessLimitOrderWithOwner(Buy, "b2", "alice", decimal.New(3, 0), decimal.New(100, 0)); err != ErrExposureLimit {
+		t.Fatal("Resting orders are not counted in exposure")
+	}
+
+	ob.ProcessMarketOrderWithOwner(Sell, "bob", decimal.New(3, 0))
+	if !ob.MarkPrice().Equal(decimal.New(100, 0)) {
+		t.Fatal("Invalid mark price", ob.MarkPrice())
+	}
+
+	if _, _, _, _, err := ob.ProcessMarketOrderWithOwner(Buy, "alice", decimal.New(3, 0)); err != ErrExposureLimit {
+		t.Fatal("Position is not counted in exposure")
+	}
+
+	if _, _, _, err := ob.ProcessLimitOrderWithOwner(Sell, "s1", "alice", decimal.New(8, 0), decimal.New(110, 0)); err != nil {
+		t.Fatal(err)
+	}
+
+	pk.SetLimit("alice", decimal.Zero)
+	if _, _, _, err := ob.ProcessLimitOrderWithOwner(Buy, "b2", "alice", decimal.New(30, 0), decimal.New(100, 0)); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,96 @@
+package orderbook
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// PriceBand rejects limit orders priced more than Percent away from the Reference price
+type PriceBand struct {
+	Reference decimal.Decimal
+	Percent   decimal.Decimal
+}
+
+// Contains checks that price is inside of the band
+func (pb *PriceBand) Contains(price decimal.Decimal) bool {
+	distance := pb.Reference.Mul(pb.Percent).Div(oneHundred)
+	return price.GreaterThanOrEqual(pb.Reference.Sub(distance)) &&
+		price.LessThanOrEqual(pb.Reference.Add(distance))
+}
+
+// CircuitBreaker halts matching when a trade would move the price more than
+// Percent away from any trade executed within the rolling Window
+type CircuitBreaker struct {
+	Percent decimal.Decimal
+	Window  time.Duration
+}
+
+// tradePoint is price of the trade in the circuit breaker window
+type tradePoint struct {
+	time  time.Time
+	price decimal.Decimal
+}
+
+// SetPriceBand enables static price band for limit orders, nil disables it
+func (ob *OrderBook) SetPriceBand(band *PriceBand) {
+	ob.band = band
+}
+
+// SetCircuitBreaker enables dynamic circuit breaker, nil disables it
+func (ob *OrderBook) SetCircuitBreaker(breaker *CircuitBreaker) {
+	ob.breaker = breaker
+	ob.window = nil
+}
+
+// LastTrade returns the last execution in the order book or nil
+func (ob *OrderBook) LastTrade() *Trade {
+	return ob.lastTrade
+}
+
+// allowTrade checks trade price with circuit breaker and halts the order book if it trips
+func (ob *OrderBook) allowTrade(price decimal.Decimal) bool {
+	if ob.breaker == nil {
+		return true
+	}
+
+	ob.pruneWindow()
+	for _, point := range ob.window {
+		limit := point.price.Mul(ob.breaker.Percent).Div(oneHundred)
+		if price.Sub(point.price).Abs().GreaterThan(limit) {
+			ob.Halt(fmt.Sprintf("circuit breaker: price %s is more than %s%% away from %s traded at %s",
+				price, ob.breaker.Percent, point.price, point.time.Format(time.RFC3339Nano)))
+			return false
+		}
+	}
+
+	return true
+}
+
+// recordTrade keeps trade for the circuit breaker and reference prices
+func (ob *OrderBook) recordTrade(t *Trade) {
+	ob.lastTrade = t
+
+	if ob.rates != nil {
+		ob.rates.add(t)
+	}
+
+	if ob.breaker == nil {
+		return
+	}
+
+	ob.pruneWindow()
+	ob.window = append(ob.window, tradePoint{time: t.Timestamp, price: t.Price})
+}
+
+// pruneWindow removes trades older than circuit breaker window
+func (ob *OrderBook) pruneWindow() {
+	from := ob.now().Add(-ob.breaker.Window)
+
+	i := 0
+	for i < len(ob.window) && ob.window[i].time.Before(from) {
+		i++
+	}
+	ob.window = ob.window[i:]
+}
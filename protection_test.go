@@ -0,0 +1,141 @@
+package orderbook
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestPriceBand(t *testing.T) {
+	ob := NewOrderBook()
+	ob.SetPriceBand(&PriceBand{Reference: decimal.New(100, 0), Percent: decimal.New(10, 0)})
+
+	if _, _, _, err := ob.ProcessLimitOrder(Buy, "b1", decimal.New(1, 0), decimal.New(89, 0)); err != ErrPriceOutOfBand {
+		t.Fatal("Can place order below the band")
+	}
+
+	if _, _, _, err := ob.ProcessLimitOrder(Sell, "s1", decimal.New(1, 0), decimal.New(111, 0)); err != ErrPriceOutOfBand {
+		t.Fatal("Can place order above the band")
+	}
+
+	if _, _, _, err := ob.ProcessLimitOrder(Buy, "b2", decimal.New(1, 0), decimal.New(90, 0)); err != nil {
+		t.Fatal(err)
+	}
+
+	ob.SetPriceBand(nil)
+	if _, _, _, err := ob.ProcessLimitOrder(Buy, "b1", decimal.New(1, 0), decimal.New(50, 0)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	ob := NewOrderBook()
+	clock := newManualClock()
+	ob.SetClock(clock)
+	ob.SetCircuitBreaker(&CircuitBreaker{Percent: decimal.New(15, 0), Window: time.Minute})
+	addDepth(ob, "", decimal.New(2, 0))
+
+	if _, _, _, _, err := ob.ProcessMarketOrder(Sell, decimal.New(2, 0)); err != nil {
+		t.Fatal(err)
+	}
+
+	// 90 was traded, 70 is more than 15% away
+	done, _, _, left, err := ob.ProcessMarketOrder(Sell, decimal.New(6, 0))
+	if err != ErrTradingHalted {
+		t.Fatal("Circuit breaker is not tripped")
+	}
+
+	if len(done) != 1 || done[0].ID() != "buy-80" || !left.Equal(decimal.New(4, 0)) {
+		t.Fatal("Invalid execution before halt", done, left)
+	}
+
+	if ob.Phase() != Halted || ob.HaltReason() == "" {
+		t.Fatal("Order book is not halted")
+	}
+
+	if _, _, _, err := ob.ProcessLimitOrder(Buy, "b1", decimal.New(1, 0), decimal.New(90, 0)); err != ErrTradingHalted {
+		t.Fatal("Halted order book accepts limit orders")
+	}
+
+	if _, _, _, _, err := ob.ProcessMarketOrder(Buy, decimal.New(1, 0)); err != ErrTradingHalted {
+		t.Fatal("Halted order book accepts market orders")
+	}
+
+	if _, err := ob.CancelOrder("buy-50"); err != nil {
+		t.Fatal("Halted order book doesn't accept cancels")
+	}
+
+	if err := ob.Resume(Halted); err != ErrInvalidPhase {
+		t.Fatal("Can resume into halted phase")
+	}
+
+	if err := ob.Resume(Continuous); err != nil {
+		t.Fatal(err)
+	}
+
+	clock.Add(2 * time.Minute)
+	if _, _, _, _, err := ob.ProcessMarketOrder(Sell, decimal.New(2, 0)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ob.Resume(Continuous); err != ErrInvalidPhase {
+		t.Fatal("Can resume not halted order book")
+	}
+}
+
+func TestAuctionUncross(t *testing.T) {
+	ob := NewOrderBook()
+	ob.Halt("maintenance")
+	if err := ob.Resume(Auction); err != nil {
+		t.Fatal(err)
+	}
+
+	ob.ProcessLimitOrder(Sell, "s100", decimal.New(5, 0), decimal.New(100, 0))
+	ob.ProcessLimitOrder(Sell, "s101", decimal.New(5, 0), decimal.New(101, 0))
+	ob.ProcessLimitOrder(Sell, "s105", decimal.New(5, 0), decimal.New(105, 0))
+	ob.ProcessLimitOrder(Buy, "b103", decimal.New(4, 0), decimal.New(103, 0))
+	ob.ProcessLimitOrder(Buy, "b102", decimal.New(4, 0), decimal.New(102, 0))
+	ob.ProcessLimitOrder(Buy, "b99", decimal.New(4, 0), decimal.New(99, 0))
+
+	if ob.bids.Len() != 3 || ob.asks.Len() != 3 {
+		t.Fatal("Auction orders are matched")
+	}
+
+	if _, _, _, _, err := ob.ProcessMarketOrder(Buy, decimal.New(1, 0)); err != ErrAuctionMarketOrder {
+		t.Fatal("Auction accepts market orders")
+	}
+
+	trades, err := ob.Uncross()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	total := decimal.Zero
+	for _, trade := range trades {
+		if !trade.Price.Equal(decimal.New(101, 0)) || !trade.Auction {
+			t.Fatal("Invalid auction trade", trade)
+		}
+		total = total.Add(trade.Quantity)
+	}
+
+	if !total.Equal(decimal.New(8, 0)) {
+		t.Fatal("Invalid uncross quantity", total)
+	}
+
+	if ob.Phase() != Continuous {
+		t.Fatal("Order book is not continuous after uncross")
+	}
+
+	if o := ob.Order("s101"); o == nil || !o.Quantity().Equal(decimal.New(2, 0)) {
+		t.Fatal("Invalid rest of the auction", o)
+	}
+
+	if ob.Order("b103") != nil || ob.Order("b102") != nil || ob.Order("s100") != nil {
+		t.Fatal("Executed orders are in the order book")
+	}
+
+	if _, err := ob.Uncross(); err != ErrInvalidPhase {
+		t.Fatal("Can uncross continuous order book")
+	}
+}
@@ -0,0 +1,131 @@
+package orderbook
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// QueuePosition describes place of the resting order in the order book
+type QueuePosition struct {
+	OrderID       string          `json:"orderId"`
+	Side          Side            `json:"side"`
+	Price         decimal.Decimal `json:"price"`
+	Quantity      decimal.Decimal `json:"quantity"`
+	OrdersAhead   int             `json:"ordersAhead"`
+	QuantityAhead decimal.Decimal `json:"quantityAhead"`
+	LevelsFromTop int             `json:"levelsFromTop"`
+	TicksFromTop  int64           `json:"ticksFromTop"`
+	// TimeToFill is estimated by the trade rate at the order price, zero if it is unknown
+	TimeToFill time.Duration `json:"timeToFill"`
+}
+
+// rateTrade is executed quantity in the trade rate window
+type rateTrade struct {
+	time     time.Time
+	price    string
+	quantity decimal.Decimal
+}
+
+// tradeRates keeps executed quantity by price within the rolling window
+type tradeRates struct {
+	window   time.Duration
+	trades   []rateTrade
+	quantity map[string]decimal.Decimal
+}
+
+// SetTradeRateWindow enables tracking of executed quantity by price within the rolling
+// window which is used to estimate time to fill of the resting orders, zero disables it
+func (ob *OrderBook) SetTradeRateWindow(window time.Duration) {
+	if window <= 0 {
+		ob.rates = nil
+		return
+	}
+
+	ob.rates = &tradeRates{
+		window:   window,
+		quantity: map[string]decimal.Decimal{},
+	}
+}
+
+// TradeRate returns quantity executed at the price per second within the trade rate window
+func (ob *OrderBook) TradeRate(price decimal.Decimal) decimal.Decimal {
+	if ob.rates == nil {
+		return decimal.Zero
+	}
+
+	ob.rates.evict(ob.now())
+	return ob.rates.quantity[price.String()].Div(decimal.NewFromFloat(ob.rates.window.Seconds()))
+}
+
+// QueuePosition returns position of the resting order in its price level and its
+// distance from the top of the order book in levels and ticks of given size
+// (TicksFromTop is zero if tickSize is zero)
+func (ob *OrderBook) QueuePosition(orderID string, tickSize decimal.Decimal) (*QueuePosition, error) {
+	e, ok := ob.orders[orderID]
+	if !ok {
+		return nil, ErrOrderNotExists
+	}
+
+	o := e.Value.(*Order)
+	pos := &QueuePosition{
+		OrderID:  o.ID(),
+		Side:     o.Side(),
+		Price:    o.Price(),
+		Quantity: o.Quantity(),
+	}
+
+	for iter := e.Prev(); iter != nil; iter = iter.Prev() {
+		pos.OrdersAhead++
+		pos.QuantityAhead = pos.QuantityAhead.Add(iter.Value.(*Order).Quantity())
+	}
+
+	var best decimal.Decimal
+	ob.GetOrderSide(o.Side()).walk(o.Side(), func(level *OrderQueue) bool {
+		if pos.LevelsFromTop == 0 && best.IsZero() {
+			best = level.Price()
+		}
+		if level.Price().Equal(o.Price()) {
+			return false
+		}
+		pos.LevelsFromTop++
+		return true
+	})
+
+	if tickSize.Sign() > 0 {
+		pos.TicksFromTop = best.Sub(o.Price()).Abs().Div(tickSize).IntPart()
+	}
+
+	if rate := ob.TradeRate(o.Price()); rate.Sign() > 0 {
+		seconds := pos.QuantityAhead.Add(o.Quantity()).Div(rate)
+		pos.TimeToFill = time.Duration(seconds.Mul(decimal.New(int64(time.Second), 0)).IntPart())
+	}
+
+	return pos, nil
+}
+
+// add registers executed quantity at the price
+func (tr *tradeRates) add(t *Trade) {
+	tr.evict(t.Timestamp)
+
+	price := t.Price.String()
+	tr.trades = append(tr.trades, rateTrade{time: t.Timestamp, price: price, quantity: t.Quantity})
+	tr.quantity[price] = tr.quantity[price].Add(t.Quantity)
+}
+
+// evict removes trades which left the window ending at now
+func (tr *tradeRates) evict(now time.Time) {
+	from := now.Add(-tr.window)
+
+	i := 0
+	for ; i < len(tr.trades) && !tr.trades[i].time.After(from); i++ {
+		t := tr.trades[i]
+		left := tr.quantity[t.price].Sub(t.quantity)
+		if left.Sign() <= 0 {
+			delete(tr.quantity, t.price)
+		} else {
+			tr.quantity[t.price] = left
+		}
+	}
+	tr.trades = tr.trades[i:]
+}
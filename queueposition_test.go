@@ -0,0 +1,62 @@
+package orderbook
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestQueuePosition(t *testing.T) {
+	ob := NewOrderBook()
+	clock := newManualClock()
+	ob.SetClock(clock)
+	ob.SetTradeRateWindow(10 * time.Second)
+
+	addDepth(ob, "", decimal.New(2, 0))
+	ob.ProcessLimitOrder(Sell, "second-100", decimal.New(3, 0), decimal.New(100, 0))
+	ob.ProcessLimitOrder(Sell, "third-100", decimal.New(1, 0), decimal.New(100, 0))
+
+	if _, err := ob.QueuePosition("fake", decimal.New(1, 0)); err != ErrOrderNotExists {
+		t.Fatal("Can get queue position of unknown order", err)
+	}
+
+	pos, err := ob.QueuePosition("third-100", decimal.New(5, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pos.OrdersAhead != 2 || !pos.QuantityAhead.Equal(decimal.New(5, 0)) ||
+		pos.LevelsFromTop != 0 || pos.TicksFromTop != 0 || pos.TimeToFill != 0 {
+		t.Fatal("Invalid queue position at the top", pos)
+	}
+
+	pos, err = ob.QueuePosition("buy-70", decimal.New(5, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pos.OrdersAhead != 0 || !pos.QuantityAhead.IsZero() || pos.LevelsFromTop != 2 || pos.TicksFromTop != 4 {
+		t.Fatal("Invalid queue position of the deep order", pos)
+	}
+
+	// 2 executed at 100 within 10 seconds
+	ob.ProcessMarketOrder(Buy, decimal.New(2, 0))
+	clock.Add(time.Second)
+
+	if !ob.TradeRate(decimal.New(100, 0)).Equal(decimal.New(2, -1)) {
+		t.Fatal("Invalid trade rate", ob.TradeRate(decimal.New(100, 0)))
+	}
+
+	pos, err = ob.QueuePosition("third-100", decimal.Zero)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// (3 ahead + 1 own) / 0.2 per second
+	if pos.OrdersAhead != 1 || pos.TimeToFill != 20*time.Second {
+		t.Fatal("Invalid time to fill", pos)
+	}
+
+	clock.Add(10 * time.Second)
+	if !ob.TradeRate(decimal.New(100, 0)).IsZero() {
+		t.Fatal("Trade left the window", ob.TradeRate(decimal.New(100, 0)))
+	}
+}
@@ -0,0 +1,128 @@
+package orderbook
+
+import (
+	"github.com/shopspring/decimal"
+)
+
+// Reservation describes funds required by the incoming order
+// Buy orders reserve Notional of the quote currency, sell orders reserve Quantity
+// of the base asset. Price is the limit price of the order, it is zero for
+// market orders which have no limit
+type Reservation struct {
+	OrderID  string
+	Owner    string
+	Side     Side
+	Price    decimal.Decimal
+	Quantity decimal.Decimal
+	Notional decimal.Decimal
+}
+
+// RiskCheck validates incoming orders against account funds. The order book calls
+// Reserve before the order is processed, Settle for every trade and Release when
+// the order leaves the order book by cancel or its unfilled remainder is dropped
+type RiskCheck interface {
+	Reserve(r *Reservation) error
+	Settle(t *Trade)
+	Release(owner, orderID string)
+}
+
+// SetRiskCheck sets pre-trade risk check, nil disables it
+func (ob *OrderBook) SetRiskCheck(risk RiskCheck) {
+	ob.risk = risk
+}
+
+// RiskCheck returns pre-trade risk check of the order book, nil if not set
+func (ob *OrderBook) RiskCheck() RiskCheck {
+	return ob.risk
+}
+
+// reserveLimit reserves funds for the limit order
+func (ob *OrderBook) reserveLimit(side Side, orderID, owner string, quantity, price decimal.Decimal) error {
+	if ob.risk == nil {
+		return nil
+	}
+
+	return ob.risk.Reserve(&Reservation{
+		OrderID:  orderID,
+		Owner:    owner,
+		Side:     side,
+		Price:    price,
+		Quantity: quantity,
+		Notional: quantity.Mul(price),
+	})
+}
+
+// reserveMarket reserves funds for the market order of definite quantity
+func (ob *OrderBook) reserveMarket(side Side, orderID, owner string, quantity decimal.Decimal) error {
+	if ob.risk == nil {
+		return nil
+	}
+
+	r := &Reservation{
+		OrderID:  orderID,
+		Owner:    owner,
+		Side:     side,
+		Quantity: quantity,
+	}
+
+	if side == Buy {
+		r.Notional, _, _ = ob.CalculateMarketPrice(Buy, quantity)
+	}
+
+	return ob.risk.Reserve(r)
+}
+
+// reserveNotional reserves funds for the market order of definite notional
+func (ob *OrderBook) reserveNotional(side Side, owner string, notional decimal.Decimal) error {
+	if ob.risk == nil {
+		return nil
+	}
+
+	r := &Reservation{
+		Owner:    owner,
+		Side:     side,
+		Notional: notional,
+	}
+
+	if side == Sell {
+		r.Quantity = ob.quantityForNotional(Sell, notional)
+	}
+
+	return ob.risk.Reserve(r)
+}
+
+// quantityForNotional calculates base quantity which should be traded to get notional
+func (ob *OrderBook) quantityForNotional(side Side, notional decimal.Decimal) (quantity decimal.Decimal) {
+	var (
+		level *OrderQueue
+		iter  func(decimal.Decimal) *OrderQueue
+	)
+
+	if side == Buy {
+		level = ob.asks.MinPriceQueue()
+		iter = ob.asks.GreaterThan
+	} else {
+		level = ob.bids.MaxPriceQueue()
+		iter = ob.bids.LessThan
+	}
+
+	for notional.Sign() > 0 && level != nil {
+		levelNotional := level.Price().Mul(level.Volume())
+		if notional.LessThan(levelNotional) {
+			return quantity.Add(notional.Div(level.Price()))
+		}
+
+		quantity = quantity.Add(level.Volume())
+		notional = notional.Sub(levelNotional)
+		level = iter(level.Price())
+	}
+
+	return
+}
+
+// release returns reserved funds left for the order
+func (ob *OrderBook) release(owner, orderID string) {
+	if ob.risk != nil {
+		ob.risk.Release(owner, orderID)
+	}
+}
@@ -0,0 +1,416 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: orderbook.proto
+
+package rpc
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	OrderBook_SubmitLimitOrder_FullMethodName  = "/orderbook.v1.OrderBook/SubmitLimitOrder"
+	OrderBook_SubmitMarketOrder_FullMethodName = "/orderbook.v1.OrderBook/SubmitMarketOrder"
+	OrderBook_CancelOrder_FullMethodName       = "/orderbook.v1.OrderBook/CancelOrder"
+	OrderBook_AmendOrder_FullMethodName        = "/orderbook.v1.OrderBook/AmendOrder"
+	OrderBook_GetOrder_FullMethodName          = "/orderbook.v1.OrderBook/GetOrder"
+	OrderBook_GetDepth_FullMethodName          = "/orderbook.v1.OrderBook/GetDepth"
+	OrderBook_StreamDepth_FullMethodName       = "/orderbook.v1.OrderBook/StreamDepth"
+	OrderBook_StreamTrades_FullMethodName      = "/orderbook.v1.OrderBook/StreamTrades"
+)
+
+// OrderBookClient is the client API for OrderBook service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// OrderBook exposes order entry and market data of the matching engine.
+// Decimal values are carried as strings in the decimal notation
+type OrderBookClient interface {
+	// SubmitLimitOrder places limit order (ProcessLimitOrderWithOwner)
+	SubmitLimitOrder(ctx context.Context, in *SubmitLimitOrderRequest, opts ...grpc.CallOption) (*SubmitOrderResponse, error)
+	// SubmitMarketOrder executes market order (ProcessMarketOrderWithOwner)
+	SubmitMarketOrder(ctx context.Context, in *SubmitMarketOrderRequest, opts ...grpc.CallOption) (*SubmitOrderResponse, error)
+	// CancelOrder removes resting order (CancelOrder)
+	CancelOrder(ctx context.Context, in *CancelOrderRequest, opts ...grpc.CallOption) (*Order, error)
+	// AmendOrder replaces quantity and price of the resting order, the order loses time priority
+	AmendOrder(ctx context.Context, in *AmendOrderRequest, opts ...grpc.CallOption) (*SubmitOrderResponse, error)
+	// GetOrder returns resting order
+	GetOrder(ctx context.Context, in *GetOrderRequest, opts ...grpc.CallOption) (*Order, error)
+	// GetDepth returns price levels closest to the spread
+	GetDepth(ctx context.Context, in *DepthRequest, opts ...grpc.CallOption) (*Depth, error)
+	// StreamDepth sends depth snapshot followed by changed levels
+	StreamDepth(ctx context.Context, in *DepthRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[DepthUpdate], error)
+	// StreamTrades sends trades executed after subscription
+	StreamTrades(ctx context.Context, in *StreamTradesRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Trade], error)
+}
+
+type orderBookClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewOrderBookClient(cc grpc.ClientConnInterface) OrderBookClient {
+	return &orderBookClient{cc}
+}
+
+func (c *orderBookClient) SubmitLimitOrder(ctx context.Context, in *SubmitLimitOrderRequest, opts ...grpc.CallOption) (*SubmitOrderResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SubmitOrderResponse)
+	err := c.cc.Invoke(ctx, OrderBook_SubmitLimitOrder_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *orderBookClient) SubmitMarketOrder(ctx context.Context, in *SubmitMarketOrderRequest, opts ...grpc.CallOption) (*SubmitOrderResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SubmitOrderResponse)
+	err := c.cc.Invoke(ctx, OrderBook_SubmitMarketOrder_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *orderBookClient) CancelOrder(ctx context.Context, in *CancelOrderRequest, opts ...grpc.CallOption) (*Order, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Order)
+	err := c.cc.Invoke(ctx, OrderBook_CancelOrder_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *orderBookClient) AmendOrder(ctx context.Context, in *AmendOrderRequest, opts ...grpc.CallOption) (*SubmitOrderResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SubmitOrderResponse)
+	err := c.cc.Invoke(ctx, OrderBook_AmendOrder_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *orderBookClient) GetOrder(ctx context.Context, in *GetOrderRequest, opts ...grpc.CallOption) (*Order, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Order)
+	err := c.cc.Invoke(ctx, OrderBook_GetOrder_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *orderBookClient) GetDepth(ctx context.Context, in *DepthRequest, opts ...grpc.CallOption) (*Depth, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Depth)
+	err := c.cc.Invoke(ctx, OrderBook_GetDepth_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *orderBookClient) StreamDepth(ctx context.Context, in *DepthRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[DepthUpdate], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &OrderBook_ServiceDesc.Streams[0], OrderBook_StreamDepth_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[DepthRequest, DepthUpdate]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type OrderBook_StreamDepthClient = grpc.ServerStreamingClient[DepthUpdate]
+
+func (c *orderBookClient) StreamTrades(ctx context.Context, in *StreamTradesRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Trade], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &OrderBook_ServiceDesc.Streams[1], OrderBook_StreamTrades_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[StreamTradesRequest, Trade]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type OrderBook_StreamTradesClient = grpc.ServerStreamingClient[Trade]
+
+// OrderBookServer is the server API for OrderBook service.
+// All implementations must embed UnimplementedOrderBookServer
+// for forward compatibility.
+//
+// OrderBook exposes order entry and market data of the matching engine.
+// Decimal values are carried as strings in the decimal notation
+type OrderBookServer interface {
+	// SubmitLimitOrder places limit order (ProcessLimitOrderWithOwner)
+	SubmitLimitOrder(context.Context, *SubmitLimitOrderRequest) (*SubmitOrderResponse, error)
+	// SubmitMarketOrder executes market order (ProcessMarketOrderWithOwner)
+	SubmitMarketOrder(context.Context, *SubmitMarketOrderRequest) (*SubmitOrderResponse, error)
+	// CancelOrder removes resting order (CancelOrder)
+	CancelOrder(context.Context, *CancelOrderRequest) (*Order, error)
+	// AmendOrder replaces quantity and price of the resting order, the order loses time priority
+	AmendOrder(context.Context, *AmendOrderRequest) (*SubmitOrderResponse, error)
+	// GetOrder returns resting order
+	GetOrder(context.Context, *GetOrderRequest) (*Order, error)
+	// GetDepth returns price levels closest to the spread
+	GetDepth(context.Context, *DepthRequest) (*Depth, error)
+	// StreamDepth sends depth snapshot followed by changed levels
+	StreamDepth(*DepthRequest, grpc.ServerStreamingServer[DepthUpdate]) error
+	// StreamTrades sends trades executed after subscription
+	StreamTrades(*StreamTradesRequest, grpc.ServerStreamingServer[Trade]) error
+	mustEmbedUnimplementedOrderBookServer()
+}
+
+// UnimplementedOrderBookServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedOrderBookServer struct{}
+
+func (UnimplementedOrderBookServer) SubmitLimitOrder(context.Context, *SubmitLimitOrderRequest) (*SubmitOrderResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SubmitLimitOrder not implemented")
+}
+func (UnimplementedOrderBookServer) SubmitMarketOrder(context.Context, *SubmitMarketOrderRequest) (*SubmitOrderResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SubmitMarketOrder not implemented")
+}
+func (UnimplementedOrderBookServer) CancelOrder(context.Context, *CancelOrderRequest) (*Order, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CancelOrder not implemented")
+}
+func (UnimplementedOrderBookServer) AmendOrder(context.Context, *AmendOrderRequest) (*SubmitOrderResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AmendOrder not implemented")
+}
+func (UnimplementedOrderBookServer) GetOrder(context.Context, *GetOrderRequest) (*Order, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetOrder not implemented")
+}
+func (UnimplementedOrderBookServer) GetDepth(context.Context, *DepthRequest) (*Depth, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetDepth not implemented")
+}
+func (UnimplementedOrderBookServer) StreamDepth(*DepthRequest, grpc.ServerStreamingServer[DepthUpdate]) error {
+	return status.Errorf(codes.Unimplemented, "method StreamDepth not implemented")
+}
+func (UnimplementedOrderBookServer) StreamTrades(*StreamTradesRequest, grpc.ServerStreamingServer[Trade]) error {
+	return status.Errorf(codes.Unimplemented, "method StreamTrades not implemented")
+}
+func (UnimplementedOrderBookServer) mustEmbedUnimplementedOrderBookServer() {}
+func (UnimplementedOrderBookServer) testEmbeddedByValue()                   {}
+
+// UnsafeOrderBookServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to OrderBookServer will
+// result in compilation errors.
+type UnsafeOrderBookServer interface {
+	mustEmbedUnimplementedOrderBookServer()
+}
+
+func RegisterOrderBookServer(s grpc.ServiceRegistrar, srv OrderBookServer) {
+	// If the following call pancis, it indicates UnimplementedOrderBookServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&OrderBook_ServiceDesc, srv)
+}
+
+func _OrderBook_SubmitLimitOrder_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SubmitLimitOrderRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OrderBookServer).SubmitLimitOrder(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: OrderBook_SubmitLimitOrder_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OrderBookServer).SubmitLimitOrder(ctx, req.(*SubmitLimitOrderRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _OrderBook_SubmitMarketOrder_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SubmitMarketOrderRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OrderBookServer).SubmitMarketOrder(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: OrderBook_SubmitMarketOrder_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OrderBookServer).SubmitMarketOrder(ctx, req.(*SubmitMarketOrderRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _OrderBook_CancelOrder_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CancelOrderRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OrderBookServer).CancelOrder(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: OrderBook_CancelOrder_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OrderBookServer).CancelOrder(ctx, req.(*CancelOrderRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _OrderBook_AmendOrder_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AmendOrderRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OrderBookServer).AmendOrder(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: OrderBook_AmendOrder_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OrderBookServer).AmendOrder(ctx, req.(*AmendOrderRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _OrderBook_GetOrder_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetOrderRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OrderBookServer).GetOrder(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: OrderBook_GetOrder_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OrderBookServer).GetOrder(ctx, req.(*GetOrderRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _OrderBook_GetDepth_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DepthRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OrderBookServer).GetDepth(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: OrderBook_GetDepth_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OrderBookServer).GetDepth(ctx, req.(*DepthRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _OrderBook_StreamDepth_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(DepthRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(OrderBookServer).StreamDepth(m, &grpc.GenericServerStream[DepthRequest, DepthUpdate]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type OrderBook_StreamDepthServer = grpc.ServerStreamingServer[DepthUpdate]
+
+func _OrderBook_StreamTrades_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StreamTradesRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(OrderBookServer).StreamTrades(m, &grpc.GenericServerStream[StreamTradesRequest, Trade]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type OrderBook_StreamTradesServer = grpc.ServerStreamingServer[Trade]
+
+// OrderBook_ServiceDesc is the grpc.ServiceDesc for OrderBook service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var OrderBook_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "orderbook.v1.OrderBook",
+	HandlerType: (*OrderBookServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "SubmitLimitOrder",
+			Handler:    _OrderBook_SubmitLimitOrder_Handler,
+		},
+		{
+			MethodName: "SubmitMarketOrder",
+			Handler:    _OrderBook_SubmitMarketOrder_Handler,
+		},
+		{
+			MethodName: "CancelOrder",
+			Handler:    _OrderBook_CancelOrder_Handler,
+		},
+		{
+			MethodName: "AmendOrder",
+			Handler:    _OrderBook_AmendOrder_Handler,
+		},
+		{
+			MethodName: "GetOrder",
+			Handler:    _OrderBook_GetOrder_Handler,
+		},
+		{
+			MethodName: "GetDepth",
+			Handler:    _OrderBook_GetDepth_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamDepth",
+			Handler:       _OrderBook_StreamDepth_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "StreamTrades",
+			Handler:       _OrderBook_StreamTrades_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "orderbook.proto",
+}
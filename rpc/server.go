@@ -0,0 +1,468 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"sort"
+
+	"github.com/shopspring/decimal"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"orderbook"
+)
+
+// errSlowConsumer ends the stream which does not keep up with updates
+var errSlowConsumer = status.Error(codes.ResourceExhausted, "stream consumer is too slow, subscribe again")
+
+// Server implements OrderBook gRPC service on top of the matching engine.
+// Every call is a single command of the engine, so it behaves exactly as the Go API
+type Server struct {
+	UnimplementedOrderBookServer
+
+	engine *orderbook.Engine
+	buffer int
+
+	// fields below are accessed from the matching goroutine only
+	depthSubs map[*subscriber]struct{}
+	tradeSubs map[*subscriber]struct{}
+	levels    map[string]*PriceLevel
+	sides     map[string]orderbook.Side
+	trades    []*orderbook.Trade
+	depthSeq  uint64
+}
+
+// subscriber is server stream waiting for messages
+type subscriber struct {
+	messages chan interface{}
+	dropped  chan struct{}
+}
+
+// NewServer creates gRPC service of the matching engine
+// Arguments:
+//
+//	engine - matching engine of the order book
+//	buffer - maximum number of messages queued for the stream, slow stream is ended with ResourceExhausted
+func NewServer(engine *orderbook.Engine, buffer int) (*Server, error) {
+	s := &Server{
+		engine:    engine,
+		buffer:    buffer,
+		depthSubs: map[*subscriber]struct{}{},
+		tradeSubs: map[*subscriber]struct{}{},
+		levels:    map[string]*PriceLevel{},
+		sides:     map[string]orderbook.Side{},
+	}
+
+	err := engine.Do(func(ob *orderbook.OrderBook) {
+		ob.OnTrade(s.onTrade)
+		ob.OnOrderEvent(s.onOrderEvent)
+		engine.OnCommand(s.flush)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// SubmitLimitOrder implements OrderBookServer interface
+func (s *Server) SubmitLimitOrder(ctx context.Context, req *SubmitLimitOrderRequest) (*SubmitOrderResponse, error) {
+	side, quantity, price, err := parseOrder(req.Side, req.Quantity, req.Price)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &SubmitOrderResponse{}
+	if cerr := s.engine.Do(func(ob *orderbook.OrderBook) {
+		resp, err = submitResponse(ob.ProcessLimitOrderWithOwner(side, req.OrderId, req.Owner, quantity, price))
+	}); cerr != nil {
+		err = cerr
+	}
+
+	return resp, submitError(resp, err)
+}
+
+// SubmitMarketOrder implements OrderBookServer interface
+func (s *Server) SubmitMarketOrder(ctx context.Context, req *SubmitMarketOrderRequest) (*SubmitOrderResponse, error) {
+	side, quantity, _, err := parseOrder(req.Side, req.Quantity, "")
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &SubmitOrderResponse{}
+	if cerr := s.engine.Do(func(ob *orderbook.OrderBook) {
+		done, partial, processed, left, perr := ob.ProcessMarketOrderWithOwner(side, req.Owner, quantity)
+		resp, err = submitResponse(done, partial, processed, perr)
+		resp.QuantityLeft = left.String()
+	}); cerr != nil {
+		err = cerr
+	}
+
+	return resp, submitError(resp, err)
+}
+
+// CancelOrder implements OrderBookServer interface
+func (s *Server) CancelOrder(ctx context.Context, req *CancelOrderRequest) (*Order, error) {
+	var (
+		o   *orderbook.Order
+		err error
+	)
+	if cerr := s.engine.Do(func(ob *orderbook.OrderBook) {
+		o, err = ob.CancelOrder(req.OrderId)
+	}); cerr != nil {
+		err = cerr
+	}
+
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return orderMessage(o), nil
+}
+
+// AmendOrder implements OrderBookServer interface. The order is replaced by the order
+// with the same ID and owner atomically (see ReplaceOrder), if the new order is
+// rejected the original one stays in the order book
+func (s *Server) AmendOrder(ctx context.Context, req *AmendOrderRequest) (*SubmitOrderResponse, error) {
+	var (
+		resp *SubmitOrderResponse
+		err  error
+	)
+	if cerr := s.engine.Do(func(ob *orderbook.OrderBook) {
+		o := ob.Order(req.OrderId)
+		if o == nil {
+			err = orderbook.ErrOrderNotExists
+			return
+		}
+
+		var quantity, price decimal.Decimal
+		if _, quantity, price, err = parseOrder(sideMessage(o.Side()), req.Quantity, req.Price); err != nil {
+			return
+		}
+
+		resp, err = submitResponse(ob.ReplaceOrder(o.ID(), o.ID(), quantity, price))
+	}); cerr != nil {
+		err = cerr
+	}
+
+	return resp, submitError(resp, err)
+}
+
+// GetOrder implements OrderBookServer interface
+func (s *Server) GetOrder(ctx context.Context, req *GetOrderRequest) (*Order, error) {
+	var o *orderbook.Order
+	if err := s.engine.Do(func(ob *orderbook.OrderBook) {
+		o = ob.Order(req.OrderId)
+	}); err != nil {
+		return nil, statusError(err)
+	}
+
+	if o == nil {
+		return nil, statusError(orderbook.ErrOrderNotExists)
+	}
+	return orderMessage(o), nil
+}
+
+// GetDepth implements OrderBookServer interface
+func (s *Server) GetDepth(ctx context.Context, req *DepthRequest) (*Depth, error) {
+	var asks, bids []*orderbook.PriceLevel
+	if err := s.engine.Do(func(ob *orderbook.OrderBook) {
+		asks, bids = ob.Depth()
+	}); err != nil {
+		return nil, statusError(err)
+	}
+
+	// levels closest to the spread are the last asks and the first bids
+	if limit := int(req.Limit); limit > 0 {
+		if len(asks) > limit {
+			asks = asks[len(asks)-limit:]
+		}
+		if len(bids) > limit {
+			bids = bids[:limit]
+		}
+	}
+
+	return &Depth{Asks: levelMessages(asks), Bids: levelMessages(bids)}, nil
+}
+
+// StreamDepth implements OrderBookServer interface
+func (s *Server) StreamDepth(req *DepthRequest, stream OrderBook_StreamDepthServer) error {
+	sub := s.newSubscriber()
+	if err := s.engine.Do(func(ob *orderbook.OrderBook) {
+		asks, bids := ob.Depth()
+
+		// snapshot is sorted the same way as updates
+		for i, j := 0, len(asks)-1; i < j; i, j = i+1, j-1 {
+			asks[i], asks[j] = asks[j], asks[i]
+		}
+
+		sub.messages <- &DepthUpdate{
+			Seq:      s.depthSeq,
+			Snapshot: true,
+			Asks:     levelMessages(asks),
+			Bids:     levelMessages(bids),
+		}
+		s.depthSubs[sub] = struct{}{}
+	}); err != nil {
+		return statusError(err)
+	}
+	defer s.engine.Do(func(*orderbook.OrderBook) { delete(s.depthSubs, sub) })
+
+	return sub.serve(stream.Context(), func(m interface{}) error {
+		return stream.Send(m.(*DepthUpdate))
+	})
+}
+
+// StreamTrades implements OrderBookServer interface
+func (s *Server) StreamTrades(req *StreamTradesRequest, stream OrderBook_StreamTradesServer) error {
+	sub := s.newSubscriber()
+	if err := s.engine.Do(func(*orderbook.OrderBook) { s.tradeSubs[sub] = struct{}{} }); err != nil {
+		return statusError(err)
+	}
+	defer s.engine.Do(func(*orderbook.OrderBook) { delete(s.tradeSubs, sub) })
+
+	// headers tell the client that trades after this point are streamed
+	if err := stream.SendHeader(metadata.MD{}); err != nil {
+		return err
+	}
+
+	return sub.serve(stream.Context(), func(m interface{}) error {
+		return stream.Send(m.(*Trade))
+	})
+}
+
+func (s *Server) newSubscriber() *subscriber {
+	return &subscriber{
+		messages: make(chan interface{}, s.buffer+1),
+		dropped:  make(chan struct{}),
+	}
+}
+
+func (s *Server) onTrade(t *orderbook.Trade) {
+	s.trades = append(s.trades, t)
+}
+
+func (s *Server) onOrderEvent(ev *orderbook.OrderEvent) {
+	o := ev.Order
+	key := o.Side().String() + o.Price().String()
+	s.levels[key] = &PriceLevel{Price: o.Price().String()}
+	s.sides[key] = o.Side()
+}
+
+// flush publishes changes collected during the command
+func (s *Server) flush(ob *orderbook.OrderBook) {
+	defer func() {
+		s.levels = map[string]*PriceLevel{}
+		s.sides = map[string]orderbook.Side{}
+		s.trades = nil
+	}()
+
+	if len(s.levels) > 0 && len(s.depthSubs) > 0 {
+		s.depthSeq++
+		update := &DepthUpdate{Seq: s.depthSeq}
+
+		for key, level := range s.levels {
+			price, _ := decimal.NewFromString(level.Price)
+			level.Quantity = "0"
+			if q := ob.GetOrderSide(s.sides[key]).Floor(price); q != nil && q.Price().Equal(price) {
+				level.Quantity = q.Volume().String()
+			}
+
+			if s.sides[key] == orderbook.Buy {
+				update.Bids = append(update.Bids, level)
+			} else {
+				update.Asks = append(update.Asks, level)
+			}
+		}
+
+		sortLevels(update.Asks, false)
+		sortLevels(update.Bids, true)
+		s.publish(s.depthSubs, update)
+	}
+
+	for _, t := range s.trades {
+		s.publish(s.tradeSubs, tradeMessage(t))
+	}
+}
+
+// publish queues message to the subscribers, subscribers with full queue are dropped
+func (s *Server) publish(subs map[*subscriber]struct{}, m interface{}) {
+	for sub := range subs {
+		select {
+		case sub.messages <- m:
+		default:
+			close(sub.dropped)
+			delete(subs, sub)
+		}
+	}
+}
+
+// serve sends queued messages until the stream is cancelled or dropped
+func (sub *subscriber) serve(ctx context.Context, send func(interface{}) error) error {
+	for {
+		select {
+		case m := <-sub.messages:
+			if err := send(m); err != nil {
+				return err
+			}
+		case <-sub.dropped:
+			return errSlowConsumer
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// statusError maps order book errors to gRPC status codes
+func statusError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+
+	code := codes.Internal
+	switch {
+	case errors.Is(err, orderbook.ErrOrderNotExists):
+		code = codes.NotFound
+	case errors.Is(err, orderbook.ErrOrderExists):
+		code = codes.AlreadyExists
+	case errors.Is(err, orderbook.ErrInvalidQuantity),
+		errors.Is(err, orderbook.ErrInvalidPrice),
+		errors.Is(err, orderbook.ErrInvalidNotional),
+		errors.Is(err, orderbook.ErrInvalidLot),
+		errors.Is(err, orderbook.ErrInvalidProtection),
+		errors.Is(err, orderbook.ErrInvalidStep),
+		errors.Is(err, orderbook.ErrPriceOutOfBand):
+		code = codes.InvalidArgument
+	case errors.Is(err, orderbook.ErrInsufficientQuantity),
+		errors.Is(err, orderbook.ErrInsufficientFunds),
+		errors.Is(err, orderbook.ErrExposureLimit),
+		errors.Is(err, orderbook.ErrTradingHalted),
+		errors.Is(err, orderbook.ErrAuctionMarketOrder),
+		errors.Is(err, orderbook.ErrPreOpenMarketOrder),
+		errors.Is(err, orderbook.ErrBookClosed),
+		errors.Is(err, orderbook.ErrInvalidPhase):
+		code = codes.FailedPrecondition
+	case errors.Is(err, orderbook.ErrEngineClosed):
+		code = codes.Unavailable
+	}
+
+	return status.Error(code, err.Error())
+}
+
+// parseOrder converts side and decimal strings of the request, empty price is zero
+func parseOrder(side Side, quantity, price string) (orderbook.Side, decimal.Decimal, decimal.Decimal, error) {
+	var s orderbook.Side
+	switch side {
+	case Side_SIDE_BUY:
+		s = orderbook.Buy
+	case Side_SIDE_SELL:
+		s = orderbook.Sell
+	default:
+		return s, decimal.Zero, decimal.Zero, status.Error(codes.InvalidArgument, "orderbook: invalid order side")
+	}
+
+	q, err := decimal.NewFromString(quantity)
+	if err != nil {
+		return s, decimal.Zero, decimal.Zero, statusError(orderbook.ErrInvalidQuantity)
+	}
+
+	p := decimal.Zero
+	if price != "" {
+		if p, err = decimal.NewFromString(price); err != nil {
+			return s, decimal.Zero, decimal.Zero, statusError(orderbook.ErrInvalidPrice)
+		}
+	}
+
+	return s, q, p, nil
+}
+
+func submitResponse(done []*orderbook.Order, partial *orderbook.Order, processed decimal.Decimal, err error) (*SubmitOrderResponse, error) {
+	resp := &SubmitOrderResponse{
+		PartialQuantityProcessed: processed.String(),
+	}
+
+	for _, o := range done {
+		resp.Done = append(resp.Done, orderMessage(o))
+	}
+	if partial != nil {
+		resp.Partial = orderMessage(partial)
+	}
+
+	return resp, err
+}
+
+// submitError converts the error of the order to the status. gRPC drops the
+// response of the failed call, so executions before the error (e.g. circuit
+// breaker halt) are attached to the status as SubmitOrderResponse detail
+func submitError(resp *SubmitOrderResponse, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	st := status.Convert(statusError(err))
+	if resp == nil || (len(resp.Done) == 0 && resp.Partial == nil) {
+		return st.Err()
+	}
+
+	if detailed, derr := st.WithDetails(resp); derr == nil {
+		st = detailed
+	}
+	return st.Err()
+}
+
+func sideMessage(side orderbook.Side) Side {
+	if side == orderbook.Buy {
+		return Side_SIDE_BUY
+	}
+	return Side_SIDE_SELL
+}
+
+func orderMessage(o *orderbook.Order) *Order {
+	return &Order{
+		Id:        o.ID(),
+		Side:      sideMessage(o.Side()),
+		Owner:     o.Owner(),
+		Quantity:  o.Quantity().String(),
+		Price:     o.Price().String(),
+		Timestamp: timestamppb.New(o.Time()),
+	}
+}
+
+func tradeMessage(t *orderbook.Trade) *Trade {
+	return &Trade{
+		Id:           t.ID,
+		Price:        t.Price.String(),
+		Quantity:     t.Quantity.String(),
+		Timestamp:    timestamppb.New(t.Timestamp),
+		TakerSide:    sideMessage(t.TakerSide),
+		TakerOrderId: t.TakerOrderID,
+		TakerOwner:   t.TakerOwner,
+		MakerOrderId: t.MakerOrderID,
+		MakerOwner:   t.MakerOwner,
+		Auction:      t.Auction,
+		MakerFee:     t.MakerFee.String(),
+		TakerFee:     t.TakerFee.String(),
+	}
+}
+
+func levelMessages(levels []*orderbook.PriceLevel) []*PriceLevel {
+	messages := make([]*PriceLevel, 0, len(levels))
+	for _, l := range levels {
+		messages = append(messages, &PriceLevel{Price: l.Price.String(), Quantity: l.Quantity.String()})
+	}
+	return messages
+}
+
+// sortLevels sorts levels by price ascending or descending
+func sortLevels(levels []*PriceLevel, descending bool) {
+	sort.Slice(levels, func(i, j int) bool {
+		a, _ := decimal.NewFromString(levels[i].Price)
+		b, _ := decimal.NewFromString(levels[j].Price)
+		return a.LessThan(b) != descending
+	})
+}
@@ -0,0 +1,212 @@
+package rpc
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"orderbook"
+)
+
+func newTestClient(t *testing.T, buffer int) (OrderBookClient, *Server, func()) {
+	engine := orderbook.NewEngine(orderbook.NewOrderBook())
+	srv, err := NewServer(engine, buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l := bufconn.Listen(1 << 20)
+	g := grpc.NewServer()
+	RegisterOrderBookServer(g, srv)
+	go g.Serve(l)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return l.Dial() }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return NewOrderBookClient(conn), srv, func() {
+		conn.Close()
+		g.Stop()
+		engine.Close()
+	}
+}
+
+func limit(side Side, id, quantity, price string) *SubmitLimitOrderRequest {
+	return &SubmitLimitOrderRequest{Side: side, OrderId: id, Owner: "alice", Quantity: quantity, Price: price}
+}
+
+func TestServerOrders(t *testing.T) {
+	client, _, stop := newTestClient(t, 10)
+	defer stop()
+	ctx := context.Background()
+
+	if _, err := client.SubmitLimitOrder(ctx, limit(Side_SIDE_SELL, "sell-1", "0.000000001", "100.25")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.SubmitLimitOrder(ctx, limit(Side_SIDE_SELL, "sell-2", "2", "101")); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := client.SubmitLimitOrder(ctx, limit(Side_SIDE_SELL, "sell-1", "1", "100"))
+	if status.Code(err) != codes.AlreadyExists {
+		t.Fatal("Invalid duplicate order error", err)
+	}
+
+	_, err = client.SubmitLimitOrder(ctx, limit(Side_SIDE_BUY, "buy", "1", "-1"))
+	if status.Code(err) != codes.InvalidArgument || status.Convert(err).Message() != orderbook.ErrInvalidPrice.Error() {
+		t.Fatal("Invalid price error", err)
+	}
+
+	_, err = client.SubmitLimitOrder(ctx, limit(Side_SIDE_UNSPECIFIED, "buy", "1", "1"))
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatal("Invalid side error", err)
+	}
+
+	o, err := client.GetOrder(ctx, &GetOrderRequest{OrderId: "sell-1"})
+	if err != nil || o.Quantity != "0.000000001" || o.Price != "100.25" || o.Owner != "alice" || o.Side != Side_SIDE_SELL {
+		t.Fatal("Invalid order", o, err)
+	}
+
+	resp, err := client.SubmitMarketOrder(ctx, &SubmitMarketOrderRequest{Side: Side_SIDE_BUY, Quantity: "1.000000001"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Done) != 1 || resp.Done[0].Id != "sell-1" || resp.Partial.Id != "sell-2" ||
+		resp.Partial.Quantity != "1" || resp.PartialQuantityProcessed != "1" || resp.QuantityLeft != "0" {
+		t.Fatal("Invalid market order response", resp)
+	}
+
+	resp, err = client.AmendOrder(ctx, &AmendOrderRequest{OrderId: "sell-2", Quantity: "3", Price: "102"})
+	if err != nil || resp.Partial != nil || len(resp.Done) != 0 {
+		t.Fatal("Invalid amend response", resp, err)
+	}
+
+	o, err = client.GetOrder(ctx, &GetOrderRequest{OrderId: "sell-2"})
+	if err != nil || o.Quantity != "3" || o.Price != "102" || o.Owner != "alice" {
+		t.Fatal("Invalid amended order", o, err)
+	}
+
+	// rejected amendment keeps the original order
+	if _, err := client.AmendOrder(ctx, &AmendOrderRequest{OrderId: "sell-2", Quantity: "0", Price: "102"}); status.Code(err) != codes.InvalidArgument {
+		t.Fatal("Invalid amend error", err)
+	}
+
+	o, err = client.GetOrder(ctx, &GetOrderRequest{OrderId: "sell-2"})
+	if err != nil || o.Quantity != "3" || o.Price != "102" {
+		t.Fatal("Original order is lost by rejected amendment", o, err)
+	}
+
+	depth, err := client.GetDepth(ctx, &DepthRequest{Limit: 1})
+	if err != nil || len(depth.Asks) != 1 || depth.Asks[0].Price != "102" || len(depth.Bids) != 0 {
+		t.Fatal("Invalid depth", depth, err)
+	}
+
+	if o, err := client.CancelOrder(ctx, &CancelOrderRequest{OrderId: "sell-2"}); err != nil || o.Id != "sell-2" {
+		t.Fatal("Invalid cancel", o, err)
+	}
+
+	if _, err := client.CancelOrder(ctx, &CancelOrderRequest{OrderId: "sell-2"}); status.Code(err) != codes.NotFound {
+		t.Fatal("Invalid cancel of unknown order error", err)
+	}
+
+	if _, err := client.AmendOrder(ctx, &AmendOrderRequest{OrderId: "sell-2", Quantity: "1", Price: "1"}); status.Code(err) != codes.NotFound {
+		t.Fatal("Invalid amend of unknown order error", err)
+	}
+}
+
+func TestServerStreams(t *testing.T) {
+	client, _, stop := newTestClient(t, 10)
+	defer stop()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	client.SubmitLimitOrder(ctx, limit(Side_SIDE_SELL, "sell-1", "2", "100"))
+	client.SubmitLimitOrder(ctx, limit(Side_SIDE_SELL, "sell-2", "2", "110"))
+
+	depth, err := client.StreamDepth(ctx, &DepthRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	update, err := depth.Recv()
+	if err != nil || !update.Snapshot || update.Seq != 0 || len(update.Asks) != 2 || update.Asks[0].Price != "100" {
+		t.Fatal("Invalid depth snapshot", update, err)
+	}
+
+	trades, err := client.StreamTrades(ctx, &StreamTradesRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := trades.Header(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := client.SubmitMarketOrder(ctx, &SubmitMarketOrderRequest{Side: Side_SIDE_BUY, Quantity: "3"}); err != nil {
+		t.Fatal(err)
+	}
+
+	update, err = depth.Recv()
+	if err != nil || update.Snapshot || len(update.Asks) != 2 || update.Asks[0].Quantity != "0" || update.Asks[1].Quantity != "1" {
+		t.Fatal("Invalid depth update", update, err)
+	}
+
+	trade, err := trades.Recv()
+	if err != nil || trade.MakerOrderId != "sell-1" || trade.Quantity != "2" || trade.TakerSide != Side_SIDE_BUY {
+		t.Fatal("Invalid trade", trade, err)
+	}
+}
+
+func TestServerSlowConsumer(t *testing.T) {
+	_, srv, stop := newTestClient(t, 0)
+	defer stop()
+
+	sub := srv.newSubscriber()
+	subs := map[*subscriber]struct{}{sub: {}}
+	srv.publish(subs, &Trade{Id: 1})
+	srv.publish(subs, &Trade{Id: 2})
+
+	if len(subs) != 0 {
+		t.Fatal("Slow subscriber is not dropped")
+	}
+
+	err := sub.serve(context.Background(), func(interface{}) error { return nil })
+	if status.Code(err) != codes.ResourceExhausted {
+		t.Fatal("Invalid error of slow subscriber", err)
+	}
+}
+
+func TestServerHalt(t *testing.T) {
+	client, srv, stop := newTestClient(t, 10)
+	defer stop()
+	ctx := context.Background()
+
+	srv.engine.Do(func(ob *orderbook.OrderBook) {
+		ob.SetCircuitBreaker(&orderbook.CircuitBreaker{Percent: decimal.New(15, 0), Window: time.Minute})
+	})
+	client.SubmitLimitOrder(ctx, limit(Side_SIDE_SELL, "sell-100", "1", "100"))
+	client.SubmitLimitOrder(ctx, limit(Side_SIDE_SELL, "sell-120", "1", "120"))
+
+	// the trade at 120 is more than 15% away from 100
+	_, err := client.SubmitMarketOrder(ctx, &SubmitMarketOrderRequest{Side: Side_SIDE_BUY, Quantity: "2"})
+	st := status.Convert(err)
+	if st.Code() != codes.FailedPrecondition || len(st.Details()) != 1 {
+		t.Fatal("Invalid halted market order error", err)
+	}
+
+	resp, ok := st.Details()[0].(*SubmitOrderResponse)
+	if !ok || len(resp.Done) != 1 || resp.Done[0].Id != "sell-100" || resp.QuantityLeft != "1" {
+		t.Fatal("Executions before the halt are lost", st.Details())
+	}
+}
@@ -0,0 +1,276 @@
+package orderbook
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// SessionCancelReason describes why orders of the session were cancelled
+type SessionCancelReason string
+
+// Reasons of the session orders cancellation
+const (
+	SessionTimeout SessionCancelReason = "timeout"
+	SessionLogout  SessionCancelReason = "logout"
+)
+
+// SessionCancelEvent is emitted when resting orders of the session are cancelled
+type SessionCancelEvent struct {
+	SessionID string              `json:"sessionId"`
+	Reason    SessionCancelReason `json:"reason"`
+	Time      time.Time           `json:"time"`
+	Orders    []*Order            `json:"orders"`
+}
+
+// SessionManager implements cancel-on-disconnect on top of the OrderBook.
+// Every order placed through the manager is owned by its session. A session
+// which misses its heartbeat deadline has all of its resting orders cancelled.
+// While the manager is in use the order book should be accessed only through it.
+// The manager lock serializes access to the order book, so handlers of the
+// order book (OnTrade, OnOrderEvent, OnPhaseChange) run while it is held and
+// must not call the manager. Cancel handlers (OnCancel) run after the lock is
+// released and may call it. If the trading phase doesn't allow cancels, the
+// closed session keeps its orders until Expire cancels them in allowed phase
+type SessionManager struct {
+	mu sync.Mutex
+
+	book      *OrderBook
+	clock     Clock
+	timeout   time.Duration
+	deadlines map[string]time.Time           // sessionID -> heartbeat deadline
+	closing   map[string]SessionCancelReason // closed sessions with orders to cancel
+	handlers  []func(*SessionCancelEvent)
+	pending   []*SessionCancelEvent // events to dispatch after unlock
+}
+
+// NewSessionManager creates session manager for the order book
+// Sessions should send heartbeats more often than timeout, time is taken from clock
+func NewSessionManager(ob *OrderBook, timeout time.Duration, clock Clock) *SessionManager {
+	if clock == nil {
+		clock = SystemClock{}
+	}
+
+	return &SessionManager{
+		book:      ob,
+		clock:     clock,
+		timeout:   timeout,
+		deadlines: map[string]time.Time{},
+		closing:   map[string]SessionCancelReason{},
+	}
+}
+
+// OnCancel registers handler called every time orders of a session are cancelled
+func (sm *SessionManager) OnCancel(handler func(*SessionCancelEvent)) {
+	sm.mu.Lock()
+	defer sm.unlock()
+
+	sm.handlers = append(sm.handlers, handler)
+}
+
+// Logon opens new session
+func (sm *SessionManager) Logon(sessionID string) error {
+	sm.mu.Lock()
+	defer sm.unlock()
+
+	if _, ok := sm.closing[sessionID]; ok || sm.alive(sessionID) {
+		return ErrSessionExists
+	}
+
+	sm.deadlines[sessionID] = sm.clock.Now().Add(sm.timeout)
+	return nil
+}
+
+// Logout closes the session and cancels all of its resting orders
+// Return:
+//
+//	error - ErrSessionNotExists if the session is not active or error of the cancel,
+//	        the session is closed then and its orders are cancelled by Expire
+func (sm *SessionManager) Logout(sessionID string) ([]*Order, error) {
+	sm.mu.Lock()
+	defer sm.unlock()
+
+	if !sm.alive(sessionID) {
+		return nil, ErrSessionNotExists
+	}
+
+	return sm.close(sessionID, SessionLogout)
+}
+
+// Heartbeat moves heartbeat deadline of the session
+func (sm *SessionManager) Heartbeat(sessionID string) error {
+	sm.mu.Lock()
+	defer sm.unlock()
+
+	if !sm.alive(sessionID) {
+		return ErrSessionNotExists
+	}
+
+	sm.deadlines[sessionID] = sm.clock.Now().Add(sm.timeout)
+	return nil
+}
+
+// Sessions returns IDs of active sessions
+func (sm *SessionManager) Sessions() (sessions []string) {
+	sm.mu.Lock()
+	defer sm.unlock()
+
+	for sessionID := range sm.deadlines {
+		if sm.alive(sessionID) {
+			sessions = append(sessions, sessionID)
+		}
+	}
+	return
+}
+
+// ProcessLimitOrder places new limit order owned by the session to the order book
+// See OrderBook.ProcessLimitOrder for arguments and return values
+func (sm *SessionManager) ProcessLimitOrder(sessionID string, side Side, orderID string, quantity, price decimal.Decimal) (done []*Order, partial *Order, partialQuantityProcessed decimal.Decimal, err error) {
+	sm.mu.Lock()
+	defer sm.unlock()
+
+	if !sm.alive(sessionID) {
+		return nil, nil, decimal.Zero, ErrSessionNotExists
+	}
+
+	return sm.book.ProcessLimitOrderWithOwner(side, orderID, sessionID, quantity, price)
+}
+
+// ProcessMarketOrder processes market order of the session
+// See OrderBook.ProcessMarketOrder for arguments and return values
+func (sm *SessionManager) ProcessMarketOrder(sessionID string, side Side, quantity decimal.Decimal) (done []*Order, partial *Order, partialQuantityProcessed, quantityLeft decimal.Decimal, err error) {
+	sm.mu.Lock()
+	defer sm.unlock()
+
+	if !sm.alive(sessionID) {
+		return nil, nil, decimal.Zero, decimal.Zero, ErrSessionNotExists
+	}
+
+	return sm.book.ProcessMarketOrderWithOwner(side, sessionID, quantity)
+}
+
+// CancelOrder removes order of the session with given ID from the order book
+func (sm *SessionManager) CancelOrder(sessionID, orderID string) (*Order, error) {
+	sm.mu.Lock()
+	defer sm.unlock()
+
+	if !sm.alive(sessionID) {
+		return nil, ErrSessionNotExists
+	}
+
+	if o := sm.book.Order(orderID); o == nil || o.Owner() != sessionID {
+		return nil, ErrOrderNotExists
+	}
+
+	return sm.book.CancelOrder(orderID)
+}
+
+// Expire cancels orders of all sessions which missed their heartbeat deadline
+// and of closed sessions which orders were not cancelled yet. Sessions are
+// processed in order of their IDs
+func (sm *SessionManager) Expire() (events []*SessionCancelEvent) {
+	sm.mu.Lock()
+	defer sm.unlock()
+
+	now := sm.clock.Now()
+	sessions := make([]string, 0, len(sm.closing))
+	for sessionID := range sm.closing {
+		sessions = append(sessions, sessionID)
+	}
+	for sessionID, deadline := range sm.deadlines {
+		if now.After(deadline) {
+			sessions = append(sessions, sessionID)
+		}
+	}
+	sort.Strings(sessions)
+
+	for _, sessionID := range sessions {
+		reason, ok := sm.closing[sessionID]
+		if !ok {
+			reason = SessionTimeout
+		}
+
+		if event, err := sm.closeEvent(sessionID, reason); err == nil {
+			events = append(events, event)
+		}
+	}
+	return
+}
+
+// Run calls Expire every interval until stop is closed
+func (sm *SessionManager) Run(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			sm.Expire()
+		case <-stop:
+			return
+		}
+	}
+}
+
+// alive checks the session deadline and closes expired session
+func (sm *SessionManager) alive(sessionID string) bool {
+	deadline, ok := sm.deadlines[sessionID]
+	if !ok {
+		return false
+	}
+
+	if sm.clock.Now().After(deadline) {
+		sm.close(sessionID, SessionTimeout)
+		return false
+	}
+
+	return true
+}
+
+// close removes the session and cancels its orders
+func (sm *SessionManager) close(sessionID string, reason SessionCancelReason) ([]*Order, error) {
+	event, err := sm.closeEvent(sessionID, reason)
+	if err != nil {
+		return nil, err
+	}
+	return event.Orders, nil
+}
+
+// closeEvent removes the session and cancels its orders, handlers are
+// notified when the lock is released. If the phase doesn't allow cancels
+// the session is kept closing until Expire cancels its orders
+func (sm *SessionManager) closeEvent(sessionID string, reason SessionCancelReason) (*SessionCancelEvent, error) {
+	delete(sm.deadlines, sessionID)
+
+	orders, err := sm.book.CancelOwnerOrders(sessionID)
+	if err != nil {
+		sm.closing[sessionID] = reason
+		return nil, err
+	}
+	delete(sm.closing, sessionID)
+
+	event := &SessionCancelEvent{
+		SessionID: sessionID,
+		Reason:    reason,
+		Time:      sm.clock.Now(),
+		Orders:    orders,
+	}
+
+	sm.pending = append(sm.pending, event)
+	return event, nil
+}
+
+// unlock releases the lock and notifies handlers about cancels made under it
+func (sm *SessionManager) unlock() {
+	events, handlers := sm.pending, sm.handlers
+	sm.pending = nil
+	sm.mu.Unlock()
+
+	for _, event := range events {
+		for _, handler := range handlers {
+			handler(event)
+		}
+	}
+}
@@ -0,0 +1,211 @@
+package orderbook
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+type manualClock struct {
+	now time.Time
+}
+
+func (c *manualClock) Now() time.Time {
+	return c.now
+}
+
+func (c *manualClock) Add(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+func newManualClock() *manualClock {
+	return &manualClock{now: time.Date(2019, 3, 1, 10, 0, 0, 0, time.UTC)}
+}
+
+func TestSessionHeartbeatTimeout(t *testing.T) {
+	ob := NewOrderBook()
+	clock := newManualClock()
+	sm := NewSessionManager(ob, 5*time.Second, clock)
+
+	var events []*SessionCancelEvent
+	sm.OnCancel(func(e *SessionCancelEvent) {
+		events = append(events, e)
+	})
+
+	if err := sm.Logon("s1"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sm.Logon("s1"); err != ErrSessionExists {
+		t.Fatal("Can logon twice")
+	}
+
+	if err := sm.Logon("s2"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, _, err := sm.ProcessLimitOrder("s1", Buy, "b1", decimal.New(1, 0), decimal.New(90, 0)); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, _, err := sm.ProcessLimitOrder("s2", Sell, "s1", decimal.New(1, 0), decimal.New(100, 0)); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, _, err := sm.ProcessLimitOrder("s3", Sell, "s3", decimal.New(1, 0), decimal.New(100, 0)); err != ErrSessionNotExists {
+		t.Fatal("Can place order without session")
+	}
+
+	clock.Add(4 * time.Second)
+	if err := sm.Heartbeat("s2"); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(sm.Expire()) != 0 {
+		t.Fatal("Session expired before deadline")
+	}
+
+	clock.Add(2 * time.Second)
+	expired := sm.Expire()
+	if len(expired) != 1 || expired[0].SessionID != "s1" || expired[0].Reason != SessionTimeout {
+		t.Fatal("Invalid expired sessions", expired)
+	}
+
+	if len(expired[0].Orders) != 1 || expired[0].Orders[0].ID() != "b1" {
+		t.Fatal("Invalid cancelled orders", expired[0].Orders)
+	}
+
+	if ob.Order("b1") != nil || ob.Order("s1") == nil {
+		t.Fatal("Invalid order book state")
+	}
+
+	if len(events) != 1 || events[0] != expired[0] {
+		t.Fatal("Cancel event is not emitted")
+	}
+
+	if err := sm.Heartbeat("s1"); err != ErrSessionNotExists {
+		t.Fatal("Expired session accepts heartbeat")
+	}
+
+	// deadline is checked on access even without Expire call
+	clock.Add(10 * time.Second)
+	if _, err := sm.CancelOrder("s2", "s1"); err != ErrSessionNotExists {
+		t.Fatal("Expired session can cancel orders")
+	}
+
+	if ob.Order("s1") != nil || len(events) != 2 {
+		t.Fatal("Orders of expired session are not cancelled")
+	}
+}
+
+func TestSessionLogout(t *testing.T) {
+	ob := NewOrderBook()
+	sm := NewSessionManager(ob, time.Minute, nil)
+
+	sm.Logon("s1")
+	sm.Logon("s2")
+	sm.ProcessLimitOrder("s1", Buy, "b1", decimal.New(1, 0), decimal.New(90, 0))
+	sm.ProcessLimitOrder("s1", Buy, "b2", decimal.New(1, 0), decimal.New(80, 0))
+
+	if _, err := sm.CancelOrder("s2", "b1"); err != ErrOrderNotExists {
+		t.Fatal("Can cancel order of another session")
+	}
+
+	if o, err := sm.CancelOrder("s1", "b1"); err != nil || o.ID() != "b1" {
+		t.Fatal("Can't cancel own order", err)
+	}
+
+	var trades []*Trade
+	ob.OnTrade(func(trade *Trade) {
+		trades = append(trades, trade)
+	})
+	if _, _, _, _, err := sm.ProcessMarketOrder("s2", Sell, decimal.New(1, 0)); err != nil || len(trades) != 1 || trades[0].TakerOwner != "s2" {
+		t.Fatal("Market order of the session has no owner", trades, err)
+	}
+	sm.ProcessLimitOrder("s1", Buy, "b3", decimal.New(1, 0), decimal.New(70, 0))
+
+	if len(sm.Sessions()) != 2 {
+		t.Fatal("Invalid sessions")
+	}
+
+	cancelled, err := sm.Logout("s1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(cancelled) != 1 || cancelled[0].ID() != "b3" {
+		t.Fatal("Invalid cancelled orders", cancelled)
+	}
+
+	if _, err := sm.Logout("s1"); err != ErrSessionNotExists {
+		t.Fatal("Can logout twice")
+	}
+
+	if _, _, _, _, err := sm.ProcessMarketOrder("s1", Sell, decimal.New(1, 0)); err != ErrSessionNotExists {
+		t.Fatal("Closed session can process orders")
+	}
+}
+
+func TestSessionCancelHandlerReentry(t *testing.T) {
+	ob := NewOrderBook()
+	clock := newManualClock()
+	sm := NewSessionManager(ob, 5*time.Second, clock)
+
+	var sessions []string
+	sm.OnCancel(func(e *SessionCancelEvent) {
+		// the handler runs without the manager lock
+		sessions = sm.Sessions()
+		sm.Logon(e.SessionID)
+	})
+
+	sm.Logon("alice")
+	sm.Logon("bob")
+	sm.ProcessLimitOrder("alice", Buy, "alice-1", decimal.New(1, 0), decimal.New(100, 0))
+
+	if _, err := sm.Logout("alice"); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(sessions) != 1 || sessions[0] != "bob" {
+		t.Fatal("Invalid sessions in the cancel handler", sessions)
+	}
+	if err := sm.Heartbeat("alice"); err != nil {
+		t.Fatal("Session is not opened by the cancel handler", err)
+	}
+}
+
+func TestSessionClosedBook(t *testing.T) {
+	ob := NewOrderBook()
+	clock := newManualClock()
+	sm := NewSessionManager(ob, time.Minute, clock)
+
+	for _, sessionID := range []string{"s3", "s1", "s2"} {
+		sm.Logon(sessionID)
+		sm.ProcessLimitOrder(sessionID, Buy, "b-"+sessionID, decimal.New(1, 0), decimal.New(90, 0))
+	}
+	sm.Heartbeat("s2")
+	ob.SetPhase(Closed, "close")
+
+	if _, err := sm.Logout("s2"); err != ErrBookClosed {
+		t.Fatal("Logout cancels orders in the closed book", err)
+	}
+	if err := sm.Logon("s2"); err != ErrSessionExists || len(sm.Sessions()) != 2 {
+		t.Fatal("Session with orders to cancel is reused", err, sm.Sessions())
+	}
+
+	clock.Add(2 * time.Minute)
+	if events := sm.Expire(); len(events) != 0 || ob.Order("b-s1") == nil {
+		t.Fatal("Orders are cancelled in the closed book", events)
+	}
+
+	ob.SetPhase(PreOpen, "open")
+	events := sm.Expire()
+	if len(events) != 3 || events[0].SessionID != "s1" || events[1].SessionID != "s2" || events[2].SessionID != "s3" ||
+		events[1].Reason != SessionLogout || events[0].Reason != SessionTimeout || len(events[2].Orders) != 1 {
+		t.Fatal("Orders of closed sessions are not cancelled in order", events)
+	}
+	if len(ob.orders) != 0 || len(sm.Sessions()) != 0 || sm.Logon("s2") != nil {
+		t.Fatal("Sessions are not closed", sm.Sessions())
+	}
+}
@@ -0,0 +1,797 @@
+package orderbook
+
+import (
+	"bufio"
+	"bytes"
+	"container/list"
+	"encoding/binary"
+	"hash"
+	"hash/crc32"
+	"io"
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// SnapshotVersion is version of the binary snapshot written by WriteSnapshot.
+// Version 2 adds the last trade, state of the fee schedule and the ledger and
+// the trading schedule
+const SnapshotVersion = 2
+
+// snapshotMagic starts every binary snapshot
+var snapshotMagic = [4]byte{'O', 'B', 'S', 'N'}
+
+// maxSnapshotString limits length of strings read from snapshots
+const maxSnapshotString = 1 << 20
+
+// Binary snapshot layout, integers are varints:
+//
+//	magic "OBSN", version
+//	phase
+//	last trade flag, then the last trade: id, price, quantity, timestamp, taker side,
+//	    taker order id and owner, maker order id and owner, auction flag, maker and taker fees
+//	asks and bids from the best price: number of levels, then every level as
+//	    price, number of orders, then every order in time priority as
+//	    id, owner, timestamp delta, quantity
+//	positions flag, then mode, positions and limits sorted by account
+//	fees flag, then fee totals and daily volumes sorted by account and day
+//	ledger flag, then balances sorted by account as base total and reserved, quote
+//	    total and reserved, then reservations sorted by owner and order id as
+//	    owner, order id, side, price, amount
+//	number of scheduled phases, then every phase as offset and phase, then the
+//	    time of the last schedule check if there are scheduled phases
+//	CRC-32 (IEEE) of all previous bytes, 4 bytes big-endian
+//
+// Strings are length and bytes. Owners are numbered in order of appearance starting
+// from 1 for the empty one, zero is followed by the next new owner. Timestamps are
+// nanoseconds since the timestamp of the previous order (Unix epoch for the first one).
+// Decimals are the coefficient header and the exponent: even header is the zigzag
+// coefficient shifted left by one, odd header is the magnitude length shifted left
+// by two with the sign in the second bit followed by big-endian magnitude bytes
+
+// WriteSnapshot writes compact binary snapshot of the order book (see ReadSnapshot).
+// Levels and orders are streamed in price-time order without building the whole
+// snapshot in memory, it keeps the same state as MarshalJSON and the last trade
+func (ob *OrderBook) WriteSnapshot(w io.Writer) error {
+	sw := &snapshotWriter{crc: crc32.NewIEEE(), owners: map[string]uint64{"": 1}}
+	sw.w = bufio.NewWriter(io.MultiWriter(w, sw.crc))
+
+	sw.write(snapshotMagic[:])
+	sw.uvarint(SnapshotVersion)
+	sw.uvarint(uint64(ob.phase))
+
+	if ob.lastTrade == nil {
+		sw.uvarint(0)
+	} else {
+		sw.uvarint(1)
+		sw.trade(ob.lastTrade)
+	}
+
+	sw.side(ob.asks, ob.asks.MinPriceQueue, ob.asks.GreaterThan)
+	sw.side(ob.bids, ob.bids.MaxPriceQueue, ob.bids.LessThan)
+
+	if ob.positions == nil {
+		sw.uvarint(0)
+	} else {
+		sw.uvarint(1)
+		sw.positions(ob.positions)
+	}
+
+	if fs, ok := ob.feeModel.(*FeeSchedule); ok {
+		sw.uvarint(1)
+		sw.fees(fs)
+	} else {
+		sw.uvarint(0)
+	}
+
+	if l, ok := ob.risk.(*Ledger); ok {
+		sw.uvarint(1)
+		sw.ledger(l)
+	} else {
+		sw.uvarint(0)
+	}
+
+	sw.uvarint(uint64(len(ob.schedule)))
+	for _, scheduled := range ob.schedule {
+		sw.varint(int64(scheduled.Offset))
+		sw.uvarint(uint64(scheduled.Phase))
+	}
+	if len(ob.schedule) > 0 {
+		sw.varint(ob.scheduleChecked.UnixNano())
+	}
+
+	if sw.err == nil {
+		sw.err = sw.w.Flush()
+	}
+	if sw.err != nil {
+		return sw.err
+	}
+
+	var sum [4]byte
+	binary.BigEndian.PutUint32(sum[:], sw.crc.Sum32())
+	_, err := w.Write(sum[:])
+	return err
+}
+
+// ReadSnapshot replaces content of the order book by the binary snapshot written
+// by WriteSnapshot. Positions are read into the attached position keeper if any.
+// Fee totals and volumes are read into the attached FeeSchedule if any, its tiers
+// are configuration and are kept. Balances and reservations are read into the
+// attached Ledger if any. Reservations of another attached risk check are moved
+// from resting orders of the order book to the restored ones. The trading schedule
+// is read if the snapshot has one. The order book is not changed if the snapshot
+// is invalid or the restored orders can not be reserved
+// Return:
+//
+//	error - ErrSnapshotVersion if the version is not supported,
+//	        ErrInvalidSnapshot if the snapshot is broken, error of the risk check
+//	        if the restored order is rejected or not nil if reading fails
+func (ob *OrderBook) ReadSnapshot(r io.Reader) error {
+	sr := &snapshotReader{r: r, buf: make([]byte, 0, 32*1024), owners: []string{""}}
+
+	var magic [4]byte
+	if sr.read(magic[:]); sr.err == nil && magic != snapshotMagic {
+		return ErrInvalidSnapshot
+	}
+
+	if version := sr.uvarint(); sr.err == nil && version != SnapshotVersion {
+		return ErrSnapshotVersion
+	}
+
+	phase := Phase(sr.uvarint())
+	if sr.err == nil && (phase < Continuous || phase > Closed) {
+		return ErrInvalidSnapshot
+	}
+
+	var lastTrade *Trade
+	switch sr.uvarint() {
+	case 0:
+	case 1:
+		lastTrade = sr.trade()
+	default:
+		sr.fail()
+	}
+
+	orders := map[string]*list.Element{}
+	asks := sr.side(Sell, orders)
+	bids := sr.side(Buy, orders)
+
+	var positions *PositionKeeper
+	switch sr.uvarint() {
+	case 0:
+	case 1:
+		positions = NewPositionKeeper(MarkMid)
+		sr.positions(positions)
+	default:
+		sr.fail()
+	}
+
+	var fees *FeeSchedule
+	switch sr.uvarint() {
+	case 0:
+	case 1:
+		fees = NewFeeSchedule(nil, decimal.Zero)
+		sr.fees(fees)
+	default:
+		sr.fail()
+	}
+
+	var ledger *Ledger
+	switch sr.uvarint() {
+	case 0:
+	case 1:
+		ledger = NewLedger()
+		sr.ledger(ledger)
+	default:
+		sr.fail()
+	}
+
+	var (
+		schedule        []ScheduledPhase
+		scheduleChecked time.Time
+	)
+	count := sr.uvarint()
+	for i := uint64(0); i < count && sr.err == nil; i++ {
+		scheduled := ScheduledPhase{Offset: time.Duration(sr.varint()), Phase: Phase(sr.uvarint())}
+		if scheduled.Phase < Continuous || scheduled.Phase > Closed {
+			sr.fail()
+		}
+		schedule = append(schedule, scheduled)
+	}
+	if count > 0 {
+		scheduleChecked = time.Unix(0, sr.varint()).UTC()
+	}
+
+	sum := sr.sum()
+	var expected [4]byte
+	sr.read(expected[:])
+	if sr.err != nil {
+		return sr.err
+	}
+	if binary.BigEndian.Uint32(expected[:]) != sum {
+		return ErrInvalidSnapshot
+	}
+
+	l, ok := ob.risk.(*Ledger)
+	if ledger == nil || !ok {
+		if err := ob.reserveSnapshot(orders); err != nil {
+			return err
+		}
+	} else {
+		l.mu.Lock()
+		l.accounts = ledger.accounts
+		l.reservations = ledger.reservations
+		l.mu.Unlock()
+	}
+
+	ob.asks = asks
+	ob.bids = bids
+	ob.phase = phase
+	ob.lastTrade = lastTrade
+	ob.tradeSeq = 0
+	if lastTrade != nil {
+		ob.tradeSeq = lastTrade.ID
+	}
+	switch {
+	case positions == nil:
+	case ob.positions == nil:
+		ob.positions = positions
+	default:
+		*ob.positions = *positions
+	}
+	if fs, ok := ob.feeModel.(*FeeSchedule); ok && fees != nil {
+		fs.mu.Lock()
+		fs.volumes = fees.volumes
+		fs.totals = fees.totals
+		fs.mu.Unlock()
+	}
+	if len(schedule) > 0 {
+		ob.schedule = schedule
+		ob.scheduleChecked = scheduleChecked
+	}
+	ob.orders = map[string]*list.Element{}
+	ob.owners = map[string]map[string]*list.Element{}
+
+	for _, e := range orders {
+		ob.addOrder(e.Value.(*Order), e)
+	}
+
+	return nil
+}
+
+// reserveSnapshot moves reservations of the risk check from resting orders of the
+// order book to the restored orders. Reservations of the order book are restored
+// if any of the restored orders is rejected
+func (ob *OrderBook) reserveSnapshot(orders map[string]*list.Element) error {
+	if ob.risk == nil {
+		return nil
+	}
+
+	for _, e := range ob.orders {
+		o := e.Value.(*Order)
+		ob.release(o.Owner(), o.ID())
+	}
+
+	reserved := make([]*Order, 0, len(orders))
+	for _, e := range orders {
+		o := e.Value.(*Order)
+		if err := ob.reserveLimit(o.Side(), o.ID(), o.Owner(), o.Quantity(), o.Price()); err != nil {
+			for _, o := range reserved {
+				ob.release(o.Owner(), o.ID())
+			}
+			for _, e := range ob.orders {
+				o := e.Value.(*Order)
+				ob.reserveLimit(o.Side(), o.ID(), o.Owner(), o.Quantity(), o.Price())
+			}
+			return err
+		}
+		reserved = append(reserved, o)
+	}
+
+	return nil
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler interface
+func (ob *OrderBook) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := ob.WriteSnapshot(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler interface
+func (ob *OrderBook) UnmarshalBinary(data []byte) error {
+	return ob.ReadSnapshot(bytes.NewReader(data))
+}
+
+// snapshotWriter encodes snapshot values and keeps the first error
+type snapshotWriter struct {
+	w      *bufio.Writer
+	crc    hash.Hash32
+	buf    [binary.MaxVarintLen64]byte
+	owners map[string]uint64
+	last   int64
+	err    error
+}
+
+func (sw *snapshotWriter) write(b []byte) {
+	if sw.err == nil {
+		_, sw.err = sw.w.Write(b)
+	}
+}
+
+func (sw *snapshotWriter) uvarint(v uint64) {
+	sw.write(sw.buf[:binary.PutUvarint(sw.buf[:], v)])
+}
+
+func (sw *snapshotWriter) varint(v int64) {
+	sw.write(sw.buf[:binary.PutVarint(sw.buf[:], v)])
+}
+
+func (sw *snapshotWriter) string(s string) {
+	sw.uvarint(uint64(len(s)))
+	if sw.err == nil {
+		_, sw.err = sw.w.WriteString(s)
+	}
+}
+
+func (sw *snapshotWriter) decimal(d decimal.Decimal) {
+	if d.NumDigits() <= 18 {
+		c := d.CoefficientInt64()
+		sw.uvarint((uint64(c<<1) ^ uint64(c>>63)) << 1)
+	} else {
+		c := d.Coefficient()
+		magnitude := c.Bytes()
+		header := uint64(len(magnitude))<<2 | 1
+		if c.Sign() < 0 {
+			header |= 2
+		}
+		sw.uvarint(header)
+		sw.write(magnitude)
+	}
+	sw.varint(int64(d.Exponent()))
+}
+
+func (sw *snapshotWriter) side(os *OrderSide, best func() *OrderQueue, next func(decimal.Decimal) *OrderQueue) {
+	sw.uvarint(uint64(os.Depth()))
+
+	for level := best(); level != nil && sw.err == nil; level = next(level.Price()) {
+		sw.decimal(level.Price())
+		sw.uvarint(uint64(level.Len()))
+
+		for e := level.Head(); e != nil; e = e.Next() {
+			o := e.Value.(*Order)
+			sw.string(o.ID())
+
+			if n, ok := sw.owners[o.Owner()]; ok {
+				sw.uvarint(n)
+			} else {
+				sw.uvarint(0)
+				sw.string(o.Owner())
+				sw.owners[o.Owner()] = uint64(len(sw.owners) + 1)
+			}
+
+			timestamp := o.Time().UnixNano()
+			sw.varint(timestamp - sw.last)
+			sw.last = timestamp
+
+			sw.decimal(o.Quantity())
+		}
+	}
+}
+
+func (sw *snapshotWriter) trade(t *Trade) {
+	sw.uvarint(t.ID)
+	sw.decimal(t.Price)
+	sw.decimal(t.Quantity)
+	sw.varint(t.Timestamp.UnixNano())
+	sw.uvarint(uint64(t.TakerSide))
+	sw.string(t.TakerOrderID)
+	sw.string(t.TakerOwner)
+	sw.string(t.MakerOrderID)
+	sw.string(t.MakerOwner)
+	if t.Auction {
+		sw.uvarint(1)
+	} else {
+		sw.uvarint(0)
+	}
+	sw.decimal(t.MakerFee)
+	sw.decimal(t.TakerFee)
+}
+
+func (sw *snapshotWriter) positions(pk *PositionKeeper) {
+	sw.uvarint(uint64(pk.mode))
+
+	accounts := make([]string, 0, len(pk.positions))
+	for account := range pk.positions {
+		accounts = append(accounts, account)
+	}
+	sort.Strings(accounts)
+
+	sw.uvarint(uint64(len(accounts)))
+	for _, account := range accounts {
+		p := pk.positions[account]
+		sw.string(account)
+		sw.decimal(p.Quantity)
+		sw.decimal(p.EntryPrice)
+		sw.decimal(p.Realized)
+		sw.decimal(p.Unrealized)
+		sw.decimal(p.Fees)
+		sw.decimal(p.Volume)
+	}
+
+	accounts = accounts[:0]
+	for account := range pk.limits {
+		accounts = append(accounts, account)
+	}
+	sort.Strings(accounts)
+
+	sw.uvarint(uint64(len(accounts)))
+	for _, account := range accounts {
+		sw.string(account)
+		sw.decimal(pk.limits[account])
+	}
+}
+
+// fees writes fee totals and daily volumes of the fee schedule
+func (sw *snapshotWriter) fees(fs *FeeSchedule) {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+
+	accounts := make([]string, 0, len(fs.totals))
+	for account := range fs.totals {
+		accounts = append(accounts, account)
+	}
+	for account := range fs.volumes {
+		if _, ok := fs.totals[account]; !ok {
+			accounts = append(accounts, account)
+		}
+	}
+	sort.Strings(accounts)
+
+	sw.uvarint(uint64(len(accounts)))
+	for _, account := range accounts {
+		totals := FeeTotals{}
+		if t, ok := fs.totals[account]; ok {
+			totals = *t
+		}
+		sw.string(account)
+		sw.decimal(totals.Maker)
+		sw.decimal(totals.Taker)
+		sw.decimal(totals.Volume)
+
+		days := make([]int64, 0, len(fs.volumes[account]))
+		for day := range fs.volumes[account] {
+			days = append(days, day)
+		}
+		sort.Slice(days, func(i, j int) bool { return days[i] < days[j] })
+
+		sw.uvarint(uint64(len(days)))
+		for _, day := range days {
+			sw.varint(day)
+			sw.decimal(fs.volumes[account][day])
+		}
+	}
+}
+
+// ledger writes balances and reservations of the ledger
+func (sw *snapshotWriter) ledger(l *Ledger) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	accounts := make([]string, 0, len(l.accounts))
+	for account := range l.accounts {
+		accounts = append(accounts, account)
+	}
+	sort.Strings(accounts)
+
+	sw.uvarint(uint64(len(accounts)))
+	for _, account := range accounts {
+		a := l.accounts[account]
+		sw.string(account)
+		sw.decimal(a.base.Total)
+		sw.decimal(a.base.Reserved)
+		sw.decimal(a.quote.Total)
+		sw.decimal(a.quote.Reserved)
+	}
+
+	keys := make([]reservationKey, 0, len(l.reservations))
+	for key := range l.reservations {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].owner != keys[j].owner {
+			return keys[i].owner < keys[j].owner
+		}
+		return keys[i].orderID < keys[j].orderID
+	})
+
+	sw.uvarint(uint64(len(keys)))
+	for _, key := range keys {
+		res := l.reservations[key]
+		sw.string(key.owner)
+		sw.string(key.orderID)
+		sw.uvarint(uint64(res.side))
+		sw.decimal(res.price)
+		sw.decimal(res.amount)
+	}
+}
+
+// snapshotReader decodes snapshot values, keeps the first error and
+// calculates checksum of the consumed bytes
+type snapshotReader struct {
+	r      io.Reader
+	buf    []byte
+	pos    int // next unread byte of buf
+	hashed int // bytes of buf before hashed are added to crc
+	crc    uint32
+	owners []string
+	last   int64
+	err    error
+}
+
+// fail marks the snapshot as invalid
+func (sr *snapshotReader) fail() {
+	if sr.err == nil {
+		sr.err = ErrInvalidSnapshot
+	}
+}
+
+// fill reads more bytes keeping unread ones
+// Return:
+//
+//	bool - false if there are no more bytes
+func (sr *snapshotReader) fill() bool {
+	if sr.err != nil {
+		return false
+	}
+
+	sr.crc = crc32.Update(sr.crc, crc32.IEEETable, sr.buf[sr.hashed:sr.pos])
+	n := copy(sr.buf[:cap(sr.buf)], sr.buf[sr.pos:])
+	sr.buf = sr.buf[:n]
+	sr.pos, sr.hashed = 0, 0
+
+	for len(sr.buf) < cap(sr.buf) {
+		m, err := sr.r.Read(sr.buf[len(sr.buf):cap(sr.buf)])
+		sr.buf = sr.buf[:len(sr.buf)+m]
+		if m > 0 {
+			return true
+		}
+		if err == io.EOF {
+			sr.err = io.ErrUnexpectedEOF
+			return false
+		}
+		if err != nil {
+			sr.err = err
+			return false
+		}
+	}
+	return true
+}
+
+// sum returns checksum of the consumed bytes
+func (sr *snapshotReader) sum() uint32 {
+	sr.crc = crc32.Update(sr.crc, crc32.IEEETable, sr.buf[sr.hashed:sr.pos])
+	sr.hashed = sr.pos
+	return sr.crc
+}
+
+// ReadByte implements io.ByteReader interface for varints
+func (sr *snapshotReader) ReadByte() (byte, error) {
+	if sr.pos == len(sr.buf) && !sr.fill() {
+		return 0, sr.err
+	}
+	b := sr.buf[sr.pos]
+	sr.pos++
+	return b, nil
+}
+
+func (sr *snapshotReader) read(b []byte) {
+	for len(b) > 0 {
+		if sr.pos == len(sr.buf) && !sr.fill() {
+			return
+		}
+		n := copy(b, sr.buf[sr.pos:])
+		sr.pos += n
+		b = b[n:]
+	}
+}
+
+func (sr *snapshotReader) uvarint() uint64 {
+	if sr.err != nil {
+		return 0
+	}
+
+	v, err := binary.ReadUvarint(sr)
+	if err != nil {
+		sr.fail()
+	}
+	return v
+}
+
+func (sr *snapshotReader) varint() int64 {
+	if sr.err != nil {
+		return 0
+	}
+
+	v, err := binary.ReadVarint(sr)
+	if err != nil {
+		sr.fail()
+	}
+	return v
+}
+
+func (sr *snapshotReader) string() string {
+	n := sr.uvarint()
+	if n > maxSnapshotString {
+		sr.fail()
+	}
+	if sr.err != nil {
+		return ""
+	}
+
+	if int(n) <= len(sr.buf)-sr.pos {
+		s := string(sr.buf[sr.pos : sr.pos+int(n)])
+		sr.pos += int(n)
+		return s
+	}
+
+	b := make([]byte, n)
+	sr.read(b)
+	return string(b)
+}
+
+func (sr *snapshotReader) decimal() decimal.Decimal {
+	header := sr.uvarint()
+
+	var d decimal.Decimal
+	if header&1 == 0 {
+		zz := header >> 1
+		c := int64(zz>>1) ^ -int64(zz&1)
+		d = decimal.New(c, int32(sr.varint()))
+	} else {
+		n := header >> 2
+		if n > maxSnapshotString {
+			sr.fail()
+			return decimal.Zero
+		}
+
+		magnitude := make([]byte, n)
+		sr.read(magnitude)
+		c := new(big.Int).SetBytes(magnitude)
+		if header&2 != 0 {
+			c.Neg(c)
+		}
+		d = decimal.NewFromBigInt(c, int32(sr.varint()))
+	}
+
+	return d
+}
+
+func (sr *snapshotReader) side(side Side, orders map[string]*list.Element) *OrderSide {
+	os := NewOrderSide()
+
+	levels := sr.uvarint()
+	for i := uint64(0); i < levels && sr.err == nil; i++ {
+		price := sr.decimal()
+		count := sr.uvarint()
+		if sr.err == nil && (count == 0 || price.Sign() <= 0 || os.prices[price.String()] != nil) {
+			sr.fail()
+		}
+
+		for j := uint64(0); j < count && sr.err == nil; j++ {
+			id := sr.string()
+
+			var owner string
+			switch n := sr.uvarint(); {
+			case n == 0:
+				owner = sr.string()
+				sr.owners = append(sr.owners, owner)
+			case n <= uint64(len(sr.owners)):
+				owner = sr.owners[n-1]
+			default:
+				sr.fail()
+			}
+
+			sr.last += sr.varint()
+			quantity := sr.decimal()
+
+			if _, ok := orders[id]; sr.err == nil && (ok || quantity.Sign() <= 0) {
+				sr.fail()
+			}
+			if sr.err != nil {
+				break
+			}
+
+			o := NewOrder(id, side, quantity, price, time.Unix(0, sr.last).UTC())
+			o.owner = owner
+			orders[id] = os.Append(o)
+		}
+	}
+
+	return os
+}
+
+func (sr *snapshotReader) trade() *Trade {
+	t := &Trade{ID: sr.uvarint()}
+	t.Price = sr.decimal()
+	t.Quantity = sr.decimal()
+	t.Timestamp = time.Unix(0, sr.varint()).UTC()
+	t.TakerSide = Side(sr.uvarint())
+	t.TakerOrderID = sr.string()
+	t.TakerOwner = sr.string()
+	t.MakerOrderID = sr.string()
+	t.MakerOwner = sr.string()
+	t.Auction = sr.uvarint() == 1
+	t.MakerFee = sr.decimal()
+	t.TakerFee = sr.decimal()
+	return t
+}
+
+func (sr *snapshotReader) positions(pk *PositionKeeper) {
+	mode := MarkMode(sr.uvarint())
+	positions := map[string]*Position{}
+	limits := map[string]decimal.Decimal{}
+
+	count := sr.uvarint()
+	for i := uint64(0); i < count && sr.err == nil; i++ {
+		p := &Position{Account: sr.string()}
+		p.Quantity = sr.decimal()
+		p.EntryPrice = sr.decimal()
+		p.Realized = sr.decimal()
+		p.Unrealized = sr.decimal()
+		p.Fees = sr.decimal()
+		p.Volume = sr.decimal()
+		positions[p.Account] = p
+	}
+
+	count = sr.uvarint()
+	for i := uint64(0); i < count && sr.err == nil; i++ {
+		account := sr.string()
+		limits[account] = sr.decimal()
+	}
+
+	pk.mode = mode
+	pk.positions = positions
+	pk.limits = limits
+}
+
+// fees reads fee totals and daily volumes into the fee schedule
+func (sr *snapshotReader) fees(fs *FeeSchedule) {
+	count := sr.uvarint()
+	for i := uint64(0); i < count && sr.err == nil; i++ {
+		account := sr.string()
+		fs.totals[account] = &FeeTotals{Maker: sr.decimal(), Taker: sr.decimal(), Volume: sr.decimal()}
+
+		days := map[int64]decimal.Decimal{}
+		n := sr.uvarint()
+		for j := uint64(0); j < n && sr.err == nil; j++ {
+			day := sr.varint()
+			days[day] = sr.decimal()
+		}
+		fs.volumes[account] = days
+	}
+}
+
+// ledger reads balances and reservations into the ledger
+func (sr *snapshotReader) ledger(l *Ledger) {
+	count := sr.uvarint()
+	for i := uint64(0); i < count && sr.err == nil; i++ {
+		a := l.account(sr.string())
+		a.base = Balance{Total: sr.decimal(), Reserved: sr.decimal()}
+		a.quote = Balance{Total: sr.decimal(), Reserved: sr.decimal()}
+	}
+
+	count = sr.uvarint()
+	for i := uint64(0); i < count && sr.err == nil; i++ {
+		key := reservationKey{owner: sr.string(), orderID: sr.string()}
+		res := &reservation{side: Side(sr.uvarint()), price: sr.decimal(), amount: sr.decimal()}
+		if res.side != Buy && res.side != Sell {
+			sr.fail()
+		}
+		l.reservations[key] = res
+	}
+}
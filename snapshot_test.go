@@ -0,0 +1,215 @@
+package orderbook
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+func newSnapshotBook() *OrderBook {
+	ob := NewOrderBook()
+	pk := NewPositionKeeper(MarkLastTrade)
+	pk.SetLimit("alice", decimal.New(1000, 0))
+	ob.SetPositionKeeper(pk)
+
+	addOwnedDepth(ob, "alice", decimal.New(2, 0))
+	addOwnedDepth(ob, "bob", decimal.RequireFromString("1.25"))
+	addDepth(ob, "anonymous-", decimal.New(3, 0))
+
+	ob.ProcessMarketOrderWithOwner(Buy, "bob", decimal.New(3, 0))
+	ob.ProcessLimitOrderWithOwner(Sell, "huge", "carol", decimal.RequireFromString("123456789012345678901234.5"), decimal.New(1000, 0))
+	ob.SetPhase(Auction, "test")
+	return ob
+}
+
+func TestSnapshot(t *testing.T) {
+	ob := newSnapshotBook()
+
+	var buf bytes.Buffer
+	if err := ob.WriteSnapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	restored := NewOrderBook()
+	if err := restored.ReadSnapshot(bytes.NewReader(buf.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+
+	if restored.Phase() != Auction || len(restored.orders) != len(ob.orders) || len(restored.OwnerOrders("alice")) != len(ob.OwnerOrders("alice")) {
+		t.Fatal("Invalid restored book", restored.Phase(), len(restored.orders))
+	}
+
+	for id, e := range ob.orders {
+		o, r := e.Value.(*Order), restored.Order(id)
+		if r == nil || r.Side() != o.Side() || r.Owner() != o.Owner() || !r.Quantity().Equal(o.Quantity()) ||
+			!r.Price().Equal(o.Price()) || !r.Time().Equal(o.Time()) || r.Time().Location() != time.UTC {
+			t.Fatal("Invalid restored order", o, r)
+		}
+	}
+
+	if lt := restored.LastTrade(); lt == nil || lt.ID != ob.LastTrade().ID || !lt.Price.Equal(ob.LastTrade().Price) || lt.MakerOrderID != ob.LastTrade().MakerOrderID ||
+		lt.Timestamp.Location() != time.UTC {
+		t.Fatal("Invalid restored last trade", lt)
+	}
+
+	if !restored.asks.Volume().Equal(ob.asks.Volume()) || restored.bids.Depth() != ob.bids.Depth() {
+		t.Fatal("Invalid restored sides", restored.asks.Volume())
+	}
+
+	if p := restored.Position("bob"); p.Quantity.Sign() <= 0 || !p.Quantity.Equal(ob.Position("bob").Quantity) {
+		t.Fatal("Invalid restored position", p)
+	}
+
+	if restored.checkExposure("alice", Buy, decimal.New(2000, 0)) != ErrExposureLimit {
+		t.Fatal("Limit is not restored")
+	}
+
+	// time priority is kept
+	restored.SetPhase(Continuous, "test")
+	done, _, _, _, _ := restored.ProcessMarketOrder(Buy, decimal.RequireFromString("3.25"))
+	if len(done) != 2 || done[0].ID() != "bob-sell-100" || done[1].ID() != "anonymous-sell-100" {
+		t.Fatal("Invalid priority", done)
+	}
+}
+
+func TestSnapshotJSONEquivalence(t *testing.T) {
+	ob := newSnapshotBook()
+
+	data, err := ob.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fromBinary := NewOrderBook()
+	if err := fromBinary.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+
+	expected, _ := json.Marshal(ob)
+	fromJSON := NewOrderBook()
+	if err := json.Unmarshal(expected, fromJSON); err != nil {
+		t.Fatal(err)
+	}
+
+	if actual, _ := json.Marshal(fromBinary); !bytes.Equal(actual, expected) {
+		t.Fatal("Binary snapshot differs from JSON", string(actual), string(expected))
+	}
+
+	if actual, _ := json.Marshal(fromJSON); !bytes.Equal(actual, expected) {
+		t.Fatal("JSON snapshot is not stable", string(actual))
+	}
+
+	// binary snapshot is lossless
+	if again, _ := fromBinary.MarshalBinary(); !bytes.Equal(again, data) {
+		t.Fatal("Binary snapshot is not stable")
+	}
+}
+
+func TestSnapshotErrors(t *testing.T) {
+	data, _ := newSnapshotBook().MarshalBinary()
+
+	ob := NewOrderBook()
+	ob.ProcessLimitOrder(Buy, "kept", decimal.New(1, 0), decimal.New(10, 0))
+
+	corrupted := append([]byte(nil), data...)
+	corrupted[len(corrupted)/2] ^= 0xff
+	if err := ob.UnmarshalBinary(corrupted); err != ErrInvalidSnapshot {
+		t.Fatal("Corrupted snapshot is read", err)
+	}
+
+	if err := ob.UnmarshalBinary(data[:len(data)-1]); err == nil {
+		t.Fatal("Truncated snapshot is read")
+	}
+
+	version := append([]byte(nil), data...)
+	version[4] = SnapshotVersion + 1
+	if err := ob.UnmarshalBinary(version); err != ErrSnapshotVersion {
+		t.Fatal("Unsupported version is read", err)
+	}
+
+	// version 1 has no last trade
+	version[4] = 1
+	if err := ob.UnmarshalBinary(version); err != ErrSnapshotVersion {
+		t.Fatal("Snapshot without last trade is read", err)
+	}
+
+	if err := ob.UnmarshalBinary([]byte(`{"asks":{}}`)); err != ErrInvalidSnapshot {
+		t.Fatal("JSON is read as binary snapshot", err)
+	}
+
+	if ob.Order("kept") == nil || len(ob.orders) != 1 {
+		t.Fatal("Order book is changed by invalid snapshot")
+	}
+}
+
+func newBenchmarkBook(orders int) *OrderBook {
+	ob := NewOrderBook()
+	for i := 0; i < orders; i++ {
+		price := decimal.New(int64(1000+i%500), -1)
+		ob.ProcessLimitOrderWithOwner(Buy, fmt.Sprintf("buy-%d", i), fmt.Sprintf("owner-%d", i%100), decimal.New(int64(i%7+1), 0), price)
+		ob.ProcessLimitOrderWithOwner(Sell, fmt.Sprintf("sell-%d", i), fmt.Sprintf("owner-%d", i%100), decimal.New(int64(i%7+1), 0), price.Add(decimal.New(100, 0)))
+	}
+	return ob
+}
+
+func BenchmarkSnapshotBinary(b *testing.B) {
+	ob := newBenchmarkBook(50000)
+	var buf bytes.Buffer
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		buf.Reset()
+		if err := ob.WriteSnapshot(&buf); err != nil {
+			b.Fatal(err)
+		}
+		if err := NewOrderBook().ReadSnapshot(bytes.NewReader(buf.Bytes())); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.ReportMetric(float64(buf.Len()), "bytes")
+}
+
+func BenchmarkSnapshotJSON(b *testing.B) {
+	ob := newBenchmarkBook(50000)
+	var data []byte
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var err error
+		if data, err = json.Marshal(ob); err != nil {
+			b.Fatal(err)
+		}
+		if err := json.Unmarshal(data, NewOrderBook()); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.ReportMetric(float64(len(data)), "bytes")
+}
+
+func TestSnapshotSchedule(t *testing.T) {
+	clock := newManualClock() // 10:00:00
+	ob := NewOrderBook()
+	ob.SetClock(clock)
+	ob.SetSchedule([]ScheduledPhase{{Offset: 10*time.Hour + time.Minute, Phase: Closed}})
+
+	data, err := ob.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the restored book checks the schedule from the time of the snapshot
+	clock.Add(2 * time.Minute)
+	restored := NewOrderBook()
+	restored.SetClock(clock)
+	if err := restored.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+
+	if restored.Tick(); restored.Phase() != Closed {
+		t.Fatal("Schedule is not restored", restored.Phase())
+	}
+}
@@ -0,0 +1,98 @@
+package stream
+
+import (
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// client is WebSocket connection of the hub
+type client struct {
+	hub   *Hub
+	conn  *websocket.Conn
+	owner string
+
+	// subscriptions are accessed from the matching goroutine only
+	depth  bool
+	trades bool
+	orders bool
+
+	mu     sync.Mutex
+	queue  []*Message
+	resync bool
+
+	signal chan struct{}
+	closed chan struct{}
+}
+
+// send queues message to the client, all queued messages are dropped on overflow
+// and the client is marked for resync
+func (c *client) send(m *Message) {
+	c.mu.Lock()
+	switch {
+	case c.resync:
+	case len(c.queue) >= c.hub.buffer:
+		c.queue = nil
+		c.resync = true
+	default:
+		c.queue = append(c.queue, m)
+	}
+	c.mu.Unlock()
+
+	select {
+	case c.signal <- struct{}{}:
+	default:
+	}
+}
+
+// reset drops queued messages and resync flag
+func (c *client) reset() {
+	c.mu.Lock()
+	c.queue = nil
+	c.resync = false
+	c.mu.Unlock()
+}
+
+// readLoop handles subscription requests until the connection is closed
+func (c *client) readLoop() {
+	for {
+		var req Request
+		if err := c.conn.ReadJSON(&req); err != nil {
+			return
+		}
+		c.hub.subscribe(c, &req)
+	}
+}
+
+// writeLoop writes queued messages to the connection
+func (c *client) writeLoop() {
+	for {
+		select {
+		case <-c.signal:
+		case <-c.closed:
+			return
+		}
+
+		c.mu.Lock()
+		queue, resync := c.queue, c.resync
+		c.queue = nil
+		c.mu.Unlock()
+
+		if resync {
+			if err := c.hub.resync(c); err != nil {
+				c.conn.Close()
+				return
+			}
+			continue
+		}
+
+		for _, m := range queue {
+			c.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+			if err := c.conn.WriteJSON(m); err != nil {
+				c.conn.Close()
+				return
+			}
+		}
+	}
+}
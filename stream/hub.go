@@ -0,0 +1,298 @@
+// Package stream implements WebSocket streaming of the order book depth, trades
+// and order updates of the matching engine
+package stream
+
+import (
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/shopspring/decimal"
+
+	"orderbook"
+)
+
+// Channels of the stream
+const (
+	ChannelDepth  = "depth"
+	ChannelTrades = "trades"
+	ChannelOrders = "orders"
+)
+
+// Types of the messages:
+//
+//	TypeSnapshot - full depth or open orders of the owner
+//	TypeUpdate   - changed depth levels, zero quantity means removed level
+//	TypeTrade    - public trade
+//	TypeOrder    - change of the resting order of the owner
+//	TypeFill     - trade where the owner is maker or taker
+//	TypeError    - invalid request
+const (
+	TypeSnapshot = "snapshot"
+	TypeUpdate   = "update"
+	TypeTrade    = "trade"
+	TypeOrder    = "order"
+	TypeFill     = "fill"
+	TypeError    = "error"
+)
+
+// Operations of the client requests
+const (
+	OpSubscribe   = "subscribe"
+	OpUnsubscribe = "unsubscribe"
+)
+
+// writeTimeout limits time of writing single message to the client
+const writeTimeout = 10 * time.Second
+
+// Message is sent to the client. Seq is incremented by one with every message of the
+// channel (of the owner for orders channel), snapshot has Seq of the last update included
+// into it. Gap in sequence means that messages were dropped and the client should wait for
+// a fresh snapshot which is sent after the slow client catches up
+type Message struct {
+	Channel string                  `json:"channel"`
+	Type    string                  `json:"type"`
+	Seq     uint64                  `json:"seq"`
+	Asks    []*orderbook.PriceLevel `json:"asks,omitempty"`
+	Bids    []*orderbook.PriceLevel `json:"bids,omitempty"`
+	Trade   *orderbook.Trade        `json:"trade,omitempty"`
+	Event   *orderbook.OrderEvent   `json:"event,omitempty"`
+	Orders  []*orderbook.Order      `json:"orders,omitempty"`
+	Error   string                  `json:"error,omitempty"`
+}
+
+// Request is sent by the client to manage subscriptions
+type Request struct {
+	Op      string `json:"op"`
+	Channel string `json:"channel"`
+}
+
+// level is price level touched by the command
+type level struct {
+	side  orderbook.Side
+	price decimal.Decimal
+}
+
+// Hub streams changes of the matching engine to WebSocket clients. Owner of the private
+// orders channel is taken from "owner" query parameter of the connection, authentication
+// is expected to be done in front of the hub
+type Hub struct {
+	engine   *orderbook.Engine
+	buffer   int
+	upgrader websocket.Upgrader
+
+	// fields below are accessed from the matching goroutine only
+	clients  map[*client]struct{}
+	levels   map[string]level
+	trades   []*orderbook.Trade
+	events   []*orderbook.OrderEvent
+	depthSeq uint64
+	tradeSeq uint64
+	orderSeq map[string]uint64
+}
+
+// NewHub creates hub of the matching engine
+// Arguments:
+//
+//	engine - matching engine of the order book
+//	buffer - maximum number of messages queued for the client, slow client is resynced on overflow
+func NewHub(engine *orderbook.Engine, buffer int) (*Hub, error) {
+	h := &Hub{
+		engine:   engine,
+		buffer:   buffer,
+		clients:  map[*client]struct{}{},
+		levels:   map[string]level{},
+		orderSeq: map[string]uint64{},
+	}
+
+	err := engine.Do(func(ob *orderbook.OrderBook) {
+		ob.OnTrade(h.onTrade)
+		ob.OnOrderEvent(h.onOrderEvent)
+		engine.OnCommand(h.flush)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return h, nil
+}
+
+// ServeHTTP upgrades the connection to WebSocket and serves the client until disconnect
+func (h *Hub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	conn, err := h.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	c := &client{
+		hub:    h,
+		conn:   conn,
+		owner:  r.URL.Query().Get("owner"),
+		signal: make(chan struct{}, 1),
+		closed: make(chan struct{}),
+	}
+
+	if err := h.engine.Do(func(*orderbook.OrderBook) { h.clients[c] = struct{}{} }); err != nil {
+		return
+	}
+	defer h.engine.Do(func(*orderbook.OrderBook) { delete(h.clients, c) })
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		c.writeLoop()
+	}()
+
+	c.readLoop()
+	close(c.closed)
+	wg.Wait()
+}
+
+// subscribe handles request of the client in the matching goroutine
+func (h *Hub) subscribe(c *client, req *Request) {
+	on := req.Op == OpSubscribe
+	if !on && req.Op != OpUnsubscribe {
+		c.send(&Message{Channel: req.Channel, Type: TypeError, Error: "unknown operation"})
+		return
+	}
+
+	h.engine.Do(func(ob *orderbook.OrderBook) {
+		switch req.Channel {
+		case ChannelDepth:
+			if on && !c.depth {
+				c.send(h.depthSnapshot(ob))
+			}
+			c.depth = on
+		case ChannelTrades:
+			c.trades = on
+		case ChannelOrders:
+			if c.owner == "" {
+				c.send(&Message{Channel: req.Channel, Type: TypeError, Error: "owner is required"})
+				return
+			}
+			if on && !c.orders {
+				c.send(h.ordersSnapshot(ob, c.owner))
+			}
+			c.orders = on
+		default:
+			c.send(&Message{Channel: req.Channel, Type: TypeError, Error: "unknown channel"})
+		}
+	})
+}
+
+// resync replaces queued messages of the client with fresh snapshots
+func (h *Hub) resync(c *client) error {
+	return h.engine.Do(func(ob *orderbook.OrderBook) {
+		c.reset()
+		if c.depth {
+			c.send(h.depthSnapshot(ob))
+		}
+		if c.orders {
+			c.send(h.ordersSnapshot(ob, c.owner))
+		}
+	})
+}
+
+func (h *Hub) depthSnapshot(ob *orderbook.OrderBook) *Message {
+	asks, bids := ob.Depth()
+	return &Message{Channel: ChannelDepth, Type: TypeSnapshot, Seq: h.depthSeq, Asks: asks, Bids: bids}
+}
+
+func (h *Hub) ordersSnapshot(ob *orderbook.OrderBook, owner string) *Message {
+	return &Message{Channel: ChannelOrders, Type: TypeSnapshot, Seq: h.orderSeq[owner], Orders: ob.OwnerOrders(owner)}
+}
+
+func (h *Hub) onTrade(t *orderbook.Trade) {
+	h.trades = append(h.trades, t)
+}
+
+func (h *Hub) onOrderEvent(ev *orderbook.OrderEvent) {
+	h.events = append(h.events, ev)
+
+	o := ev.Order
+	h.levels[o.Side().String()+o.Price().String()] = level{side: o.Side(), price: o.Price()}
+}
+
+// flush publishes changes collected during the command
+func (h *Hub) flush(ob *orderbook.OrderBook) {
+	defer func() {
+		h.levels = map[string]level{}
+		h.trades = nil
+		h.events = nil
+	}()
+
+	if len(h.clients) == 0 {
+		return
+	}
+
+	if len(h.levels) > 0 {
+		h.depthSeq++
+		update := h.depthUpdate(ob)
+		h.broadcast(func(c *client) bool { return c.depth }, update)
+	}
+
+	for _, t := range h.trades {
+		h.tradeSeq++
+		h.broadcast(func(c *client) bool { return c.trades }, &Message{
+			Channel: ChannelTrades,
+			Type:    TypeTrade,
+			Seq:     h.tradeSeq,
+			Trade:   t,
+		})
+
+		h.private(t.MakerOwner, &Message{Type: TypeFill, Trade: t})
+		if t.TakerOwner != t.MakerOwner {
+			h.private(t.TakerOwner, &Message{Type: TypeFill, Trade: t})
+		}
+	}
+
+	for _, ev := range h.events {
+		h.private(ev.Order.Owner(), &Message{Type: TypeOrder, Event: ev})
+	}
+}
+
+// depthUpdate returns current volume of the touched levels, asks ascending and bids descending
+func (h *Hub) depthUpdate(ob *orderbook.OrderBook) *Message {
+	m := &Message{Channel: ChannelDepth, Type: TypeUpdate, Seq: h.depthSeq}
+
+	for _, l := range h.levels {
+		pl := &orderbook.PriceLevel{Price: l.price}
+		if q := ob.GetOrderSide(l.side).Floor(l.price); q != nil && q.Price().Equal(l.price) {
+			pl.Quantity = q.Volume()
+		}
+
+		if l.side == orderbook.Buy {
+			m.Bids = append(m.Bids, pl)
+		} else {
+			m.Asks = append(m.Asks, pl)
+		}
+	}
+
+	sort.Slice(m.Asks, func(i, j int) bool { return m.Asks[i].Price.LessThan(m.Asks[j].Price) })
+	sort.Slice(m.Bids, func(i, j int) bool { return m.Bids[i].Price.GreaterThan(m.Bids[j].Price) })
+	return m
+}
+
+// private sends message of the orders channel to the clients of the owner
+func (h *Hub) private(owner string, m *Message) {
+	if owner == "" {
+		return
+	}
+
+	h.orderSeq[owner]++
+	m.Channel = ChannelOrders
+	m.Seq = h.orderSeq[owner]
+	h.broadcast(func(c *client) bool { return c.orders && c.owner == owner }, m)
+}
+
+func (h *Hub) broadcast(match func(*client) bool, m *Message) {
+	for c := range h.clients {
+		if match(c) {
+			c.send(m)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package stream
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/shopspring/decimal"
+
+	"orderbook"
+)
+
+func newTestHub(t *testing.T, buffer int) (*Hub, *orderbook.Engine, *httptest.Server) {
+	engine := orderbook.NewEngine(orderbook.NewOrderBook())
+	hub, err := NewHub(engine, buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return hub, engine, httptest.NewServer(hub)
+}
+
+func dial(t *testing.T, srv *httptest.Server, query string) *websocket.Conn {
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+query, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return conn
+}
+
+func read(t *testing.T, conn *websocket.Conn) *Message {
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	m := &Message{}
+	if err := conn.ReadJSON(m); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func subscribe(t *testing.T, conn *websocket.Conn, channel string) {
+	if err := conn.WriteJSON(&Request{Op: OpSubscribe, Channel: channel}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestHubStreams(t *testing.T) {
+	_, engine, srv := newTestHub(t, 100)
+	defer srv.Close()
+	defer engine.Close()
+
+	engine.Do(func(ob *orderbook.OrderBook) {
+		ob.ProcessLimitOrder(orderbook.Sell, "sell-100", decimal.New(2, 0), decimal.New(100, 0))
+	})
+
+	public := dial(t, srv, "")
+	defer public.Close()
+	private := dial(t, srv, "?owner=alice")
+	defer private.Close()
+
+	subscribe(t, public, ChannelDepth)
+	m := read(t, public)
+	if m.Type != TypeSnapshot || m.Seq != 0 || len(m.Asks) != 1 || !m.Asks[0].Quantity.Equal(decimal.New(2, 0)) {
+		t.Fatal("Invalid depth snapshot", m)
+	}
+
+	subscribe(t, public, ChannelTrades)
+	subscribe(t, private, ChannelOrders)
+	if m := read(t, private); m.Type != TypeSnapshot || len(m.Orders) != 0 {
+		t.Fatal("Invalid orders snapshot", m)
+	}
+
+	// subscriptions are handled in order, wait for the trades one with a marker request
+	subscribe(t, public, "unknown")
+	if m := read(t, public); m.Type != TypeError {
+		t.Fatal("Invalid error message", m)
+	}
+
+	engine.Do(func(ob *orderbook.OrderBook) {
+		ob.ProcessLimitOrderWithOwner(orderbook.Buy, "alice-1", "alice", decimal.New(3, 0), decimal.New(100, 0))
+	})
+
+	m = read(t, public)
+	if m.Type != TypeUpdate || m.Seq != 1 || len(m.Asks) != 1 || !m.Asks[0].Quantity.IsZero() ||
+		len(m.Bids) != 1 || !m.Bids[0].Quantity.Equal(decimal.New(1, 0)) {
+		t.Fatal("Invalid depth update", m)
+	}
+
+	m = read(t, public)
+	if m.Channel != ChannelTrades || m.Seq != 1 || m.Trade.TakerOrderID != "alice-1" {
+		t.Fatal("Invalid trade", m)
+	}
+
+	m = read(t, private)
+	if m.Type != TypeFill || m.Seq != 1 || !m.Trade.Quantity.Equal(decimal.New(2, 0)) {
+		t.Fatal("Invalid fill", m)
+	}
+
+	m = read(t, private)
+	if m.Type != TypeOrder || m.Seq != 2 || m.Event.Status != orderbook.OrderNew || m.Event.Order.ID() != "alice-1" {
+		t.Fatal("Invalid order update", m)
+	}
+}
+
+func TestHubResync(t *testing.T) {
+	hub, engine, srv := newTestHub(t, 2)
+	srv.Close()
+	defer engine.Close()
+
+	c := &client{hub: hub, signal: make(chan struct{}, 1), depth: true}
+	engine.Do(func(*orderbook.OrderBook) { hub.clients[c] = struct{}{} })
+
+	// every command produces depth update
+	for i := int64(1); i <= 3; i++ {
+		engine.Do(func(ob *orderbook.OrderBook) {
+			ob.ProcessLimitOrder(orderbook.Sell, fmt.Sprintf("sell-%d", i), decimal.New(1, 0), decimal.New(100+i, 0))
+		})
+	}
+
+	var (
+		resync bool
+		queued int
+	)
+	engine.Do(func(*orderbook.OrderBook) {
+		c.mu.Lock()
+		resync, queued = c.resync, len(c.queue)
+		c.mu.Unlock()
+	})
+	if !resync || queued != 0 {
+		t.Fatal("Slow client is not marked for resync", resync, queued)
+	}
+
+	if err := hub.resync(c); err != nil {
+		t.Fatal(err)
+	}
+
+	if c.resync || len(c.queue) != 1 || c.queue[0].Type != TypeSnapshot || c.queue[0].Seq != 3 || len(c.queue[0].Asks) != 3 {
+		t.Fatal("Invalid resync", c.resync, c.queue)
+	}
+}
@@ -0,0 +1,279 @@
+package orderbook
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// TradeTape keeps history of the order book trades in the ring buffer limited by
+// number of trades and by age. Evicted trades can be spilled to the segment file
+// as JSON lines. It is safe to read trades from another goroutine
+type TradeTape struct {
+	mu sync.RWMutex
+
+	capacity  int
+	retention time.Duration
+
+	buf   []*Trade
+	start int
+	size  int
+
+	segment *os.File
+	writer  *bufio.Writer
+	err     error // first error of spilling to the segment file
+}
+
+// NewTradeTape creates trade tape fed by the order book trades
+// Arguments:
+//
+//	ob        - order book providing trades
+//	capacity  - maximum number of kept trades, zero keeps any number
+//	retention - maximum age of kept trades relative to the last trade, zero keeps trades of any age
+func NewTradeTape(ob *OrderBook, capacity int, retention time.Duration) *TradeTape {
+	tt := &TradeTape{
+		capacity:  capacity,
+		retention: retention,
+	}
+
+	if ob != nil {
+		ob.OnTrade(tt.Add)
+	}
+
+	return tt
+}
+
+// SpillTo appends trades evicted from the tape to the segment file at path
+func (tt *TradeTape) SpillTo(path string) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	tt.mu.Lock()
+	defer tt.mu.Unlock()
+
+	if err := tt.closeSegment(); err != nil {
+		f.Close()
+		return err
+	}
+
+	tt.segment = f
+	tt.writer = bufio.NewWriter(f)
+	return nil
+}
+
+// Flush writes buffered evicted trades to the segment file, it returns the
+// first error of spilling trades since the segment is opened
+func (tt *TradeTape) Flush() error {
+	tt.mu.Lock()
+	defer tt.mu.Unlock()
+
+	if tt.writer == nil {
+		return nil
+	}
+	if err := tt.writer.Flush(); tt.err == nil {
+		tt.err = err
+	}
+	return tt.err
+}
+
+// Close flushes and closes the segment file, it returns the first error of
+// spilling trades to the segment file
+func (tt *TradeTape) Close() error {
+	tt.mu.Lock()
+	defer tt.mu.Unlock()
+
+	return tt.closeSegment()
+}
+
+// Add puts the trade to the tape, trades are expected in order of IDs
+func (tt *TradeTape) Add(t *Trade) {
+	tt.mu.Lock()
+	defer tt.mu.Unlock()
+
+	if tt.capacity > 0 && tt.size == tt.capacity {
+		tt.evict()
+	}
+
+	if tt.size == len(tt.buf) {
+		tt.grow()
+	}
+
+	tt.buf[(tt.start+tt.size)%len(tt.buf)] = t
+	tt.size++
+
+	if tt.retention > 0 {
+		from := t.Timestamp.Add(-tt.retention)
+		for tt.size > 0 && tt.at(0).Timestamp.Before(from) {
+			tt.evict()
+		}
+	}
+}
+
+// Len returns number of trades kept in the tape
+func (tt *TradeTape) Len() int {
+	tt.mu.RLock()
+	defer tt.mu.RUnlock()
+
+	return tt.size
+}
+
+// Recent returns up to limit latest trades, newest first
+func (tt *TradeTape) Recent(limit int) []*Trade {
+	return tt.Before(0, limit)
+}
+
+// Before returns up to limit trades with ID less than cursor, newest first.
+// Zero cursor starts from the latest trade
+func (tt *TradeTape) Before(cursor uint64, limit int) []*Trade {
+	tt.mu.RLock()
+	defer tt.mu.RUnlock()
+
+	i := tt.size
+	if cursor > 0 {
+		i = tt.searchID(cursor)
+	}
+
+	var trades []*Trade
+	for i--; i >= 0 && (limit <= 0 || len(trades) < limit); i-- {
+		trades = append(trades, tt.at(i))
+	}
+
+	return trades
+}
+
+// After returns up to limit trades with ID greater than cursor, oldest first
+func (tt *TradeTape) After(cursor uint64, limit int) []*Trade {
+	tt.mu.RLock()
+	defer tt.mu.RUnlock()
+
+	return tt.collect(tt.searchID(cursor+1), limit, func(*Trade) bool { return true })
+}
+
+// Since returns up to limit trades executed at or after from, oldest first
+func (tt *TradeTape) Since(from time.Time, limit int) []*Trade {
+	tt.mu.RLock()
+	defer tt.mu.RUnlock()
+
+	i := sort.Search(tt.size, func(i int) bool {
+		return !tt.at(i).Timestamp.Before(from)
+	})
+
+	return tt.collect(i, limit, func(*Trade) bool { return true })
+}
+
+// ByOrder returns up to limit trades with ID greater than cursor where the order
+// was either maker or taker, oldest first
+func (tt *TradeTape) ByOrder(orderID string, cursor uint64, limit int) []*Trade {
+	tt.mu.RLock()
+	defer tt.mu.RUnlock()
+
+	return tt.collect(tt.searchID(cursor+1), limit, func(t *Trade) bool {
+		return t.MakerOrderID == orderID || t.TakerOrderID == orderID
+	})
+}
+
+// ReadSegment returns up to limit trades with ID greater than cursor from the
+// segment file written by the trade tape, oldest first
+func ReadSegment(path string, cursor uint64, limit int) ([]*Trade, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var trades []*Trade
+	dec := json.NewDecoder(bufio.NewReader(f))
+	for limit <= 0 || len(trades) < limit {
+		t := &Trade{}
+		if err := dec.Decode(t); err == io.EOF {
+			break
+		} else if err != nil {
+			return trades, err
+		}
+
+		if t.ID > cursor {
+			trades = append(trades, t)
+		}
+	}
+
+	return trades, nil
+}
+
+// at returns i-th oldest trade
+func (tt *TradeTape) at(i int) *Trade {
+	return tt.buf[(tt.start+i)%len(tt.buf)]
+}
+
+// searchID returns index of the first trade with ID greater or equal to id
+func (tt *TradeTape) searchID(id uint64) int {
+	return sort.Search(tt.size, func(i int) bool {
+		return tt.at(i).ID >= id
+	})
+}
+
+// collect returns up to limit trades starting at index i which match the filter
+func (tt *TradeTape) collect(i, limit int, match func(*Trade) bool) []*Trade {
+	var trades []*Trade
+	for ; i < tt.size && (limit <= 0 || len(trades) < limit); i++ {
+		if t := tt.at(i); match(t) {
+			trades = append(trades, t)
+		}
+	}
+	return trades
+}
+
+// grow doubles the ring buffer up to the capacity
+func (tt *TradeTape) grow() {
+	n := 2 * len(tt.buf)
+	if n == 0 {
+		n = 16
+	}
+	if tt.capacity > 0 && n > tt.capacity {
+		n = tt.capacity
+	}
+
+	buf := make([]*Trade, n)
+	for i := 0; i < tt.size; i++ {
+		buf[i] = tt.at(i)
+	}
+	tt.buf, tt.start = buf, 0
+}
+
+// evict removes the oldest trade and spills it to the segment file
+func (tt *TradeTape) evict() {
+	t := tt.at(0)
+	tt.buf[tt.start] = nil
+	tt.start = (tt.start + 1) % len(tt.buf)
+	tt.size--
+
+	if tt.writer != nil && tt.err == nil {
+		data, err := json.Marshal(t)
+		if err == nil {
+			_, err = tt.writer.Write(append(data, '\n'))
+		}
+		tt.err = err
+	}
+}
+
+// closeSegment flushes and closes current segment file
+func (tt *TradeTape) closeSegment() error {
+	if tt.segment == nil {
+		return nil
+	}
+
+	err := tt.err
+	if ferr := tt.writer.Flush(); err == nil {
+		err = ferr
+	}
+	if cerr := tt.segment.Close(); err == nil {
+		err = cerr
+	}
+	tt.segment, tt.writer, tt.err = nil, nil, nil
+	return err
+}
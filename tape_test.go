@@ -0,0 +1,108 @@
+package orderbook
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestTradeTape(t *testing.T) {
+	ob := NewOrderBook()
+	clock := newManualClock()
+	ob.SetClock(clock)
+	tape := NewTradeTape(ob, 3, 0)
+
+	path := filepath.Join(t.TempDir(), "trades.jsonl")
+	if err := tape.SpillTo(path); err != nil {
+		t.Fatal(err)
+	}
+
+	addDepth(ob, "", decimal.New(1, 0))
+	for i := 0; i < 5; i++ {
+		ob.ProcessMarketOrder(Buy, decimal.New(1, 0))
+		clock.Add(time.Second)
+	}
+
+	if tape.Len() != 3 {
+		t.Fatal("Invalid tape length", tape.Len())
+	}
+
+	recent := tape.Recent(2)
+	if len(recent) != 2 || recent[0].ID != 5 || recent[1].ID != 4 {
+		t.Fatal("Invalid recent trades", recent)
+	}
+
+	older := tape.Before(recent[1].ID, 10)
+	if len(older) != 1 || older[0].ID != 3 {
+		t.Fatal("Invalid trades before cursor", older)
+	}
+
+	newer := tape.After(3, 0)
+	if len(newer) != 2 || newer[0].ID != 4 || newer[1].ID != 5 {
+		t.Fatal("Invalid trades after cursor", newer)
+	}
+
+	since := tape.Since(newManualClock().Now().Add(4*time.Second), 0)
+	if len(since) != 1 || since[0].MakerOrderID != "sell-140" {
+		t.Fatal("Invalid trades since time", since)
+	}
+
+	if trades := tape.ByOrder("sell-130", 0, 0); len(trades) != 1 || trades[0].ID != 4 {
+		t.Fatal("Invalid trades by order", trades)
+	}
+
+	if err := tape.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	spilled, err := ReadSegment(path, 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(spilled) != 1 || spilled[0].ID != 2 || spilled[0].MakerOrderID != "sell-110" ||
+		!spilled[0].Price.Equal(decimal.New(110, 0)) {
+		t.Fatal("Invalid spilled trades", spilled)
+	}
+}
+
+func TestTradeTapeRetention(t *testing.T) {
+	ob := NewOrderBook()
+	clock := newManualClock()
+	ob.SetClock(clock)
+	tape := NewTradeTape(ob, 0, time.Minute)
+
+	addDepth(ob, "", decimal.New(10, 0))
+	for i := 0; i < 40; i++ {
+		ob.ProcessMarketOrder(Buy, decimal.New(1, 0))
+		clock.Add(10 * time.Second)
+	}
+
+	// trades within the minute before the last one
+	trades := tape.After(0, 0)
+	if tape.Len() != 7 || trades[0].ID != 34 || trades[6].ID != 40 {
+		t.Fatal("Invalid retained trades", tape.Len(), trades)
+	}
+}
+
+func TestTradeTapeSpillError(t *testing.T) {
+	tape := NewTradeTape(nil, 1, 0)
+	if err := tape.SpillTo(filepath.Join(t.TempDir(), "trades.jsonl")); err != nil {
+		t.Fatal(err)
+	}
+
+	// segment file fails on write
+	tape.segment.Close()
+
+	tape.Add(&Trade{ID: 1})
+	tape.Add(&Trade{ID: 2})
+	if err := tape.Flush(); err == nil {
+		t.Fatal("Spill error is not returned by Flush")
+	}
+
+	tape.Add(&Trade{ID: 3})
+	if err := tape.Close(); err == nil {
+		t.Fatal("Spill error is not returned by Close")
+	}
+}
@@ -0,0 +1,150 @@
+package orderbook
+
+import (
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Ticker contains rolling window statistics of the trades and the best prices of the order book
+type Ticker struct {
+	LastPrice          decimal.Decimal `json:"lastPrice"`
+	LastQuantity       decimal.Decimal `json:"lastQuantity"`
+	OpenPrice          decimal.Decimal `json:"openPrice"`
+	HighPrice          decimal.Decimal `json:"highPrice"`
+	LowPrice           decimal.Decimal `json:"lowPrice"`
+	Volume             decimal.Decimal `json:"volume"`
+	QuoteVolume        decimal.Decimal `json:"quoteVolume"`
+	PriceChange        decimal.Decimal `json:"priceChange"`
+	PriceChangePercent decimal.Decimal `json:"priceChangePercent"`
+	WeightedAvgPrice   decimal.Decimal `json:"weightedAvgPrice"`
+	Trades             int             `json:"trades"`
+	BestBid            *PriceLevel     `json:"bestBid"`
+	BestAsk            *PriceLevel     `json:"bestAsk"`
+	OpenTime           time.Time       `json:"openTime"`
+	CloseTime          time.Time       `json:"closeTime"`
+}
+
+// tickerTrade is the trade in the ticker window
+type tickerTrade struct {
+	time     time.Time
+	price    decimal.Decimal
+	quantity decimal.Decimal
+}
+
+// TickerStats maintains rolling window (usually 24h) statistics of the order book
+// trades incrementally: volumes are added and subtracted as trades enter and
+// leave the window, high and low prices are kept in monotonic queues
+type TickerStats struct {
+	mu sync.Mutex
+
+	ob     *OrderBook
+	window time.Duration
+
+	trades      []tickerTrade // trades within the window, oldest first
+	highs       []tickerTrade // decreasing prices
+	lows        []tickerTrade // increasing prices
+	last        *tickerTrade
+	volume      decimal.Decimal
+	quoteVolume decimal.Decimal
+}
+
+// NewTickerStats creates ticker statistics attached to the order book
+func NewTickerStats(ob *OrderBook, window time.Duration) *TickerStats {
+	ts := &TickerStats{
+		ob:     ob,
+		window: window,
+	}
+
+	ob.OnTrade(ts.Add)
+	return ts
+}
+
+// Add applies the trade to the statistics
+func (ts *TickerStats) Add(t *Trade) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	tt := tickerTrade{time: t.Timestamp, price: t.Price, quantity: t.Quantity}
+	ts.last = &tt
+
+	ts.trades = append(ts.trades, tt)
+	ts.volume = ts.volume.Add(tt.quantity)
+	ts.quoteVolume = ts.quoteVolume.Add(tt.price.Mul(tt.quantity))
+
+	for len(ts.highs) > 0 && ts.highs[len(ts.highs)-1].price.LessThanOrEqual(tt.price) {
+		ts.highs = ts.highs[:len(ts.highs)-1]
+	}
+	ts.highs = append(ts.highs, tt)
+
+	for len(ts.lows) > 0 && ts.lows[len(ts.lows)-1].price.GreaterThanOrEqual(tt.price) {
+		ts.lows = ts.lows[:len(ts.lows)-1]
+	}
+	ts.lows = append(ts.lows, tt)
+
+	ts.evict(t.Timestamp)
+}
+
+// Ticker returns statistics of the window ending at the order book clock time.
+// It reads the best prices of the order book, so it should be called from the
+// goroutine which processes orders
+func (ts *TickerStats) Ticker() *Ticker {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	now := ts.ob.now()
+	ts.evict(now)
+
+	ticker := &Ticker{
+		Volume:      ts.volume,
+		QuoteVolume: ts.quoteVolume,
+		Trades:      len(ts.trades),
+		OpenTime:    now.Add(-ts.window),
+		CloseTime:   now,
+	}
+
+	if ts.last != nil {
+		ticker.LastPrice = ts.last.price
+		ticker.LastQuantity = ts.last.quantity
+	}
+
+	if len(ts.trades) > 0 {
+		ticker.OpenPrice = ts.trades[0].price
+		ticker.HighPrice = ts.highs[0].price
+		ticker.LowPrice = ts.lows[0].price
+		ticker.PriceChange = ticker.LastPrice.Sub(ticker.OpenPrice)
+		ticker.PriceChangePercent = ticker.PriceChange.Mul(oneHundred).Div(ticker.OpenPrice)
+		ticker.WeightedAvgPrice = ts.quoteVolume.Div(ts.volume)
+	}
+
+	if bid := ts.ob.bids.MaxPriceQueue(); bid != nil {
+		ticker.BestBid = &PriceLevel{Price: bid.Price(), Quantity: bid.Volume()}
+	}
+
+	if ask := ts.ob.asks.MinPriceQueue(); ask != nil {
+		ticker.BestAsk = &PriceLevel{Price: ask.Price(), Quantity: ask.Volume()}
+	}
+
+	return ticker
+}
+
+// evict removes trades which left the window ending at now
+func (ts *TickerStats) evict(now time.Time) {
+	from := now.Add(-ts.window)
+
+	i := 0
+	for ; i < len(ts.trades) && !ts.trades[i].time.After(from); i++ {
+		ts.volume = ts.volume.Sub(ts.trades[i].quantity)
+		ts.quoteVolume = ts.quoteVolume.Sub(ts.trades[i].price.Mul(ts.trades[i].quantity))
+	}
+	ts.trades = ts.trades[i:]
+
+	for len(ts.highs) > 0 && !ts.highs[0].time.After(from) {
+		ts.highs = ts.highs[1:]
+	}
+
+	for len(ts.lows) > 0 && !ts.lows[0].time.After(from) {
+		ts.lows = ts.lows[1:]
+	}
+}
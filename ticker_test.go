@@ -0,0 +1,64 @@
+package orderbook
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestTickerStats(t *testing.T) {
+	ob := NewOrderBook()
+	clock := newManualClock()
+	ob.SetClock(clock)
+	ts := NewTickerStats(ob, 24*time.Hour)
+
+	addDepth(ob, "", decimal.New(2, 0))
+
+	ticker := ts.Ticker()
+	if ticker.Trades != 0 || !ticker.BestBid.Price.Equal(decimal.New(90, 0)) || !ticker.BestAsk.Price.Equal(decimal.New(100, 0)) {
+		t.Fatal("Invalid empty ticker", ticker)
+	}
+
+	ob.ProcessMarketOrder(Buy, decimal.New(4, 0)) // 2@100, 2@110
+
+	clock.Add(12 * time.Hour)
+	ob.ProcessMarketOrder(Sell, decimal.New(2, 0)) // 2@90
+
+	ticker = ts.Ticker()
+	if ticker.Trades != 3 || !ticker.OpenPrice.Equal(decimal.New(100, 0)) || !ticker.HighPrice.Equal(decimal.New(110, 0)) ||
+		!ticker.LowPrice.Equal(decimal.New(90, 0)) || !ticker.LastPrice.Equal(decimal.New(90, 0)) {
+		t.Fatal("Invalid ticker prices", ticker)
+	}
+
+	if !ticker.Volume.Equal(decimal.New(6, 0)) || !ticker.QuoteVolume.Equal(decimal.New(600, 0)) || !ticker.WeightedAvgPrice.Equal(decimal.New(100, 0)) {
+		t.Fatal("Invalid ticker volumes", ticker)
+	}
+
+	if !ticker.PriceChange.Equal(decimal.New(-10, 0)) || !ticker.PriceChangePercent.Equal(decimal.New(-10, 0)) {
+		t.Fatal("Invalid ticker change", ticker)
+	}
+
+	// trades of the first day leave the window
+	clock.Add(13 * time.Hour)
+	ticker = ts.Ticker()
+	if ticker.Trades != 1 || !ticker.OpenPrice.Equal(decimal.New(90, 0)) || !ticker.HighPrice.Equal(decimal.New(90, 0)) ||
+		!ticker.Volume.Equal(decimal.New(2, 0)) || ticker.PriceChange.Sign() != 0 {
+		t.Fatal("Invalid ticker after window", ticker)
+	}
+
+	if !ticker.BestBid.Price.Equal(decimal.New(80, 0)) || !ticker.BestAsk.Price.Equal(decimal.New(120, 0)) {
+		t.Fatal("Invalid best prices", ticker.BestBid, ticker.BestAsk)
+	}
+
+	clock.Add(24 * time.Hour)
+	ticker = ts.Ticker()
+	if ticker.Trades != 0 || ticker.Volume.Sign() != 0 || !ticker.LastPrice.Equal(decimal.New(90, 0)) {
+		t.Fatal("Invalid ticker without trades", ticker)
+	}
+
+	if _, err := json.Marshal(ticker); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,86 @@
+package orderbook
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Trade represents single execution between resting (maker) and incoming (taker) orders.
+// Trades of the auction uncross have no aggressor, the order placed later is reported as taker
+type Trade struct {
+	ID           uint64          `json:"id"`
+	Price        decimal.Decimal `json:"price"`
+	Quantity     decimal.Decimal `json:"quantity"`
+	Timestamp    time.Time       `json:"timestamp"`
+	TakerSide    Side            `json:"takerSide"`
+	TakerOrderID string          `json:"takerOrderId,omitempty"`
+	TakerOwner   string          `json:"takerOwner,omitempty"`
+	MakerOrderID string          `json:"makerOrderId"`
+	MakerOwner   string          `json:"makerOwner,omitempty"`
+	Auction      bool            `json:"auction,omitempty"`
+	MakerFee     decimal.Decimal `json:"makerFee"`
+	TakerFee     decimal.Decimal `json:"takerFee"`
+}
+
+// Notional returns traded amount in the quote currency
+func (t *Trade) Notional() decimal.Decimal {
+	return t.Price.Mul(t.Quantity)
+}
+
+// OnTrade registers handler called for every execution in the order book
+func (ob *OrderBook) OnTrade(handler func(*Trade)) {
+	ob.tradeHandlers = append(ob.tradeHandlers, handler)
+}
+
+// SetClock replaces clock used for order and trade timestamps
+func (ob *OrderBook) SetClock(clock Clock) {
+	ob.clock = clock
+}
+
+// now returns current time of the order book clock
+func (ob *OrderBook) now() time.Time {
+	if ob.clock == nil {
+		return SystemClock{}.Now()
+	}
+	return ob.clock.Now()
+}
+
+// trade registers execution of maker order by taker one and notifies handlers
+func (ob *OrderBook) trade(taker, maker *Order, price, quantity decimal.Decimal, auction bool) *Trade {
+	ob.tradeSeq++
+
+	t := &Trade{
+		ID:           ob.tradeSeq,
+		Price:        price,
+		Quantity:     quantity,
+		Timestamp:    ob.now(),
+		TakerSide:    taker.Side(),
+		TakerOrderID: taker.ID(),
+		TakerOwner:   taker.Owner(),
+		MakerOrderID: maker.ID(),
+		MakerOwner:   maker.Owner(),
+		Auction:      auction,
+	}
+
+	if ob.feeModel != nil {
+		t.MakerFee, t.TakerFee = ob.feeModel.Fees(t)
+		ob.feeModel.Record(t)
+	}
+
+	if ob.risk != nil {
+		ob.risk.Settle(t)
+	}
+
+	if ob.positions != nil {
+		ob.positions.apply(t)
+	}
+
+	ob.recordTrade(t)
+
+	for _, handler := range ob.tradeHandlers {
+		handler(t)
+	}
+
+	return t
+}
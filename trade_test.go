@@ -0,0 +1,53 @@
+package orderbook
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestTradeHandler(t *testing.T) {
+	ob := NewOrderBook()
+	clock := newManualClock()
+	ob.SetClock(clock)
+
+	var trades []*Trade
+	ob.OnTrade(func(trade *Trade) {
+		trades = append(trades, trade)
+	})
+
+	ob.ProcessLimitOrderWithOwner(Sell, "s1", "alice", decimal.New(2, 0), decimal.New(100, 0))
+	ob.ProcessLimitOrderWithOwner(Sell, "s2", "alice", decimal.New(2, 0), decimal.New(110, 0))
+
+	clock.Add(time.Second)
+	ob.ProcessLimitOrderWithOwner(Buy, "b1", "bob", decimal.New(3, 0), decimal.New(110, 0))
+	ob.ProcessMarketOrder(Buy, decimal.New(1, 0))
+
+	if len(trades) != 3 {
+		t.Fatal("Invalid trades amount", len(trades))
+	}
+
+	first := trades[0]
+	if first.ID != 1 || first.MakerOrderID != "s1" || first.TakerOrderID != "b1" ||
+		first.MakerOwner != "alice" || first.TakerOwner != "bob" || first.TakerSide != Buy {
+		t.Fatal("Invalid trade", first)
+	}
+
+	if !first.Price.Equal(decimal.New(100, 0)) || !first.Quantity.Equal(decimal.New(2, 0)) ||
+		!first.Notional().Equal(decimal.New(200, 0)) || !first.Timestamp.Equal(clock.Now()) {
+		t.Fatal("Invalid trade", first)
+	}
+
+	if trades[1].MakerOrderID != "s2" || !trades[1].Quantity.Equal(decimal.New(1, 0)) {
+		t.Fatal("Invalid trade", trades[1])
+	}
+
+	if trades[2].ID != 3 || trades[2].TakerOrderID != "" || trades[2].MakerOrderID != "s2" {
+		t.Fatal("Invalid trade", trades[2])
+	}
+
+	if ob.LastTrade() != trades[2] {
+		t.Fatal("Invalid last trade")
+	}
+}